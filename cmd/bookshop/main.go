@@ -28,6 +28,7 @@ import (
 	"golang.org/x/exp/slog"
 
 	httpdelivery "github.com/yourorg/bookshop/internal/delivery/http"
+	"github.com/yourorg/bookshop/internal/domain"
 	"github.com/yourorg/bookshop/internal/integration"
 	"github.com/yourorg/bookshop/internal/repository"
 	"github.com/yourorg/bookshop/internal/service"
@@ -109,28 +110,112 @@ func main() {
 	// --- Kafka ---
 	kafkaProducer := integration.NewKafkaProducer()
 
-	// --- Keycloak ---
-	keycloak := integration.NewKeycloakClient()
-
 	// --- Интеграции ---
 	redisCache := integration.NewRedisCache(rdb)
 
+	// --- Keycloak ---
+	keycloak := integration.NewKeycloakClient(integration.KeycloakConfig{
+		URL:                   viper.GetString("keycloak.url"),
+		Realm:                 viper.GetString("keycloak.realm"),
+		ClientID:              viper.GetString("keycloak.client_id"),
+		ClientSecret:          viper.GetString("keycloak.client_secret"),
+		Issuer:                viper.GetString("keycloak.issuer"),
+		Leeway:                viper.GetDuration("keycloak.leeway"),
+		Mode:                  viper.GetString("keycloak.mode"),
+		IntrospectionCacheTTL: viper.GetDuration("keycloak.introspection_cache_ttl"),
+	}, redisCache)
+
+	// --- Платёжный шлюз ---
+	paymentGateway := integration.NewPaymentGateway(integration.PaymentGatewayConfig{
+		URL:     viper.GetString("payment.url"),
+		APIKey:  viper.GetString("payment.api_key"),
+		Timeout: viper.GetDuration("payment.timeout"),
+	})
+
 	// --- Репозитории ---
 	bookRepo := repository.NewBookPostgres(dbpool)
 	categoryRepo := repository.NewCategoryPostgres(dbpool)
 	cartRepo := repository.NewCartPostgres(dbpool)
 	orderRepo := repository.NewOrderPostgres(dbpool)
+	userRepo := repository.NewUserPostgres(dbpool)
+	outboxRepo := repository.NewOutboxPostgres(dbpool)
+	reservations := repository.NewCartRedis(rdb)
+	paymentRepo := repository.NewPaymentPostgres(dbpool)
+	returnRepo := repository.NewReturnPostgres(dbpool)
+	promoRepo := repository.NewPromoPostgres(dbpool)
+	taxRepo := repository.NewTaxPostgres(dbpool)
 
 	// --- Сервисы ---
-	bookService := service.NewBookService(bookRepo, categoryRepo, redisCache)
+	bookService := service.NewBookService(bookRepo, categoryRepo, redisCache, logger)
 	categoryService := service.NewCategoryService(categoryRepo, bookRepo)
-	cartService := service.NewCartService(cartRepo, bookRepo, redisCache, logger)
-	orderService := service.NewOrderService(orderRepo, cartRepo, bookRepo, kafkaProducer, redisCache)
+	taxCountry := viper.GetString("tax.default_country")
+	if taxCountry == "" {
+		taxCountry = "RU"
+	}
+	taxService := service.NewTaxService(taxRepo, domain.TaxRegion{Country: taxCountry, Region: viper.GetString("tax.default_region")})
+	cartService := service.NewCartService(cartRepo, bookRepo, reservations, promoRepo, taxService, logger)
+	orderService := service.NewOrderService(orderRepo, cartRepo, bookRepo, reservations, paymentRepo, paymentGateway, cartService, taxService, logger)
+	userService := service.NewUserService(userRepo, redisCache)
+	returnService := service.NewReturnService(orderRepo, returnRepo, paymentRepo, paymentGateway)
+	promoService := service.NewPromoService(promoRepo)
+
+	// --- Outbox relay: события заказов из outbox в Kafka ---
+	relay := service.NewOutboxRelay(outboxRepo, kafkaProducer, logger, service.OutboxRelayConfig{
+		PollInterval: viper.GetDuration("outbox.poll_interval"),
+		BatchSize:    viper.GetInt("outbox.batch_size"),
+		Lease:        viper.GetDuration("outbox.lease"),
+		MinBackoff:   viper.GetDuration("outbox.min_backoff"),
+		MaxBackoff:   viper.GetDuration("outbox.max_backoff"),
+	})
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+
+	// --- Освобождение просроченных резервов корзин ---
+	sweepInterval := viper.GetDuration("cart.sweep_interval")
+	if sweepInterval <= 0 {
+		sweepInterval = time.Minute
+	}
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		cartService.RunExpirySweeper(relayCtx, sweepInterval)
+	}()
+
+	// --- Применение запланированных цен ---
+	pricePollInterval := viper.GetDuration("books.price_poll_interval")
+	if pricePollInterval <= 0 {
+		pricePollInterval = time.Minute
+	}
+	priceSchedulerDone := make(chan struct{})
+	go func() {
+		defer close(priceSchedulerDone)
+		bookService.RunPriceScheduler(relayCtx, pricePollInterval)
+	}()
+
+	// --- Повтор возвратов денег за отменённые и возвращённые заказы ---
+	settleInterval := viper.GetDuration("payment.settle_interval")
+	if settleInterval <= 0 {
+		settleInterval = time.Minute
+	}
+	settlerDone := make(chan struct{})
+	go func() {
+		defer close(settlerDone)
+		orderService.RunPaymentSettler(relayCtx, settleInterval)
+	}()
 
 	// --- Delivery ---
-	handler := httpdelivery.NewHandler(bookService, categoryService, cartService, orderService, logger)
-	auth := httpdelivery.NewAuthMiddleware(keycloak, logger)
-	router := handler.Router(auth)
+	handler := httpdelivery.NewHandler(bookService, categoryService, cartService, orderService, userService, returnService, promoService, taxService, []byte(viper.GetString("payment.webhook_secret")), logger)
+	if secret := viper.GetString("http.cursor_secret"); secret != "" {
+		handler.Cursors = httpdelivery.NewCursorCodec([]byte(secret))
+	}
+	auth := httpdelivery.NewAuthMiddleware(keycloak, userService, logger)
+	idempotency := httpdelivery.NewIdempotencyMiddleware(redisCache, logger)
+	guestCart := httpdelivery.NewGuestCartMiddleware(cartService, []byte(viper.GetString("http.cart_secret")), logger)
+	router := handler.Router(auth, idempotency, guestCart)
 
 	// --- HTTP server ---
 	srv := &http.Server{
@@ -154,5 +239,10 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("shutdown error", "err", err)
 	}
+	stopRelay()
+	<-relayDone
+	<-sweeperDone
+	<-priceSchedulerDone
+	<-settlerDone
 	logger.Info("Server exited")
 }
@@ -0,0 +1,39 @@
+// fakepay — локальный платёжный шлюз для разработки. Читает тот же конфиг,
+// что и сервис: ключи из секции payment, адреса и задержки из fakepay.
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+
+	"github.com/yourorg/bookshop/internal/integration/fakepay"
+)
+
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		configPath = "./configs/config.yaml"
+	}
+	viper.SetConfigFile(configPath)
+	if err := viper.ReadInConfig(); err != nil {
+		logger.Error("failed to read config", "err", err)
+		os.Exit(1)
+	}
+	srv := fakepay.New(fakepay.Config{
+		APIKey:        viper.GetString("payment.api_key"),
+		WebhookURL:    viper.GetString("fakepay.webhook_url"),
+		WebhookSecret: []byte(viper.GetString("payment.webhook_secret")),
+		ConfirmDelay:  viper.GetDuration("fakepay.confirm_delay"),
+		TimeoutDelay:  viper.GetDuration("fakepay.timeout_delay"),
+	})
+	addr := viper.GetString("fakepay.addr")
+	logger.Info("fake payment gateway started", "addr", addr)
+	if err := http.ListenAndServe(addr, srv); err != nil {
+		logger.Error("server error", "err", err)
+		os.Exit(1)
+	}
+}
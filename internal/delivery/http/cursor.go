@@ -0,0 +1,71 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// CursorCodec превращает domain.Cursor в непрозрачную строку, подписанную
+// HMAC, чтобы клиент не мог подделать позицию в выдаче.
+type CursorCodec struct {
+	secret []byte
+}
+
+// NewCursorCodec создаёт кодек. Если secret пустой, генерируется случайный
+// ключ: курсоры тогда действуют только до перезапуска процесса.
+func NewCursorCodec(secret []byte) *CursorCodec {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic("cursor secret: " + err.Error())
+		}
+	}
+	return &CursorCodec{secret: secret}
+}
+
+func (c *CursorCodec) Encode(cur *domain.Cursor) string {
+	if cur == nil {
+		return ""
+	}
+	payload, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// Decode возвращает nil для пустой строки (первая страница).
+func (c *CursorCodec) Decode(s string) (*domain.Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	payloadPart, sigPart, ok := strings.Cut(s, ".")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil || !hmac.Equal(sig, c.sign(payload)) {
+		return nil, errInvalidCursor
+	}
+	var cur domain.Cursor
+	if err := json.Unmarshal(payload, &cur); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &cur, nil
+}
+
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/service"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	cartTokenHeader = "X-Cart-Token"
+	cartTokenCookie = "cart_token"
+	// cartTokenMaxAge — срок жизни cookie гостевой корзины, 30 дней.
+	cartTokenMaxAge          = 30 * 24 * 60 * 60
+	cartMergedHeader         = "Cart-Merged"
+	cartMergeConflictsHeader = "Cart-Merge-Conflicts"
+)
+
+type guestKey struct{}
+type cartMergeKey struct{}
+
+// CartOwnerFrom возвращает владельца корзины: пользователя из JWT или,
+// для анонимного запроса, гостя, положенного GuestCartMiddleware.
+func CartOwnerFrom(ctx context.Context) (string, error) {
+	if p, err := PrincipalFrom(ctx); err == nil {
+		return p.UserID, nil
+	}
+	if guestID, ok := ctx.Value(guestKey{}).(string); ok && guestID != "" {
+		return guestID, nil
+	}
+	return "", ErrUnauthenticated
+}
+
+// cartMergeFrom возвращает итог переноса гостевой корзины в этом запросе.
+func cartMergeFrom(ctx context.Context) *domain.CartMergeResult {
+	res, _ := ctx.Value(cartMergeKey{}).(*domain.CartMergeResult)
+	return res
+}
+
+// GuestCartMiddleware даёт анонимным посетителям корзину. Гость
+// определяется подписанным токеном из заголовка X-Cart-Token или cookie
+// cart_token; без токена выдаётся новый. Гостевая корзина хранится так же,
+// как корзина пользователя, под случайным UUID. Первый аутентифицированный
+// запрос с токеном переносит гостевую корзину в корзину пользователя;
+// итог передаётся в заголовках Cart-Merged и Cart-Merge-Conflicts.
+// Ставится после JWTAuth или OptionalJWTAuth.
+type GuestCartMiddleware struct {
+	cart   service.CartService
+	secret []byte
+	Logger *slog.Logger
+}
+
+// NewGuestCartMiddleware создаёт middleware. Если secret пустой,
+// генерируется случайный ключ: гостевые корзины тогда теряются при
+// перезапуске процесса.
+func NewGuestCartMiddleware(cart service.CartService, secret []byte, logger *slog.Logger) *GuestCartMiddleware {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic("cart secret: " + err.Error())
+		}
+	}
+	return &GuestCartMiddleware{cart: cart, secret: secret, Logger: logger}
+}
+
+func (m *GuestCartMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		token, fromCookie := cartToken(r)
+		guestID, valid := m.verify(token)
+
+		if principal, err := PrincipalFrom(ctx); err == nil {
+			if valid {
+				res, err := m.cart.MergeGuest(ctx, guestID, principal.UserID)
+				if err != nil {
+					// Гостевая корзина не потеряна — перенос повторится со следующим запросом
+					m.Logger.Error("failed to merge guest cart", "userID", principal.UserID, "err", err)
+				} else {
+					if fromCookie {
+						http.SetCookie(w, &http.Cookie{Name: cartTokenCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+					}
+					w.Header().Set(cartMergedHeader, strconv.Itoa(res.Merged))
+					if len(res.Conflicts) > 0 {
+						conflicts, _ := json.Marshal(res.Conflicts)
+						w.Header().Set(cartMergeConflictsHeader, string(conflicts))
+					}
+					ctx = context.WithValue(ctx, cartMergeKey{}, res)
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if !valid {
+			// Нет токена или подпись не сходится (например, сменился ключ) — новая корзина
+			guestID = newGuestID()
+			token = m.sign(guestID)
+			http.SetCookie(w, &http.Cookie{
+				Name:     cartTokenCookie,
+				Value:    token,
+				Path:     "/",
+				MaxAge:   cartTokenMaxAge,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+			w.Header().Set(cartTokenHeader, token)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, guestKey{}, guestID)))
+	})
+}
+
+// cartToken берёт токен из заголовка, иначе из cookie.
+func cartToken(r *http.Request) (token string, fromCookie bool) {
+	if token := r.Header.Get(cartTokenHeader); token != "" {
+		return token, false
+	}
+	if c, err := r.Cookie(cartTokenCookie); err == nil {
+		return c.Value, true
+	}
+	return "", false
+}
+
+func (m *GuestCartMiddleware) sign(guestID string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(guestID))
+	return guestID + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (m *GuestCartMiddleware) verify(token string) (string, bool) {
+	guestID, _, ok := strings.Cut(token, ".")
+	if !ok || guestID == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(token), []byte(m.sign(guestID))) {
+		return "", false
+	}
+	return guestID, true
+}
+
+// newGuestID возвращает случайный UUID v4: carts.user_id имеет тип UUID.
+func newGuestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("guest id: " + err.Error())
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/mocks"
+	"golang.org/x/exp/slog"
+)
+
+func newTestGuestCart(cart *mocks.CartService) *GuestCartMiddleware {
+	return NewGuestCartMiddleware(cart, []byte("secret"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestGuestCart_Anonymous_IssuesToken(t *testing.T) {
+	mw := newTestGuestCart(new(mocks.CartService))
+	var owner string
+	h := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		owner, err = CartOwnerFrom(r.Context())
+		require.NoError(t, err)
+	}))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/cart", nil))
+	token := rw.Header().Get(cartTokenHeader)
+	require.NotEmpty(t, token)
+	guestID, ok := mw.verify(token)
+	require.True(t, ok)
+	assert.Equal(t, guestID, owner)
+	require.Len(t, rw.Result().Cookies(), 1)
+	assert.Equal(t, token, rw.Result().Cookies()[0].Value)
+
+	// Повторный запрос с cookie попадает в ту же корзину без нового токена
+	req := httptest.NewRequest("GET", "/cart", nil)
+	req.AddCookie(&http.Cookie{Name: cartTokenCookie, Value: token})
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Empty(t, rw.Header().Get(cartTokenHeader))
+	assert.Equal(t, guestID, owner)
+}
+
+func TestGuestCart_Anonymous_TamperedTokenReplaced(t *testing.T) {
+	mw := newTestGuestCart(new(mocks.CartService))
+	token := mw.sign(newGuestID())
+	forged := "00000000-0000-4000-8000-000000000001" + token[36:]
+
+	var owner string
+	h := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner, _ = CartOwnerFrom(r.Context())
+	}))
+	req := httptest.NewRequest("GET", "/cart", nil)
+	req.Header.Set(cartTokenHeader, forged)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.NotEqual(t, "00000000-0000-4000-8000-000000000001", owner)
+	assert.NotEmpty(t, rw.Header().Get(cartTokenHeader))
+}
+
+func TestGuestCart_Authenticated_MergesAndReportsConflicts(t *testing.T) {
+	cart := new(mocks.CartService)
+	mw := newTestGuestCart(cart)
+	guestID := newGuestID()
+	res := &domain.CartMergeResult{Merged: 1, Conflicts: []domain.CartMergeConflict{{BookID: 7, Requested: 3, Merged: 1, Available: 1}}}
+	cart.On("MergeGuest", mock.Anything, guestID, "user-1").Return(res, nil).Once()
+
+	var merge *domain.CartMergeResult
+	h := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner, err := CartOwnerFrom(r.Context())
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", owner)
+		merge = cartMergeFrom(r.Context())
+	}))
+	req := httptest.NewRequest("GET", "/cart", nil)
+	req.AddCookie(&http.Cookie{Name: cartTokenCookie, Value: mw.sign(guestID)})
+	req = req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	assert.Equal(t, res, merge)
+	assert.Equal(t, "1", rw.Header().Get(cartMergedHeader))
+	var conflicts []domain.CartMergeConflict
+	require.NoError(t, json.Unmarshal([]byte(rw.Header().Get(cartMergeConflictsHeader)), &conflicts))
+	assert.Equal(t, res.Conflicts, conflicts)
+	// Cookie гостевой корзины сбрасывается
+	require.Len(t, rw.Result().Cookies(), 1)
+	assert.Equal(t, -1, rw.Result().Cookies()[0].MaxAge)
+	cart.AssertExpectations(t)
+}
+
+func TestGuestCart_Authenticated_MergeFailureKeepsToken(t *testing.T) {
+	cart := new(mocks.CartService)
+	mw := newTestGuestCart(cart)
+	guestID := newGuestID()
+	cart.On("MergeGuest", mock.Anything, guestID, "user-1").Return(nil, errors.New("db down"))
+
+	called := false
+	h := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req.Header.Set(cartTokenHeader, mw.sign(guestID))
+	req = req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	assert.True(t, called)
+	assert.Empty(t, rw.Header().Get(cartMergedHeader))
+	assert.Empty(t, rw.Result().Cookies())
+}
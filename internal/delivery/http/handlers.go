@@ -1,14 +1,21 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
 	"github.com/yourorg/bookshop/internal/service"
 	"golang.org/x/exp/slog"
 )
@@ -18,40 +25,198 @@ type Handler struct {
 	Category service.CategoryService
 	Cart     service.CartService
 	Order    service.OrderService
+	User     service.UserService
 	Logger   *slog.Logger
+	// Return, Promo и Tax — возвраты заказов, промокоды и ставки НДС.
+	Return service.ReturnService
+	Promo  service.PromoService
+	Tax    service.TaxService
+	// Cursors подписывает курсоры пагинации; по умолчанию — случайный ключ.
+	Cursors *CursorCodec
+	// PaymentWebhookSecret проверяет подписи вебхуков платёжного шлюза;
+	// пока он пуст, вебхуки отклоняются.
+	PaymentWebhookSecret []byte
 }
 
-func NewHandler(book service.BookService, category service.CategoryService, cart service.CartService, order service.OrderService, logger *slog.Logger) *Handler {
+func NewHandler(book service.BookService, category service.CategoryService, cart service.CartService, order service.OrderService, user service.UserService, returns service.ReturnService, promo service.PromoService, tax service.TaxService, paymentWebhookSecret []byte, logger *slog.Logger) *Handler {
 	return &Handler{
-		Book:     book,
-		Category: category,
-		Cart:     cart,
-		Order:    order,
-		Logger:   logger,
+		Book:                 book,
+		Category:             category,
+		Cart:                 cart,
+		Order:                order,
+		User:                 user,
+		Return:               returns,
+		Promo:                promo,
+		Tax:                  tax,
+		Logger:               logger,
+		Cursors:              NewCursorCodec(nil),
+		PaymentWebhookSecret: paymentWebhookSecret,
 	}
 }
 
+// parsePage разбирает ?cursor=&limit=&with_total=.
+func (h *Handler) parsePage(q url.Values, defaultLimit int) (domain.PageRequest, error) {
+	page := domain.PageRequest{Limit: defaultLimit}
+	after, err := h.Cursors.Decode(q.Get("cursor"))
+	if err != nil {
+		return page, err
+	}
+	page.After = after
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			return page, fmt.Errorf("invalid limit %q", v)
+		}
+		page.Limit = n
+	}
+	if v := q.Get("with_total"); v != "" {
+		withTotal, err := strconv.ParseBool(v)
+		if err != nil {
+			return page, fmt.Errorf("invalid with_total %q", v)
+		}
+		page.WithTotal = withTotal
+	}
+	return page, nil
+}
+
+// principal возвращает пользователя из контекста или отвечает 401.
+func (h *Handler) principal(w http.ResponseWriter, r *http.Request) (*domain.Principal, bool) {
+	p, err := PrincipalFrom(r.Context())
+	if err != nil {
+		h.Logger.Warn("request without principal", "path", r.URL.Path)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return p, true
+}
+
+// cartOwner — владелец корзины: пользователь или гость (см. GuestCartMiddleware).
+func (h *Handler) cartOwner(w http.ResponseWriter, r *http.Request) (string, bool) {
+	owner, err := CartOwnerFrom(r.Context())
+	if err != nil {
+		h.Logger.Warn("request without cart owner", "path", r.URL.Path)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	return owner, true
+}
+
 // ListBooks godoc
 // @Summary      Get list of books
-// @Description  Returns a list of books, optionally filtered by category
+// @Description  Returns a filtered and sorted list of books together with facet counts per category and price bucket
 // @Tags         books
 // @Produce      json
-// @Param        category_id  query     int  false  "Category ID"
-// @Success      200  {array}  domain.Book
+// @Param        category_id  query     []int   false  "Category ID (repeatable)"  collectionFormat(multi)
+// @Param        min_price    query     number  false  "Minimum price"
+// @Param        max_price    query     number  false  "Maximum price"
+// @Param        min_year     query     int     false  "Minimum publication year"
+// @Param        max_year     query     int     false  "Maximum publication year"
+// @Param        author       query     string  false  "Author (substring, case-insensitive)"
+// @Param        in_stock     query     bool    false  "Only books in stock (default true); false includes sold-out books"
+// @Param        sort         query     string  false  "Sort field: price, -price, year, -year, title, -title, created_at, -created_at"
+// @Param        cursor       query     string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param        limit        query     int     false  "Page size (default 100, max 100)"
+// @Param        with_total   query     bool    false  "Include total count of matching books"
+// @Success      200  {object}  domain.BookList
+// @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /books [get]
 func (h *Handler) ListBooks(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	var categoryIDs []int
+	filter, err := h.parseBookFilter(r.URL.Query())
+	if err != nil {
+		h.Logger.Error("invalid book list request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Book.List(r.Context(), filter)
+	if err != nil {
+		h.Logger.Error("failed to list books", "err", err)
+		if strings.Contains(err.Error(), "invalid sort") || strings.Contains(err.Error(), "invalid cursor") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	facets, err := h.Book.Facets(r.Context(), filter)
+	if err != nil {
+		h.Logger.Error("failed to get book facets", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	list.Facets = facets
+	list.NextCursor = h.Cursors.Encode(list.Next)
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *Handler) parseBookFilter(q url.Values) (domain.BookFilter, error) {
+	filter := domain.BookFilter{Author: strings.TrimSpace(q.Get("author")), Sort: q.Get("sort")}
+	page, err := h.parsePage(q, 100)
+	if err != nil {
+		return filter, err
+	}
+	filter.Limit, filter.After, filter.WithTotal = page.Limit, page.After, page.WithTotal
 	for _, v := range q["category_id"] {
 		id, err := strconv.Atoi(v)
-		if err == nil {
-			categoryIDs = append(categoryIDs, id)
+		if err != nil {
+			return filter, fmt.Errorf("invalid category_id %q", v)
+		}
+		filter.CategoryIDs = append(filter.CategoryIDs, id)
+	}
+	for name, dst := range map[string]**domain.Money{"min_price": &filter.MinPrice, "max_price": &filter.MaxPrice} {
+		if v := q.Get(name); v != "" {
+			m, err := domain.ParseMoney(v)
+			if err != nil || m.IsNegative() {
+				return filter, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = &m
+		}
+	}
+	for name, dst := range map[string]**int{"min_year": &filter.MinYear, "max_year": &filter.MaxYear} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = &n
+		}
+	}
+	if v := q.Get("in_stock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid in_stock %q", v)
 		}
+		filter.IncludeSoldOut = !inStock
+	}
+	if !domain.ValidBookSort(filter.Sort) {
+		return filter, fmt.Errorf("invalid sort %q", filter.Sort)
 	}
-	books, err := h.Book.List(r.Context(), categoryIDs, 100, 0)
+	return filter, nil
+}
+
+// SearchBooks godoc
+// @Summary      Search books
+// @Description  Full-text search by title and author (Russian and English stemming, prefix matching), ordered by relevance
+// @Tags         books
+// @Produce      json
+// @Param        q       query     string  true   "Search query"
+// @Param        limit   query     int     false  "Max results (default 20, max 100)"
+// @Param        offset  query     int     false  "Offset"
+// @Success      200  {array}  domain.Book
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /books/search [get]
+func (h *Handler) SearchBooks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	books, err := h.Book.Search(r.Context(), q.Get("q"), limit, offset)
 	if err != nil {
-		h.Logger.Error("failed to list books", "err", err)
+		h.Logger.Error("failed to search books", "q", q.Get("q"), "err", err)
+		if strings.Contains(err.Error(), "query required") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -95,16 +260,17 @@ func (h *Handler) GetBook(w http.ResponseWriter, r *http.Request) {
 // @Success      201  {object}  domain.Book
 // @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /books [post]
 func (h *Handler) CreateBook(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Title      string  `json:"title"`
-		Author     string  `json:"author"`
-		Year       int     `json:"year"`
-		Price      float64 `json:"price"`
-		CategoryID int     `json:"category_id"`
-		Inventory  int     `json:"stock"`
+		Title      string       `json:"title"`
+		Author     string       `json:"author"`
+		Year       int          `json:"year"`
+		Price      domain.Money `json:"price" swaggertype:"number"`
+		CategoryID int          `json:"category_id"`
+		Inventory  int          `json:"stock"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Title == "" || req.Author == "" || req.CategoryID == 0 {
 		h.Logger.Error("invalid book create request", "err", err)
@@ -146,6 +312,7 @@ func (h *Handler) CreateBook(w http.ResponseWriter, r *http.Request) {
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /books/{id} [put]
 func (h *Handler) UpdateBook(w http.ResponseWriter, r *http.Request) {
@@ -157,11 +324,11 @@ func (h *Handler) UpdateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
-		Title      string  `json:"title"`
-		Author     string  `json:"author"`
-		Year       int     `json:"year"`
-		Price      float64 `json:"price"`
-		CategoryID int     `json:"category_id"`
+		Title      string       `json:"title"`
+		Author     string       `json:"author"`
+		Year       int          `json:"year"`
+		Price      domain.Money `json:"price" swaggertype:"number"`
+		CategoryID int          `json:"category_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Title == "" || req.Author == "" || req.CategoryID == 0 {
 		h.Logger.Error("invalid book update request", "err", err)
@@ -198,6 +365,7 @@ func (h *Handler) UpdateBook(w http.ResponseWriter, r *http.Request) {
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /books/{id} [delete]
 func (h *Handler) DeleteBook(w http.ResponseWriter, r *http.Request) {
@@ -220,22 +388,284 @@ func (h *Handler) DeleteBook(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// AdjustInventory godoc
+// @Summary      Adjust book inventory
+// @Description  Records a stock movement (admin only). type is restock or damage with a positive quantity, or correction with a signed quantity
+// @Tags         inventory
+// @Accept       json
+// @Produce      json
+// @Param        id          path      int                     true  "Book ID"
+// @Param        adjustment  body      inventoryAdjustRequest  true  "Adjustment"
+// @Success      201  {object}  domain.InventoryMovement
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /books/{id}/inventory [post]
+func (h *Handler) AdjustInventory(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid book id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req inventoryAdjustRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.Error("invalid inventory adjustment request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	m, err := h.Book.AdjustInventory(r.Context(), id, req.Type, req.Quantity, req.Reason, principal.UserID)
+	if err != nil {
+		h.Logger.Error("failed to adjust inventory", "id", id, "err", err)
+		errStr := err.Error()
+		switch {
+		case strings.Contains(errStr, "book not found"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(errStr, "not enough books in stock"):
+			w.WriteHeader(http.StatusConflict)
+		case strings.Contains(errStr, "reason required"),
+			strings.Contains(errStr, "quantity must be"),
+			strings.Contains(errStr, "invalid adjustment type"):
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+type inventoryAdjustRequest struct {
+	Type     string `json:"type"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+}
+
+// InventoryHistory godoc
+// @Summary      Book inventory history
+// @Description  Returns stock movements of a book, newest first (admin only)
+// @Tags         inventory
+// @Produce      json
+// @Param        id          path      int     true   "Book ID"
+// @Param        cursor      query     string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param        limit       query     int     false  "Page size (default 20, max 100)"
+// @Param        with_total  query     bool    false  "Include total count"
+// @Success      200  {object}  domain.InventoryMovementList
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /books/{id}/inventory/history [get]
+func (h *Handler) InventoryHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid book id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	page, err := h.parsePage(r.URL.Query(), 20)
+	if err != nil {
+		h.Logger.Error("invalid inventory history request", "id", id, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Book.InventoryHistory(r.Context(), id, page)
+	if err != nil {
+		h.Logger.Error("failed to get inventory history", "id", id, "err", err)
+		if strings.Contains(err.Error(), "book not found") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	list.NextCursor = h.Cursors.Encode(list.Next)
+	json.NewEncoder(w).Encode(list)
+}
+
+// ScheduleBookPrice godoc
+// @Summary      Schedule book price
+// @Description  Schedules a price for [effective_from, effective_to) (admin only). Without effective_from the price takes effect immediately; without effective_to it lasts until a later price. Among overlapping prices the one starting later wins
+// @Tags         prices
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int                          true  "Book ID"
+// @Param        price  body      domain.SchedulePriceRequest  true  "Price"
+// @Success      201  {object}  domain.BookPrice
+// @Failure      400  {object}  validationResponse
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /books/{id}/prices [post]
+func (h *Handler) ScheduleBookPrice(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid book id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req domain.SchedulePriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.Error("invalid schedule price request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	price, err := h.Book.SchedulePrice(r.Context(), id, req, principal.UserID)
+	if err != nil {
+		h.Logger.Error("failed to schedule price", "id", id, "err", err)
+		var invalid *domain.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(validationResponse{Error: "invalid price", Fields: invalid.Fields})
+		case strings.Contains(err.Error(), "book not found"):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(price)
+}
+
+// ListBookPrices godoc
+// @Summary      Book price history
+// @Description  Returns past, current and scheduled prices of a book, latest effective_from first. The admin who set a price is not returned
+// @Tags         prices
+// @Produce      json
+// @Param        id          path      int     true   "Book ID"
+// @Param        cursor      query     string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param        limit       query     int     false  "Page size (default 20, max 100)"
+// @Param        with_total  query     bool    false  "Include total count"
+// @Success      200  {object}  domain.BookPriceList
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /books/{id}/prices [get]
+func (h *Handler) ListBookPrices(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid book id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	page, err := h.parsePage(r.URL.Query(), 20)
+	if err != nil {
+		h.Logger.Error("invalid price history request", "id", id, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Book.PriceHistory(r.Context(), id, page)
+	if err != nil {
+		h.Logger.Error("failed to get price history", "id", id, "err", err)
+		errStr := err.Error()
+		switch {
+		case strings.Contains(errStr, "book not found"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(errStr, "invalid cursor"):
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	// История публичная: кто из админов менял цену, наружу не отдаётся
+	for _, p := range list.Items {
+		p.Actor = ""
+	}
+	list.NextCursor = h.Cursors.Encode(list.Next)
+	json.NewEncoder(w).Encode(list)
+}
+
+// CancelBookPrice godoc
+// @Summary      Cancel scheduled book price
+// @Description  Removes a price that has not taken effect yet (admin only)
+// @Tags         prices
+// @Param        id        path  int  true  "Book ID"
+// @Param        price_id  path  int  true  "Price ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /books/{id}/prices/{price_id} [delete]
+func (h *Handler) CancelBookPrice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.Logger.Error("invalid book id", "id", chi.URLParam(r, "id"), "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	priceID, err := strconv.Atoi(chi.URLParam(r, "price_id"))
+	if err != nil {
+		h.Logger.Error("invalid price id", "price_id", chi.URLParam(r, "price_id"), "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := h.Book.CancelPrice(r.Context(), id, priceID); err != nil {
+		h.Logger.Error("failed to cancel price", "id", id, "price_id", priceID, "err", err)
+		errStr := err.Error()
+		switch {
+		case strings.Contains(errStr, "price not found"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(errStr, "price already in effect"):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ListCategories godoc
 // @Summary      Get list of categories
-// @Description  Returns a list of categories
+// @Description  Returns a page of categories ordered by id
 // @Tags         categories
 // @Produce      json
-// @Success      200  {array}  domain.Category
+// @Param        cursor      query     string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param        limit       query     int     false  "Page size (default 100, max 100)"
+// @Param        with_total  query     bool    false  "Include total count"
+// @Success      200  {object}  domain.CategoryList
+// @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /categories [get]
 func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
-	categories, err := h.Category.List(r.Context())
+	page, err := h.parsePage(r.URL.Query(), 100)
+	if err != nil {
+		h.Logger.Error("invalid category list request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Category.List(r.Context(), page)
 	if err != nil {
 		h.Logger.Error("failed to list categories", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(categories)
+	list.NextCursor = h.Cursors.Encode(list.Next)
+	json.NewEncoder(w).Encode(list)
 }
 
 // CreateCategory godoc
@@ -248,6 +678,7 @@ func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
 // @Success      201  {object}  domain.Category
 // @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /categories [post]
 func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
@@ -284,6 +715,7 @@ func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /categories/{id} [put]
 func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
@@ -324,6 +756,7 @@ func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /categories/{id} [delete]
 func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
@@ -348,38 +781,68 @@ func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 
 // GetCart godoc
 // @Summary      Get user's cart
-// @Description  Returns the cart for the authenticated user
+// @Description  Returns the cart for the authenticated user or guest with line totals and subtotal at current prices. Items whose price differs from price_snapshot (the price when added) or that cannot be bought are flagged, and stale is set. Each item is reserved until expires_at; expired items are not returned. If the request carried a guest cart token, merge reports how the guest cart was moved into the user's cart. tax is the VAT already included in the prices, per item and in total, for the shipping country and region given in the query or the default region
 // @Tags         cart
 // @Produce      json
-// @Success      200  {object}  domain.Cart
+// @Param        X-Cart-Token  header  string  false  "Guest cart token; a new one is returned in the X-Cart-Token header and cart_token cookie if missing"
+// @Param        country       query   string  false  "Shipping country (ISO 3166-1 alpha-2) for tax calculation"
+// @Param        region        query   string  false  "Shipping region for tax calculation"
+// @Success      200  {object}  cartResponse
+// @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /cart [get]
 func (h *Handler) GetCart(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	cart, err := h.Cart.GetByUserID(r.Context(), userID)
+	userID, ok := h.cartOwner(w, r)
+	if !ok {
+		return
+	}
+	var cart *domain.Cart
+	var err error
+	if country := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("country"))); country != "" {
+		if !domain.ShipsTo(country) {
+			h.Logger.Error("unsupported tax country", "country", country)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		cart, err = h.Cart.GetForRegion(r.Context(), userID, domain.TaxRegion{Country: country, Region: strings.TrimSpace(r.URL.Query().Get("region"))})
+	} else {
+		cart, err = h.Cart.GetByUserID(r.Context(), userID)
+	}
 	if err != nil {
 		h.Logger.Error("failed to get cart", "userID", userID, "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(cart)
+	json.NewEncoder(w).Encode(cartResponse{Cart: *cart, Merge: cartMergeFrom(r.Context())})
+}
+
+type cartResponse struct {
+	domain.Cart
+	Merge *domain.CartMergeResult `json:"merge,omitempty"`
 }
 
 // AddToCart godoc
 // @Summary      Add book to cart
-// @Description  Adds a book to the authenticated user's cart
+// @Description  Adds a book to the cart of the authenticated user or guest
 // @Tags         cart
 // @Accept       json
 // @Produce      json
+// @Param        X-Cart-Token  header  string  false  "Guest cart token; a new one is returned in the X-Cart-Token header and cart_token cookie if missing"
+// @Param        Idempotency-Key  header  string  false  "Repeated requests with the same key return the first response"
 // @Param        item  body      map[string]int  true  "Book ID to add"
 // @Success      201  {object}  nil
 // @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /cart [post]
 func (h *Handler) AddToCart(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
+	userID, ok := h.cartOwner(w, r)
+	if !ok {
+		return
+	}
 	var req struct {
 		BookID int `json:"book_id"`
 	}
@@ -405,16 +868,21 @@ func (h *Handler) AddToCart(w http.ResponseWriter, r *http.Request) {
 
 // RemoveFromCart godoc
 // @Summary      Remove book from cart
-// @Description  Removes a book from the authenticated user's cart
+// @Description  Removes a book from the cart of the authenticated user or guest
 // @Tags         cart
+// @Param        X-Cart-Token  header  string  false  "Guest cart token; a new one is returned in the X-Cart-Token header and cart_token cookie if missing"
 // @Param        book_id   path      int  true  "Book ID"
 // @Success      204  {object}  nil
 // @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /cart/{book_id} [delete]
 func (h *Handler) RemoveFromCart(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
+	userID, ok := h.cartOwner(w, r)
+	if !ok {
+		return
+	}
 	bookID, err := strconv.Atoi(chi.URLParam(r, "book_id"))
 	if err != nil {
 		h.Logger.Error("invalid book id for remove from cart", "id", chi.URLParam(r, "book_id"), "err", err)
@@ -429,48 +897,279 @@ func (h *Handler) RemoveFromCart(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ClearCart godoc
-// @Summary      Clear cart
-// @Description  Clears the authenticated user's cart
+// SetCartItemQuantity godoc
+// @Summary      Set book quantity in cart
+// @Description  Sets the absolute quantity of a book in the cart of the authenticated user or guest; 0 removes the book. Stock, including copies held by other carts, is checked before anything changes
 // @Tags         cart
-// @Success      204  {object}  nil
+// @Accept       json
+// @Produce      json
+// @Param        X-Cart-Token  header  string  false  "Guest cart token; a new one is returned in the X-Cart-Token header and cart_token cookie if missing"
+// @Param        book_id   path      int                      true  "Book ID"
+// @Param        item      body      cartItemQuantityRequest  true  "New quantity"
+// @Success      200  {object}  cartResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  outOfStockResponse
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
-// @Router       /cart [delete]
-func (h *Handler) ClearCart(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	if err := h.Cart.Clear(r.Context(), userID); err != nil {
-		h.Logger.Error("failed to clear cart", "userID", userID, "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+// @Router       /cart/items/{book_id} [put]
+func (h *Handler) SetCartItemQuantity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.cartOwner(w, r)
+	if !ok {
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	bookID, err := strconv.Atoi(chi.URLParam(r, "book_id"))
+	if err != nil {
+		h.Logger.Error("invalid book id for set cart quantity", "id", chi.URLParam(r, "book_id"), "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req cartItemQuantityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Quantity == nil {
+		h.Logger.Error("invalid set cart quantity request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = h.Cart.SetItemQuantity(r.Context(), userID, bookID, *req.Quantity)
+	h.writeCartUpdate(w, r, userID, err)
 }
 
-// PlaceOrder godoc
-// @Summary      Place an order
-// @Description  Places an order for the authenticated user
-// @Tags         orders
+type cartItemQuantityRequest struct {
+	Quantity *int `json:"quantity"`
+}
+
+// SetCartItems godoc
+// @Summary      Set quantities of several books in cart
+// @Description  Sets absolute quantities for several books at once; 0 removes a book. Either all changes are applied or none: if any book is short, the response lists every such book
+// @Tags         cart
+// @Accept       json
 // @Produce      json
-// @Success      201  {object}  domain.Order
+// @Param        X-Cart-Token  header  string  false  "Guest cart token; a new one is returned in the X-Cart-Token header and cart_token cookie if missing"
+// @Param        items  body      cartBatchRequest  true  "Books and quantities, up to 100"
+// @Success      200  {object}  cartResponse
 // @Failure      400  {object}  map[string]string
-// @Failure      409  {object}  map[string]string
+// @Failure      409  {object}  outOfStockResponse
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
-// @Router       /orders [post]
-func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	order, err := h.Order.Create(r.Context(), userID)
+// @Router       /cart/items:batch [post]
+func (h *Handler) SetCartItems(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.cartOwner(w, r)
+	if !ok {
+		return
+	}
+	var req cartBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.Error("invalid cart batch request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err := h.Cart.SetItems(r.Context(), userID, req.Items)
+	h.writeCartUpdate(w, r, userID, err)
+}
+
+type cartBatchRequest struct {
+	Items []domain.CartItemQuantity `json:"items"`
+}
+
+// writeCartUpdate отвечает на изменение корзины: ошибкой или новой корзиной.
+func (h *Handler) writeCartUpdate(w http.ResponseWriter, r *http.Request, userID string, err error) {
 	if err != nil {
-		h.Logger.Error("failed to place order", "userID", userID, "err", err)
+		h.Logger.Error("failed to update cart", "userID", userID, "err", err)
+		var outOfStock *domain.OutOfStockError
+		if errors.As(err, &outOfStock) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(outOfStockResponse{Error: "not enough books in stock", Items: outOfStock.Items})
+			return
+		}
 		errStr := err.Error()
-		if strings.Contains(errStr, "cart is empty") {
+		if strings.Contains(errStr, "book not found") ||
+			strings.Contains(errStr, "invalid quantity") ||
+			strings.Contains(errStr, "batch is empty") ||
+			strings.Contains(errStr, "batch is too large") ||
+			strings.Contains(errStr, "duplicate book in batch") {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		if strings.Contains(errStr, "book not found") || strings.Contains(errStr, "book out of stock") {
-			w.WriteHeader(http.StatusConflict)
-			return
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cart, err := h.Cart.GetByUserID(r.Context(), userID)
+	if err != nil {
+		h.Logger.Error("failed to get cart", "userID", userID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(cartResponse{Cart: *cart, Merge: cartMergeFrom(r.Context())})
+}
+
+// ClearCart godoc
+// @Summary      Clear cart
+// @Description  Clears the cart of the authenticated user or guest
+// @Tags         cart
+// @Param        X-Cart-Token  header  string  false  "Guest cart token; a new one is returned in the X-Cart-Token header and cart_token cookie if missing"
+// @Success      204  {object}  nil
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /cart [delete]
+func (h *Handler) ClearCart(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.cartOwner(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Cart.Clear(r.Context(), userID); err != nil {
+		h.Logger.Error("failed to clear cart", "userID", userID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApplyCartPromo godoc
+// @Summary      Apply a promo code to the cart
+// @Description  Checks the promo code against the current cart and stores it. The returned cart has the discount split across items and total = subtotal - discount. The code is checked again when the order is placed; if it stops applying, GET /cart shows promo_reason and the order is rejected with 409
+// @Tags         cart
+// @Accept       json
+// @Produce      json
+// @Param        X-Cart-Token  header  string                    false  "Guest cart token; a new one is returned in the X-Cart-Token header and cart_token cookie if missing"
+// @Param        promo         body    domain.ApplyPromoRequest  true   "Promo code"
+// @Success      200  {object}  cartResponse
+// @Failure      400  {object}  map[string]string  "Empty code or empty cart"
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  promoErrorResponse  "The code exists but does not apply: reason is inactive, not_started, expired, min_subtotal, not_applicable, usage_limit or user_limit"
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /cart/promo [post]
+func (h *Handler) ApplyCartPromo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.cartOwner(w, r)
+	if !ok {
+		return
+	}
+	var req domain.ApplyPromoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.Error("invalid promo code request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	cart, err := h.Cart.ApplyPromo(r.Context(), userID, req.Code)
+	if err != nil {
+		h.Logger.Error("failed to apply promo code", "userID", userID, "err", err)
+		var promoErr *domain.PromoError
+		if errors.As(err, &promoErr) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(promoErrorResponseFrom(promoErr))
+			return
+		}
+		errStr := err.Error()
+		switch {
+		case strings.Contains(errStr, "invalid promo code"), strings.Contains(errStr, "cart is empty"):
+			w.WriteHeader(http.StatusBadRequest)
+		case strings.Contains(errStr, "promo code not found"):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(cartResponse{Cart: *cart, Merge: cartMergeFrom(r.Context())})
+}
+
+// RemoveCartPromo godoc
+// @Summary      Remove the promo code from the cart
+// @Tags         cart
+// @Param        X-Cart-Token  header  string  false  "Guest cart token; a new one is returned in the X-Cart-Token header and cart_token cookie if missing"
+// @Success      204  {object}  nil
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /cart/promo [delete]
+func (h *Handler) RemoveCartPromo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.cartOwner(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Cart.RemovePromo(r.Context(), userID); err != nil {
+		h.Logger.Error("failed to remove promo code", "userID", userID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type promoErrorResponse struct {
+	Error  string `json:"error"`
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+func promoErrorResponseFrom(err *domain.PromoError) promoErrorResponse {
+	return promoErrorResponse{Error: "promo code cannot be applied", Code: err.Code, Reason: err.Reason}
+}
+
+// PlaceOrder godoc
+// @Summary      Place an order
+// @Description  Places an order for the authenticated user at current prices. shipping_address and phone are required and validated for the destination country (RU, KZ, BY, AM); the order stores subtotal, discount, shipping, tax and total. If a price changed since the book was added to the cart, the order is rejected with 409 and the list of changes unless confirm_prices is true. The cart's promo code is redeemed with the order; if it no longer applies, the order is rejected with 409 and {"error": "promo code cannot be applied", "code", "reason"}
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key  header  string                    false  "Repeated requests with the same key return the first response"
+// @Param        order            body    domain.PlaceOrderRequest  true   "Shipping details and order options"
+// @Success      201  {object}  domain.Order
+// @Failure      400  {object}  validationResponse  "Invalid shipping details, or the cart is empty (no body)"
+// @Failure      409  {object}  outOfStockResponse  "Not enough books in stock. If prices changed, the body is {"error": "cart prices changed", "items": [{"book_id", "price_snapshot", "price"}]}"
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /orders [post]
+func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	var req domain.PlaceOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.Logger.Error("invalid place order request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	order, err := h.Order.Create(r.Context(), userID, req)
+	if err != nil {
+		h.Logger.Error("failed to place order", "userID", userID, "err", err)
+		errStr := err.Error()
+		if strings.Contains(errStr, "cart is empty") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var invalid *domain.ValidationError
+		if errors.As(err, &invalid) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(validationResponse{Error: "invalid shipping details", Fields: invalid.Fields})
+			return
+		}
+		var stale *domain.StaleCartError
+		if errors.As(err, &stale) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(stalePricesResponse{Error: "cart prices changed", Items: stale.Items})
+			return
+		}
+		var promoErr *domain.PromoError
+		if errors.As(err, &promoErr) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(promoErrorResponseFrom(promoErr))
+			return
+		}
+		var outOfStock *domain.OutOfStockError
+		if errors.As(err, &outOfStock) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(outOfStockResponse{Error: "not enough books in stock", Items: outOfStock.Items})
+			return
+		}
+		if strings.Contains(errStr, "book not found") {
+			w.WriteHeader(http.StatusConflict)
+			return
 		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -479,23 +1178,871 @@ func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(order)
 }
 
+type outOfStockResponse struct {
+	Error string                  `json:"error"`
+	Items []domain.OutOfStockItem `json:"items"`
+}
+
+type stalePricesResponse struct {
+	Error string               `json:"error"`
+	Items []domain.PriceChange `json:"items"`
+}
+
+type validationResponse struct {
+	Error  string              `json:"error"`
+	Fields []domain.FieldError `json:"fields"`
+}
+
+// GetOrder godoc
+// @Summary      Get an order
+// @Description  Returns an order with its items, book details, totals and shipping details. Customers see only their own orders, admins see any
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      int  true  "Order ID"
+// @Success      200  {object}  domain.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /orders/{id} [get]
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid order id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	order, err := h.Order.Get(r.Context(), id, principal.UserID, principal.IsAdmin())
+	if err != nil {
+		h.Logger.Error("failed to get order", "id", id, "userID", principal.UserID, "err", err)
+		if strings.Contains(err.Error(), "order not found") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(order)
+}
+
 // ListOrders godoc
 // @Summary      List user's orders
-// @Description  Returns a list of orders for the authenticated user
+// @Description  Returns a page of orders for the authenticated user, newest first
 // @Tags         orders
 // @Produce      json
-// @Success      200  {array}  domain.Order
+// @Param        cursor      query     string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param        limit       query     int     false  "Page size (default 20, max 100)"
+// @Param        with_total  query     bool    false  "Include total count"
+// @Success      200  {object}  domain.OrderList
+// @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
 // @Security     ApiKeyAuth
 // @Router       /orders [get]
 func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	orders, err := h.Order.ListByUser(r.Context(), userID)
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	page, err := h.parsePage(r.URL.Query(), 20)
+	if err != nil {
+		h.Logger.Error("invalid order list request", "userID", userID, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Order.ListByUser(r.Context(), userID, page)
 	if err != nil {
 		h.Logger.Error("failed to list orders", "userID", userID, "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(orders)
+	list.NextCursor = h.Cursors.Encode(list.Next)
+	json.NewEncoder(w).Encode(list)
+}
+
+// TransitionOrder godoc
+// @Summary      Change order status
+// @Description  Moves an order to a new status (admin only). Allowed: pending→paid|cancelled, paid→shipped|cancelled|refunded, shipped→delivered|cancelled, delivered→refunded
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id          path      int                      true  "Order ID"
+// @Param        transition  body      orderTransitionRequest  true  "Target status"
+// @Success      200  {object}  domain.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /orders/{id}/transitions [post]
+func (h *Handler) TransitionOrder(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid order id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req orderTransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Status == "" {
+		h.Logger.Error("invalid order transition request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	order, err := h.Order.Transition(r.Context(), id, req.Status, principal.UserID, req.Reason)
+	if err != nil {
+		h.Logger.Error("failed to change order status", "id", id, "status", req.Status, "err", err)
+		errStr := err.Error()
+		switch {
+		case strings.Contains(errStr, "invalid status"):
+			w.WriteHeader(http.StatusBadRequest)
+		case strings.Contains(errStr, "order not found"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(errStr, "illegal transition"), strings.Contains(errStr, "order status changed concurrently"):
+			w.WriteHeader(http.StatusConflict)
+		case strings.Contains(errStr, "payment refund failed"):
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(order)
+}
+
+type orderTransitionRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// CancelOrder godoc
+// @Summary      Cancel an order
+// @Description  Cancels an order and returns its books to stock. Customers can cancel their own pending orders, admins can cancel paid and shipped orders too
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id      path      int                 true   "Order ID"
+// @Param        cancel  body      orderCancelRequest  false  "Cancellation reason"
+// @Success      200  {object}  domain.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /orders/{id}/cancel [post]
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid order id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req orderCancelRequest
+	// Тело необязательно
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.Logger.Error("invalid order cancel request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	order, err := h.Order.Cancel(r.Context(), id, principal.UserID, principal.IsAdmin(), req.Reason)
+	if err != nil {
+		h.Logger.Error("failed to cancel order", "id", id, "userID", principal.UserID, "err", err)
+		errStr := err.Error()
+		switch {
+		case strings.Contains(errStr, "order not found"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(errStr, "order cannot be cancelled"),
+			strings.Contains(errStr, "illegal transition"),
+			strings.Contains(errStr, "order status changed concurrently"):
+			w.WriteHeader(http.StatusConflict)
+		case strings.Contains(errStr, "payment refund failed"):
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(order)
+}
+
+type orderCancelRequest struct {
+	Reason string `json:"reason"`
+}
+
+// PayOrder godoc
+// @Summary      Pay for an order
+// @Description  Authorizes the order total with the payment provider and captures it; the order becomes paid only after the capture succeeds. If the bank confirms later, the response is 202 with a pending payment and the order is paid when the provider's webhook arrives. Repeating the request resumes an unfinished payment, so it is safe to retry after 502
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key  header  string                  false  "Repeated requests with the same key return the first response"
+// @Param        id               path    int                     true   "Order ID"
+// @Param        payment          body    domain.PayOrderRequest  true   "Card token from the provider's payment form"
+// @Success      200  {object}  domain.Payment  "Payment captured, order is paid"
+// @Success      202  {object}  domain.Payment  "Waiting for the bank's confirmation"
+// @Failure      400  {object}  map[string]string
+// @Failure      402  {object}  paymentDeclinedResponse
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      502  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /orders/{id}/pay [post]
+func (h *Handler) PayOrder(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid order id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req domain.PayOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.Logger.Error("invalid pay order request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	payment, err := h.Order.Pay(r.Context(), id, principal.UserID, req)
+	if err != nil {
+		h.Logger.Error("failed to pay order", "id", id, "userID", principal.UserID, "err", err)
+		var declined *domain.PaymentDeclinedError
+		if errors.As(err, &declined) {
+			w.WriteHeader(http.StatusPaymentRequired)
+			json.NewEncoder(w).Encode(paymentDeclinedResponse{Error: "payment declined", Reason: declined.Reason})
+			return
+		}
+		errStr := err.Error()
+		switch {
+		case strings.Contains(errStr, "payment token required"):
+			w.WriteHeader(http.StatusBadRequest)
+		case strings.Contains(errStr, "order not found"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(errStr, "order cannot be paid"),
+			strings.Contains(errStr, "payment already in progress"),
+			strings.Contains(errStr, "payment status changed concurrently"):
+			w.WriteHeader(http.StatusConflict)
+		case strings.Contains(errStr, "payment provider unavailable"):
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	if payment.Status == domain.PaymentPending {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(payment)
+}
+
+type paymentDeclinedResponse struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// maxWebhookBody — предел тела вебхука платёжного шлюза.
+const maxWebhookBody = 64 << 10
+
+// paymentWebhookTolerance — насколько старой может быть подпись вебхука.
+const paymentWebhookTolerance = 5 * time.Minute
+
+// PaymentWebhook godoc
+// @Summary      Payment provider webhook
+// @Description  Receives payment status notifications from the payment provider. The body must be signed with the shared secret in the X-Payment-Signature header ("t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">"); signatures older than 5 minutes are rejected. Repeated notifications are ignored
+// @Tags         payments
+// @Accept       json
+// @Param        X-Payment-Signature  header  string               true  "Signature"
+// @Param        event                body    domain.PaymentEvent  true  "Payment event"
+// @Success      200
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /payments/webhook [post]
+func (h *Handler) PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		h.Logger.Error("invalid payment webhook body", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(h.PaymentWebhookSecret) == 0 {
+		h.Logger.Error("payment webhook secret is not configured")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	sig := r.Header.Get(integration.PaymentSignatureHeader)
+	if err := integration.VerifyPaymentWebhook(h.PaymentWebhookSecret, sig, body, time.Now(), paymentWebhookTolerance); err != nil {
+		h.Logger.Error("rejected payment webhook", "err", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var evt domain.PaymentEvent
+	if err := json.Unmarshal(body, &evt); err != nil || evt.PaymentID == "" {
+		h.Logger.Error("invalid payment webhook", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := h.Order.HandlePaymentEvent(r.Context(), evt); err != nil {
+		h.Logger.Error("failed to handle payment event", "paymentID", evt.PaymentID, "status", evt.Status, "err", err)
+		if strings.Contains(err.Error(), "payment not found") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// Шлюз повторит уведомление
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListOrderTransitions godoc
+// @Summary      Order status history
+// @Description  Returns status transitions of an order, oldest first (admin only)
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      int  true  "Order ID"
+// @Success      200  {array}   domain.OrderTransition
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /orders/{id}/transitions [get]
+func (h *Handler) ListOrderTransitions(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid order id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	transitions, err := h.Order.Transitions(r.Context(), id)
+	if err != nil {
+		h.Logger.Error("failed to list order transitions", "id", id, "err", err)
+		if strings.Contains(err.Error(), "order not found") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(transitions)
+}
+
+// GetMe godoc
+// @Summary      Get current user's profile
+// @Description  Returns the stored profile of the authenticated user
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  domain.User
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /me [get]
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	user, err := h.User.GetByID(r.Context(), principal.UserID)
+	if err != nil {
+		h.Logger.Error("failed to get user", "userID", principal.UserID, "err", err)
+		switch {
+		case strings.Contains(err.Error(), "user not found"):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
+
+// RequestReturn godoc
+// @Summary      Request a return
+// @Description  Creates a return request for items of a delivered order. Quantities are limited to what was bought minus what is already in non-rejected returns. The refund is the items' order prices, shipping is not refunded
+// @Tags         returns
+// @Accept       json
+// @Produce      json
+// @Param        Idempotency-Key  header  string                true  "Repeated requests with the same key return the first response"
+// @Param        id               path    int                   true  "Order ID"
+// @Param        return           body    domain.ReturnRequest  true  "Order items, quantities and reason"
+// @Success      201  {object}  domain.Return
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /orders/{id}/returns [post]
+func (h *Handler) RequestReturn(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid order id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req domain.ReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.Error("invalid return request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ret, err := h.Return.Request(r.Context(), id, principal.UserID, req)
+	if err != nil {
+		h.Logger.Error("failed to request return", "orderID", id, "userID", principal.UserID, "err", err)
+		w.WriteHeader(returnErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ret)
+}
+
+// ListOrderReturns godoc
+// @Summary      List returns of an order
+// @Description  Returns all return requests of the order, oldest first. Customers see only their own orders
+// @Tags         returns
+// @Produce      json
+// @Param        id  path  int  true  "Order ID"
+// @Success      200  {array}   domain.Return
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /orders/{id}/returns [get]
+func (h *Handler) ListOrderReturns(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid order id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	returns, err := h.Return.ListByOrder(r.Context(), id, principal.UserID, principal.IsAdmin())
+	if err != nil {
+		h.Logger.Error("failed to list order returns", "orderID", id, "userID", principal.UserID, "err", err)
+		w.WriteHeader(returnErrorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(returns)
+}
+
+// GetReturn godoc
+// @Summary      Get a return
+// @Description  Customers see only their own returns, admins see any
+// @Tags         returns
+// @Produce      json
+// @Param        id  path  int  true  "Return ID"
+// @Success      200  {object}  domain.Return
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /returns/{id} [get]
+func (h *Handler) GetReturn(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid return id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ret, err := h.Return.Get(r.Context(), id, principal.UserID, principal.IsAdmin())
+	if err != nil {
+		h.Logger.Error("failed to get return", "id", id, "userID", principal.UserID, "err", err)
+		w.WriteHeader(returnErrorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(ret)
+}
+
+// ListReturns godoc
+// @Summary      List returns
+// @Description  Returns a page of return requests, newest first (admin only)
+// @Tags         returns
+// @Produce      json
+// @Param        status  query     string  false  "requested, approved, rejected or refunded"
+// @Param        cursor  query     string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Success      200  {object}  domain.ReturnList
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /returns [get]
+func (h *Handler) ListReturns(w http.ResponseWriter, r *http.Request) {
+	page, err := h.parsePage(r.URL.Query(), 20)
+	if err != nil {
+		h.Logger.Error("invalid return list request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Return.List(r.Context(), r.URL.Query().Get("status"), page)
+	if err != nil {
+		h.Logger.Error("failed to list returns", "err", err)
+		w.WriteHeader(returnErrorStatus(err))
+		return
+	}
+	list.NextCursor = h.Cursors.Encode(list.Next)
+	json.NewEncoder(w).Encode(list)
+}
+
+// ApproveReturn godoc
+// @Summary      Approve a return
+// @Description  Puts the returned books back in stock and refunds their price through the payment provider (admin only). If the provider fails, the return stays approved and the response is 502; approving it again retries only the refund
+// @Tags         returns
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                    true   "Return ID"
+// @Param        resolve  body  returnResolveRequest  false  "Comment"
+// @Success      200  {object}  domain.Return
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      502  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /returns/{id}/approve [post]
+func (h *Handler) ApproveReturn(w http.ResponseWriter, r *http.Request) {
+	h.resolveReturn(w, r, h.Return.Approve)
+}
+
+// RejectReturn godoc
+// @Summary      Reject a return
+// @Description  Rejects a requested return (admin only); the reason is required and shown to the customer
+// @Tags         returns
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                    true  "Return ID"
+// @Param        resolve  body  returnResolveRequest  true  "Rejection reason"
+// @Success      200  {object}  domain.Return
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /returns/{id}/reject [post]
+func (h *Handler) RejectReturn(w http.ResponseWriter, r *http.Request) {
+	h.resolveReturn(w, r, h.Return.Reject)
+}
+
+type returnResolveRequest struct {
+	Reason string `json:"reason"`
+}
+
+// resolveReturn разбирает запрос админа и применяет решение по возврату.
+func (h *Handler) resolveReturn(w http.ResponseWriter, r *http.Request, resolve func(ctx context.Context, id int, actor, note string) (*domain.Return, error)) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.Logger.Error("invalid return id", "id", idStr, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req returnResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.Logger.Error("invalid return resolve request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ret, err := resolve(r.Context(), id, principal.UserID, req.Reason)
+	if err != nil {
+		h.Logger.Error("failed to resolve return", "id", id, "actor", principal.UserID, "err", err)
+		w.WriteHeader(returnErrorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(ret)
+}
+
+// returnErrorStatus сопоставляет ошибки возвратов HTTP-статусам.
+func returnErrorStatus(err error) int {
+	errStr := err.Error()
+	switch {
+	case strings.Contains(errStr, "invalid return"),
+		strings.Contains(errStr, "invalid status"):
+		return http.StatusBadRequest
+	case strings.Contains(errStr, "order not found"),
+		strings.Contains(errStr, "return not found"):
+		return http.StatusNotFound
+	case strings.Contains(errStr, "order cannot be returned"),
+		strings.Contains(errStr, "return quantity exceeds remaining"),
+		strings.Contains(errStr, "return cannot be"),
+		strings.Contains(errStr, "status changed concurrently"):
+		return http.StatusConflict
+	case strings.Contains(errStr, "payment refund failed"):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// CreatePromoCode godoc
+// @Summary      Create a promo code
+// @Description  Creates a promo code (admin only). kind is percent (percent 1-100) or fixed (amount). The code is stored upper-case. Scope is all books, one category_id or one book_id; starts_at/ends_at bound the validity window [starts_at, ends_at); per_user_limit and usage_limit count placed orders, 0 means unlimited. Percent discounts are rounded down to a kopeck
+// @Tags         promo-codes
+// @Accept       json
+// @Produce      json
+// @Param        promo  body      domain.PromoCode  true  "Promo code"
+// @Success      201  {object}  domain.PromoCode
+// @Failure      400  {object}  validationResponse
+// @Failure      409  {object}  map[string]string  "Code already exists"
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /promo-codes [post]
+func (h *Handler) CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	var p domain.PromoCode
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		h.Logger.Error("invalid promo code", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := h.Promo.Create(r.Context(), &p); err != nil {
+		h.Logger.Error("failed to create promo code", "err", err)
+		h.writePromoError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+// UpdatePromoCode godoc
+// @Summary      Update a promo code
+// @Description  Replaces the terms of a promo code (admin only). The code itself and used_count do not change; set active to false to stop new redemptions
+// @Tags         promo-codes
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int               true  "Promo code ID"
+// @Param        promo  body      domain.PromoCode  true  "Promo code"
+// @Success      200  {object}  domain.PromoCode
+// @Failure      400  {object}  validationResponse
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /promo-codes/{id} [put]
+func (h *Handler) UpdatePromoCode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.Logger.Error("invalid promo code id", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var p domain.PromoCode
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		h.Logger.Error("invalid promo code", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p.ID = id
+	if err := h.Promo.Update(r.Context(), &p); err != nil {
+		h.Logger.Error("failed to update promo code", "id", id, "err", err)
+		h.writePromoError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// GetPromoCode godoc
+// @Summary      Get a promo code
+// @Description  Returns a promo code with its usage count (admin only)
+// @Tags         promo-codes
+// @Produce      json
+// @Param        id   path      int  true  "Promo code ID"
+// @Success      200  {object}  domain.PromoCode
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /promo-codes/{id} [get]
+func (h *Handler) GetPromoCode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.Logger.Error("invalid promo code id", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p, err := h.Promo.Get(r.Context(), id)
+	if err != nil {
+		h.Logger.Error("failed to get promo code", "id", id, "err", err)
+		h.writePromoError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// ListPromoCodes godoc
+// @Summary      List promo codes
+// @Description  Returns a page of promo codes, newest first (admin only)
+// @Tags         promo-codes
+// @Produce      json
+// @Param        cursor  query     string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Success      200  {object}  domain.PromoCodeList
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /promo-codes [get]
+func (h *Handler) ListPromoCodes(w http.ResponseWriter, r *http.Request) {
+	page, err := h.parsePage(r.URL.Query(), 20)
+	if err != nil {
+		h.Logger.Error("invalid promo code list request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Promo.List(r.Context(), page)
+	if err != nil {
+		h.Logger.Error("failed to list promo codes", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	list.NextCursor = h.Cursors.Encode(list.Next)
+	json.NewEncoder(w).Encode(list)
+}
+
+// writePromoError отвечает на ошибку управления промокодами.
+func (h *Handler) writePromoError(w http.ResponseWriter, err error) {
+	var invalid *domain.ValidationError
+	if errors.As(err, &invalid) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(validationResponse{Error: "invalid promo code", Fields: invalid.Fields})
+		return
+	}
+	errStr := err.Error()
+	switch {
+	case strings.Contains(errStr, "promo code not found"):
+		w.WriteHeader(http.StatusNotFound)
+	case strings.Contains(errStr, "promo code already exists"):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// CreateTaxRate godoc
+// @Summary      Add a tax rate version
+// @Description  Adds a VAT rate for a shipping country, optionally narrowed to a region and/or book category_id (admin only). Rates are versioned: a new row with a later effective_from replaces the previous rate for the same country, region and category; without effective_from it applies immediately. The most specific rate wins: region and category, region, category, whole country
+// @Tags         tax-rates
+// @Accept       json
+// @Produce      json
+// @Param        rate  body      domain.TaxRate  true  "Tax rate"
+// @Success      201  {object}  domain.TaxRate
+// @Failure      400  {object}  validationResponse
+// @Failure      404  {object}  map[string]string  "Category not found"
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /tax-rates [post]
+func (h *Handler) CreateTaxRate(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.principal(w, r)
+	if !ok {
+		return
+	}
+	var rate domain.TaxRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		h.Logger.Error("invalid tax rate", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rate.Actor = principal.UserID
+	if err := h.Tax.Create(r.Context(), &rate); err != nil {
+		h.Logger.Error("failed to create tax rate", "err", err)
+		var invalid *domain.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(validationResponse{Error: "invalid tax rate", Fields: invalid.Fields})
+		case strings.Contains(err.Error(), "category not found"):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rate)
+}
+
+// ListTaxRates godoc
+// @Summary      List tax rates
+// @Description  Returns a page of all tax rate versions, newest first (admin only)
+// @Tags         tax-rates
+// @Produce      json
+// @Param        cursor  query     string  false  "Opaque cursor from next_cursor of the previous page"
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Success      200  {object}  domain.TaxRateList
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Security     ApiKeyAuth
+// @Router       /tax-rates [get]
+func (h *Handler) ListTaxRates(w http.ResponseWriter, r *http.Request) {
+	page, err := h.parsePage(r.URL.Query(), 20)
+	if err != nil {
+		h.Logger.Error("invalid tax rate list request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Tax.List(r.Context(), page)
+	if err != nil {
+		h.Logger.Error("failed to list tax rates", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	list.NextCursor = h.Cursors.Encode(list.Next)
+	json.NewEncoder(w).Encode(list)
 }
- 
\ No newline at end of file
@@ -0,0 +1,455 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+	"github.com/yourorg/bookshop/internal/mocks"
+	"golang.org/x/exp/slog"
+)
+
+func newTestHandler() *Handler {
+	return NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestParseBookFilter_Defaults(t *testing.T) {
+	f, err := newTestHandler().parseBookFilter(url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, f.Limit)
+	assert.False(t, f.IncludeSoldOut)
+	assert.Nil(t, f.MinPrice)
+}
+
+func TestParseBookFilter_AllParams(t *testing.T) {
+	h := newTestHandler()
+	cursor := h.Cursors.Encode(&domain.Cursor{Sort: "-price", Value: "150", ID: 7})
+	q, _ := url.ParseQuery("category_id=1&category_id=2&min_price=100.5&max_price=900&min_year=1990&max_year=2000&author=%20Толстой%20&in_stock=false&sort=-price&limit=20&with_total=true&cursor=" + cursor)
+	f, err := h.parseBookFilter(q)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, f.CategoryIDs)
+	assert.Equal(t, domain.NewMoney(10050), *f.MinPrice)
+	assert.Equal(t, domain.NewMoney(90000), *f.MaxPrice)
+	assert.Equal(t, 1990, *f.MinYear)
+	assert.Equal(t, 2000, *f.MaxYear)
+	assert.Equal(t, "Толстой", f.Author)
+	assert.True(t, f.IncludeSoldOut)
+	assert.Equal(t, "-price", f.Sort)
+	assert.Equal(t, 20, f.Limit)
+	assert.Equal(t, &domain.Cursor{Sort: "-price", Value: "150", ID: 7}, f.After)
+	assert.True(t, f.WithTotal)
+}
+
+func TestParseBookFilter_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		"category_id=abc",
+		"min_price=-1",
+		"max_year=soon",
+		"in_stock=maybe",
+		"sort=inventory",
+		"limit=1000",
+		"with_total=maybe",
+		"cursor=garbage",
+	} {
+		q, _ := url.ParseQuery(raw)
+		_, err := newTestHandler().parseBookFilter(q)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestCursorCodec_RoundTrip(t *testing.T) {
+	codec := NewCursorCodec([]byte("secret"))
+	cur := &domain.Cursor{Sort: "title", Value: "Анна Каренина", ID: 42}
+	encoded := codec.Encode(cur)
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, cur, decoded)
+
+	empty, err := codec.Decode("")
+	require.NoError(t, err)
+	assert.Nil(t, empty)
+	assert.Equal(t, "", codec.Encode(nil))
+}
+
+func TestCursorCodec_RejectsTampering(t *testing.T) {
+	codec := NewCursorCodec([]byte("secret"))
+	encoded := codec.Encode(&domain.Cursor{ID: 42})
+
+	_, err := NewCursorCodec([]byte("other-secret")).Decode(encoded)
+	assert.Error(t, err)
+
+	forged := NewCursorCodec([]byte("other-secret")).Encode(&domain.Cursor{ID: 1})
+	_, err = codec.Decode(forged)
+	assert.Error(t, err)
+
+	payload, sig, _ := strings.Cut(encoded, ".")
+	_, err = codec.Decode(payload + "x." + sig)
+	assert.Error(t, err)
+}
+
+func TestPlaceOrder_OutOfStock(t *testing.T) {
+	orders := new(mocks.OrderService)
+	outOfStock := &domain.OutOfStockError{Items: []domain.OutOfStockItem{{BookID: 42, Requested: 3, Available: 1}}}
+	orders.On("Create", mock.Anything, "user-1", domain.PlaceOrderRequest{}).Return(nil, fmt.Errorf("create order: %w", outOfStock))
+	h := newTestHandler()
+	h.Order = orders
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/orders", nil)
+	h.PlaceOrder(rw, req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"})))
+
+	assert.Equal(t, 409, rw.Code)
+	var body outOfStockResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, outOfStock.Items, body.Items)
+}
+
+func newTestRouter(h *Handler) http.Handler {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return h.Router(
+		NewAuthMiddleware(new(mocks.KeycloakClient), nil, logger),
+		NewIdempotencyMiddleware(newMemCache(), logger),
+		NewGuestCartMiddleware(h.Cart, []byte("secret"), logger),
+	)
+}
+
+func TestSetCartItems_GuestBatch(t *testing.T) {
+	cart := new(mocks.CartService)
+	items := []domain.CartItemQuantity{{BookID: 1, Quantity: 2}, {BookID: 2, Quantity: 0}}
+	cart.On("SetItems", mock.Anything, mock.AnythingOfType("string"), items).Return(nil)
+	cart.On("GetByUserID", mock.Anything, mock.AnythingOfType("string")).Return(&domain.Cart{Items: []domain.CartItem{{BookID: 1, Quantity: 2}}}, nil)
+	h := newTestHandler()
+	h.Cart = cart
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/cart/items:batch", strings.NewReader(`{"items":[{"book_id":1,"quantity":2},{"book_id":2,"quantity":0}]}`))
+	newTestRouter(h).ServeHTTP(rw, req)
+
+	require.Equal(t, 200, rw.Code)
+	var body cartResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, 2, body.Items[0].Quantity)
+	cart.AssertExpectations(t)
+}
+
+func TestSetCartItemQuantity_OutOfStock(t *testing.T) {
+	cart := new(mocks.CartService)
+	outOfStock := &domain.OutOfStockError{Items: []domain.OutOfStockItem{{BookID: 7, Requested: 5, Available: 2}}}
+	cart.On("SetItemQuantity", mock.Anything, "user-1", 7, 5).Return(outOfStock)
+	h := newTestHandler()
+	h.Cart = cart
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/cart/items/7", strings.NewReader(`{"quantity":5}`))
+	req = req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"}))
+	ctx := chi.NewRouteContext()
+	ctx.URLParams.Add("book_id", "7")
+	h.SetCartItemQuantity(rw, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, ctx)))
+
+	assert.Equal(t, 409, rw.Code)
+	var body outOfStockResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, outOfStock.Items, body.Items)
+}
+
+func TestSetCartItemQuantity_MissingQuantity(t *testing.T) {
+	h := newTestHandler()
+	h.Cart = new(mocks.CartService)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/cart/items/7", strings.NewReader(`{}`))
+	newTestRouter(h).ServeHTTP(rw, req)
+	assert.Equal(t, 400, rw.Code)
+}
+
+func TestPlaceOrder_StalePrices(t *testing.T) {
+	orders := new(mocks.OrderService)
+	stale := &domain.StaleCartError{Items: []domain.PriceChange{{BookID: 42, PriceSnapshot: domain.NewMoney(1000), Price: domain.NewMoney(1250)}}}
+	orders.On("Create", mock.Anything, "user-1", domain.PlaceOrderRequest{}).Return(nil, stale)
+	orders.On("Create", mock.Anything, "user-1", domain.PlaceOrderRequest{ConfirmPrices: true}).Return(&domain.Order{ID: 1}, nil)
+	h := newTestHandler()
+	h.Order = orders
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/orders", nil)
+	h.PlaceOrder(rw, req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"})))
+	assert.Equal(t, 409, rw.Code)
+	var body stalePricesResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, stale.Items, body.Items)
+
+	rw = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/orders", strings.NewReader(`{"confirm_prices": true}`))
+	h.PlaceOrder(rw, req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"})))
+	assert.Equal(t, 201, rw.Code)
+}
+
+func TestPlaceOrder_InvalidShipping(t *testing.T) {
+	orders := new(mocks.OrderService)
+	invalid := &domain.ValidationError{Fields: []domain.FieldError{{Field: "phone", Reason: "required"}}}
+	orders.On("Create", mock.Anything, "user-1", domain.PlaceOrderRequest{}).Return(nil, invalid)
+	h := newTestHandler()
+	h.Order = orders
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/orders", nil)
+	h.PlaceOrder(rw, req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"})))
+	assert.Equal(t, 400, rw.Code)
+	var body validationResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, invalid.Fields, body.Fields)
+}
+
+func TestApplyCartPromo_StatusMapping(t *testing.T) {
+	cart := new(mocks.CartService)
+	guest := mock.AnythingOfType("string")
+	cart.On("ApplyPromo", mock.Anything, guest, "sale10").Return(&domain.Cart{PromoCode: "SALE10", Discount: domain.NewMoney(1000)}, nil)
+	cart.On("ApplyPromo", mock.Anything, guest, "late").Return(nil, &domain.PromoError{Code: "LATE", Reason: domain.PromoExpired})
+	cart.On("ApplyPromo", mock.Anything, guest, "nope").Return(nil, fmt.Errorf("promo code not found: %w", errors.New("no rows")))
+	cart.On("ApplyPromo", mock.Anything, guest, "").Return(nil, fmt.Errorf("invalid promo code: %w", errors.New("code is required")))
+	cart.On("RemovePromo", mock.Anything, guest).Return(nil)
+	h := newTestHandler()
+	h.Cart = cart
+	router := newTestRouter(h)
+
+	apply := func(code string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest("POST", "/cart/promo", strings.NewReader(`{"code":"`+code+`"}`)))
+		return rw
+	}
+	rw := apply("sale10")
+	require.Equal(t, 200, rw.Code)
+	var body cartResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, "SALE10", body.PromoCode)
+
+	rw = apply("late")
+	assert.Equal(t, 409, rw.Code)
+	var promoBody promoErrorResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&promoBody))
+	assert.Equal(t, promoErrorResponse{Error: "promo code cannot be applied", Code: "LATE", Reason: domain.PromoExpired}, promoBody)
+
+	assert.Equal(t, 404, apply("nope").Code)
+	assert.Equal(t, 400, apply("").Code)
+
+	// DELETE /cart/promo не путается с DELETE /cart/{book_id}
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("DELETE", "/cart/promo", nil))
+	assert.Equal(t, 204, rw.Code)
+	cart.AssertCalled(t, "RemovePromo", mock.Anything, guest)
+}
+
+func TestPlaceOrder_PromoNoLongerApplies(t *testing.T) {
+	orders := new(mocks.OrderService)
+	orders.On("Create", mock.Anything, "user-1", domain.PlaceOrderRequest{}).Return(nil, &domain.PromoError{Code: "SALE10", Reason: domain.PromoUserLimit})
+	h := newTestHandler()
+	h.Order = orders
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/orders", nil)
+	h.PlaceOrder(rw, req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"})))
+	assert.Equal(t, 409, rw.Code)
+	var body promoErrorResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, domain.PromoUserLimit, body.Reason)
+}
+
+func TestGetOrder(t *testing.T) {
+	orders := new(mocks.OrderService)
+	orders.On("Get", mock.Anything, 5, "user-1", false).Return(&domain.Order{ID: 5, UserID: "user-1", Total: domain.NewMoney(35000)}, nil)
+	orders.On("Get", mock.Anything, 6, "user-1", false).Return(nil, errors.New("order not found: order belongs to another user"))
+	h := newTestHandler()
+	h.Order = orders
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req = req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"}))
+		r := chi.NewRouter()
+		r.Get("/orders/{id}", h.GetOrder)
+		r.ServeHTTP(rw, req)
+		return rw
+	}
+	rw := get("/orders/5")
+	assert.Equal(t, 200, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"total":350`)
+	assert.Equal(t, 404, get("/orders/6").Code)
+	assert.Equal(t, 400, get("/orders/abc").Code)
+}
+
+func TestPayOrder_StatusMapping(t *testing.T) {
+	orders := new(mocks.OrderService)
+	paid := domain.PayOrderRequest{PaymentToken: "tok_ok"}
+	orders.On("Pay", mock.Anything, 1, "user-1", paid).Return(&domain.Payment{ID: 1, Status: domain.PaymentCaptured}, nil)
+	orders.On("Pay", mock.Anything, 2, "user-1", paid).Return(&domain.Payment{ID: 2, Status: domain.PaymentPending}, nil)
+	orders.On("Pay", mock.Anything, 3, "user-1", paid).Return(nil, &domain.PaymentDeclinedError{Reason: "insufficient_funds"})
+	orders.On("Pay", mock.Anything, 4, "user-1", paid).Return(nil, errors.New("order cannot be paid: status is cancelled"))
+	orders.On("Pay", mock.Anything, 5, "user-1", paid).Return(nil, errors.New("payment provider unavailable: context deadline exceeded"))
+	h := newTestHandler()
+	h.Order = orders
+
+	pay := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"payment_token":"tok_ok"}`))
+		req = req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1"}))
+		r := chi.NewRouter()
+		r.Post("/orders/{id}/pay", h.PayOrder)
+		r.ServeHTTP(rw, req)
+		return rw
+	}
+	assert.Equal(t, 200, pay("/orders/1/pay").Code)
+	assert.Equal(t, 202, pay("/orders/2/pay").Code)
+	rw := pay("/orders/3/pay")
+	assert.Equal(t, 402, rw.Code)
+	var body paymentDeclinedResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, "insufficient_funds", body.Reason)
+	assert.Equal(t, 409, pay("/orders/4/pay").Code)
+	assert.Equal(t, 502, pay("/orders/5/pay").Code)
+}
+
+func TestPaymentWebhook_Signature(t *testing.T) {
+	orders := new(mocks.OrderService)
+	evt := domain.PaymentEvent{PaymentID: "pay_1", Status: domain.PaymentAuthorized}
+	orders.On("HandlePaymentEvent", mock.Anything, evt).Return(nil).Once()
+	h := newTestHandler()
+	h.Order = orders
+	h.PaymentWebhookSecret = []byte("whsec")
+
+	body := `{"payment_id":"pay_1","status":"authorized"}`
+	send := func(sig string) int {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/payments/webhook", strings.NewReader(body))
+		req.Header.Set(integration.PaymentSignatureHeader, sig)
+		h.PaymentWebhook(rw, req)
+		return rw.Code
+	}
+	assert.Equal(t, 401, send(""))
+	assert.Equal(t, 401, send(integration.SignPaymentWebhook([]byte("other"), []byte(body), time.Now())))
+	assert.Equal(t, 401, send(integration.SignPaymentWebhook(h.PaymentWebhookSecret, []byte(body), time.Now().Add(-time.Hour))))
+	assert.Equal(t, 200, send(integration.SignPaymentWebhook(h.PaymentWebhookSecret, []byte(body), time.Now())))
+	orders.AssertExpectations(t)
+}
+
+func TestReturns_StatusMapping(t *testing.T) {
+	returns := new(mocks.ReturnService)
+	req := domain.ReturnRequest{Items: []domain.ReturnItemRequest{{OrderItemID: 11, Quantity: 2}}, Reason: "брак"}
+	returns.On("Request", mock.Anything, 7, "user-1", req).Return(&domain.Return{ID: 3, OrderID: 7, Status: domain.ReturnRequested}, nil)
+	returns.On("Request", mock.Anything, 8, "user-1", req).Return(nil, errors.New("return quantity exceeds remaining: order item 11: requested 2, remaining 1"))
+	returns.On("Approve", mock.Anything, 3, "admin-1", "").Return(nil, errors.New("payment refund failed: gateway down"))
+	returns.On("Reject", mock.Anything, 4, "admin-1", "").Return(nil, errors.New("invalid return: rejection reason is required"))
+	h := newTestHandler()
+	h.Return = returns
+
+	do := func(method, path, body, userID string) int {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: userID}))
+		r := chi.NewRouter()
+		r.Post("/orders/{id}/returns", h.RequestReturn)
+		r.Post("/returns/{id}/approve", h.ApproveReturn)
+		r.Post("/returns/{id}/reject", h.RejectReturn)
+		r.ServeHTTP(rw, req)
+		return rw.Code
+	}
+	body := `{"items": [{"order_item_id": 11, "quantity": 2}], "reason": "брак"}`
+	assert.Equal(t, 201, do("POST", "/orders/7/returns", body, "user-1"))
+	assert.Equal(t, 409, do("POST", "/orders/8/returns", body, "user-1"))
+	assert.Equal(t, 400, do("POST", "/orders/7/returns", "{", "user-1"))
+	assert.Equal(t, 502, do("POST", "/returns/3/approve", "", "admin-1"))
+	assert.Equal(t, 400, do("POST", "/returns/4/reject", "", "admin-1"))
+}
+
+func TestBookPrices_StatusMapping(t *testing.T) {
+	books := new(mocks.BookService)
+	sale := domain.SchedulePriceRequest{Price: domain.NewMoney(29900), Reason: "распродажа"}
+	books.On("SchedulePrice", mock.Anything, 1, sale, "admin-1").Return(&domain.BookPrice{ID: 5, BookID: 1, Price: sale.Price}, nil)
+	books.On("SchedulePrice", mock.Anything, 2, sale, "admin-1").Return(nil, fmt.Errorf("book not found: %w", errors.New("no rows")))
+	books.On("SchedulePrice", mock.Anything, 3, sale, "admin-1").Return(nil, &domain.ValidationError{Fields: []domain.FieldError{{Field: "effective_from", Reason: "must not be in the past"}}})
+	books.On("CancelPrice", mock.Anything, 1, 5).Return(fmt.Errorf("cancel price: %w", errors.New("price already in effect")))
+	books.On("CancelPrice", mock.Anything, 1, 6).Return(nil)
+	h := newTestHandler()
+	h.Book = books
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "admin-1"}))
+		r := chi.NewRouter()
+		r.Post("/books/{id}/prices", h.ScheduleBookPrice)
+		r.Delete("/books/{id}/prices/{price_id}", h.CancelBookPrice)
+		r.ServeHTTP(rw, req)
+		return rw
+	}
+	body := `{"price": 299.00, "reason": "распродажа"}`
+	assert.Equal(t, 201, do("POST", "/books/1/prices", body).Code)
+	assert.Equal(t, 404, do("POST", "/books/2/prices", body).Code)
+	rw := do("POST", "/books/3/prices", body)
+	assert.Equal(t, 400, rw.Code)
+	var invalid validationResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&invalid))
+	assert.Equal(t, "effective_from", invalid.Fields[0].Field)
+	assert.Equal(t, 409, do("DELETE", "/books/1/prices/5", "").Code)
+	assert.Equal(t, 204, do("DELETE", "/books/1/prices/6", "").Code)
+}
+
+// История цен доступна без входа, id админа в ответ не попадает.
+func TestListBookPrices_HidesActor(t *testing.T) {
+	books := new(mocks.BookService)
+	books.On("PriceHistory", mock.Anything, 1, mock.Anything).Return(&domain.BookPriceList{Items: []*domain.BookPrice{
+		{ID: 5, BookID: 1, Price: domain.NewMoney(29900), Reason: "распродажа", Actor: "admin-1"},
+	}}, nil)
+	h := newTestHandler()
+	h.Book = books
+
+	rw := httptest.NewRecorder()
+	r := chi.NewRouter()
+	r.Get("/books/{id}/prices", h.ListBookPrices)
+	r.ServeHTTP(rw, httptest.NewRequest("GET", "/books/1/prices", nil))
+	require.Equal(t, 200, rw.Code)
+	assert.NotContains(t, rw.Body.String(), "admin-1")
+	assert.Contains(t, rw.Body.String(), "распродажа")
+}
+
+func TestTaxRates_StatusMapping(t *testing.T) {
+	taxes := new(mocks.TaxService)
+	taxes.On("Create", mock.Anything, mock.MatchedBy(func(r *domain.TaxRate) bool { return r.Country == "RU" })).Return(nil)
+	taxes.On("Create", mock.Anything, mock.MatchedBy(func(r *domain.TaxRate) bool { return r.Country == "KZ" })).Return(fmt.Errorf("category not found: %w", errors.New("fk")))
+	taxes.On("Create", mock.Anything, mock.MatchedBy(func(r *domain.TaxRate) bool { return r.Country == "US" })).Return(&domain.ValidationError{Fields: []domain.FieldError{{Field: "country", Reason: "shipping to US is not supported"}}})
+	h := newTestHandler()
+	h.Tax = taxes
+
+	do := func(body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/tax-rates", strings.NewReader(body))
+		req = req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: "admin-1"}))
+		h.CreateTaxRate(rw, req)
+		return rw
+	}
+	rw := do(`{"country": "RU", "rate": 10}`)
+	assert.Equal(t, 201, rw.Code)
+	var created domain.TaxRate
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&created))
+	assert.Equal(t, domain.Percent(1000), created.Rate)
+	assert.Equal(t, "admin-1", created.Actor)
+	assert.Equal(t, 404, do(`{"country": "KZ", "category_id": 99, "rate": 12}`).Code)
+	rw = do(`{"country": "US", "rate": 10}`)
+	assert.Equal(t, 400, rw.Code)
+	var invalid validationResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&invalid))
+	assert.Equal(t, "country", invalid.Fields[0].Field)
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/yourorg/bookshop/internal/integration"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyTTL — сколько хранится ответ на первый запрос.
+	idempotencyTTL = 24 * 60 * 60
+	// idempotencyLockTTL — сколько живёт метка «запрос выполняется», если
+	// процесс упал, не дописав ответ.
+	idempotencyLockTTL   = 120
+	maxIdempotencyKeyLen = 255
+)
+
+// IdempotencyMiddleware повторяет ответ на первый запрос с тем же
+// Idempotency-Key вместо повторного выполнения. Ключи раздельные для
+// каждого пользователя (или гостя), поэтому middleware ставится после
+// JWTAuth или GuestCartMiddleware.
+type IdempotencyMiddleware struct {
+	cache  integration.RedisCache
+	Logger *slog.Logger
+}
+
+func NewIdempotencyMiddleware(cache integration.RedisCache, logger *slog.Logger) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{cache: cache, Logger: logger}
+}
+
+// idempotencyRecord хранится в Redis. Fingerprint защищает от повторного
+// использования ключа с другим запросом.
+type idempotencyRecord struct {
+	Done        bool   `json:"done"`
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+func (m *IdempotencyMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+		owner, err := CartOwnerFrom(r.Context())
+		if err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.RequestURI()+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+		cacheKey := "idem:" + owner + ":" + hashKey(key)
+
+		pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+		acquired, err := m.cache.SetNX(cacheKey, string(pending), idempotencyLockTTL)
+		if err != nil {
+			// Без Redis не можем гарантировать идемпотентность — не рискуем дублем
+			m.Logger.Error("idempotency store unavailable", "err", err)
+			http.Error(w, "idempotency store unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if !acquired {
+			m.replay(w, cacheKey, fingerprint)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status >= 500 {
+			// Запрос не выполнен — разрешаем повтор с тем же ключом
+			m.cache.Del(cacheKey)
+			return
+		}
+		done, _ := json.Marshal(idempotencyRecord{
+			Done:        true,
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		})
+		if err := m.cache.Set(cacheKey, string(done), idempotencyTTL); err != nil {
+			m.Logger.Error("failed to store idempotent response", "owner", owner, "err", err)
+		}
+	})
+}
+
+func (m *IdempotencyMiddleware) replay(w http.ResponseWriter, cacheKey, fingerprint string) {
+	var rec idempotencyRecord
+	cached, err := m.cache.Get(cacheKey)
+	if err != nil || cached == "" || json.Unmarshal([]byte(cached), &rec) != nil {
+		// Метка истекла между SetNX и Get — клиент может повторить
+		http.Error(w, "request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+	if rec.Fingerprint != fingerprint {
+		http.Error(w, "Idempotency-Key was used with a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if !rec.Done {
+		http.Error(w, "request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+	if rec.ContentType != "" {
+		w.Header().Set("Content-Type", rec.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// responseRecorder пишет ответ клиенту и одновременно запоминает его.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yourorg/bookshop/internal/domain"
+	"golang.org/x/exp/slog"
+)
+
+type memCache struct {
+	mu   sync.Mutex
+	vals map[string]string
+}
+
+func newMemCache() *memCache { return &memCache{vals: map[string]string{}} }
+
+func (c *memCache) Get(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.vals[key]
+	if !ok {
+		return "", errors.New("redis: nil")
+	}
+	return v, nil
+}
+
+func (c *memCache) Set(key, value string, ttlSeconds int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vals[key] = value
+	return nil
+}
+
+func (c *memCache) SetNX(key, value string, ttlSeconds int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.vals[key]; ok {
+		return false, nil
+	}
+	c.vals[key] = value
+	return true, nil
+}
+
+func (c *memCache) Del(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.vals, key)
+	return nil
+}
+
+func (c *memCache) TTL(key string) (int64, error) { return 0, nil }
+
+func idempotentRequest(userID, key, body string) *http.Request {
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotencyHeader, key)
+	}
+	return req.WithContext(WithPrincipal(req.Context(), &domain.Principal{UserID: userID}))
+}
+
+func newTestIdempotency() *IdempotencyMiddleware {
+	return NewIdempotencyMiddleware(newMemCache(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestIdempotency_ReplaysFirstResponse(t *testing.T) {
+	calls := 0
+	h := newTestIdempotency().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":1}`)
+	}))
+
+	first := httptest.NewRecorder()
+	h.ServeHTTP(first, idempotentRequest("user-1", "k1", ""))
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, idempotentRequest("user-1", "k1", ""))
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, `{"id":1}`, second.Body.String())
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotency_InProgressConflict(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := newTestIdempotency().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), idempotentRequest("user-1", "k1", ""))
+		close(done)
+	}()
+	<-started
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, idempotentRequest("user-1", "k1", ""))
+	close(release)
+	<-done
+
+	assert.Equal(t, http.StatusConflict, rw.Code)
+}
+
+func TestIdempotency_KeyReusedWithDifferentBody(t *testing.T) {
+	h := newTestIdempotency().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest("user-1", "k1", `{"book_id":1}`))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, idempotentRequest("user-1", "k1", `{"book_id":2}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, rw.Code)
+}
+
+func TestIdempotency_ServerErrorIsNotStored(t *testing.T) {
+	calls := 0
+	h := newTestIdempotency().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest("user-1", "k1", ""))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, idempotentRequest("user-1", "k1", ""))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+}
+
+func TestIdempotency_KeysArePerUserAndOptional(t *testing.T) {
+	calls := 0
+	h := newTestIdempotency().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest("user-1", "k1", ""))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest("user-2", "k1", ""))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest("user-1", "", ""))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest("user-1", "", ""))
+	assert.Equal(t, 4, calls)
+}
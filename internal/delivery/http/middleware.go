@@ -2,20 +2,43 @@ package http
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/yourorg/bookshop/internal/domain"
 	"github.com/yourorg/bookshop/internal/integration"
+	"github.com/yourorg/bookshop/internal/service"
 	"golang.org/x/exp/slog"
 )
 
+type principalKey struct{}
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// WithPrincipal кладёт аутентифицированного пользователя в контекст.
+func WithPrincipal(ctx context.Context, p *domain.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom достаёт пользователя, положенного JWTAuth. Если middleware
+// не применялся, возвращает ErrUnauthenticated.
+func PrincipalFrom(ctx context.Context) (*domain.Principal, error) {
+	p, ok := ctx.Value(principalKey{}).(*domain.Principal)
+	if !ok || p == nil || p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	return p, nil
+}
+
 type AuthMiddleware struct {
 	keycloak integration.KeycloakClient
+	users    service.UserService
 	Logger   *slog.Logger
 }
 
-func NewAuthMiddleware(keycloak integration.KeycloakClient, logger *slog.Logger) *AuthMiddleware {
-	return &AuthMiddleware{keycloak: keycloak, Logger: logger}
+func NewAuthMiddleware(keycloak integration.KeycloakClient, users service.UserService, logger *slog.Logger) *AuthMiddleware {
+	return &AuthMiddleware{keycloak: keycloak, users: users, Logger: logger}
 }
 
 func (a *AuthMiddleware) JWTAuth(next http.Handler) http.Handler {
@@ -26,35 +49,49 @@ func (a *AuthMiddleware) JWTAuth(next http.Handler) http.Handler {
 			http.Error(w, "missing token", http.StatusUnauthorized)
 			return
 		}
-		userID, email, roles, err := a.keycloak.ValidateToken(r.Context(), token)
+		principal, err := a.keycloak.ValidateToken(r.Context(), token)
 		if err != nil {
 			a.Logger.Warn("invalid token", "err", err)
 			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
-		ctx := context.WithValue(r.Context(), "userID", userID)
-		ctx = context.WithValue(ctx, "email", email)
-		ctx = context.WithValue(ctx, "roles", roles)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		// Синхронизируем профиль с claims токена; ошибка не блокирует запрос
+		if a.users != nil {
+			if _, err := a.users.GetOrCreate(r.Context(), principal.UserID, principal.Email, principal.IsAdmin()); err != nil {
+				a.Logger.Error("failed to sync user profile", "userID", principal.UserID, "err", err)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// OptionalJWTAuth пропускает запросы без Authorization как анонимные, а
+// запросы с токеном проверяет так же, как JWTAuth.
+func (a *AuthMiddleware) OptionalJWTAuth(next http.Handler) http.Handler {
+	withAuth := a.JWTAuth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		withAuth.ServeHTTP(w, r)
 	})
 }
 
 func (a *AuthMiddleware) RequireRole(role string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			roles, ok := r.Context().Value("roles").([]string)
-			if !ok {
-				a.Logger.Warn("no roles in context")
-				http.Error(w, "forbidden", http.StatusForbidden)
+			principal, err := PrincipalFrom(r.Context())
+			if err != nil {
+				a.Logger.Warn("no principal in context")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
 				return
 			}
-			for _, roleVal := range roles {
-				if roleVal == role {
-					next.ServeHTTP(w, r)
-					return
-				}
+			if principal.HasRole(role) {
+				next.ServeHTTP(w, r)
+				return
 			}
-			a.Logger.Warn("forbidden: required role missing", "required", role, "roles", roles)
+			a.Logger.Warn("forbidden: required role missing", "required", role, "roles", principal.Roles)
 			http.Error(w, "forbidden", http.StatusForbidden)
 		})
 	}
@@ -66,4 +103,3 @@ func extractBearerToken(header string) string {
 	}
 	return ""
 }
- 
\ No newline at end of file
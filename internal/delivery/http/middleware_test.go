@@ -5,29 +5,41 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"io"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
 	"github.com/yourorg/bookshop/internal/mocks"
 	"golang.org/x/exp/slog"
 )
 
 func TestAuthMiddleware_JWTAuth_Success(t *testing.T) {
 	keycloak := new(mocks.KeycloakClient)
-	keycloak.On("ValidateToken", mock.Anything, "valid-token").Return("user-1", "user@ex.com", []string{"user"}, nil)
+	exp := time.Now().Add(5 * time.Minute)
+	keycloak.On("ValidateToken", mock.Anything, "valid-token").Return(&domain.Principal{
+		UserID:      "user-1",
+		Email:       "user@ex.com",
+		Roles:       []string{"user"},
+		ClientRoles: map[string][]string{"account": {"view-profile"}},
+		ExpiresAt:   exp,
+	}, nil)
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	mw := NewAuthMiddleware(keycloak, logger)
+	mw := NewAuthMiddleware(keycloak, nil, logger)
 
 	called := false
 	h := mw.JWTAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		called = true
-		ctx := r.Context()
-		require.Equal(t, "user-1", ctx.Value("userID"))
-		require.Equal(t, "user@ex.com", ctx.Value("email"))
-		require.Contains(t, ctx.Value("roles").([]string), "user")
+		p, err := PrincipalFrom(r.Context())
+		require.NoError(t, err)
+		require.Equal(t, "user-1", p.UserID)
+		require.Equal(t, "user@ex.com", p.Email)
+		require.True(t, p.HasRole("user"))
+		require.True(t, p.HasClientRole("account", "view-profile"))
+		require.Equal(t, exp, p.ExpiresAt)
 	}))
 
 	req := httptest.NewRequest("GET", "/", nil)
@@ -42,16 +54,100 @@ func TestAuthMiddleware_JWTAuth_Success(t *testing.T) {
 func TestAuthMiddleware_RequireRole_Forbidden(t *testing.T) {
 	keycloak := new(mocks.KeycloakClient)
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	mw := NewAuthMiddleware(keycloak, logger)
+	mw := NewAuthMiddleware(keycloak, nil, logger)
 
 	h := mw.RequireRole("admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 	}))
 
 	req := httptest.NewRequest("GET", "/", nil)
-	ctx := context.WithValue(req.Context(), "roles", []string{"user"})
+	ctx := WithPrincipal(req.Context(), &domain.Principal{UserID: "user-1", Roles: []string{"user"}})
 	rw := httptest.NewRecorder()
 
 	h.ServeHTTP(rw, req.WithContext(ctx))
 	assert.Equal(t, 403, rw.Code)
 }
+
+func TestAuthMiddleware_RequireRole_NoPrincipal(t *testing.T) {
+	keycloak := new(mocks.KeycloakClient)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mw := NewAuthMiddleware(keycloak, nil, logger)
+
+	h := mw.RequireRole("admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+
+	// Строковые ключи больше не используются и не должны давать доступ
+	req := httptest.NewRequest("GET", "/", nil)
+	ctx := context.WithValue(req.Context(), "roles", []string{"admin"})
+	rw := httptest.NewRecorder()
+
+	h.ServeHTTP(rw, req.WithContext(ctx))
+	assert.Equal(t, 401, rw.Code)
+}
+
+func TestHandler_WithoutAuthMiddleware_Unauthorized(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, logger)
+
+	for _, handler := range []http.HandlerFunc{h.GetCart, h.PlaceOrder, h.ListOrders} {
+		rw := httptest.NewRecorder()
+		require.NotPanics(t, func() {
+			handler(rw, httptest.NewRequest("GET", "/", nil))
+		})
+		assert.Equal(t, 401, rw.Code)
+	}
+}
+
+func TestAuthMiddleware_JWTAuth_SyncsUserProfile(t *testing.T) {
+	keycloak := new(mocks.KeycloakClient)
+	users := new(mocks.UserService)
+	keycloak.On("ValidateToken", mock.Anything, "admin-token").Return(&domain.Principal{
+		UserID: "admin-1",
+		Email:  "admin@ex.com",
+		Roles:  []string{"admin"},
+	}, nil)
+	users.On("GetOrCreate", mock.Anything, "admin-1", "admin@ex.com", true).Return(&domain.User{ID: "admin-1"}, nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mw := NewAuthMiddleware(keycloak, users, logger)
+
+	h := mw.JWTAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rw := httptest.NewRecorder()
+
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, 200, rw.Code)
+	users.AssertExpectations(t)
+}
+
+func TestAuthMiddleware_OptionalJWTAuth_Anonymous(t *testing.T) {
+	keycloak := new(mocks.KeycloakClient)
+	mw := NewAuthMiddleware(keycloak, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	called := false
+	h := mw.OptionalJWTAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		_, err := PrincipalFrom(r.Context())
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/cart", nil))
+	assert.True(t, called)
+	keycloak.AssertNotCalled(t, "ValidateToken", mock.Anything, mock.Anything)
+}
+
+func TestAuthMiddleware_OptionalJWTAuth_InvalidToken(t *testing.T) {
+	keycloak := new(mocks.KeycloakClient)
+	keycloak.On("ValidateToken", mock.Anything, "bad").Return(nil, context.DeadlineExceeded)
+	mw := NewAuthMiddleware(keycloak, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	h := mw.OptionalJWTAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not be called")
+	}))
+	req := httptest.NewRequest("GET", "/cart", nil)
+	req.Header.Set("Authorization", "Bearer bad")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+}
@@ -6,13 +6,17 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-func (h *Handler) Router(auth *AuthMiddleware) http.Handler {
+func (h *Handler) Router(auth *AuthMiddleware, idempotency *IdempotencyMiddleware, guestCart *GuestCartMiddleware) http.Handler {
 	r := chi.NewRouter()
 
 	// --- Публичные ---
 	r.Get("/books", h.ListBooks)
+	r.Get("/books/search", h.SearchBooks)
 	r.Get("/books/{id}", h.GetBook)
+	r.Get("/books/{id}/prices", h.ListBookPrices)
 	r.Get("/categories", h.ListCategories)
+	// Подлинность проверяется подписью шлюза, а не JWT
+	r.Post("/payments/webhook", h.PaymentWebhook)
 
 	// --- Только для админов ---
 	r.Group(func(r chi.Router) {
@@ -24,19 +28,51 @@ func (h *Handler) Router(auth *AuthMiddleware) http.Handler {
 		r.Post("/books", h.CreateBook)
 		r.Put("/books/{id}", h.UpdateBook)
 		r.Delete("/books/{id}", h.DeleteBook)
+		r.Post("/books/{id}/inventory", h.AdjustInventory)
+		r.Get("/books/{id}/inventory/history", h.InventoryHistory)
+		r.Post("/books/{id}/prices", h.ScheduleBookPrice)
+		r.Delete("/books/{id}/prices/{price_id}", h.CancelBookPrice)
+		r.Post("/orders/{id}/transitions", h.TransitionOrder)
+		r.Get("/orders/{id}/transitions", h.ListOrderTransitions)
+		r.Get("/returns", h.ListReturns)
+		r.Post("/returns/{id}/approve", h.ApproveReturn)
+		r.Post("/returns/{id}/reject", h.RejectReturn)
+		r.Get("/promo-codes", h.ListPromoCodes)
+		r.Post("/promo-codes", h.CreatePromoCode)
+		r.Get("/promo-codes/{id}", h.GetPromoCode)
+		r.Put("/promo-codes/{id}", h.UpdatePromoCode)
+		r.Get("/tax-rates", h.ListTaxRates)
+		r.Post("/tax-rates", h.CreateTaxRate)
 	})
 
-	// --- Для аутентифицированных пользователей ---
+	// --- Корзина: пользователи и гости ---
 	r.Group(func(r chi.Router) {
-		r.Use(auth.JWTAuth)
+		r.Use(auth.OptionalJWTAuth)
+		r.Use(guestCart.Handler)
 		r.Get("/cart", h.GetCart)
-		r.Post("/cart", h.AddToCart)
+		r.With(idempotency.Handler).Post("/cart", h.AddToCart)
 		r.Delete("/cart/{book_id}", h.RemoveFromCart)
 		r.Delete("/cart", h.ClearCart)
-		r.Post("/orders", h.PlaceOrder)
+		r.Put("/cart/items/{book_id}", h.SetCartItemQuantity)
+		r.Post("/cart/items:batch", h.SetCartItems)
+		r.Post("/cart/promo", h.ApplyCartPromo)
+		r.Delete("/cart/promo", h.RemoveCartPromo)
+	})
+
+	// --- Для аутентифицированных пользователей ---
+	r.Group(func(r chi.Router) {
+		r.Use(auth.JWTAuth)
+		r.Use(guestCart.Handler)
+		r.With(idempotency.Handler).Post("/orders", h.PlaceOrder)
 		r.Get("/orders", h.ListOrders)
+		r.Get("/orders/{id}", h.GetOrder)
+		r.Post("/orders/{id}/cancel", h.CancelOrder)
+		r.With(idempotency.Handler).Post("/orders/{id}/pay", h.PayOrder)
+		r.With(idempotency.Handler).Post("/orders/{id}/returns", h.RequestReturn)
+		r.Get("/orders/{id}/returns", h.ListOrderReturns)
+		r.Get("/returns/{id}", h.GetReturn)
+		r.Get("/me", h.GetMe)
 	})
 
 	return r
 }
- 
\ No newline at end of file
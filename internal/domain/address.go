@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ShippingAddress — адрес доставки заказа. Country — код ISO 3166-1 alpha-2.
+type ShippingAddress struct {
+	Recipient  string `json:"recipient"`
+	Country    string `json:"country"`
+	Region     string `json:"region,omitempty"`
+	City       string `json:"city"`
+	PostalCode string `json:"postal_code"`
+	Street     string `json:"street"`
+}
+
+// shippingCountry — правила страны доставки: формат индекса и телефона
+// (E.164 после нормализации) и стоимость доставки.
+type shippingCountry struct {
+	postalCode *regexp.Regexp
+	phone      *regexp.Regexp
+	fee        Money
+	// freeFrom — от какой суммы товаров доставка бесплатна; ноль — никогда.
+	freeFrom Money
+}
+
+var shippingCountries = map[string]shippingCountry{
+	"RU": {regexp.MustCompile(`^\d{6}$`), regexp.MustCompile(`^\+7[3489]\d{9}$`), NewMoney(30000), NewMoney(300000)},
+	"KZ": {regexp.MustCompile(`^\d{6}$`), regexp.MustCompile(`^\+7[67]\d{9}$`), NewMoney(60000), Money{}},
+	"BY": {regexp.MustCompile(`^\d{6}$`), regexp.MustCompile(`^\+375\d{9}$`), NewMoney(60000), Money{}},
+	"AM": {regexp.MustCompile(`^\d{4}$`), regexp.MustCompile(`^\+374\d{8}$`), NewMoney(90000), Money{}},
+}
+
+const maxAddressField = 200
+
+// FieldError — ошибка в одном поле запроса.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError возвращается, если данные доставки не прошли проверку.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Reason)
+	}
+	return "invalid shipping details: " + strings.Join(parts, ", ")
+}
+
+// NormalizePhone убирает из номера пробелы, дефисы, скобки и точки.
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+}
+
+// Normalize приводит адрес к каноническому виду: обрезает пробелы,
+// код страны и индекс — в верхний регистр.
+func (a *ShippingAddress) Normalize() {
+	a.Recipient = strings.TrimSpace(a.Recipient)
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+	a.Region = strings.TrimSpace(a.Region)
+	a.City = strings.TrimSpace(a.City)
+	a.PostalCode = strings.ToUpper(strings.TrimSpace(a.PostalCode))
+	a.Street = strings.TrimSpace(a.Street)
+}
+
+// ValidateShipping проверяет нормализованные адрес и телефон по правилам
+// страны доставки и возвращает *ValidationError со всеми ошибками сразу.
+func ValidateShipping(addr *ShippingAddress, phone string) error {
+	var fields []FieldError
+	add := func(field, reason string) { fields = append(fields, FieldError{Field: field, Reason: reason}) }
+	if addr == nil {
+		add("shipping_address", "required")
+		if phone == "" {
+			add("phone", "required")
+		}
+		return &ValidationError{Fields: fields}
+	}
+	for _, f := range []struct {
+		name, value string
+		required    bool
+	}{
+		{"shipping_address.recipient", addr.Recipient, true},
+		{"shipping_address.region", addr.Region, false},
+		{"shipping_address.city", addr.City, true},
+		{"shipping_address.street", addr.Street, true},
+	} {
+		switch {
+		case f.required && f.value == "":
+			add(f.name, "required")
+		case utf8.RuneCountInString(f.value) > maxAddressField:
+			add(f.name, fmt.Sprintf("longer than %d characters", maxAddressField))
+		}
+	}
+	country, ok := shippingCountries[addr.Country]
+	switch {
+	case addr.Country == "":
+		add("shipping_address.country", "required")
+	case !ok:
+		add("shipping_address.country", "shipping to "+addr.Country+" is not supported")
+	}
+	if ok && !country.postalCode.MatchString(addr.PostalCode) {
+		add("shipping_address.postal_code", "invalid format for "+addr.Country)
+	}
+	switch {
+	case phone == "":
+		add("phone", "required")
+	case ok && !country.phone.MatchString(phone):
+		add("phone", "invalid format for "+addr.Country)
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// ShippingCost возвращает стоимость доставки в страну для заказа на
+// сумму subtotal. Страна должна пройти ValidateShipping.
+func ShippingCost(country string, subtotal Money) Money {
+	c := shippingCountries[country]
+	if !c.freeFrom.IsZero() && subtotal.Amount >= c.freeFrom.Amount {
+		return NewMoney(0)
+	}
+	return c.fee
+}
+
+// ShipsTo сообщает, доставляем ли мы в страну (код ISO в верхнем регистре).
+func ShipsTo(country string) bool {
+	_, ok := shippingCountries[country]
+	return ok
+}
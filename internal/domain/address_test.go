@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAddress(country, postalCode string) *ShippingAddress {
+	return &ShippingAddress{Recipient: "Иван Петров", Country: country, City: "Город", PostalCode: postalCode, Street: "ул. Ленина, 1"}
+}
+
+func TestValidateShipping_PerCountry(t *testing.T) {
+	cases := []struct {
+		country, postal, phone string
+		ok                     bool
+	}{
+		{"RU", "101000", "+79161234567", true},
+		{"RU", "10100", "+79161234567", false},
+		{"RU", "101000", "+77011234567", false}, // номер Казахстана
+		{"KZ", "050000", "+77011234567", true},
+		{"KZ", "050000", "+79161234567", false},
+		{"BY", "220030", "+375291234567", true},
+		{"BY", "220030", "+37529123456", false},
+		{"AM", "0010", "+37410123456", true},
+		{"AM", "001000", "+37410123456", false},
+		{"US", "10001", "+12125551234", false},
+	}
+	for _, tc := range cases {
+		err := ValidateShipping(testAddress(tc.country, tc.postal), tc.phone)
+		if tc.ok {
+			assert.NoError(t, err, "%s %s %s", tc.country, tc.postal, tc.phone)
+		} else {
+			assert.Error(t, err, "%s %s %s", tc.country, tc.postal, tc.phone)
+		}
+	}
+}
+
+func TestValidateShipping_ReportsAllFields(t *testing.T) {
+	err := ValidateShipping(&ShippingAddress{Country: "RU"}, "")
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []FieldError{
+		{Field: "shipping_address.recipient", Reason: "required"},
+		{Field: "shipping_address.city", Reason: "required"},
+		{Field: "shipping_address.street", Reason: "required"},
+		{Field: "shipping_address.postal_code", Reason: "invalid format for RU"},
+		{Field: "phone", Reason: "required"},
+	}, invalid.Fields)
+
+	err = ValidateShipping(nil, "")
+	require.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Fields, 2)
+}
+
+func TestNormalizeShipping(t *testing.T) {
+	assert.Equal(t, "+79161234567", NormalizePhone(" +7 (916) 123-45-67 "))
+	a := &ShippingAddress{Country: " kz ", PostalCode: " 050000 ", City: " Алматы "}
+	a.Normalize()
+	assert.Equal(t, "KZ", a.Country)
+	assert.Equal(t, "050000", a.PostalCode)
+	assert.Equal(t, "Алматы", a.City)
+}
+
+func TestShippingCost(t *testing.T) {
+	assert.Equal(t, NewMoney(30000), ShippingCost("RU", NewMoney(299999)))
+	assert.Equal(t, NewMoney(0), ShippingCost("RU", NewMoney(300000)))
+	assert.Equal(t, NewMoney(60000), ShippingCost("KZ", NewMoney(1000000)))
+}
@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -14,7 +16,7 @@ type Book struct {
 	Title      string    `json:"title"`
 	Author     string    `json:"author"`
 	Year       int       `json:"year"`
-	Price      float64   `json:"price"`
+	Price      Money     `json:"price" swaggertype:"number"`
 	CategoryID int       `json:"category_id"`
 	Category   *Category `json:"category,omitempty"`
 	Inventory  int       `json:"inventory"`
@@ -23,40 +25,150 @@ type Book struct {
 }
 
 type User struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	IsAdmin bool   `json:"is_admin"`
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	IsAdmin   bool      `json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Cart struct {
-	ID        int        `json:"id"`
-	UserID    string     `json:"user_id"`
-	Items     []CartItem `json:"items"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	ID     int        `json:"id"`
+	UserID string     `json:"user_id"`
+	Items  []CartItem `json:"items"`
+	// Subtotal — сумма по текущим ценам.
+	Subtotal Money `json:"subtotal" swaggertype:"number"`
+	// PromoCode — применённый промокод; Discount — скидка по нему,
+	// Total = Subtotal - Discount. Если промокод перестал действовать,
+	// скидка нулевая, а PromoReason объясняет почему (см. PromoError).
+	PromoCode   string `json:"promo_code,omitempty"`
+	PromoReason string `json:"promo_reason,omitempty"`
+	Discount    Money  `json:"discount" swaggertype:"number"`
+	Total       Money  `json:"total" swaggertype:"number"`
+	// Tax — НДС, включённый в Total, для региона TaxRegion: адреса у
+	// корзины нет, регион передаёт клиент или берётся по умолчанию.
+	Tax       Money      `json:"tax" swaggertype:"number"`
+	TaxRegion *TaxRegion `json:"tax_region,omitempty"`
+	// Stale — у какой-то позиции изменилась цена или её нельзя купить.
+	Stale     bool      `json:"stale"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type CartItem struct {
-	ID         int       `json:"id"`
-	CartID     int       `json:"cart_id"`
-	BookID     int       `json:"book_id"`
-	Book       *Book     `json:"book,omitempty"`
-	Quantity   int       `json:"quantity"`
-	ReservedAt time.Time `json:"reserved_at"`
+	ID       int   `json:"id"`
+	CartID   int   `json:"cart_id"`
+	BookID   int   `json:"book_id"`
+	Book     *Book `json:"book,omitempty"`
+	Quantity int   `json:"quantity"`
+	// PriceSnapshot — цена на момент добавления в корзину.
+	PriceSnapshot Money `json:"price_snapshot" swaggertype:"number"`
+	// Price — текущая цена книги, LineTotal = Price * Quantity.
+	Price        Money     `json:"price" swaggertype:"number"`
+	LineTotal    Money     `json:"line_total" swaggertype:"number"`
+	Discount     Money     `json:"discount" swaggertype:"number"`
+	TaxRate      Percent   `json:"tax_rate" swaggertype:"number"`
+	Tax          Money     `json:"tax" swaggertype:"number"`
+	PriceChanged bool      `json:"price_changed"`
+	Unavailable  bool      `json:"unavailable"`
+	ReservedAt   time.Time `json:"reserved_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// PriceChange — позиция корзины, цена которой изменилась после добавления.
+type PriceChange struct {
+	BookID        int   `json:"book_id"`
+	PriceSnapshot Money `json:"price_snapshot" swaggertype:"number"`
+	Price         Money `json:"price" swaggertype:"number"`
+}
+
+// StaleCartError возвращается при оформлении заказа, если цены в корзине
+// изменились, а клиент не подтвердил новые.
+type StaleCartError struct {
+	Items []PriceChange
+}
+
+func (e *StaleCartError) Error() string {
+	parts := make([]string, 0, len(e.Items))
+	for _, it := range e.Items {
+		parts = append(parts, fmt.Sprintf("book %d (%s -> %s)", it.BookID, it.PriceSnapshot.Decimal(), it.Price.Decimal()))
+	}
+	return "cart prices changed: " + strings.Join(parts, ", ")
+}
+
+// CartItemQuantity — абсолютное количество книги в корзине; 0 удаляет позицию.
+type CartItemQuantity struct {
+	BookID   int `json:"book_id"`
+	Quantity int `json:"quantity"`
+}
+
+// CartMergeConflict — позиция гостевой корзины, которая не поместилась
+// в корзину пользователя целиком: книг на складе меньше, чем нужно.
+type CartMergeConflict struct {
+	BookID    int `json:"book_id"`
+	Requested int `json:"requested"`
+	Merged    int `json:"merged"`
+	Available int `json:"available"`
+}
+
+// CartMergeResult — итог переноса гостевой корзины в корзину пользователя.
+type CartMergeResult struct {
+	Merged    int                 `json:"merged"`
+	Conflicts []CartMergeConflict `json:"conflicts,omitempty"`
+}
+
+// CartReservationTTL — сколько позиция корзины держит книги. Добавление
+// экземпляра продлевает резерв всей позиции.
+const CartReservationTTL = 30 * time.Minute
+
+// PlaceOrderRequest — параметры оформления заказа.
+type PlaceOrderRequest struct {
+	// ConfirmPrices — клиент видел изменившиеся цены и согласен на них.
+	ConfirmPrices   bool             `json:"confirm_prices"`
+	ShippingAddress *ShippingAddress `json:"shipping_address"`
+	// Phone — контактный телефон в международном формате, например +79161234567.
+	Phone string `json:"phone"`
 }
 
 type Order struct {
-	ID        int         `json:"id"`
-	UserID    string      `json:"user_id"`
-	Items     []OrderItem `json:"items"`
-	CreatedAt time.Time   `json:"created_at"`
+	ID     int         `json:"id"`
+	UserID string      `json:"user_id"`
+	Status string      `json:"status"`
+	Items  []OrderItem `json:"items"`
+	// Суммы на момент оформления: Total = Subtotal - Discount + Shipping.
+	// Tax — НДС, уже включённый в цены позиций, в Total не добавляется.
+	Subtotal Money `json:"subtotal" swaggertype:"number"`
+	// Discount — скидка по промокоду PromoCode, разложенная по позициям.
+	Discount        Money            `json:"discount" swaggertype:"number"`
+	PromoCode       string           `json:"promo_code,omitempty"`
+	Shipping        Money            `json:"shipping" swaggertype:"number"`
+	Tax             Money            `json:"tax" swaggertype:"number"`
+	Total           Money            `json:"total" swaggertype:"number"`
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"`
+	Phone           string           `json:"phone,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
 }
 
 type OrderItem struct {
-	ID       int     `json:"id"`
-	OrderID  int     `json:"order_id"`
-	BookID   int     `json:"book_id"`
-	Book     *Book   `json:"book,omitempty"`
-	Price    float64 `json:"price"`
-	Quantity int     `json:"quantity"`
+	ID       int   `json:"id"`
+	OrderID  int   `json:"order_id"`
+	BookID   int   `json:"book_id"`
+	Book     *Book `json:"book,omitempty"`
+	Price    Money `json:"price" swaggertype:"number"`
+	Quantity int   `json:"quantity"`
+	// Discount — скидка на всю позицию, а не на экземпляр.
+	Discount Money `json:"discount" swaggertype:"number"`
+	// Tax — НДС, включённый в сумму позиции после скидки, по ставке TaxRate.
+	TaxRate Percent `json:"tax_rate" swaggertype:"number"`
+	Tax     Money   `json:"tax" swaggertype:"number"`
+}
+
+// OrderSubtotal считает сумму позиций заказа.
+func OrderSubtotal(items []OrderItem) Money {
+	var total Money
+	for _, it := range items {
+		total = total.Add(it.Price.Mul(it.Quantity))
+	}
+	return total
 }
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BookFilter — параметры выборки каталога. Нулевые значения означают
+// отсутствие ограничения.
+type BookFilter struct {
+	CategoryIDs    []int
+	MinPrice       *Money
+	MaxPrice       *Money
+	MinYear        *int
+	MaxYear        *int
+	Author         string
+	IncludeSoldOut bool
+	// Sort — поле сортировки, "-" в начале означает убывание.
+	Sort      string
+	Limit     int
+	After     *Cursor
+	WithTotal bool
+}
+
+var bookSortFields = map[string]bool{
+	"price":      true,
+	"year":       true,
+	"title":      true,
+	"created_at": true,
+}
+
+// BookCursor строит курсор, указывающий на книгу b в выдаче с сортировкой sort.
+func BookCursor(b *Book, sort string) *Cursor {
+	c := &Cursor{Sort: sort, ID: b.ID}
+	switch strings.TrimPrefix(sort, "-") {
+	case "price":
+		c.Value = b.Price.Decimal()
+	case "year":
+		c.Value = strconv.Itoa(b.Year)
+	case "title":
+		c.Value = b.Title
+	case "created_at":
+		c.Value = b.CreatedAt.Format(time.RFC3339Nano)
+	}
+	return c
+}
+
+// ValidBookSort проверяет значение параметра sort.
+func ValidBookSort(sort string) bool {
+	return sort == "" || bookSortFields[strings.TrimPrefix(sort, "-")]
+}
+
+// PriceBucketBounds — границы ценовых диапазонов для фасетов:
+// [0, 500), [500, 1000), [1000, 2000), [2000, 5000), [5000, ∞).
+var PriceBucketBounds = []float64{500, 1000, 2000, 5000}
+
+type CategoryFacet struct {
+	CategoryID int    `json:"category_id"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+}
+
+type PriceBucketFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int      `json:"count"`
+}
+
+// BookFacets считаются по тем же фильтрам, что и выдача, кроме фильтра
+// по самому фасету: счётчики категорий не учитывают category_id, а ценовые
+// диапазоны — min_price/max_price. Так витрина может показать альтернативы.
+type BookFacets struct {
+	Categories   []CategoryFacet    `json:"categories"`
+	PriceBuckets []PriceBucketFacet `json:"price_buckets"`
+}
+
+type BookList struct {
+	Items      []*Book     `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Total      *int        `json:"total,omitempty"`
+	Facets     *BookFacets `json:"facets,omitempty"`
+	Next       *Cursor     `json:"-"`
+}
@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+// Типы событий заказа. Событие пишется в таблицу outbox в той же
+// транзакции, что и изменение заказа, и публикуется в Kafka отдельно.
+const (
+	EventOrderPlaced        = "order_placed"
+	EventOrderStatusChanged = "order_status_changed"
+	EventOrderCancelled     = "order_cancelled"
+	// События возврата публикуются в один топик, шаг — в поле status.
+	EventReturnRequested = "return_requested"
+	EventReturnApproved  = "return_approved"
+	EventReturnRejected  = "return_rejected"
+	EventReturnRefunded  = "return_refunded"
+)
+
+type OrderEventBook struct {
+	BookID   int `json:"book_id"`
+	Quantity int `json:"quantity"`
+}
+
+type OrderPlacedEvent struct {
+	OrderID int              `json:"order_id"`
+	UserID  string           `json:"user_id"`
+	Books   []OrderEventBook `json:"books"`
+}
+
+type OrderStatusChangedEvent struct {
+	OrderID   int       `json:"order_id"`
+	UserID    string    `json:"user_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// OrderCancelledEvent — заказ отменён, книги вернулись на склад.
+type OrderCancelledEvent struct {
+	OrderID     int              `json:"order_id"`
+	UserID      string           `json:"user_id"`
+	Actor       string           `json:"actor"`
+	Reason      string           `json:"reason,omitempty"`
+	Books       []OrderEventBook `json:"books"`
+	CancelledAt time.Time        `json:"cancelled_at"`
+}
+
+// ReturnEvent — шаг возврата. Books — возвращаемые книги; на складе они
+// оказываются после approved.
+type ReturnEvent struct {
+	ReturnID     int              `json:"return_id"`
+	OrderID      int              `json:"order_id"`
+	UserID       string           `json:"user_id"`
+	Status       string           `json:"status"`
+	Actor        string           `json:"actor"`
+	Reason       string           `json:"reason,omitempty"`
+	Books        []OrderEventBook `json:"books"`
+	RefundAmount Money            `json:"refund_amount"`
+	ChangedAt    time.Time        `json:"changed_at"`
+}
+
+// OutboxMessage — неотправленное событие. Payload — JSON одного из
+// типов выше в зависимости от Type.
+type OutboxMessage struct {
+	ID        int64
+	Type      string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Виды движений по складу. Остаток книги всегда равен сумме Delta её движений.
+const (
+	MovementInitial    = "initial"    // начальный остаток при создании книги
+	MovementRestock    = "restock"    // поступление
+	MovementDamage     = "damage"     // списание брака
+	MovementCorrection = "correction" // инвентаризация, delta любого знака
+	MovementOrder      = "order"      // продажа
+	MovementCancel     = "cancel"     // возврат на склад при отмене заказа
+	MovementReturn     = "return"     // возврат покупателем после доставки
+)
+
+type InventoryMovement struct {
+	ID     int    `json:"id"`
+	BookID int    `json:"book_id"`
+	Delta  int    `json:"delta"`
+	Kind   string `json:"kind"`
+	Reason string `json:"reason,omitempty"`
+	// Actor — id пользователя, пустой для системных движений.
+	Actor        string    `json:"actor,omitempty"`
+	OrderID      *int      `json:"order_id,omitempty"`
+	BalanceAfter int       `json:"balance_after"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type InventoryMovementList struct {
+	Items      []*InventoryMovement `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+	Total      *int                 `json:"total,omitempty"`
+	Next       *Cursor              `json:"-"`
+}
+
+// OutOfStockItem — позиция, которую не удалось списать со склада.
+type OutOfStockItem struct {
+	BookID    int `json:"book_id"`
+	Requested int `json:"requested"`
+	Available int `json:"available"`
+}
+
+// OutOfStockError возвращается при оформлении заказа, если остатка
+// не хватает хотя бы по одной книге. Перечисляет все такие книги.
+type OutOfStockError struct {
+	Items []OutOfStockItem
+}
+
+func (e *OutOfStockError) Error() string {
+	parts := make([]string, 0, len(e.Items))
+	for _, it := range e.Items {
+		parts = append(parts, fmt.Sprintf("book %d (requested %d, available %d)", it.BookID, it.Requested, it.Available))
+	}
+	return "not enough books in stock: " + strings.Join(parts, ", ")
+}
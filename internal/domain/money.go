@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency — валюта магазина. В JSON и в Postgres (NUMERIC(10,2))
+// валюта не передаётся: все суммы в ней.
+const DefaultCurrency = "RUB"
+
+// Money — сумма в минимальных единицах валюты (копейках) и код валюты
+// ISO 4217. Арифметика целочисленная, поэтому итоги не «уплывают» на
+// копейку, как при float64. Все поддерживаемые валюты имеют две цифры
+// после запятой.
+//
+// В JSON сумма кодируется числом в основных единицах (123.45), как
+// раньше float64, — формат ответа API не изменился.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney создаёт сумму из копеек в валюте магазина.
+func NewMoney(minor int64) Money {
+	return Money{Amount: minor, Currency: DefaultCurrency}
+}
+
+var errInvalidMoney = errors.New("invalid money amount")
+
+// ParseMoney разбирает десятичную запись вида "123", "123.4" или "-0.05".
+// Больше двух знаков после запятой — ошибка, а не округление.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" || len(fracPart) > 2 || strings.ContainsAny(intPart+fracPart, "+-eE") {
+		return Money{}, fmt.Errorf("%w: %q", errInvalidMoney, s)
+	}
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", errInvalidMoney, s)
+	}
+	var cents int64
+	if fracPart != "" {
+		cents, err = strconv.ParseInt(fracPart+strings.Repeat("0", 2-len(fracPart)), 10, 64)
+		if err != nil {
+			return Money{}, fmt.Errorf("%w: %q", errInvalidMoney, s)
+		}
+	}
+	amount := units*100 + cents
+	if neg {
+		amount = -amount
+	}
+	return NewMoney(amount), nil
+}
+
+// Add складывает суммы одной валюты. Нулевое значение Money{} совместимо
+// с любой валютой, поэтому с него можно начинать подсчёт итога.
+func (m Money) Add(o Money) Money {
+	cur := m.Currency
+	if cur == "" {
+		cur = o.Currency
+	} else if o.Currency != "" && o.Currency != cur {
+		panic(fmt.Sprintf("money: currency mismatch %s + %s", cur, o.Currency))
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: cur}
+}
+
+// Sub вычитает сумму той же валюты.
+func (m Money) Sub(o Money) Money {
+	return m.Add(o.Mul(-1))
+}
+
+// Mul умножает сумму на количество.
+func (m Money) Mul(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// Decimal возвращает сумму в основных единицах без лишних нулей:
+// 50000 -> "500", 9990 -> "99.9", 9999 -> "99.99".
+func (m Money) Decimal() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	units, cents := amount/100, amount%100
+	switch {
+	case cents == 0:
+		return fmt.Sprintf("%s%d", sign, units)
+	case cents%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, units, cents/10)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units, cents)
+}
+
+func (m Money) String() string {
+	cur := m.Currency
+	if cur == "" {
+		cur = DefaultCurrency
+	}
+	return m.Decimal() + " " + cur
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON принимает число (как раньше для float64) или строку.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Scan читает NUMERIC из Postgres: pgx передаёт его текстом.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case []byte:
+		return m.Scan(string(v))
+	case int64:
+		*m = NewMoney(v * 100)
+		return nil
+	}
+	return fmt.Errorf("money: cannot scan %T", src)
+}
+
+// Value передаёт сумму в Postgres десятичной строкой, без потери точности.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
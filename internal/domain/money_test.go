@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"500", 50000, true},
+		{"99.9", 9990, true},
+		{"0.05", 5, true},
+		{"-1.5", -150, true},
+		{"12.345", 0, false},
+		{"1e3", 0, false},
+		{".5", 0, false},
+		{"abc", 0, false},
+	}
+	for _, tc := range cases {
+		m, err := ParseMoney(tc.in)
+		if !tc.ok {
+			assert.Error(t, err, tc.in)
+			continue
+		}
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, NewMoney(tc.want), m, tc.in)
+	}
+}
+
+func TestMoney_Arithmetic_Exact(t *testing.T) {
+	// 0.1 * 3 во float64 даёт 0.30000000000000004
+	total := NewMoney(10).Mul(3)
+	assert.Equal(t, NewMoney(30), total)
+
+	var sum Money
+	for i := 0; i < 10; i++ {
+		sum = sum.Add(NewMoney(10))
+	}
+	assert.Equal(t, NewMoney(100), sum)
+	assert.Panics(t, func() { NewMoney(1).Add(Money{Amount: 1, Currency: "USD"}) })
+	assert.Equal(t, NewMoney(-5), NewMoney(10).Sub(NewMoney(15)))
+}
+
+func TestMoney_JSON_CompatibleWithFloat(t *testing.T) {
+	for _, tc := range []struct {
+		m    Money
+		want string
+	}{
+		{NewMoney(50000), "500"},
+		{NewMoney(9990), "99.9"},
+		{NewMoney(9999), "99.99"},
+		{NewMoney(5), "0.05"},
+		{NewMoney(-150), "-1.5"},
+		{Money{}, "0"},
+	} {
+		data, err := json.Marshal(tc.m)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, string(data))
+	}
+
+	var req struct {
+		Price Money `json:"price"`
+		Alt   Money `json:"alt"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"price": 19.99, "alt": "7.5"}`), &req))
+	assert.Equal(t, NewMoney(1999), req.Price)
+	assert.Equal(t, NewMoney(750), req.Alt)
+	assert.Error(t, json.Unmarshal([]byte(`{"price": 19.999}`), &req))
+}
+
+func TestMoney_ScanValue(t *testing.T) {
+	var m Money
+	require.NoError(t, m.Scan("123.40"))
+	assert.Equal(t, NewMoney(12340), m)
+	v, err := m.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "123.4", v)
+	assert.Error(t, m.Scan(1.5))
+}
+
+func TestOrderSubtotal(t *testing.T) {
+	items := []OrderItem{
+		{Price: NewMoney(1999), Quantity: 3},
+		{Price: NewMoney(10), Quantity: 7},
+	}
+	assert.Equal(t, NewMoney(6067), OrderSubtotal(items))
+	assert.Equal(t, Money{}, OrderSubtotal(nil))
+}
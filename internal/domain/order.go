@@ -0,0 +1,51 @@
+package domain
+
+import "time"
+
+// Статусы заказа.
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+// orderTransitions — допустимые переходы между статусами. cancelled и
+// refunded конечные; возврат денег после доставки — refunded.
+var orderTransitions = map[string][]string{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderShipped, OrderCancelled, OrderRefunded},
+	OrderShipped:   {OrderDelivered, OrderCancelled},
+	OrderDelivered: {OrderRefunded},
+	OrderCancelled: {},
+	OrderRefunded:  {},
+}
+
+func ValidOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// CanTransition сообщает, разрешён ли переход заказа из from в to.
+func CanTransition(from, to string) bool {
+	for _, s := range orderTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderTransition — запись журнала смены статуса. Для создания заказа
+// From пустой.
+type OrderTransition struct {
+	ID        int       `json:"id"`
+	OrderID   int       `json:"order_id"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{OrderPending, OrderPaid, true},
+		{OrderPending, OrderCancelled, true},
+		{OrderPending, OrderShipped, false},
+		{OrderPaid, OrderShipped, true},
+		{OrderPaid, OrderRefunded, true},
+		{OrderShipped, OrderDelivered, true},
+		{OrderShipped, OrderPaid, false},
+		{OrderDelivered, OrderRefunded, true},
+		{OrderDelivered, OrderCancelled, false},
+		{OrderCancelled, OrderPending, false},
+		{OrderRefunded, OrderPaid, false},
+		{"", OrderPending, false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, CanTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
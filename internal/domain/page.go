@@ -0,0 +1,42 @@
+package domain
+
+// Cursor — позиция в выдаче для keyset-пагинации: значение поля сортировки
+// и id последнего элемента страницы. Новые строки, вставленные между
+// запросами страниц, не сдвигают выдачу, в отличие от OFFSET.
+type Cursor struct {
+	Sort  string `json:"s,omitempty"`
+	Value string `json:"v,omitempty"`
+	ID    int    `json:"id"`
+}
+
+type PageRequest struct {
+	After     *Cursor
+	Limit     int
+	WithTotal bool
+}
+
+type OrderList struct {
+	Items      []*Order `json:"items"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Total      *int     `json:"total,omitempty"`
+	Next       *Cursor  `json:"-"`
+}
+
+type CategoryList struct {
+	Items      []*Category `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Total      *int        `json:"total,omitempty"`
+	Next       *Cursor     `json:"-"`
+}
+
+type ReturnList struct {
+	Items      []*Return `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	Next       *Cursor   `json:"-"`
+}
+
+type PromoCodeList struct {
+	Items      []*PromoCode `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Next       *Cursor      `json:"-"`
+}
@@ -0,0 +1,58 @@
+package domain
+
+import "time"
+
+// Статусы платежа. Платёж создаётся в pending; провайдер авторизует его
+// сразу или позже (вебхуком), после списания (capture) заказ становится
+// оплаченным.
+const (
+	PaymentPending    = "pending"
+	PaymentAuthorized = "authorized"
+	PaymentCaptured   = "captured"
+	PaymentDeclined   = "declined"
+	PaymentRefunded   = "refunded"
+	// PaymentRefunding — заказ отменён или возвращён, а деньги ещё не
+	// вернулись: статус ставится в транзакции заказа, возврат у провайдера
+	// выполняется после неё и повторяется, пока не пройдёт.
+	PaymentRefunding = "refunding"
+	// PaymentCancelled — авторизация пришла, когда заказ уже отменён;
+	// деньги не списываются.
+	PaymentCancelled = "cancelled"
+)
+
+// Payment — попытка оплаты заказа. ProviderID пуст, пока провайдер не
+// ответил на авторизацию. Refunded — сколько уже возвращено, в том числе
+// частичными возвратами книг.
+type Payment struct {
+	ID            int       `json:"id"`
+	OrderID       int       `json:"order_id"`
+	ProviderID    string    `json:"provider_id,omitempty"`
+	Status        string    `json:"status"`
+	Amount        Money     `json:"amount" swaggertype:"number"`
+	Refunded      Money     `json:"refunded" swaggertype:"number"`
+	DeclineReason string    `json:"decline_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PayOrderRequest — оплата заказа. PaymentToken — токен карты, выданный
+// платёжной формой провайдера.
+type PayOrderRequest struct {
+	PaymentToken string `json:"payment_token"`
+}
+
+// PaymentEvent — уведомление провайдера об изменении статуса платежа.
+type PaymentEvent struct {
+	PaymentID     string `json:"payment_id"`
+	Status        string `json:"status"`
+	DeclineReason string `json:"decline_reason,omitempty"`
+}
+
+// PaymentDeclinedError — провайдер отклонил авторизацию или списание.
+type PaymentDeclinedError struct {
+	Reason string
+}
+
+func (e *PaymentDeclinedError) Error() string {
+	return "payment declined: " + e.Reason
+}
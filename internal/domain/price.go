@@ -0,0 +1,65 @@
+package domain
+
+import "time"
+
+// BookPrice — цена книги на интервале [EffectiveFrom, EffectiveTo). Без
+// EffectiveTo цена действует, пока её не перекроет более поздняя. Если в
+// один момент подходят несколько цен, действует та, что начинается позже:
+// распродажа с концом перекрывает базовую цену, а после конца базовая
+// возвращается сама.
+type BookPrice struct {
+	ID            int        `json:"id"`
+	BookID        int        `json:"book_id"`
+	Price         Money      `json:"price" swaggertype:"number"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to,omitempty"`
+	Reason        string     `json:"reason,omitempty"`
+	// Actor — id админа, пустой для цены из PUT /books/{id}.
+	Actor     string    `json:"actor,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SchedulePriceRequest — тело POST /books/{id}/prices. Без effective_from
+// цена действует сразу.
+type SchedulePriceRequest struct {
+	Price         Money      `json:"price" swaggertype:"number"`
+	EffectiveFrom *time.Time `json:"effective_from,omitempty"`
+	EffectiveTo   *time.Time `json:"effective_to,omitempty"`
+	Reason        string     `json:"reason"`
+}
+
+// Normalize приводит интервал к UTC: в Postgres колонки TIMESTAMP без
+// часового пояса.
+func (p *BookPrice) Normalize() {
+	p.EffectiveFrom = p.EffectiveFrom.UTC()
+	if p.EffectiveTo != nil {
+		t := p.EffectiveTo.UTC()
+		p.EffectiveTo = &t
+	}
+}
+
+// Validate проверяет цену перед планированием. Историю не переписываем:
+// цена не может начаться раньше now.
+func (p *BookPrice) Validate(now time.Time) error {
+	var fields []FieldError
+	if p.Price.IsNegative() {
+		fields = append(fields, FieldError{Field: "price", Reason: "must not be negative"})
+	}
+	if p.EffectiveFrom.Before(now) {
+		fields = append(fields, FieldError{Field: "effective_from", Reason: "must not be in the past"})
+	}
+	if p.EffectiveTo != nil && !p.EffectiveTo.After(p.EffectiveFrom) {
+		fields = append(fields, FieldError{Field: "effective_to", Reason: "must be after effective_from"})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+type BookPriceList struct {
+	Items      []*BookPrice `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Total      *int         `json:"total,omitempty"`
+	Next       *Cursor      `json:"-"`
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// Principal — аутентифицированный пользователь, полученный из токена Keycloak.
+type Principal struct {
+	UserID string
+	Email  string
+	// Roles — роли realm'а (realm_access.roles).
+	Roles []string
+	// ClientRoles — роли клиентов (resource_access.<client>.roles).
+	ClientRoles map[string][]string
+	ExpiresAt   time.Time
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) HasClientRole(client, role string) bool {
+	for _, r := range p.ClientRoles[client] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole("admin")
+}
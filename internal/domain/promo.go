@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Виды скидки по промокоду.
+const (
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+)
+
+// Причины, по которым промокод не применяется (PromoError.Reason).
+const (
+	PromoInactive      = "inactive"
+	PromoNotStarted    = "not_started"
+	PromoExpired       = "expired"
+	PromoMinSubtotal   = "min_subtotal"
+	PromoNotApplicable = "not_applicable"
+	PromoUsageLimit    = "usage_limit"
+	PromoUserLimit     = "user_limit"
+)
+
+// PromoCode — промокод, которым управляет админ. Скидка — процент или
+// фиксированная сумма от позиций, попадающих в область действия: все
+// книги, одна категория (CategoryID) или одна книга (BookID). Нулевые
+// лимиты означают «без ограничений».
+type PromoCode struct {
+	ID   int    `json:"id"`
+	Code string `json:"code"`
+	Kind string `json:"kind"`
+	// Percent — размер скидки для kind=percent, от 1 до 100.
+	Percent int `json:"percent,omitempty"`
+	// Amount — размер скидки для kind=fixed.
+	Amount      Money      `json:"amount" swaggertype:"number"`
+	MinSubtotal Money      `json:"min_subtotal" swaggertype:"number"`
+	CategoryID  *int       `json:"category_id,omitempty"`
+	BookID      *int       `json:"book_id,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	// PerUserLimit — сколько заказов может оформить с промокодом один
+	// покупатель, UsageLimit — все покупатели вместе.
+	PerUserLimit int       `json:"per_user_limit"`
+	UsageLimit   int       `json:"usage_limit"`
+	UsedCount    int       `json:"used_count"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NormalizePromoCode приводит код к виду, в котором он хранится:
+// без пробелов по краям, в верхнем регистре.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Normalize приводит код к хранимому виду, а окно действия — к UTC: в
+// Postgres колонки TIMESTAMP без часового пояса.
+func (p *PromoCode) Normalize() {
+	p.Code = NormalizePromoCode(p.Code)
+	if p.StartsAt != nil {
+		t := p.StartsAt.UTC()
+		p.StartsAt = &t
+	}
+	if p.EndsAt != nil {
+		t := p.EndsAt.UTC()
+		p.EndsAt = &t
+	}
+}
+
+// Validate проверяет промокод перед сохранением.
+func (p *PromoCode) Validate() error {
+	var fields []FieldError
+	if p.Code == "" || strings.ContainsAny(p.Code, " \t\n") || len(p.Code) > 32 {
+		fields = append(fields, FieldError{Field: "code", Reason: "must be 1-32 characters without spaces"})
+	}
+	switch p.Kind {
+	case PromoPercent:
+		if p.Percent < 1 || p.Percent > 100 {
+			fields = append(fields, FieldError{Field: "percent", Reason: "must be between 1 and 100"})
+		}
+	case PromoFixed:
+		if p.Amount.Amount <= 0 {
+			fields = append(fields, FieldError{Field: "amount", Reason: "must be positive"})
+		}
+	default:
+		fields = append(fields, FieldError{Field: "kind", Reason: "must be percent or fixed"})
+	}
+	if p.MinSubtotal.IsNegative() {
+		fields = append(fields, FieldError{Field: "min_subtotal", Reason: "must not be negative"})
+	}
+	if p.CategoryID != nil && p.BookID != nil {
+		fields = append(fields, FieldError{Field: "book_id", Reason: "category_id and book_id are mutually exclusive"})
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		fields = append(fields, FieldError{Field: "ends_at", Reason: "must be after starts_at"})
+	}
+	if p.PerUserLimit < 0 {
+		fields = append(fields, FieldError{Field: "per_user_limit", Reason: "must not be negative"})
+	}
+	if p.UsageLimit < 0 {
+		fields = append(fields, FieldError{Field: "usage_limit", Reason: "must not be negative"})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// PromoLine — позиция корзины или заказа для расчёта скидки.
+type PromoLine struct {
+	BookID     int
+	CategoryID int
+	Total      Money
+}
+
+// Applies сообщает, попадает ли позиция в область действия промокода.
+func (p *PromoCode) Applies(line PromoLine) bool {
+	switch {
+	case p.BookID != nil:
+		return line.BookID == *p.BookID
+	case p.CategoryID != nil:
+		return line.CategoryID == *p.CategoryID
+	}
+	return true
+}
+
+// Discount считает скидку на момент now и раскладывает её по позициям:
+// результат — скидка каждой позиции в порядке lines. Процент берётся от
+// суммы подходящих позиций и округляется вниз до копейки; фиксированная
+// скидка не больше этой суммы. Скидка делится пропорционально суммам
+// позиций, копейки от округления достаются первым позициям, у которых
+// остаётся место. Лимиты использования здесь проверяются только по
+// UsedCount — окончательно их проверяет транзакция заказа.
+func (p *PromoCode) Discount(lines []PromoLine, now time.Time) ([]Money, error) {
+	switch {
+	case !p.Active:
+		return nil, &PromoError{Code: p.Code, Reason: PromoInactive}
+	case p.StartsAt != nil && now.Before(*p.StartsAt):
+		return nil, &PromoError{Code: p.Code, Reason: PromoNotStarted}
+	case p.EndsAt != nil && !now.Before(*p.EndsAt):
+		return nil, &PromoError{Code: p.Code, Reason: PromoExpired}
+	case p.UsageLimit > 0 && p.UsedCount >= p.UsageLimit:
+		return nil, &PromoError{Code: p.Code, Reason: PromoUsageLimit}
+	}
+	var subtotal, eligible Money
+	for _, l := range lines {
+		subtotal = subtotal.Add(l.Total)
+		if p.Applies(l) {
+			eligible = eligible.Add(l.Total)
+		}
+	}
+	if subtotal.Amount < p.MinSubtotal.Amount {
+		return nil, &PromoError{Code: p.Code, Reason: PromoMinSubtotal}
+	}
+	if eligible.Amount <= 0 {
+		return nil, &PromoError{Code: p.Code, Reason: PromoNotApplicable}
+	}
+	total := eligible.Amount * int64(p.Percent) / 100
+	if p.Kind == PromoFixed {
+		total = min(p.Amount.Amount, eligible.Amount)
+	}
+
+	shares := make([]Money, len(lines))
+	rest := total
+	for i, l := range lines {
+		shares[i] = NewMoney(0)
+		if p.Applies(l) {
+			shares[i].Amount = total * l.Total.Amount / eligible.Amount
+			rest -= shares[i].Amount
+		}
+	}
+	for i, l := range lines {
+		if rest == 0 {
+			break
+		}
+		if !p.Applies(l) {
+			continue
+		}
+		add := min(l.Total.Amount-shares[i].Amount, rest)
+		shares[i].Amount += add
+		rest -= add
+	}
+	return shares, nil
+}
+
+// PromoError — промокод существует, но к этой корзине не применяется.
+type PromoError struct {
+	Code   string
+	Reason string
+}
+
+func (e *PromoError) Error() string {
+	return "promo code cannot be applied: " + e.Code + ": " + e.Reason
+}
+
+// ApplyPromoRequest — тело POST /cart/promo.
+type ApplyPromoRequest struct {
+	Code string `json:"code"`
+}
+
+// ItemDiscount — часть скидки позиции заказа, приходящаяся на quantity из
+// ordered экземпляров. Округляется вверх, чтобы сумма возвратов по частям
+// не превысила оплаченное.
+func ItemDiscount(line Money, quantity, ordered int) Money {
+	if ordered <= 0 || line.IsZero() {
+		return NewMoney(0)
+	}
+	n := int64(ordered)
+	return NewMoney((line.Amount*int64(quantity) + n - 1) / n)
+}
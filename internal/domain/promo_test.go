@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func promoReason(t *testing.T, err error) string {
+	t.Helper()
+	var perr *PromoError
+	require.True(t, errors.As(err, &perr), "%v", err)
+	return perr.Reason
+}
+
+func TestPromoCode_Discount_PercentSplitsAcrossLines(t *testing.T) {
+	p := &PromoCode{Code: "SALE10", Kind: PromoPercent, Percent: 10, Active: true}
+	lines := []PromoLine{
+		{BookID: 1, Total: NewMoney(3333)},
+		{BookID: 2, Total: NewMoney(3333)},
+		{BookID: 3, Total: NewMoney(3334)},
+	}
+	shares, err := p.Discount(lines, time.Now())
+	require.NoError(t, err)
+	// 10% от 100.00 = 10.00; доли 3.333 округляются вниз, копейка уходит первой позиции
+	assert.Equal(t, []Money{NewMoney(334), NewMoney(333), NewMoney(333)}, shares)
+}
+
+func TestPromoCode_Discount_PercentRoundsDown(t *testing.T) {
+	p := &PromoCode{Code: "SALE15", Kind: PromoPercent, Percent: 15, Active: true}
+	shares, err := p.Discount([]PromoLine{{BookID: 1, Total: NewMoney(999)}}, time.Now())
+	require.NoError(t, err)
+	// 15% от 9.99 = 1.4985 -> 1.49
+	assert.Equal(t, NewMoney(149), shares[0])
+}
+
+func TestPromoCode_Discount_ScopeAndFixedCap(t *testing.T) {
+	category := 5
+	p := &PromoCode{Code: "FANTASY", Kind: PromoFixed, Amount: NewMoney(50000), CategoryID: &category, Active: true}
+	lines := []PromoLine{
+		{BookID: 1, CategoryID: 5, Total: NewMoney(30000)},
+		{BookID: 2, CategoryID: 7, Total: NewMoney(90000)},
+	}
+	shares, err := p.Discount(lines, time.Now())
+	require.NoError(t, err)
+	// Фиксированная скидка не больше суммы подходящих позиций
+	assert.Equal(t, []Money{NewMoney(30000), NewMoney(0)}, shares)
+
+	book := 9
+	p = &PromoCode{Code: "BOOK9", Kind: PromoPercent, Percent: 50, BookID: &book, Active: true}
+	_, err = p.Discount(lines, time.Now())
+	assert.Equal(t, PromoNotApplicable, promoReason(t, err))
+}
+
+func TestPromoCode_Discount_Rejections(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	lines := []PromoLine{{BookID: 1, Total: NewMoney(100000)}}
+	cases := map[string]*PromoCode{
+		PromoInactive:    {Kind: PromoPercent, Percent: 10},
+		PromoNotStarted:  {Kind: PromoPercent, Percent: 10, Active: true, StartsAt: &later},
+		PromoExpired:     {Kind: PromoPercent, Percent: 10, Active: true, EndsAt: &now},
+		PromoUsageLimit:  {Kind: PromoPercent, Percent: 10, Active: true, UsageLimit: 3, UsedCount: 3},
+		PromoMinSubtotal: {Kind: PromoPercent, Percent: 10, Active: true, MinSubtotal: NewMoney(100001)},
+	}
+	for reason, p := range cases {
+		_, err := p.Discount(lines, now)
+		assert.Equal(t, reason, promoReason(t, err), reason)
+	}
+
+	// Окно [starts_at, ends_at): начало включено
+	p := &PromoCode{Kind: PromoPercent, Percent: 10, Active: true, StartsAt: &now, EndsAt: &later}
+	_, err := p.Discount(lines, now)
+	assert.NoError(t, err)
+	p.StartsAt = &earlier
+	_, err = p.Discount(lines, later)
+	assert.Equal(t, PromoExpired, promoReason(t, err))
+}
+
+func TestPromoCode_Validate(t *testing.T) {
+	category, book := 1, 2
+	valid := PromoCode{Code: "SALE10", Kind: PromoPercent, Percent: 10}
+	require.NoError(t, valid.Validate())
+
+	bad := PromoCode{Code: "two words", Kind: PromoFixed, CategoryID: &category, BookID: &book, PerUserLimit: -1}
+	var invalid *ValidationError
+	require.True(t, errors.As(bad.Validate(), &invalid))
+	var fields []string
+	for _, f := range invalid.Fields {
+		fields = append(fields, f.Field)
+	}
+	assert.Equal(t, []string{"code", "amount", "book_id", "per_user_limit"}, fields)
+}
+
+func TestItemDiscount_RoundsUpForPartialReturns(t *testing.T) {
+	line := NewMoney(100)
+	// 1.00 скидки на 3 экземпляра: возврат по одному не вернёт больше оплаченного
+	assert.Equal(t, NewMoney(34), ItemDiscount(line, 1, 3))
+	assert.Equal(t, NewMoney(67), ItemDiscount(line, 2, 3))
+	assert.Equal(t, line, ItemDiscount(line, 3, 3))
+	assert.Equal(t, NewMoney(0), ItemDiscount(Money{}, 1, 3))
+}
@@ -0,0 +1,59 @@
+package domain
+
+import "time"
+
+// Статусы возврата. Одобренный возврат уже вернул книги на склад, но
+// деньги ещё не вернулись; refunded — конечный успешный статус.
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+	ReturnRefunded  = "refunded"
+)
+
+// Return — заявка на возврат части позиций доставленного заказа.
+type Return struct {
+	ID      int          `json:"id"`
+	OrderID int          `json:"order_id"`
+	UserID  string       `json:"user_id"`
+	Status  string       `json:"status"`
+	Reason  string       `json:"reason"`
+	Items   []ReturnItem `json:"items"`
+	// RefundAmount — сколько вернуть покупателю: цены позиций на момент
+	// заказа за вычетом их доли скидки, без доставки.
+	RefundAmount Money     `json:"refund_amount" swaggertype:"number"`
+	ResolvedBy   string    `json:"resolved_by,omitempty"`
+	Resolution   string    `json:"resolution,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ReturnItem — возвращаемое количество одной позиции заказа.
+type ReturnItem struct {
+	ID          int   `json:"id"`
+	OrderItemID int   `json:"order_item_id"`
+	BookID      int   `json:"book_id"`
+	Price       Money `json:"price" swaggertype:"number"`
+	Quantity    int   `json:"quantity"`
+	// Discount — доля скидки заказа на возвращаемые экземпляры.
+	Discount Money `json:"discount" swaggertype:"number"`
+}
+
+// ReturnRefund считает сумму к возврату по позициям.
+func ReturnRefund(items []ReturnItem) Money {
+	total := Money{}
+	for _, it := range items {
+		total = total.Add(it.Price.Mul(it.Quantity)).Sub(it.Discount)
+	}
+	return total
+}
+
+type ReturnRequest struct {
+	Items  []ReturnItemRequest `json:"items"`
+	Reason string              `json:"reason"`
+}
+
+type ReturnItemRequest struct {
+	OrderItemID int `json:"order_item_id"`
+	Quantity    int `json:"quantity"`
+}
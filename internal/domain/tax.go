@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"strings"
+	"time"
+)
+
+// Percent — процент с точностью до сотых: 1000 = 10%. В JSON и в
+// Postgres (NUMERIC(5,2)) — десятичное число, как Money.
+type Percent int64
+
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return []byte(Money{Amount: int64(p)}.Decimal()), nil
+}
+
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	var m Money
+	if err := m.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*p = Percent(m.Amount)
+	return nil
+}
+
+func (p *Percent) Scan(src interface{}) error {
+	var m Money
+	if err := m.Scan(src); err != nil {
+		return err
+	}
+	*p = Percent(m.Amount)
+	return nil
+}
+
+func (p Percent) Value() (driver.Value, error) {
+	return Money{Amount: int64(p)}.Decimal(), nil
+}
+
+// TaxRate — ставка НДС для страны доставки, региона (пустой — вся
+// страна) и категории книг (nil — все категории), действующая с
+// EffectiveFrom. Ставки не меняются: новая ставка — новая строка с более
+// поздним EffectiveFrom, старые остаются для истории.
+type TaxRate struct {
+	ID         int     `json:"id"`
+	Country    string  `json:"country"`
+	Region     string  `json:"region,omitempty"`
+	CategoryID *int    `json:"category_id,omitempty"`
+	Rate       Percent `json:"rate" swaggertype:"number"`
+	// EffectiveFrom — с какого момента ставка действует; при создании
+	// без него — сразу.
+	EffectiveFrom time.Time `json:"effective_from"`
+	Actor         string    `json:"actor,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Normalize приводит страну и регион к виду, в котором они хранятся, а
+// время — к UTC.
+func (r *TaxRate) Normalize() {
+	r.Country = strings.ToUpper(strings.TrimSpace(r.Country))
+	r.Region = strings.TrimSpace(r.Region)
+	r.EffectiveFrom = r.EffectiveFrom.UTC()
+}
+
+// Validate проверяет ставку перед сохранением. Ставки, уже применённые к
+// заказам, не переписываются: новая версия не может начаться раньше now.
+func (r *TaxRate) Validate(now time.Time) error {
+	var fields []FieldError
+	if !ShipsTo(r.Country) {
+		fields = append(fields, FieldError{Field: "country", Reason: "shipping to " + r.Country + " is not supported"})
+	}
+	if r.Rate < 0 || r.Rate > 10000 {
+		fields = append(fields, FieldError{Field: "rate", Reason: "must be between 0 and 100"})
+	}
+	if r.EffectiveFrom.Before(now) {
+		fields = append(fields, FieldError{Field: "effective_from", Reason: "must not be in the past"})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+type TaxRateList struct {
+	Items      []*TaxRate `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Next       *Cursor    `json:"-"`
+}
+
+// TaxRegion — куда доставляется заказ: ставки зависят от страны и региона.
+type TaxRegion struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+}
+
+// TaxLine — позиция корзины или заказа для расчёта налога: Amount —
+// сумма позиции после скидки.
+type TaxLine struct {
+	CategoryID int
+	Amount     Money
+}
+
+// LineTax — ставка и налог одной позиции.
+type LineTax struct {
+	Rate Percent
+	Tax  Money
+}
+
+// RateFor выбирает из действующих ставок страны самую точную для региона
+// и категории: регион и категория, затем регион, затем категория, затем
+// ставка всей страны. nil — ставки нет, налог не начисляется.
+func RateFor(rates []*TaxRate, region string, categoryID int) *TaxRate {
+	var best *TaxRate
+	bestScore := -1
+	for _, r := range rates {
+		score := 0
+		if r.Region != "" {
+			if !strings.EqualFold(r.Region, strings.TrimSpace(region)) {
+				continue
+			}
+			score += 2
+		}
+		if r.CategoryID != nil {
+			if *r.CategoryID != categoryID {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best
+}
+
+// IncludedTax — НДС, который уже входит в сумму amount по ставке rate:
+// amount * rate / (100% + rate). Цены магазина включают НДС, поэтому налог
+// выделяется из суммы, а не добавляется к ней. Округляется до копейки,
+// половина копейки — вверх.
+func IncludedTax(amount Money, rate Percent) Money {
+	if rate <= 0 || amount.Amount <= 0 {
+		return NewMoney(0)
+	}
+	num := amount.Amount * int64(rate)
+	den := 10000 + int64(rate)
+	return NewMoney((2*num + den) / (2 * den))
+}
+
+// LineTaxes считает НДС каждой позиции по ставке из rates. Налог
+// округляется по каждой позиции отдельно, итог — сумма налогов позиций,
+// а не налог от суммы заказа: так итог всегда сходится с позициями.
+func LineTaxes(rates []*TaxRate, region TaxRegion, lines []TaxLine) ([]LineTax, Money) {
+	taxes := make([]LineTax, len(lines))
+	total := NewMoney(0)
+	for i, l := range lines {
+		taxes[i].Tax = NewMoney(0)
+		if r := RateFor(rates, region.Region, l.CategoryID); r != nil {
+			taxes[i] = LineTax{Rate: r.Rate, Tax: IncludedTax(l.Amount, r.Rate)}
+		}
+		total = total.Add(taxes[i].Tax)
+	}
+	return taxes, total
+}
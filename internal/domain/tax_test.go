@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Правила округления НДС: налог выделяется из суммы с НДС,
+// amount * rate / (100% + rate), и округляется до копейки, половина — вверх.
+func TestIncludedTax_Rounding(t *testing.T) {
+	cases := []struct {
+		amount int64
+		rate   Percent
+		want   int64
+	}{
+		{10000, 1000, 909},  // 100 ₽ при 10%: 9.0909 → 9.09
+		{11000, 1000, 1000}, // 110 ₽ при 10% — ровно 10 ₽
+		{3, 2000, 1},        // 0.03 при 20%: ровно полкопейки → вверх
+		{100, 2000, 17},     // 1 ₽ при 20%: 0.1667 → 0.17
+		{1, 1000, 0},        // 0.01 при 10%: 0.0009 → 0
+		{10000, 0, 0},
+		{0, 2000, 0},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, NewMoney(tc.want), IncludedTax(NewMoney(tc.amount), tc.rate), "%d at %d", tc.amount, tc.rate)
+	}
+}
+
+// Налог округляется по каждой позиции, итог — сумма позиций: три позиции
+// по 1 ₽ при 20% дают 0.51, хотя налог от суммы 3 ₽ был бы 0.50.
+func TestLineTaxes_RoundsPerLine(t *testing.T) {
+	rates := []*TaxRate{{Country: "AM", Rate: 2000}}
+	lines := []TaxLine{{Amount: NewMoney(100)}, {Amount: NewMoney(100)}, {Amount: NewMoney(100)}}
+
+	taxes, total := LineTaxes(rates, TaxRegion{Country: "AM"}, lines)
+	require.Len(t, taxes, 3)
+	for _, lt := range taxes {
+		assert.Equal(t, LineTax{Rate: 2000, Tax: NewMoney(17)}, lt)
+	}
+	assert.Equal(t, NewMoney(51), total)
+	assert.Equal(t, NewMoney(50), IncludedTax(NewMoney(300), 2000))
+}
+
+func TestLineTaxes_NoRateNoTax(t *testing.T) {
+	taxes, total := LineTaxes(nil, TaxRegion{Country: "RU"}, []TaxLine{{Amount: NewMoney(10000)}})
+	assert.Equal(t, []LineTax{{Tax: NewMoney(0)}}, taxes)
+	assert.Equal(t, NewMoney(0), total)
+}
+
+func TestRateFor_MostSpecificWins(t *testing.T) {
+	fiction := 7
+	rates := []*TaxRate{
+		{ID: 1, Country: "RU", Rate: 1000},
+		{ID: 2, Country: "RU", CategoryID: &fiction, Rate: 2000},
+		{ID: 3, Country: "RU", Region: "Moscow", Rate: 500},
+		{ID: 4, Country: "RU", Region: "Moscow", CategoryID: &fiction, Rate: 0},
+	}
+	assert.Equal(t, 1, RateFor(rates, "", 1).ID)
+	assert.Equal(t, 2, RateFor(rates, "", fiction).ID)
+	assert.Equal(t, 3, RateFor(rates, " moscow ", 1).ID)
+	assert.Equal(t, 4, RateFor(rates, "Moscow", fiction).ID)
+	assert.Equal(t, 2, RateFor(rates, "Kazan", fiction).ID)
+	assert.Nil(t, RateFor(rates[2:], "Kazan", 1))
+}
+
+func TestTaxRate_Validate(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := &TaxRate{Country: " kz ", Rate: 1200, EffectiveFrom: now.In(time.FixedZone("ALMT", 5*60*60))}
+	r.Normalize()
+	assert.Equal(t, "KZ", r.Country)
+	assert.Equal(t, time.UTC, r.EffectiveFrom.Location())
+	require.NoError(t, r.Validate(now))
+
+	err := (&TaxRate{Country: "US", Rate: 10001, EffectiveFrom: now.Add(-time.Second)}).Validate(now)
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	require.Len(t, invalid.Fields, 3)
+	assert.Equal(t, "country", invalid.Fields[0].Field)
+	assert.Equal(t, "rate", invalid.Fields[1].Field)
+	assert.Equal(t, "effective_from", invalid.Fields[2].Field)
+}
+
+func TestPercent_JSON(t *testing.T) {
+	data, err := json.Marshal(TaxRate{Rate: 1250})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"rate":12.5`)
+
+	var r TaxRate
+	require.NoError(t, json.Unmarshal([]byte(`{"rate":10}`), &r))
+	assert.Equal(t, Percent(1000), r.Rate)
+}
@@ -0,0 +1,310 @@
+// Package fakepay — платёжный шлюз для локального запуска и тестов. Говорит
+// на том же протоколе, что integration.PaymentGatewayClient; исход платежа
+// выбирается токеном карты, как тестовые карты настоящих шлюзов.
+package fakepay
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+)
+
+// Тестовые токены карт. Любой другой токен авторизуется сразу.
+const (
+	// TokenDecline — отказ банка.
+	TokenDecline = "tok_decline"
+	// TokenInsufficientFunds — отказ из-за нехватки средств.
+	TokenInsufficientFunds = "tok_insufficient_funds"
+	// TokenTimeout — шлюз авторизует платёж, но отвечает только через
+	// Config.TimeoutDelay: клиент не дожидается ответа и должен повторить
+	// запрос с тем же Idempotency-Key.
+	TokenTimeout = "tok_timeout"
+	// TokenDelayed — ответ pending, авторизация приходит вебхуком через
+	// Config.ConfirmDelay.
+	TokenDelayed = "tok_delayed"
+	// TokenDelayedDecline — ответ pending, отказ приходит вебхуком.
+	TokenDelayedDecline = "tok_delayed_decline"
+	// TokenCaptureDecline — авторизация проходит, списание отклоняется.
+	TokenCaptureDecline = "tok_capture_decline"
+)
+
+type Config struct {
+	// APIKey, если задан, обязателен в заголовке Authorization: Bearer.
+	APIKey string
+	// WebhookURL — куда отправлять уведомления; пусто — не отправлять.
+	WebhookURL    string
+	WebhookSecret []byte
+	ConfirmDelay  time.Duration
+	TimeoutDelay  time.Duration
+}
+
+type payment struct {
+	ID       string
+	Status   string
+	Amount   domain.Money
+	Captured domain.Money
+	Refunded domain.Money
+	Token    string
+}
+
+// Server — fake-шлюз, реализует http.Handler. Состояние хранится в памяти.
+type Server struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	payments map[string]*payment
+	// idempotency — ключ запроса -> сохранённый ответ.
+	idempotency map[string]response
+	wg          sync.WaitGroup
+}
+
+type response struct {
+	code int
+	body integration.PaymentResult
+}
+
+func New(cfg Config) *Server {
+	if cfg.ConfirmDelay <= 0 {
+		cfg.ConfirmDelay = 2 * time.Second
+	}
+	if cfg.TimeoutDelay <= 0 {
+		cfg.TimeoutDelay = 30 * time.Second
+	}
+	return &Server{
+		cfg:         cfg,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		payments:    map[string]*payment{},
+		idempotency: map[string]response{},
+	}
+}
+
+// Wait ждёт отправки всех отложенных вебхуков.
+func (s *Server) Wait() { s.wg.Wait() }
+
+// Status возвращает статус платежа, например для проверок в тестах.
+func (s *Server) Status(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.payments[id]; ok {
+		return p.Status
+	}
+	return ""
+}
+
+type paymentRequest struct {
+	OrderID  int          `json:"order_id"`
+	Amount   domain.Money `json:"amount"`
+	Currency string       `json:"currency"`
+	Token    string       `json:"token"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+s.cfg.APIKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req paymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount.Amount <= 0 {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	var res response
+	var delay time.Duration
+	switch {
+	case len(parts) == 1 && parts[0] == "payments":
+		res, delay = s.authorize(key, req)
+	case len(parts) == 3 && parts[0] == "payments" && parts[2] == "capture":
+		res = s.capture(key, parts[1], req.Amount)
+	case len(parts) == 3 && parts[0] == "payments" && parts[2] == "refund":
+		res = s.refund(key, parts[1], req.Amount)
+	case len(parts) == 3 && parts[0] == "payments" && parts[2] == "void":
+		res = s.void(key, parts[1])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.code)
+	json.NewEncoder(w).Encode(res.body)
+}
+
+// remembered возвращает ответ на повтор запроса с тем же ключом.
+func (s *Server) remembered(key string) (response, bool) {
+	if key == "" {
+		return response{}, false
+	}
+	res, ok := s.idempotency[key]
+	return res, ok
+}
+
+func (s *Server) remember(key string, res response) response {
+	if key != "" {
+		s.idempotency[key] = res
+	}
+	return res
+}
+
+func (s *Server) authorize(key string, req paymentRequest) (response, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if res, ok := s.remembered(key); ok {
+		return res, 0
+	}
+	p := &payment{ID: "pay_" + randomID(), Amount: req.Amount, Token: req.Token}
+	s.payments[p.ID] = p
+	var delay time.Duration
+	switch req.Token {
+	case TokenDecline:
+		p.Status = domain.PaymentDeclined
+		return s.remember(key, response{http.StatusPaymentRequired, integration.PaymentResult{ID: p.ID, Status: p.Status, DeclineReason: "card_declined"}}), 0
+	case TokenInsufficientFunds:
+		p.Status = domain.PaymentDeclined
+		return s.remember(key, response{http.StatusPaymentRequired, integration.PaymentResult{ID: p.ID, Status: p.Status, DeclineReason: "insufficient_funds"}}), 0
+	case TokenDelayed, TokenDelayedDecline:
+		p.Status = domain.PaymentPending
+		final, reason := domain.PaymentAuthorized, ""
+		if req.Token == TokenDelayedDecline {
+			final, reason = domain.PaymentDeclined, "card_declined"
+		}
+		s.confirmLater(p.ID, final, reason)
+		return s.remember(key, response{http.StatusOK, integration.PaymentResult{ID: p.ID, Status: p.Status}}), 0
+	case TokenTimeout:
+		// Платёж авторизован, но клиент узнает об этом только из повтора
+		delay = s.cfg.TimeoutDelay
+	}
+	p.Status = domain.PaymentAuthorized
+	return s.remember(key, response{http.StatusOK, integration.PaymentResult{ID: p.ID, Status: p.Status}}), delay
+}
+
+func (s *Server) capture(key, id string, amount domain.Money) response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if res, ok := s.remembered(key); ok {
+		return res
+	}
+	p, ok := s.payments[id]
+	switch {
+	case !ok:
+		return response{http.StatusNotFound, integration.PaymentResult{ID: id}}
+	case p.Status != domain.PaymentAuthorized || amount.Amount > p.Amount.Amount:
+		return response{http.StatusConflict, integration.PaymentResult{ID: id, Status: p.Status}}
+	case p.Token == TokenCaptureDecline:
+		p.Status = domain.PaymentDeclined
+		return s.remember(key, response{http.StatusPaymentRequired, integration.PaymentResult{ID: id, Status: p.Status, DeclineReason: "capture_declined"}})
+	}
+	p.Status = domain.PaymentCaptured
+	p.Captured = amount
+	return s.remember(key, response{http.StatusOK, integration.PaymentResult{ID: id, Status: p.Status}})
+}
+
+func (s *Server) refund(key, id string, amount domain.Money) response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if res, ok := s.remembered(key); ok {
+		return res
+	}
+	p, ok := s.payments[id]
+	switch {
+	case !ok:
+		return response{http.StatusNotFound, integration.PaymentResult{ID: id}}
+	case p.Status != domain.PaymentCaptured || p.Refunded.Amount+amount.Amount > p.Captured.Amount:
+		return response{http.StatusConflict, integration.PaymentResult{ID: id, Status: p.Status}}
+	}
+	// Частичные возвраты копятся; платёж становится refunded, когда
+	// возвращено всё списанное
+	p.Refunded.Amount += amount.Amount
+	if p.Refunded.Amount == p.Captured.Amount {
+		p.Status = domain.PaymentRefunded
+	}
+	return s.remember(key, response{http.StatusOK, integration.PaymentResult{ID: id, Status: domain.PaymentRefunded}})
+}
+
+// void снимает блокировку авторизованной суммы. Списанный платёж
+// отменить нельзя — только вернуть.
+func (s *Server) void(key, id string) response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if res, ok := s.remembered(key); ok {
+		return res
+	}
+	p, ok := s.payments[id]
+	switch {
+	case !ok:
+		return response{http.StatusNotFound, integration.PaymentResult{ID: id}}
+	case p.Status != domain.PaymentAuthorized:
+		return response{http.StatusConflict, integration.PaymentResult{ID: id, Status: p.Status}}
+	}
+	p.Status = domain.PaymentCancelled
+	return s.remember(key, response{http.StatusOK, integration.PaymentResult{ID: id, Status: p.Status}})
+}
+
+// confirmLater меняет статус платежа через ConfirmDelay и отправляет вебхук.
+func (s *Server) confirmLater(id, status, reason string) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		time.Sleep(s.cfg.ConfirmDelay)
+		s.mu.Lock()
+		s.payments[id].Status = status
+		s.mu.Unlock()
+		s.sendWebhook(domain.PaymentEvent{PaymentID: id, Status: status, DeclineReason: reason})
+	}()
+}
+
+// sendWebhook отправляет подписанное уведомление, повторяя при ошибках.
+func (s *Server) sendWebhook(evt domain.PaymentEvent) {
+	if s.cfg.WebhookURL == "" {
+		return
+	}
+	body, _ := json.Marshal(evt)
+	for attempt := 0; attempt < 5; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(integration.PaymentSignatureHeader, integration.SignPaymentWebhook(s.cfg.WebhookSecret, body, time.Now()))
+		resp, err := s.client.Do(req)
+		cancel()
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return
+			}
+		}
+	}
+}
+
+func randomID() string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("fakepay id: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
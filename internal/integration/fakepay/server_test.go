@@ -0,0 +1,188 @@
+package fakepay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+)
+
+var webhookSecret = []byte("whsec")
+
+// newTestGateway запускает fake-шлюз и клиент к нему. Вебхуки шлюза
+// складываются в канал.
+func newTestGateway(t *testing.T, cfg Config, timeout time.Duration) (*Server, *integration.PaymentGatewayClient, <-chan domain.PaymentEvent) {
+	t.Helper()
+	events := make(chan domain.PaymentEvent, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := integration.VerifyPaymentWebhook(webhookSecret, r.Header.Get(integration.PaymentSignatureHeader), body, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var evt domain.PaymentEvent
+		require.NoError(t, json.Unmarshal(body, &evt))
+		events <- evt
+	}))
+	t.Cleanup(hook.Close)
+
+	cfg.APIKey = "key"
+	cfg.WebhookURL = hook.URL
+	cfg.WebhookSecret = webhookSecret
+	srv := New(cfg)
+	gw := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		gw.Close()
+		srv.Wait()
+	})
+	client := integration.NewPaymentGateway(integration.PaymentGatewayConfig{URL: gw.URL, APIKey: "key", Timeout: timeout})
+	return srv, client, events
+}
+
+func authorization(key, token string) integration.PaymentAuthorization {
+	return integration.PaymentAuthorization{IdempotencyKey: key, OrderID: 7, Amount: domain.NewMoney(35000), Token: token}
+}
+
+func TestFakepay_AuthorizeCaptureRefund(t *testing.T) {
+	_, client, _ := newTestGateway(t, Config{}, time.Second)
+	ctx := context.Background()
+
+	res, err := client.Authorize(ctx, authorization("payment-1", "tok_ok"))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentAuthorized, res.Status)
+
+	captured, err := client.Capture(ctx, res.ID, domain.NewMoney(35000))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentCaptured, captured.Status)
+	// Повторное списание с тем же ключом не ошибка
+	captured, err = client.Capture(ctx, res.ID, domain.NewMoney(35000))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentCaptured, captured.Status)
+
+	refunded, err := client.Refund(ctx, res.ID, "refund-"+res.ID, domain.NewMoney(35000))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentRefunded, refunded.Status)
+}
+
+func TestFakepay_Declines(t *testing.T) {
+	_, client, _ := newTestGateway(t, Config{}, time.Second)
+	ctx := context.Background()
+
+	res, err := client.Authorize(ctx, authorization("payment-1", TokenInsufficientFunds))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentDeclined, res.Status)
+	assert.Equal(t, "insufficient_funds", res.DeclineReason)
+
+	res, err = client.Authorize(ctx, authorization("payment-2", TokenCaptureDecline))
+	require.NoError(t, err)
+	require.Equal(t, domain.PaymentAuthorized, res.Status)
+	captured, err := client.Capture(ctx, res.ID, domain.NewMoney(35000))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentDeclined, captured.Status)
+}
+
+func TestFakepay_TimeoutThenIdempotentRetry(t *testing.T) {
+	srv, client, _ := newTestGateway(t, Config{TimeoutDelay: time.Second}, 100*time.Millisecond)
+	ctx := context.Background()
+
+	_, err := client.Authorize(ctx, authorization("payment-1", TokenTimeout))
+	require.Error(t, err)
+
+	// Шлюз успел авторизовать платёж; повтор с тем же ключом возвращает его, а не создаёт второй
+	res, err := client.Authorize(ctx, authorization("payment-1", TokenTimeout))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentAuthorized, res.Status)
+	srv.mu.Lock()
+	assert.Len(t, srv.payments, 1)
+	srv.mu.Unlock()
+}
+
+func TestFakepay_DelayedConfirmationWebhook(t *testing.T) {
+	srv, client, events := newTestGateway(t, Config{ConfirmDelay: 10 * time.Millisecond}, time.Second)
+	ctx := context.Background()
+
+	res, err := client.Authorize(ctx, authorization("payment-1", TokenDelayed))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentPending, res.Status)
+
+	select {
+	case evt := <-events:
+		assert.Equal(t, domain.PaymentEvent{PaymentID: res.ID, Status: domain.PaymentAuthorized}, evt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	assert.Equal(t, domain.PaymentAuthorized, srv.Status(res.ID))
+
+	res, err = client.Authorize(ctx, authorization("payment-2", TokenDelayedDecline))
+	require.NoError(t, err)
+	select {
+	case evt := <-events:
+		assert.Equal(t, domain.PaymentDeclined, evt.Status)
+		assert.Equal(t, "card_declined", evt.DeclineReason)
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestFakepay_RequiresAPIKey(t *testing.T) {
+	srv := New(Config{APIKey: "key"})
+	rw := httptest.NewRecorder()
+	srv.ServeHTTP(rw, httptest.NewRequest("POST", "/payments", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+}
+
+func TestFakepay_PartialRefunds(t *testing.T) {
+	srv, client, _ := newTestGateway(t, Config{}, time.Second)
+	ctx := context.Background()
+	res, err := client.Authorize(ctx, authorization("payment-1", "tok_ok"))
+	require.NoError(t, err)
+	_, err = client.Capture(ctx, res.ID, domain.NewMoney(35000))
+	require.NoError(t, err)
+
+	refunded, err := client.Refund(ctx, res.ID, "return-1", domain.NewMoney(10000))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentRefunded, refunded.Status)
+	assert.Equal(t, domain.PaymentCaptured, srv.Status(res.ID))
+	// Повтор с тем же ключом не возвращает деньги второй раз
+	_, err = client.Refund(ctx, res.ID, "return-1", domain.NewMoney(10000))
+	require.NoError(t, err)
+	_, err = client.Refund(ctx, res.ID, "return-2", domain.NewMoney(30000))
+	require.Error(t, err)
+
+	_, err = client.Refund(ctx, res.ID, "refund-"+res.ID, domain.NewMoney(25000))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentRefunded, srv.Status(res.ID))
+}
+
+func TestFakepay_VoidReleasesAuthorization(t *testing.T) {
+	srv, client, _ := newTestGateway(t, Config{}, time.Second)
+	ctx := context.Background()
+	res, err := client.Authorize(ctx, authorization("payment-1", "tok_ok"))
+	require.NoError(t, err)
+
+	voided, err := client.Void(ctx, res.ID, domain.NewMoney(35000))
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentCancelled, voided.Status)
+	assert.Equal(t, domain.PaymentCancelled, srv.Status(res.ID))
+	// Повтор с тем же ключом не ошибка, а списать отменённое нельзя
+	_, err = client.Void(ctx, res.ID, domain.NewMoney(35000))
+	require.NoError(t, err)
+	_, err = client.Capture(ctx, res.ID, domain.NewMoney(35000))
+	require.Error(t, err)
+
+	// Списанный платёж не отменить
+	res, err = client.Authorize(ctx, authorization("payment-2", "tok_ok"))
+	require.NoError(t, err)
+	_, err = client.Capture(ctx, res.ID, domain.NewMoney(35000))
+	require.NoError(t, err)
+	_, err = client.Void(ctx, res.ID, domain.NewMoney(35000))
+	require.Error(t, err)
+}
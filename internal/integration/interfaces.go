@@ -2,10 +2,12 @@ package integration
 
 import (
 	"context"
+
+	"github.com/yourorg/bookshop/internal/domain"
 )
 
 type KeycloakClient interface {
-	ValidateToken(ctx context.Context, token string) (userID, email string, roles []string, err error)
+	ValidateToken(ctx context.Context, token string) (*domain.Principal, error)
 }
 
 type RedisCache interface {
@@ -13,8 +15,24 @@ type RedisCache interface {
 	Set(key string, value string, ttlSeconds int) error
 	Del(key string) error
 	TTL(key string) (int64, error)
+	SetNX(key string, value string, ttlSeconds int) (bool, error)
 }
 
 type KafkaProducer interface {
 	PublishOrderPlaced(ctx context.Context, orderID int, userID string, books []OrderPlacedBook) error
+	PublishOrderStatusChanged(ctx context.Context, evt domain.OrderStatusChangedEvent) error
+	PublishOrderCancelled(ctx context.Context, evt domain.OrderCancelledEvent) error
+	PublishReturnEvent(ctx context.Context, evt domain.ReturnEvent) error
+}
+
+// PaymentProvider — платёжный шлюз. Authorize блокирует сумму на карте,
+// Capture списывает заблокированное, Refund возвращает списанное целиком
+// или частями; у каждого возврата свой idempotencyKey, Void снимает
+// блокировку, если списывать уже не нужно. Ответ со статусом pending
+// означает, что итог придёт вебхуком.
+type PaymentProvider interface {
+	Authorize(ctx context.Context, req PaymentAuthorization) (*PaymentResult, error)
+	Capture(ctx context.Context, paymentID string, amount domain.Money) (*PaymentResult, error)
+	Refund(ctx context.Context, paymentID, idempotencyKey string, amount domain.Money) (*PaymentResult, error)
+	Void(ctx context.Context, paymentID string, amount domain.Money) (*PaymentResult, error)
 }
@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// introspector проверяет токены через OAuth2 token introspection (RFC 7662).
+// Активные результаты кэшируются в Redis до истечения токена, чтобы не
+// ходить в Keycloak на каждый запрос.
+type introspector struct {
+	url          string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+	cache        RedisCache
+	cacheTTL     time.Duration
+}
+
+var errTokenInactive = errors.New("token is not active")
+
+func (i *introspector) claims(ctx context.Context, token string) (jwt.MapClaims, error) {
+	key := introspectionCacheKey(token)
+	if i.cache != nil {
+		if cached, err := i.cache.Get(key); err == nil && cached != "" {
+			var claims jwt.MapClaims
+			if err := json.Unmarshal([]byte(cached), &claims); err == nil {
+				if exp, err := claims.GetExpirationTime(); err == nil && exp != nil && exp.After(time.Now()) {
+					return claims, nil
+				}
+			}
+		}
+	}
+
+	claims, err := i.request(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, errTokenInactive
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, errors.New("introspection response has no exp")
+	}
+	if i.cache != nil {
+		ttl := time.Until(exp.Time)
+		if i.cacheTTL > 0 && i.cacheTTL < ttl {
+			ttl = i.cacheTTL
+		}
+		if seconds := int(ttl / time.Second); seconds > 0 {
+			if data, err := json.Marshal(claims); err == nil {
+				i.cache.Set(key, string(data), seconds)
+			}
+		}
+	}
+	return claims, nil
+}
+
+func (i *introspector) request(ctx context.Context, token string) (jwt.MapClaims, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("introspection request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspect token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspect token: unexpected status %d", resp.StatusCode)
+	}
+	var claims jwt.MapClaims
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("decode introspection response: %w", err)
+	}
+	return claims, nil
+}
+
+// introspectionCacheKey не хранит сам токен в Redis.
+func introspectionCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "introspect:" + hex.EncodeToString(sum[:])
+}
@@ -5,13 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/yourorg/bookshop/internal/domain"
 )
 
 type KafkaProducerImpl struct {
-	writer     *kafka.Writer
-	orderTopic string
+	writer          *kafka.Writer
+	statusWriter    *kafka.Writer
+	cancelledWriter *kafka.Writer
+	returnWriter    *kafka.Writer
+	orderTopic      string
 }
 
 func NewKafkaProducer() *KafkaProducerImpl {
@@ -23,36 +28,94 @@ func NewKafkaProducer() *KafkaProducerImpl {
 	if topic == "" {
 		topic = "order_placed"
 	}
+	statusTopic := os.Getenv("KAFKA_ORDER_STATUS_TOPIC")
+	if statusTopic == "" {
+		statusTopic = "order_status_changed"
+	}
+	cancelledTopic := os.Getenv("KAFKA_ORDER_CANCELLED_TOPIC")
+	if cancelledTopic == "" {
+		cancelledTopic = "order_cancelled"
+	}
+	returnTopic := os.Getenv("KAFKA_ORDER_RETURN_TOPIC")
+	if returnTopic == "" {
+		returnTopic = "order_returns"
+	}
 	return &KafkaProducerImpl{
 		writer: &kafka.Writer{
 			Addr:     kafka.TCP(brokers...),
 			Topic:    topic,
 			Balancer: &kafka.LeastBytes{},
 		},
+		// Ключ сообщения — id заказа, поэтому события одного заказа
+		// попадают в одну партицию и читаются по порядку.
+		statusWriter: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    statusTopic,
+			Balancer: &kafka.Hash{},
+		},
+		cancelledWriter: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    cancelledTopic,
+			Balancer: &kafka.Hash{},
+		},
+		returnWriter: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    returnTopic,
+			Balancer: &kafka.Hash{},
+		},
 		orderTopic: topic,
 	}
 }
 
-type OrderPlacedBook struct {
-	BookID   int `json:"book_id"`
-	Quantity int `json:"quantity"`
+// OrderPlacedBook — позиция в событиях заказа.
+type OrderPlacedBook = domain.OrderEventBook
+
+func (k *KafkaProducerImpl) PublishOrderPlaced(ctx context.Context, orderID int, userID string, books []OrderPlacedBook) error {
+	evt := domain.OrderPlacedEvent{OrderID: orderID, UserID: userID, Books: books}
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	if err := k.writer.WriteMessages(ctx, kafka.Message{Value: data}); err != nil {
+		return fmt.Errorf("write kafka: %w", err)
+	}
+	return nil
 }
 
-type orderPlacedEvent struct {
-	OrderID int               `json:"order_id"`
-	UserID  string            `json:"user_id"`
-	Books   []OrderPlacedBook `json:"books"`
+func (k *KafkaProducerImpl) PublishOrderStatusChanged(ctx context.Context, evt domain.OrderStatusChangedEvent) error {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	msg := kafka.Message{Key: []byte(strconv.Itoa(evt.OrderID)), Value: data}
+	if err := k.statusWriter.WriteMessages(ctx, msg); err != nil {
+		return fmt.Errorf("write kafka: %w", err)
+	}
+	return nil
 }
 
-func (k *KafkaProducerImpl) PublishOrderPlaced(ctx context.Context, orderID int, userID string, books []OrderPlacedBook) error {
-	evt := orderPlacedEvent{OrderID: orderID, UserID: userID, Books: books}
+func (k *KafkaProducerImpl) PublishOrderCancelled(ctx context.Context, evt domain.OrderCancelledEvent) error {
 	data, err := json.Marshal(evt)
 	if err != nil {
 		return fmt.Errorf("marshal event: %w", err)
 	}
-	if err := k.writer.WriteMessages(ctx, kafka.Message{Value: data}); err != nil {
+	msg := kafka.Message{Key: []byte(strconv.Itoa(evt.OrderID)), Value: data}
+	if err := k.cancelledWriter.WriteMessages(ctx, msg); err != nil {
+		return fmt.Errorf("write kafka: %w", err)
+	}
+	return nil
+}
+
+// PublishReturnEvent публикует шаг возврата; все шаги идут в один топик
+// с ключом — id заказа.
+func (k *KafkaProducerImpl) PublishReturnEvent(ctx context.Context, evt domain.ReturnEvent) error {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	msg := kafka.Message{Key: []byte(strconv.Itoa(evt.OrderID)), Value: data}
+	if err := k.returnWriter.WriteMessages(ctx, msg); err != nil {
 		return fmt.Errorf("write kafka: %w", err)
 	}
 	return nil
 }
- 
\ No newline at end of file
@@ -4,44 +4,181 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourorg/bookshop/internal/domain"
 )
 
+const (
+	// KeycloakModeJWT — локальная проверка подписи и claims по JWKS.
+	KeycloakModeJWT = "jwt"
+	// KeycloakModeIntrospection — проверка каждого токена через
+	// token introspection endpoint (RFC 7662): отозванные сессии
+	// перестают работать сразу, поддерживаются непрозрачные токены.
+	KeycloakModeIntrospection = "introspection"
+)
+
+type KeycloakConfig struct {
+	URL      string
+	Realm    string
+	ClientID string
+	// ClientSecret нужен только для introspection: endpoint доступен
+	// лишь конфиденциальным клиентам.
+	ClientSecret string
+	// Issuer переопределяет ожидаемый iss, если Keycloak доступен
+	// сервису по другому адресу, чем клиентам (например, keycloak:8080 и localhost:8080).
+	Issuer string
+	Leeway time.Duration
+	Mode   string
+	// IntrospectionCacheTTL ограничивает время жизни закэшированного
+	// результата introspection. 0 — кэшировать до истечения токена.
+	IntrospectionCacheTTL time.Duration
+}
+
 type KeycloakClientImpl struct {
-	// publicKey, issuer, audience и др. можно добавить в конфиг
+	issuer     string
+	clientID   string
+	leeway     time.Duration
+	mode       string
+	jwks       *jwksCache
+	introspect *introspector
 }
 
-func NewKeycloakClient() *KeycloakClientImpl {
-	return &KeycloakClientImpl{}
+// NewKeycloakClient создаёт клиент Keycloak. cache используется только
+// в режиме introspection и может быть nil.
+func NewKeycloakClient(cfg KeycloakConfig, cache RedisCache) *KeycloakClientImpl {
+	realmURL := strings.TrimRight(cfg.URL, "/") + "/realms/" + cfg.Realm
+	issuer := cfg.Issuer
+	if issuer == "" {
+		issuer = realmURL
+	}
+	mode := cfg.Mode
+	if mode == "" {
+		mode = KeycloakModeJWT
+	}
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	return &KeycloakClientImpl{
+		issuer:   issuer,
+		clientID: cfg.ClientID,
+		leeway:   cfg.Leeway,
+		mode:     mode,
+		jwks:     newJWKSCache(realmURL+"/protocol/openid-connect/certs", httpClient),
+		introspect: &introspector{
+			url:          realmURL + "/protocol/openid-connect/token/introspect",
+			clientID:     cfg.ClientID,
+			clientSecret: cfg.ClientSecret,
+			httpClient:   httpClient,
+			cache:        cache,
+			cacheTTL:     cfg.IntrospectionCacheTTL,
+		},
+	}
 }
 
-func (k *KeycloakClientImpl) ValidateToken(ctx context.Context, tokenStr string) (userID, email string, roles []string, err error) {
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenStr, jwt.MapClaims{})
+func (k *KeycloakClientImpl) ValidateToken(ctx context.Context, tokenStr string) (*domain.Principal, error) {
+	var claims jwt.MapClaims
+	var err error
+	switch k.mode {
+	case KeycloakModeIntrospection:
+		claims, err = k.introspect.claims(ctx, tokenStr)
+	case KeycloakModeJWT:
+		claims, err = k.verifyJWT(ctx, tokenStr)
+	default:
+		err = fmt.Errorf("unknown keycloak mode %q", k.mode)
+	}
 	if err != nil {
-		return "", "", nil, fmt.Errorf("invalid token format: %w", errors.New("invalid token format"))
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if err := k.validateAudience(claims); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	p := &domain.Principal{
+		Roles:       extractRoles(claims),
+		ClientRoles: extractClientRoles(claims),
+	}
+	p.UserID, _ = claims["sub"].(string)
+	p.Email, _ = claims["email"].(string)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		p.ExpiresAt = exp.Time
+	}
+	return p, nil
+}
+
+func (k *KeycloakClientImpl) verifyJWT(ctx context.Context, tokenStr string) (jwt.MapClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(k.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(k.leeway),
+	)
+	token, err := parser.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("missing kid")
+		}
+		return k.jwks.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
 	}
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", "", nil, fmt.Errorf("invalid claims: %w", errors.New("invalid claims"))
+		return nil, errors.New("invalid claims")
+	}
+	return claims, nil
+}
+
+// validateAudience проверяет, что токен выпущен для нашего клиента.
+// Keycloak кладёт в aud только клиентов с ролями ("account"), поэтому
+// принимаем также совпадение azp.
+func (k *KeycloakClientImpl) validateAudience(claims jwt.MapClaims) error {
+	if k.clientID == "" {
+		return nil
 	}
-	uid, _ := claims["sub"].(string)
-	email, _ = claims["email"].(string)
-	roles = extractRoles(claims)
-	return uid, email, roles, nil
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return err
+	}
+	for _, a := range aud {
+		if a == k.clientID {
+			return nil
+		}
+	}
+	if azp, _ := claims["azp"].(string); azp == k.clientID {
+		return nil
+	}
+	return errors.New("token is not issued for this client")
 }
 
 func extractRoles(claims jwt.MapClaims) []string {
-	var roles []string
-	if realm, ok := claims["realm_access"].(map[string]interface{}); ok {
-		if rs, ok := realm["roles"].([]interface{}); ok {
-			for _, r := range rs {
-				if s, ok := r.(string); ok {
-					roles = append(roles, s)
-				}
-			}
+	realm, _ := claims["realm_access"].(map[string]interface{})
+	return stringList(realm["roles"])
+}
+
+func extractClientRoles(claims jwt.MapClaims) map[string][]string {
+	resources, ok := claims["resource_access"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	roles := make(map[string][]string, len(resources))
+	for client, v := range resources {
+		if access, ok := v.(map[string]interface{}); ok {
+			roles[client] = stringList(access["roles"])
 		}
 	}
 	return roles
 }
- 
\ No newline at end of file
+
+func stringList(v interface{}) []string {
+	var res []string
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,412 @@
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testJWKSServer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+	hits int
+}
+
+func newTestJWKSServer(t *testing.T) *testJWKSServer {
+	s := &testJWKSServer{keys: map[string]*rsa.PrivateKey{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/realms/bookshop/protocol/openid-connect/certs", r.URL.Path)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.hits++
+		var keys []map[string]string
+		for kid, k := range s.keys {
+			keys = append(keys, map[string]string{
+				"kid": kid,
+				"kty": "RSA",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testJWKSServer) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+	return key
+}
+
+func (s *testJWKSServer) fetches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits
+}
+
+func (s *testJWKSServer) issuer() string {
+	return s.URL + "/realms/bookshop"
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = kid
+	s, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func validClaims(issuer string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "user-1",
+		"email": "user@ex.com",
+		"iss":   issuer,
+		"aud":   "account",
+		"azp":   "bookshop-api",
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"realm_access": map[string]interface{}{
+			"roles": []string{"user", "admin"},
+		},
+		"resource_access": map[string]interface{}{
+			"account": map[string]interface{}{
+				"roles": []string{"view-profile"},
+			},
+		},
+	}
+}
+
+func newTestKeycloak(srv *testJWKSServer) *KeycloakClientImpl {
+	return NewKeycloakClient(KeycloakConfig{URL: srv.URL, Realm: "bookshop", ClientID: "bookshop-api"}, nil)
+}
+
+func TestKeycloakClient_ValidateToken_Success(t *testing.T) {
+	srv := newTestJWKSServer(t)
+	key := srv.addKey(t, "k1")
+	kc := newTestKeycloak(srv)
+
+	claims := validClaims(srv.issuer())
+	p, err := kc.ValidateToken(context.Background(), signToken(t, key, "k1", claims))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.UserID)
+	assert.Equal(t, "user@ex.com", p.Email)
+	assert.ElementsMatch(t, []string{"user", "admin"}, p.Roles)
+	assert.True(t, p.HasClientRole("account", "view-profile"))
+	assert.False(t, p.HasClientRole("bookshop-api", "view-profile"))
+	assert.Equal(t, claims["exp"], p.ExpiresAt.Unix())
+
+	// Повторная проверка не должна заново скачивать JWKS
+	_, err = kc.ValidateToken(context.Background(), signToken(t, key, "k1", validClaims(srv.issuer())))
+	require.NoError(t, err)
+	assert.Equal(t, 1, srv.fetches())
+}
+
+func TestKeycloakClient_ValidateToken_ForgedSignature(t *testing.T) {
+	srv := newTestJWKSServer(t)
+	srv.addKey(t, "k1")
+	kc := newTestKeycloak(srv)
+
+	forged, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = kc.ValidateToken(context.Background(), signToken(t, forged, "k1", validClaims(srv.issuer())))
+	require.Error(t, err)
+}
+
+func TestKeycloakClient_ValidateToken_RejectsUnsigned(t *testing.T) {
+	srv := newTestJWKSServer(t)
+	srv.addKey(t, "k1")
+	kc := newTestKeycloak(srv)
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims(srv.issuer()))
+	tok.Header["kid"] = "k1"
+	unsigned, err := tok.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = kc.ValidateToken(context.Background(), unsigned)
+	require.Error(t, err)
+}
+
+func TestKeycloakClient_ValidateToken_Claims(t *testing.T) {
+	srv := newTestJWKSServer(t)
+	key := srv.addKey(t, "k1")
+	kc := newTestKeycloak(srv)
+
+	cases := map[string]func(c jwt.MapClaims){
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no exp":         func(c jwt.MapClaims) { delete(c, "exp") },
+		"not yet valid":  func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Minute).Unix() },
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "http://evil/realms/bookshop" },
+		"foreign client": func(c jwt.MapClaims) { c["azp"] = "other-client" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims(srv.issuer())
+			mutate(claims)
+			_, err := kc.ValidateToken(context.Background(), signToken(t, key, "k1", claims))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestKeycloakClient_ValidateToken_AudienceWithoutAzp(t *testing.T) {
+	srv := newTestJWKSServer(t)
+	key := srv.addKey(t, "k1")
+	kc := newTestKeycloak(srv)
+
+	claims := validClaims(srv.issuer())
+	claims["aud"] = []string{"account", "bookshop-api"}
+	delete(claims, "azp")
+	_, err := kc.ValidateToken(context.Background(), signToken(t, key, "k1", claims))
+	require.NoError(t, err)
+}
+
+func TestKeycloakClient_ValidateToken_IssuerOverride(t *testing.T) {
+	srv := newTestJWKSServer(t)
+	key := srv.addKey(t, "k1")
+	kc := NewKeycloakClient(KeycloakConfig{
+		URL:      srv.URL,
+		Realm:    "bookshop",
+		ClientID: "bookshop-api",
+		Issuer:   "http://localhost:8080/realms/bookshop",
+	}, nil)
+
+	_, err := kc.ValidateToken(context.Background(), signToken(t, key, "k1", validClaims("http://localhost:8080/realms/bookshop")))
+	require.NoError(t, err)
+	_, err = kc.ValidateToken(context.Background(), signToken(t, key, "k1", validClaims(srv.issuer())))
+	require.Error(t, err)
+}
+
+func TestKeycloakClient_ValidateToken_KeyRotation(t *testing.T) {
+	srv := newTestJWKSServer(t)
+	oldKey := srv.addKey(t, "k1")
+	kc := newTestKeycloak(srv)
+	kc.jwks.minInterval = 0
+
+	_, err := kc.ValidateToken(context.Background(), signToken(t, oldKey, "k1", validClaims(srv.issuer())))
+	require.NoError(t, err)
+
+	newKey := srv.addKey(t, "k2")
+	_, err = kc.ValidateToken(context.Background(), signToken(t, newKey, "k2", validClaims(srv.issuer())))
+	require.NoError(t, err)
+	assert.Equal(t, 2, srv.fetches())
+
+	_, err = kc.ValidateToken(context.Background(), signToken(t, newKey, "unknown", validClaims(srv.issuer())))
+	require.Error(t, err)
+}
+
+func TestKeycloakClient_ValidateToken_UnknownKidRateLimited(t *testing.T) {
+	srv := newTestJWKSServer(t)
+	key := srv.addKey(t, "k1")
+	kc := newTestKeycloak(srv)
+
+	for i := 0; i < 3; i++ {
+		_, err := kc.ValidateToken(context.Background(), signToken(t, key, "unknown", validClaims(srv.issuer())))
+		require.Error(t, err)
+	}
+	assert.Equal(t, 1, srv.fetches())
+}
+
+type memCache struct {
+	mu   sync.Mutex
+	vals map[string]string
+	ttls map[string]int
+}
+
+func newMemCache() *memCache {
+	return &memCache{vals: map[string]string{}, ttls: map[string]int{}}
+}
+
+func (c *memCache) Get(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.vals[key]
+	if !ok {
+		return "", errors.New("redis: nil")
+	}
+	return v, nil
+}
+
+func (c *memCache) Set(key, value string, ttlSeconds int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vals[key] = value
+	c.ttls[key] = ttlSeconds
+	return nil
+}
+
+func (c *memCache) Del(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.vals, key)
+	delete(c.ttls, key)
+	return nil
+}
+
+func (c *memCache) TTL(key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int64(c.ttls[key]), nil
+}
+
+func (c *memCache) SetNX(key, value string, ttlSeconds int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.vals[key]; ok {
+		return false, nil
+	}
+	c.vals[key] = value
+	c.ttls[key] = ttlSeconds
+	return true, nil
+}
+
+type testIntrospectionServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	active map[string]jwt.MapClaims
+	hits   int
+}
+
+func newTestIntrospectionServer(t *testing.T) *testIntrospectionServer {
+	s := &testIntrospectionServer{active: map[string]jwt.MapClaims{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/realms/bookshop/protocol/openid-connect/token/introspect", r.URL.Path)
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "bookshop-api" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.hits++
+		claims, ok := s.active[r.PostForm.Get("token")]
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]bool{"active": false})
+			return
+		}
+		resp := jwt.MapClaims{"active": true}
+		for k, v := range claims {
+			resp[k] = v
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testIntrospectionServer) issue(token string, claims jwt.MapClaims) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[token] = claims
+}
+
+func (s *testIntrospectionServer) revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, token)
+}
+
+func (s *testIntrospectionServer) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits
+}
+
+func newTestIntrospectionKeycloak(srv *testIntrospectionServer, cache RedisCache, cacheTTL time.Duration) *KeycloakClientImpl {
+	return NewKeycloakClient(KeycloakConfig{
+		URL:                   srv.URL,
+		Realm:                 "bookshop",
+		ClientID:              "bookshop-api",
+		ClientSecret:          "s3cret",
+		Mode:                  KeycloakModeIntrospection,
+		IntrospectionCacheTTL: cacheTTL,
+	}, cache)
+}
+
+func TestKeycloakClient_Introspection_OpaqueToken(t *testing.T) {
+	srv := newTestIntrospectionServer(t)
+	srv.issue("opaque-token", validClaims("ignored"))
+	cache := newMemCache()
+	kc := newTestIntrospectionKeycloak(srv, cache, 0)
+
+	p, err := kc.ValidateToken(context.Background(), "opaque-token")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.UserID)
+	assert.Equal(t, "user@ex.com", p.Email)
+	assert.ElementsMatch(t, []string{"user", "admin"}, p.Roles)
+
+	// Второй вызов обслуживается из кэша, TTL — до истечения токена
+	_, err = kc.ValidateToken(context.Background(), "opaque-token")
+	require.NoError(t, err)
+	assert.Equal(t, 1, srv.requests())
+	ttl, _ := cache.TTL(introspectionCacheKey("opaque-token"))
+	assert.InDelta(t, 300, ttl, 5)
+	_, err = cache.Get("introspect:opaque-token")
+	assert.Error(t, err, "raw token must not be used as a cache key")
+}
+
+func TestKeycloakClient_Introspection_RevokedToken(t *testing.T) {
+	srv := newTestIntrospectionServer(t)
+	srv.issue("token", validClaims("ignored"))
+	kc := newTestIntrospectionKeycloak(srv, nil, 0)
+
+	_, err := kc.ValidateToken(context.Background(), "token")
+	require.NoError(t, err)
+
+	srv.revoke("token")
+	_, err = kc.ValidateToken(context.Background(), "token")
+	require.ErrorIs(t, err, errTokenInactive)
+}
+
+func TestKeycloakClient_Introspection_CacheTTLCap(t *testing.T) {
+	srv := newTestIntrospectionServer(t)
+	srv.issue("token", validClaims("ignored"))
+	cache := newMemCache()
+	kc := newTestIntrospectionKeycloak(srv, cache, 10*time.Second)
+
+	_, err := kc.ValidateToken(context.Background(), "token")
+	require.NoError(t, err)
+	ttl, _ := cache.TTL(introspectionCacheKey("token"))
+	assert.Equal(t, int64(10), ttl)
+}
+
+func TestKeycloakClient_Introspection_ForeignClient(t *testing.T) {
+	srv := newTestIntrospectionServer(t)
+	claims := validClaims("ignored")
+	claims["azp"] = "other-client"
+	srv.issue("token", claims)
+	kc := newTestIntrospectionKeycloak(srv, nil, 0)
+
+	_, err := kc.ValidateToken(context.Background(), "token")
+	require.Error(t, err)
+}
+
+func TestKeycloakClient_Introspection_BadCredentials(t *testing.T) {
+	srv := newTestIntrospectionServer(t)
+	srv.issue("token", validClaims("ignored"))
+	kc := newTestIntrospectionKeycloak(srv, nil, 0)
+	kc.introspect.clientSecret = "wrong"
+
+	_, err := kc.ValidateToken(context.Background(), "token")
+	require.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+// PaymentSignatureHeader — заголовок с подписью вебхука платёжного шлюза:
+// "t=<unix time>,v1=<hex HMAC-SHA256 от "<t>.<тело>">".
+const PaymentSignatureHeader = "X-Payment-Signature"
+
+// PaymentAuthorization — запрос авторизации. IdempotencyKey одинаков для
+// повторов одного платежа: шлюз не заблокирует сумму дважды, если первый
+// запрос дошёл, а ответ потерялся.
+type PaymentAuthorization struct {
+	IdempotencyKey string
+	OrderID        int
+	Amount         domain.Money
+	Token          string
+}
+
+// PaymentResult — ответ шлюза. Status — один из domain.Payment*.
+type PaymentResult struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	DeclineReason string `json:"decline_reason,omitempty"`
+}
+
+type PaymentGatewayConfig struct {
+	URL    string
+	APIKey string
+	// Timeout ограничивает каждый запрос к шлюзу.
+	Timeout time.Duration
+}
+
+// PaymentGatewayClient — HTTP-клиент платёжного шлюза. Тот же протокол
+// реализует fakepay для локального запуска и тестов.
+type PaymentGatewayClient struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewPaymentGateway(cfg PaymentGatewayConfig) *PaymentGatewayClient {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &PaymentGatewayClient{
+		url:        strings.TrimRight(cfg.URL, "/"),
+		apiKey:     cfg.APIKey,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type paymentRequest struct {
+	OrderID  int          `json:"order_id,omitempty"`
+	Amount   domain.Money `json:"amount"`
+	Currency string       `json:"currency"`
+	Token    string       `json:"token,omitempty"`
+}
+
+func (c *PaymentGatewayClient) Authorize(ctx context.Context, req PaymentAuthorization) (*PaymentResult, error) {
+	body := paymentRequest{OrderID: req.OrderID, Amount: req.Amount, Currency: req.Amount.Currency, Token: req.Token}
+	return c.do(ctx, "/payments", req.IdempotencyKey, body)
+}
+
+func (c *PaymentGatewayClient) Capture(ctx context.Context, paymentID string, amount domain.Money) (*PaymentResult, error) {
+	body := paymentRequest{Amount: amount, Currency: amount.Currency}
+	return c.do(ctx, "/payments/"+url.PathEscape(paymentID)+"/capture", "capture-"+paymentID, body)
+}
+
+func (c *PaymentGatewayClient) Refund(ctx context.Context, paymentID, idempotencyKey string, amount domain.Money) (*PaymentResult, error) {
+	body := paymentRequest{Amount: amount, Currency: amount.Currency}
+	return c.do(ctx, "/payments/"+url.PathEscape(paymentID)+"/refund", idempotencyKey, body)
+}
+
+func (c *PaymentGatewayClient) Void(ctx context.Context, paymentID string, amount domain.Money) (*PaymentResult, error) {
+	body := paymentRequest{Amount: amount, Currency: amount.Currency}
+	return c.do(ctx, "/payments/"+url.PathEscape(paymentID)+"/void", "void-"+paymentID, body)
+}
+
+// do отправляет запрос и разбирает ответ. Отказ (402) — не ошибка, а
+// результат со статусом declined; остальные не-2xx ответы — ошибки.
+func (c *PaymentGatewayClient) do(ctx context.Context, path, idempotencyKey string, body interface{}) (*PaymentResult, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal payment request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("payment gateway request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPaymentRequired {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("payment gateway: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var res PaymentResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode payment response: %w", err)
+	}
+	return &res, nil
+}
+
+// SignPaymentWebhook подписывает тело вебхука для заголовка PaymentSignatureHeader.
+func SignPaymentWebhook(secret, body []byte, at time.Time) string {
+	t := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + t + ",v1=" + paymentSignature(secret, t, body)
+}
+
+var ErrInvalidPaymentSignature = errors.New("invalid payment signature")
+
+// VerifyPaymentWebhook проверяет подпись вебхука и что он подписан не
+// раньше, чем tolerance назад: перехваченный запрос нельзя повторить позже.
+func VerifyPaymentWebhook(secret []byte, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			sig = v
+		}
+	}
+	ts, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidPaymentSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(paymentSignature(secret, t, body))) {
+		return ErrInvalidPaymentSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: signed %s ago", ErrInvalidPaymentSignature, age.Round(time.Second))
+	}
+	return nil
+}
+
+func paymentSignature(secret []byte, t string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
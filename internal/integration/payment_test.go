@@ -0,0 +1,35 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPaymentWebhook(t *testing.T) {
+	secret := []byte("whsec")
+	body := []byte(`{"payment_id":"pay_1","status":"authorized"}`)
+	now := time.Unix(1_700_000_000, 0)
+	sig := SignPaymentWebhook(secret, body, now)
+
+	require.NoError(t, VerifyPaymentWebhook(secret, sig, body, now.Add(time.Minute), 5*time.Minute))
+
+	cases := map[string]struct {
+		secret []byte
+		header string
+		body   []byte
+		now    time.Time
+	}{
+		"tampered body": {secret, sig, []byte(`{"payment_id":"pay_2","status":"authorized"}`), now},
+		"wrong secret":  {[]byte("other"), sig, body, now},
+		"replayed late": {secret, sig, body, now.Add(10 * time.Minute)},
+		"no signature":  {secret, "", body, now},
+		"garbage":       {secret, "t=abc,v1=00", body, now},
+	}
+	for name, tc := range cases {
+		err := VerifyPaymentWebhook(tc.secret, tc.header, tc.body, tc.now, 5*time.Minute)
+		assert.ErrorIs(t, err, ErrInvalidPaymentSignature, name)
+	}
+}
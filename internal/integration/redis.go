@@ -38,4 +38,10 @@ func (r *RedisCacheImpl) TTL(key string) (int64, error) {
 	}
 	return int64(dur.Seconds()), nil
 }
- 
\ No newline at end of file
+
+// SetNX записывает значение, только если ключа ещё нет. Возвращает true,
+// если запись произошла.
+func (r *RedisCacheImpl) SetNX(key string, value string, ttlSeconds int) (bool, error) {
+	ctx := context.Background()
+	return r.rdb.SetNX(ctx, key, value, time.Duration(ttlSeconds)*time.Second).Result()
+}
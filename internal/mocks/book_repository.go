@@ -7,6 +7,8 @@ import (
 
 	mock "github.com/stretchr/testify/mock"
 	domain "github.com/yourorg/bookshop/internal/domain"
+
+	time "time"
 )
 
 // BookRepository is an autogenerated mock type for the BookRepository type
@@ -14,6 +16,156 @@ type BookRepository struct {
 	mock.Mock
 }
 
+// AdjustInventory provides a mock function with given fields: ctx, m
+func (_m *BookRepository) AdjustInventory(ctx context.Context, m *domain.InventoryMovement) error {
+	ret := _m.Called(ctx, m)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AdjustInventory")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.InventoryMovement) error); ok {
+		r0 = rf(ctx, m)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ApplyDuePrices provides a mock function with given fields: ctx, now
+func (_m *BookRepository) ApplyDuePrices(ctx context.Context, now time.Time) ([]*domain.Book, error) {
+	ret := _m.Called(ctx, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ApplyDuePrices")
+	}
+
+	var r0 []*domain.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) ([]*domain.Book, error)); ok {
+		return rf(ctx, now)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []*domain.Book); ok {
+		r0 = rf(ctx, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Book)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CancelPrice provides a mock function with given fields: ctx, bookID, priceID, now
+func (_m *BookRepository) CancelPrice(ctx context.Context, bookID int, priceID int, now time.Time) error {
+	ret := _m.Called(ctx, bookID, priceID, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelPrice")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, time.Time) error); ok {
+		r0 = rf(ctx, bookID, priceID, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Count provides a mock function with given fields: ctx, filter
+func (_m *BookRepository) Count(ctx context.Context, filter domain.BookFilter) (int, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Count")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) (int, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) int); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.BookFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountMovements provides a mock function with given fields: ctx, bookID
+func (_m *BookRepository) CountMovements(ctx context.Context, bookID int) (int, error) {
+	ret := _m.Called(ctx, bookID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountMovements")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (int, error)); ok {
+		return rf(ctx, bookID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) int); ok {
+		r0 = rf(ctx, bookID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, bookID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountPrices provides a mock function with given fields: ctx, bookID
+func (_m *BookRepository) CountPrices(ctx context.Context, bookID int) (int, error) {
+	ret := _m.Called(ctx, bookID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountPrices")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (int, error)); ok {
+		return rf(ctx, bookID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) int); ok {
+		r0 = rf(ctx, bookID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, bookID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Create provides a mock function with given fields: ctx, book
 func (_m *BookRepository) Create(ctx context.Context, book *domain.Book) error {
 	ret := _m.Called(ctx, book)
@@ -50,6 +202,36 @@ func (_m *BookRepository) Delete(ctx context.Context, id int) error {
 	return r0
 }
 
+// Facets provides a mock function with given fields: ctx, filter
+func (_m *BookRepository) Facets(ctx context.Context, filter domain.BookFilter) (*domain.BookFacets, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Facets")
+	}
+
+	var r0 *domain.BookFacets
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) (*domain.BookFacets, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) *domain.BookFacets); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.BookFacets)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.BookFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetByID provides a mock function with given fields: ctx, id
 func (_m *BookRepository) GetByID(ctx context.Context, id int) (*domain.Book, error) {
 	ret := _m.Called(ctx, id)
@@ -80,9 +262,9 @@ func (_m *BookRepository) GetByID(ctx context.Context, id int) (*domain.Book, er
 	return r0, r1
 }
 
-// List provides a mock function with given fields: ctx, categoryIDs, limit, offset
-func (_m *BookRepository) List(ctx context.Context, categoryIDs []int, limit int, offset int) ([]*domain.Book, error) {
-	ret := _m.Called(ctx, categoryIDs, limit, offset)
+// List provides a mock function with given fields: ctx, filter
+func (_m *BookRepository) List(ctx context.Context, filter domain.BookFilter) ([]*domain.Book, error) {
+	ret := _m.Called(ctx, filter)
 
 	if len(ret) == 0 {
 		panic("no return value specified for List")
@@ -90,19 +272,157 @@ func (_m *BookRepository) List(ctx context.Context, categoryIDs []int, limit int
 
 	var r0 []*domain.Book
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, []int, int, int) ([]*domain.Book, error)); ok {
-		return rf(ctx, categoryIDs, limit, offset)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) ([]*domain.Book, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) []*domain.Book); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Book)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.BookFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListMovements provides a mock function with given fields: ctx, bookID, page
+func (_m *BookRepository) ListMovements(ctx context.Context, bookID int, page domain.PageRequest) ([]*domain.InventoryMovement, error) {
+	ret := _m.Called(ctx, bookID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListMovements")
+	}
+
+	var r0 []*domain.InventoryMovement
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.PageRequest) ([]*domain.InventoryMovement, error)); ok {
+		return rf(ctx, bookID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.PageRequest) []*domain.InventoryMovement); ok {
+		r0 = rf(ctx, bookID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.InventoryMovement)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, domain.PageRequest) error); ok {
+		r1 = rf(ctx, bookID, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListPrices provides a mock function with given fields: ctx, bookID, page
+func (_m *BookRepository) ListPrices(ctx context.Context, bookID int, page domain.PageRequest) ([]*domain.BookPrice, error) {
+	ret := _m.Called(ctx, bookID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListPrices")
+	}
+
+	var r0 []*domain.BookPrice
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.PageRequest) ([]*domain.BookPrice, error)); ok {
+		return rf(ctx, bookID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.PageRequest) []*domain.BookPrice); ok {
+		r0 = rf(ctx, bookID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.BookPrice)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, domain.PageRequest) error); ok {
+		r1 = rf(ctx, bookID, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NextPriceChange provides a mock function with given fields: ctx, now
+func (_m *BookRepository) NextPriceChange(ctx context.Context, now time.Time) (*time.Time, error) {
+	ret := _m.Called(ctx, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NextPriceChange")
+	}
+
+	var r0 *time.Time
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (*time.Time, error)); ok {
+		return rf(ctx, now)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) *time.Time); ok {
+		r0 = rf(ctx, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*time.Time)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SchedulePrice provides a mock function with given fields: ctx, p
+func (_m *BookRepository) SchedulePrice(ctx context.Context, p *domain.BookPrice) error {
+	ret := _m.Called(ctx, p)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SchedulePrice")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.BookPrice) error); ok {
+		r0 = rf(ctx, p)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Search provides a mock function with given fields: ctx, query, limit, offset
+func (_m *BookRepository) Search(ctx context.Context, query string, limit int, offset int) ([]*domain.Book, error) {
+	ret := _m.Called(ctx, query, limit, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Search")
+	}
+
+	var r0 []*domain.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) ([]*domain.Book, error)); ok {
+		return rf(ctx, query, limit, offset)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, []int, int, int) []*domain.Book); ok {
-		r0 = rf(ctx, categoryIDs, limit, offset)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) []*domain.Book); ok {
+		r0 = rf(ctx, query, limit, offset)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*domain.Book)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, []int, int, int) error); ok {
-		r1 = rf(ctx, categoryIDs, limit, offset)
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, int) error); ok {
+		r1 = rf(ctx, query, limit, offset)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -14,6 +14,54 @@ type BookService struct {
 	mock.Mock
 }
 
+// AdjustInventory provides a mock function with given fields: ctx, bookID, kind, quantity, reason, actor
+func (_m *BookService) AdjustInventory(ctx context.Context, bookID int, kind string, quantity int, reason string, actor string) (*domain.InventoryMovement, error) {
+	ret := _m.Called(ctx, bookID, kind, quantity, reason, actor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AdjustInventory")
+	}
+
+	var r0 *domain.InventoryMovement
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, int, string, string) (*domain.InventoryMovement, error)); ok {
+		return rf(ctx, bookID, kind, quantity, reason, actor)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, int, string, string) *domain.InventoryMovement); ok {
+		r0 = rf(ctx, bookID, kind, quantity, reason, actor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.InventoryMovement)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, int, string, string) error); ok {
+		r1 = rf(ctx, bookID, kind, quantity, reason, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CancelPrice provides a mock function with given fields: ctx, bookID, priceID
+func (_m *BookService) CancelPrice(ctx context.Context, bookID int, priceID int) error {
+	ret := _m.Called(ctx, bookID, priceID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelPrice")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) error); ok {
+		r0 = rf(ctx, bookID, priceID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Create provides a mock function with given fields: ctx, book
 func (_m *BookService) Create(ctx context.Context, book *domain.Book) error {
 	ret := _m.Called(ctx, book)
@@ -50,6 +98,36 @@ func (_m *BookService) Delete(ctx context.Context, id int) error {
 	return r0
 }
 
+// Facets provides a mock function with given fields: ctx, filter
+func (_m *BookService) Facets(ctx context.Context, filter domain.BookFilter) (*domain.BookFacets, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Facets")
+	}
+
+	var r0 *domain.BookFacets
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) (*domain.BookFacets, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) *domain.BookFacets); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.BookFacets)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.BookFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetByID provides a mock function with given fields: ctx, id
 func (_m *BookService) GetByID(ctx context.Context, id int) (*domain.Book, error) {
 	ret := _m.Called(ctx, id)
@@ -80,29 +158,149 @@ func (_m *BookService) GetByID(ctx context.Context, id int) (*domain.Book, error
 	return r0, r1
 }
 
-// List provides a mock function with given fields: ctx, categoryIDs, limit, offset
-func (_m *BookService) List(ctx context.Context, categoryIDs []int, limit int, offset int) ([]*domain.Book, error) {
-	ret := _m.Called(ctx, categoryIDs, limit, offset)
+// InventoryHistory provides a mock function with given fields: ctx, bookID, page
+func (_m *BookService) InventoryHistory(ctx context.Context, bookID int, page domain.PageRequest) (*domain.InventoryMovementList, error) {
+	ret := _m.Called(ctx, bookID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InventoryHistory")
+	}
+
+	var r0 *domain.InventoryMovementList
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.PageRequest) (*domain.InventoryMovementList, error)); ok {
+		return rf(ctx, bookID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.PageRequest) *domain.InventoryMovementList); ok {
+		r0 = rf(ctx, bookID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.InventoryMovementList)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, domain.PageRequest) error); ok {
+		r1 = rf(ctx, bookID, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, filter
+func (_m *BookService) List(ctx context.Context, filter domain.BookFilter) (*domain.BookList, error) {
+	ret := _m.Called(ctx, filter)
 
 	if len(ret) == 0 {
 		panic("no return value specified for List")
 	}
 
+	var r0 *domain.BookList
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) (*domain.BookList, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.BookFilter) *domain.BookList); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.BookList)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.BookFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PriceHistory provides a mock function with given fields: ctx, bookID, page
+func (_m *BookService) PriceHistory(ctx context.Context, bookID int, page domain.PageRequest) (*domain.BookPriceList, error) {
+	ret := _m.Called(ctx, bookID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PriceHistory")
+	}
+
+	var r0 *domain.BookPriceList
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.PageRequest) (*domain.BookPriceList, error)); ok {
+		return rf(ctx, bookID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.PageRequest) *domain.BookPriceList); ok {
+		r0 = rf(ctx, bookID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.BookPriceList)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, domain.PageRequest) error); ok {
+		r1 = rf(ctx, bookID, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SchedulePrice provides a mock function with given fields: ctx, bookID, req, actor
+func (_m *BookService) SchedulePrice(ctx context.Context, bookID int, req domain.SchedulePriceRequest, actor string) (*domain.BookPrice, error) {
+	ret := _m.Called(ctx, bookID, req, actor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SchedulePrice")
+	}
+
+	var r0 *domain.BookPrice
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.SchedulePriceRequest, string) (*domain.BookPrice, error)); ok {
+		return rf(ctx, bookID, req, actor)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, domain.SchedulePriceRequest, string) *domain.BookPrice); ok {
+		r0 = rf(ctx, bookID, req, actor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.BookPrice)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, domain.SchedulePriceRequest, string) error); ok {
+		r1 = rf(ctx, bookID, req, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Search provides a mock function with given fields: ctx, query, limit, offset
+func (_m *BookService) Search(ctx context.Context, query string, limit int, offset int) ([]*domain.Book, error) {
+	ret := _m.Called(ctx, query, limit, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Search")
+	}
+
 	var r0 []*domain.Book
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, []int, int, int) ([]*domain.Book, error)); ok {
-		return rf(ctx, categoryIDs, limit, offset)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) ([]*domain.Book, error)); ok {
+		return rf(ctx, query, limit, offset)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, []int, int, int) []*domain.Book); ok {
-		r0 = rf(ctx, categoryIDs, limit, offset)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) []*domain.Book); ok {
+		r0 = rf(ctx, query, limit, offset)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*domain.Book)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, []int, int, int) error); ok {
-		r1 = rf(ctx, categoryIDs, limit, offset)
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, int) error); ok {
+		r1 = rf(ctx, query, limit, offset)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -50,6 +50,34 @@ func (_m *CartRepository) Clear(ctx context.Context, userID string) error {
 	return r0
 }
 
+// DeleteExpired provides a mock function with given fields: ctx
+func (_m *CartRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpired")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int64, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetByUserID provides a mock function with given fields: ctx, userID
 func (_m *CartRepository) GetByUserID(ctx context.Context, userID string) (*domain.Cart, error) {
 	ret := _m.Called(ctx, userID)
@@ -138,6 +166,34 @@ func (_m *CartRepository) ListItems(ctx context.Context, userID string) ([]*doma
 	return r0, r1
 }
 
+// MoveItem provides a mock function with given fields: ctx, from, to, bookID, quantity
+func (_m *CartRepository) MoveItem(ctx context.Context, from string, to string, bookID int, quantity int) (bool, error) {
+	ret := _m.Called(ctx, from, to, bookID, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MoveItem")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int, int) (bool, error)); ok {
+		return rf(ctx, from, to, bookID, quantity)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int, int) bool); ok {
+		r0 = rf(ctx, from, to, bookID, quantity)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int, int) error); ok {
+		r1 = rf(ctx, from, to, bookID, quantity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RemoveItem provides a mock function with given fields: ctx, userID, bookID
 func (_m *CartRepository) RemoveItem(ctx context.Context, userID string, bookID int) error {
 	ret := _m.Called(ctx, userID, bookID)
@@ -156,6 +212,42 @@ func (_m *CartRepository) RemoveItem(ctx context.Context, userID string, bookID
 	return r0
 }
 
+// SetItems provides a mock function with given fields: ctx, userID, items
+func (_m *CartRepository) SetItems(ctx context.Context, userID string, items []domain.CartItemQuantity) error {
+	ret := _m.Called(ctx, userID, items)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetItems")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []domain.CartItemQuantity) error); ok {
+		r0 = rf(ctx, userID, items)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPromoCode provides a mock function with given fields: ctx, userID, code
+func (_m *CartRepository) SetPromoCode(ctx context.Context, userID string, code string) error {
+	ret := _m.Called(ctx, userID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetPromoCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, userID, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewCartRepository creates a new instance of CartRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewCartRepository(t interface {
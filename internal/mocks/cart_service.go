@@ -32,6 +32,36 @@ func (_m *CartService) AddItem(ctx context.Context, userID string, bookID int) e
 	return r0
 }
 
+// ApplyPromo provides a mock function with given fields: ctx, userID, code
+func (_m *CartService) ApplyPromo(ctx context.Context, userID string, code string) (*domain.Cart, error) {
+	ret := _m.Called(ctx, userID, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ApplyPromo")
+	}
+
+	var r0 *domain.Cart
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*domain.Cart, error)); ok {
+		return rf(ctx, userID, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *domain.Cart); ok {
+		r0 = rf(ctx, userID, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Cart)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userID, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Clear provides a mock function with given fields: ctx, userID
 func (_m *CartService) Clear(ctx context.Context, userID string) error {
 	ret := _m.Called(ctx, userID)
@@ -80,6 +110,36 @@ func (_m *CartService) GetByUserID(ctx context.Context, userID string) (*domain.
 	return r0, r1
 }
 
+// GetForRegion provides a mock function with given fields: ctx, userID, region
+func (_m *CartService) GetForRegion(ctx context.Context, userID string, region domain.TaxRegion) (*domain.Cart, error) {
+	ret := _m.Called(ctx, userID, region)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetForRegion")
+	}
+
+	var r0 *domain.Cart
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.TaxRegion) (*domain.Cart, error)); ok {
+		return rf(ctx, userID, region)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.TaxRegion) *domain.Cart); ok {
+		r0 = rf(ctx, userID, region)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Cart)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.TaxRegion) error); ok {
+		r1 = rf(ctx, userID, region)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListItems provides a mock function with given fields: ctx, userID
 func (_m *CartService) ListItems(ctx context.Context, userID string) ([]*domain.CartItem, error) {
 	ret := _m.Called(ctx, userID)
@@ -110,6 +170,36 @@ func (_m *CartService) ListItems(ctx context.Context, userID string) ([]*domain.
 	return r0, r1
 }
 
+// MergeGuest provides a mock function with given fields: ctx, guestID, userID
+func (_m *CartService) MergeGuest(ctx context.Context, guestID string, userID string) (*domain.CartMergeResult, error) {
+	ret := _m.Called(ctx, guestID, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MergeGuest")
+	}
+
+	var r0 *domain.CartMergeResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*domain.CartMergeResult, error)); ok {
+		return rf(ctx, guestID, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *domain.CartMergeResult); ok {
+		r0 = rf(ctx, guestID, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.CartMergeResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, guestID, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RemoveItem provides a mock function with given fields: ctx, userID, bookID
 func (_m *CartService) RemoveItem(ctx context.Context, userID string, bookID int) error {
 	ret := _m.Called(ctx, userID, bookID)
@@ -128,6 +218,60 @@ func (_m *CartService) RemoveItem(ctx context.Context, userID string, bookID int
 	return r0
 }
 
+// RemovePromo provides a mock function with given fields: ctx, userID
+func (_m *CartService) RemovePromo(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemovePromo")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetItemQuantity provides a mock function with given fields: ctx, userID, bookID, quantity
+func (_m *CartService) SetItemQuantity(ctx context.Context, userID string, bookID int, quantity int) error {
+	ret := _m.Called(ctx, userID, bookID, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetItemQuantity")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) error); ok {
+		r0 = rf(ctx, userID, bookID, quantity)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetItems provides a mock function with given fields: ctx, userID, items
+func (_m *CartService) SetItems(ctx context.Context, userID string, items []domain.CartItemQuantity) error {
+	ret := _m.Called(ctx, userID, items)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetItems")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []domain.CartItemQuantity) error); ok {
+		r0 = rf(ctx, userID, items)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewCartService creates a new instance of CartService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewCartService(t interface {
@@ -14,6 +14,34 @@ type CategoryRepository struct {
 	mock.Mock
 }
 
+// Count provides a mock function with given fields: ctx
+func (_m *CategoryRepository) Count(ctx context.Context) (int, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Count")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Create provides a mock function with given fields: ctx, category
 func (_m *CategoryRepository) Create(ctx context.Context, category *domain.Category) error {
 	ret := _m.Called(ctx, category)
@@ -110,9 +138,9 @@ func (_m *CategoryRepository) GetByName(ctx context.Context, name string) (*doma
 	return r0, r1
 }
 
-// List provides a mock function with given fields: ctx
-func (_m *CategoryRepository) List(ctx context.Context) ([]*domain.Category, error) {
-	ret := _m.Called(ctx)
+// List provides a mock function with given fields: ctx, page
+func (_m *CategoryRepository) List(ctx context.Context, page domain.PageRequest) ([]*domain.Category, error) {
+	ret := _m.Called(ctx, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for List")
@@ -120,19 +148,19 @@ func (_m *CategoryRepository) List(ctx context.Context) ([]*domain.Category, err
 
 	var r0 []*domain.Category
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) ([]*domain.Category, error)); ok {
-		return rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) ([]*domain.Category, error)); ok {
+		return rf(ctx, page)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) []*domain.Category); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) []*domain.Category); ok {
+		r0 = rf(ctx, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*domain.Category)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PageRequest) error); ok {
+		r1 = rf(ctx, page)
 	} else {
 		r1 = ret.Error(1)
 	}
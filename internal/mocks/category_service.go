@@ -80,29 +80,29 @@ func (_m *CategoryService) GetByID(ctx context.Context, id int) (*domain.Categor
 	return r0, r1
 }
 
-// List provides a mock function with given fields: ctx
-func (_m *CategoryService) List(ctx context.Context) ([]*domain.Category, error) {
-	ret := _m.Called(ctx)
+// List provides a mock function with given fields: ctx, page
+func (_m *CategoryService) List(ctx context.Context, page domain.PageRequest) (*domain.CategoryList, error) {
+	ret := _m.Called(ctx, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for List")
 	}
 
-	var r0 []*domain.Category
+	var r0 *domain.CategoryList
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) ([]*domain.Category, error)); ok {
-		return rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) (*domain.CategoryList, error)); ok {
+		return rf(ctx, page)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) []*domain.Category); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) *domain.CategoryList); ok {
+		r0 = rf(ctx, page)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*domain.Category)
+			r0 = ret.Get(0).(*domain.CategoryList)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PageRequest) error); ok {
+		r1 = rf(ctx, page)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,68 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	domain "github.com/yourorg/bookshop/internal/domain"
+)
+
+// CheckoutDiscounter is an autogenerated mock type for the CheckoutDiscounter type
+type CheckoutDiscounter struct {
+	mock.Mock
+}
+
+// CheckoutDiscount provides a mock function with given fields: ctx, userID, lines
+func (_m *CheckoutDiscounter) CheckoutDiscount(ctx context.Context, userID string, lines []domain.PromoLine) (*domain.PromoCode, []domain.Money, error) {
+	ret := _m.Called(ctx, userID, lines)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CheckoutDiscount")
+	}
+
+	var r0 *domain.PromoCode
+	var r1 []domain.Money
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []domain.PromoLine) (*domain.PromoCode, []domain.Money, error)); ok {
+		return rf(ctx, userID, lines)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, []domain.PromoLine) *domain.PromoCode); ok {
+		r0 = rf(ctx, userID, lines)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.PromoCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, []domain.PromoLine) []domain.Money); ok {
+		r1 = rf(ctx, userID, lines)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]domain.Money)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, []domain.PromoLine) error); ok {
+		r2 = rf(ctx, userID, lines)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewCheckoutDiscounter creates a new instance of CheckoutDiscounter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewCheckoutDiscounter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *CheckoutDiscounter {
+	mock := &CheckoutDiscounter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
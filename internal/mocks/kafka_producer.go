@@ -5,8 +5,9 @@ package mocks
 import (
 	context "context"
 
+	domain "github.com/yourorg/bookshop/internal/domain"
+
 	mock "github.com/stretchr/testify/mock"
-	integration "github.com/yourorg/bookshop/internal/integration"
 )
 
 // KafkaProducer is an autogenerated mock type for the KafkaProducer type
@@ -14,8 +15,26 @@ type KafkaProducer struct {
 	mock.Mock
 }
 
+// PublishOrderCancelled provides a mock function with given fields: ctx, evt
+func (_m *KafkaProducer) PublishOrderCancelled(ctx context.Context, evt domain.OrderCancelledEvent) error {
+	ret := _m.Called(ctx, evt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublishOrderCancelled")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.OrderCancelledEvent) error); ok {
+		r0 = rf(ctx, evt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // PublishOrderPlaced provides a mock function with given fields: ctx, orderID, userID, books
-func (_m *KafkaProducer) PublishOrderPlaced(ctx context.Context, orderID int, userID string, books []integration.OrderPlacedBook) error {
+func (_m *KafkaProducer) PublishOrderPlaced(ctx context.Context, orderID int, userID string, books []domain.OrderEventBook) error {
 	ret := _m.Called(ctx, orderID, userID, books)
 
 	if len(ret) == 0 {
@@ -23,7 +42,7 @@ func (_m *KafkaProducer) PublishOrderPlaced(ctx context.Context, orderID int, us
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, int, string, []integration.OrderPlacedBook) error); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, []domain.OrderEventBook) error); ok {
 		r0 = rf(ctx, orderID, userID, books)
 	} else {
 		r0 = ret.Error(0)
@@ -32,6 +51,42 @@ func (_m *KafkaProducer) PublishOrderPlaced(ctx context.Context, orderID int, us
 	return r0
 }
 
+// PublishOrderStatusChanged provides a mock function with given fields: ctx, evt
+func (_m *KafkaProducer) PublishOrderStatusChanged(ctx context.Context, evt domain.OrderStatusChangedEvent) error {
+	ret := _m.Called(ctx, evt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublishOrderStatusChanged")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.OrderStatusChangedEvent) error); ok {
+		r0 = rf(ctx, evt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PublishReturnEvent provides a mock function with given fields: ctx, evt
+func (_m *KafkaProducer) PublishReturnEvent(ctx context.Context, evt domain.ReturnEvent) error {
+	ret := _m.Called(ctx, evt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublishReturnEvent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.ReturnEvent) error); ok {
+		r0 = rf(ctx, evt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewKafkaProducer creates a new instance of KafkaProducer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewKafkaProducer(t interface {
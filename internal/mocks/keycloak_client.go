@@ -5,6 +5,8 @@ package mocks
 import (
 	context "context"
 
+	domain "github.com/yourorg/bookshop/internal/domain"
+
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -14,47 +16,33 @@ type KeycloakClient struct {
 }
 
 // ValidateToken provides a mock function with given fields: ctx, token
-func (_m *KeycloakClient) ValidateToken(ctx context.Context, token string) (string, string, []string, error) {
+func (_m *KeycloakClient) ValidateToken(ctx context.Context, token string) (*domain.Principal, error) {
 	ret := _m.Called(ctx, token)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ValidateToken")
 	}
 
-	var r0 string
-	var r1 string
-	var r2 []string
-	var r3 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (string, string, []string, error)); ok {
+	var r0 *domain.Principal
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.Principal, error)); ok {
 		return rf(ctx, token)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.Principal); ok {
 		r0 = rf(ctx, token)
 	} else {
-		r0 = ret.Get(0).(string)
-	}
-
-	if rf, ok := ret.Get(1).(func(context.Context, string) string); ok {
-		r1 = rf(ctx, token)
-	} else {
-		r1 = ret.Get(1).(string)
-	}
-
-	if rf, ok := ret.Get(2).(func(context.Context, string) []string); ok {
-		r2 = rf(ctx, token)
-	} else {
-		if ret.Get(2) != nil {
-			r2 = ret.Get(2).([]string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Principal)
 		}
 	}
 
-	if rf, ok := ret.Get(3).(func(context.Context, string) error); ok {
-		r3 = rf(ctx, token)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
 	} else {
-		r3 = ret.Error(3)
+		r1 = ret.Error(1)
 	}
 
-	return r0, r1, r2, r3
+	return r0, r1
 }
 
 // NewKeycloakClient creates a new instance of KeycloakClient. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
@@ -0,0 +1,66 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	domain "github.com/yourorg/bookshop/internal/domain"
+)
+
+// LineTaxer is an autogenerated mock type for the LineTaxer type
+type LineTaxer struct {
+	mock.Mock
+}
+
+// LineTaxes provides a mock function with given fields: ctx, region, lines
+func (_m *LineTaxer) LineTaxes(ctx context.Context, region domain.TaxRegion, lines []domain.TaxLine) ([]domain.LineTax, domain.Money, error) {
+	ret := _m.Called(ctx, region, lines)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LineTaxes")
+	}
+
+	var r0 []domain.LineTax
+	var r1 domain.Money
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.TaxRegion, []domain.TaxLine) ([]domain.LineTax, domain.Money, error)); ok {
+		return rf(ctx, region, lines)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.TaxRegion, []domain.TaxLine) []domain.LineTax); ok {
+		r0 = rf(ctx, region, lines)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.LineTax)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.TaxRegion, []domain.TaxLine) domain.Money); ok {
+		r1 = rf(ctx, region, lines)
+	} else {
+		r1 = ret.Get(1).(domain.Money)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, domain.TaxRegion, []domain.TaxLine) error); ok {
+		r2 = rf(ctx, region, lines)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewLineTaxer creates a new instance of LineTaxer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewLineTaxer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *LineTaxer {
+	mock := &LineTaxer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
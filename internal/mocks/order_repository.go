@@ -14,6 +14,64 @@ type OrderRepository struct {
 	mock.Mock
 }
 
+// Cancel provides a mock function with given fields: ctx, orderID, from, actor, reason
+func (_m *OrderRepository) Cancel(ctx context.Context, orderID int, from string, actor string, reason string) (*domain.OrderTransition, error) {
+	ret := _m.Called(ctx, orderID, from, actor, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Cancel")
+	}
+
+	var r0 *domain.OrderTransition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, string) (*domain.OrderTransition, error)); ok {
+		return rf(ctx, orderID, from, actor, reason)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, string) *domain.OrderTransition); ok {
+		r0 = rf(ctx, orderID, from, actor, reason)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.OrderTransition)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string, string) error); ok {
+		r1 = rf(ctx, orderID, from, actor, reason)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountByUser provides a mock function with given fields: ctx, userID
+func (_m *OrderRepository) CountByUser(ctx context.Context, userID string) (int, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountByUser")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (int, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Create provides a mock function with given fields: ctx, order
 func (_m *OrderRepository) Create(ctx context.Context, order *domain.Order) error {
 	ret := _m.Called(ctx, order)
@@ -32,9 +90,39 @@ func (_m *OrderRepository) Create(ctx context.Context, order *domain.Order) erro
 	return r0
 }
 
-// ListByUser provides a mock function with given fields: ctx, userID
-func (_m *OrderRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Order, error) {
-	ret := _m.Called(ctx, userID)
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *OrderRepository) GetByID(ctx context.Context, id int) (*domain.Order, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByID")
+	}
+
+	var r0 *domain.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*domain.Order, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *domain.Order); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListByUser provides a mock function with given fields: ctx, userID, page
+func (_m *OrderRepository) ListByUser(ctx context.Context, userID string, page domain.PageRequest) ([]*domain.Order, error) {
+	ret := _m.Called(ctx, userID, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListByUser")
@@ -42,19 +130,79 @@ func (_m *OrderRepository) ListByUser(ctx context.Context, userID string) ([]*do
 
 	var r0 []*domain.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*domain.Order, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PageRequest) ([]*domain.Order, error)); ok {
+		return rf(ctx, userID, page)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []*domain.Order); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PageRequest) []*domain.Order); ok {
+		r0 = rf(ctx, userID, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*domain.Order)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.PageRequest) error); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTransitions provides a mock function with given fields: ctx, orderID
+func (_m *OrderRepository) ListTransitions(ctx context.Context, orderID int) ([]*domain.OrderTransition, error) {
+	ret := _m.Called(ctx, orderID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTransitions")
+	}
+
+	var r0 []*domain.OrderTransition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*domain.OrderTransition, error)); ok {
+		return rf(ctx, orderID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*domain.OrderTransition); ok {
+		r0 = rf(ctx, orderID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.OrderTransition)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, orderID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateStatus provides a mock function with given fields: ctx, orderID, from, to, actor, reason
+func (_m *OrderRepository) UpdateStatus(ctx context.Context, orderID int, from string, to string, actor string, reason string) (*domain.OrderTransition, error) {
+	ret := _m.Called(ctx, orderID, from, to, actor, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateStatus")
+	}
+
+	var r0 *domain.OrderTransition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, string, string) (*domain.OrderTransition, error)); ok {
+		return rf(ctx, orderID, from, to, actor, reason)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, string, string) *domain.OrderTransition); ok {
+		r0 = rf(ctx, orderID, from, to, actor, reason)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.OrderTransition)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string, string, string) error); ok {
+		r1 = rf(ctx, orderID, from, to, actor, reason)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -14,9 +14,39 @@ type OrderService struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: ctx, userID
-func (_m *OrderService) Create(ctx context.Context, userID string) (*domain.Order, error) {
-	ret := _m.Called(ctx, userID)
+// Cancel provides a mock function with given fields: ctx, orderID, actor, isAdmin, reason
+func (_m *OrderService) Cancel(ctx context.Context, orderID int, actor string, isAdmin bool, reason string) (*domain.Order, error) {
+	ret := _m.Called(ctx, orderID, actor, isAdmin, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Cancel")
+	}
+
+	var r0 *domain.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, bool, string) (*domain.Order, error)); ok {
+		return rf(ctx, orderID, actor, isAdmin, reason)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, bool, string) *domain.Order); ok {
+		r0 = rf(ctx, orderID, actor, isAdmin, reason)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, bool, string) error); ok {
+		r1 = rf(ctx, orderID, actor, isAdmin, reason)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Create provides a mock function with given fields: ctx, userID, req
+func (_m *OrderService) Create(ctx context.Context, userID string, req domain.PlaceOrderRequest) (*domain.Order, error) {
+	ret := _m.Called(ctx, userID, req)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Create")
@@ -24,19 +54,19 @@ func (_m *OrderService) Create(ctx context.Context, userID string) (*domain.Orde
 
 	var r0 *domain.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.Order, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PlaceOrderRequest) (*domain.Order, error)); ok {
+		return rf(ctx, userID, req)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.Order); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PlaceOrderRequest) *domain.Order); ok {
+		r0 = rf(ctx, userID, req)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*domain.Order)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.PlaceOrderRequest) error); ok {
+		r1 = rf(ctx, userID, req)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -44,29 +74,167 @@ func (_m *OrderService) Create(ctx context.Context, userID string) (*domain.Orde
 	return r0, r1
 }
 
-// ListByUser provides a mock function with given fields: ctx, userID
-func (_m *OrderService) ListByUser(ctx context.Context, userID string) ([]*domain.Order, error) {
-	ret := _m.Called(ctx, userID)
+// Get provides a mock function with given fields: ctx, orderID, actor, isAdmin
+func (_m *OrderService) Get(ctx context.Context, orderID int, actor string, isAdmin bool) (*domain.Order, error) {
+	ret := _m.Called(ctx, orderID, actor, isAdmin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *domain.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, bool) (*domain.Order, error)); ok {
+		return rf(ctx, orderID, actor, isAdmin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, bool) *domain.Order); ok {
+		r0 = rf(ctx, orderID, actor, isAdmin)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, bool) error); ok {
+		r1 = rf(ctx, orderID, actor, isAdmin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HandlePaymentEvent provides a mock function with given fields: ctx, evt
+func (_m *OrderService) HandlePaymentEvent(ctx context.Context, evt domain.PaymentEvent) error {
+	ret := _m.Called(ctx, evt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HandlePaymentEvent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PaymentEvent) error); ok {
+		r0 = rf(ctx, evt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListByUser provides a mock function with given fields: ctx, userID, page
+func (_m *OrderService) ListByUser(ctx context.Context, userID string, page domain.PageRequest) (*domain.OrderList, error) {
+	ret := _m.Called(ctx, userID, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListByUser")
 	}
 
-	var r0 []*domain.Order
+	var r0 *domain.OrderList
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PageRequest) (*domain.OrderList, error)); ok {
+		return rf(ctx, userID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PageRequest) *domain.OrderList); ok {
+		r0 = rf(ctx, userID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.OrderList)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.PageRequest) error); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Pay provides a mock function with given fields: ctx, orderID, userID, req
+func (_m *OrderService) Pay(ctx context.Context, orderID int, userID string, req domain.PayOrderRequest) (*domain.Payment, error) {
+	ret := _m.Called(ctx, orderID, userID, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Pay")
+	}
+
+	var r0 *domain.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, domain.PayOrderRequest) (*domain.Payment, error)); ok {
+		return rf(ctx, orderID, userID, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, domain.PayOrderRequest) *domain.Payment); ok {
+		r0 = rf(ctx, orderID, userID, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, domain.PayOrderRequest) error); ok {
+		r1 = rf(ctx, orderID, userID, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Transition provides a mock function with given fields: ctx, orderID, to, actor, reason
+func (_m *OrderService) Transition(ctx context.Context, orderID int, to string, actor string, reason string) (*domain.Order, error) {
+	ret := _m.Called(ctx, orderID, to, actor, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Transition")
+	}
+
+	var r0 *domain.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, string) (*domain.Order, error)); ok {
+		return rf(ctx, orderID, to, actor, reason)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, string) *domain.Order); ok {
+		r0 = rf(ctx, orderID, to, actor, reason)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string, string) error); ok {
+		r1 = rf(ctx, orderID, to, actor, reason)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Transitions provides a mock function with given fields: ctx, orderID
+func (_m *OrderService) Transitions(ctx context.Context, orderID int) ([]*domain.OrderTransition, error) {
+	ret := _m.Called(ctx, orderID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Transitions")
+	}
+
+	var r0 []*domain.OrderTransition
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*domain.Order, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*domain.OrderTransition, error)); ok {
+		return rf(ctx, orderID)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []*domain.Order); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*domain.OrderTransition); ok {
+		r0 = rf(ctx, orderID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*domain.Order)
+			r0 = ret.Get(0).([]*domain.OrderTransition)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, orderID)
 	} else {
 		r1 = ret.Error(1)
 	}
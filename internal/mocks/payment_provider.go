@@ -0,0 +1,151 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	domain "github.com/yourorg/bookshop/internal/domain"
+	integration "github.com/yourorg/bookshop/internal/integration"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// PaymentProvider is an autogenerated mock type for the PaymentProvider type
+type PaymentProvider struct {
+	mock.Mock
+}
+
+// Authorize provides a mock function with given fields: ctx, req
+func (_m *PaymentProvider) Authorize(ctx context.Context, req integration.PaymentAuthorization) (*integration.PaymentResult, error) {
+	ret := _m.Called(ctx, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Authorize")
+	}
+
+	var r0 *integration.PaymentResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, integration.PaymentAuthorization) (*integration.PaymentResult, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, integration.PaymentAuthorization) *integration.PaymentResult); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*integration.PaymentResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, integration.PaymentAuthorization) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Capture provides a mock function with given fields: ctx, paymentID, amount
+func (_m *PaymentProvider) Capture(ctx context.Context, paymentID string, amount domain.Money) (*integration.PaymentResult, error) {
+	ret := _m.Called(ctx, paymentID, amount)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Capture")
+	}
+
+	var r0 *integration.PaymentResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.Money) (*integration.PaymentResult, error)); ok {
+		return rf(ctx, paymentID, amount)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.Money) *integration.PaymentResult); ok {
+		r0 = rf(ctx, paymentID, amount)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*integration.PaymentResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.Money) error); ok {
+		r1 = rf(ctx, paymentID, amount)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Refund provides a mock function with given fields: ctx, paymentID, idempotencyKey, amount
+func (_m *PaymentProvider) Refund(ctx context.Context, paymentID string, idempotencyKey string, amount domain.Money) (*integration.PaymentResult, error) {
+	ret := _m.Called(ctx, paymentID, idempotencyKey, amount)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Refund")
+	}
+
+	var r0 *integration.PaymentResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, domain.Money) (*integration.PaymentResult, error)); ok {
+		return rf(ctx, paymentID, idempotencyKey, amount)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, domain.Money) *integration.PaymentResult); ok {
+		r0 = rf(ctx, paymentID, idempotencyKey, amount)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*integration.PaymentResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, domain.Money) error); ok {
+		r1 = rf(ctx, paymentID, idempotencyKey, amount)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Void provides a mock function with given fields: ctx, paymentID, amount
+func (_m *PaymentProvider) Void(ctx context.Context, paymentID string, amount domain.Money) (*integration.PaymentResult, error) {
+	ret := _m.Called(ctx, paymentID, amount)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Void")
+	}
+
+	var r0 *integration.PaymentResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.Money) (*integration.PaymentResult, error)); ok {
+		return rf(ctx, paymentID, amount)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.Money) *integration.PaymentResult); ok {
+		r0 = rf(ctx, paymentID, amount)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*integration.PaymentResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.Money) error); ok {
+		r1 = rf(ctx, paymentID, amount)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewPaymentProvider creates a new instance of PaymentProvider. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPaymentProvider(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PaymentProvider {
+	mock := &PaymentProvider{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,185 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	domain "github.com/yourorg/bookshop/internal/domain"
+)
+
+// PaymentRepository is an autogenerated mock type for the PaymentRepository type
+type PaymentRepository struct {
+	mock.Mock
+}
+
+// Capture provides a mock function with given fields: ctx, id, actor
+func (_m *PaymentRepository) Capture(ctx context.Context, id int, actor string) (*domain.OrderTransition, error) {
+	ret := _m.Called(ctx, id, actor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Capture")
+	}
+
+	var r0 *domain.OrderTransition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) (*domain.OrderTransition, error)); ok {
+		return rf(ctx, id, actor)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) *domain.OrderTransition); ok {
+		r0 = rf(ctx, id, actor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.OrderTransition)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, id, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Create provides a mock function with given fields: ctx, p
+func (_m *PaymentRepository) Create(ctx context.Context, p *domain.Payment) error {
+	ret := _m.Called(ctx, p)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Payment) error); ok {
+		r0 = rf(ctx, p)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetActiveByOrder provides a mock function with given fields: ctx, orderID
+func (_m *PaymentRepository) GetActiveByOrder(ctx context.Context, orderID int) (*domain.Payment, error) {
+	ret := _m.Called(ctx, orderID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetActiveByOrder")
+	}
+
+	var r0 *domain.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*domain.Payment, error)); ok {
+		return rf(ctx, orderID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *domain.Payment); ok {
+		r0 = rf(ctx, orderID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, orderID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetByProviderID provides a mock function with given fields: ctx, providerID
+func (_m *PaymentRepository) GetByProviderID(ctx context.Context, providerID string) (*domain.Payment, error) {
+	ret := _m.Called(ctx, providerID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByProviderID")
+	}
+
+	var r0 *domain.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.Payment, error)); ok {
+		return rf(ctx, providerID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.Payment); ok {
+		r0 = rf(ctx, providerID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, providerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListUnsettled provides a mock function with given fields: ctx, limit
+func (_m *PaymentRepository) ListUnsettled(ctx context.Context, limit int) ([]*domain.Payment, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUnsettled")
+	}
+
+	var r0 []*domain.Payment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*domain.Payment, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*domain.Payment); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Payment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateStatus provides a mock function with given fields: ctx, id, from, to, providerID, reason
+func (_m *PaymentRepository) UpdateStatus(ctx context.Context, id int, from string, to string, providerID string, reason string) error {
+	ret := _m.Called(ctx, id, from, to, providerID, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, string, string) error); ok {
+		r0 = rf(ctx, id, from, to, providerID, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewPaymentRepository creates a new instance of PaymentRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPaymentRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PaymentRepository {
+	mock := &PaymentRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,183 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	domain "github.com/yourorg/bookshop/internal/domain"
+)
+
+// PromoRepository is an autogenerated mock type for the PromoRepository type
+type PromoRepository struct {
+	mock.Mock
+}
+
+// CountRedemptions provides a mock function with given fields: ctx, promoID, userID
+func (_m *PromoRepository) CountRedemptions(ctx context.Context, promoID int, userID string) (int, error) {
+	ret := _m.Called(ctx, promoID, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountRedemptions")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) (int, error)); ok {
+		return rf(ctx, promoID, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) int); ok {
+		r0 = rf(ctx, promoID, userID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, promoID, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Create provides a mock function with given fields: ctx, p
+func (_m *PromoRepository) Create(ctx context.Context, p *domain.PromoCode) error {
+	ret := _m.Called(ctx, p)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.PromoCode) error); ok {
+		r0 = rf(ctx, p)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetByCode provides a mock function with given fields: ctx, code
+func (_m *PromoRepository) GetByCode(ctx context.Context, code string) (*domain.PromoCode, error) {
+	ret := _m.Called(ctx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByCode")
+	}
+
+	var r0 *domain.PromoCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.PromoCode, error)); ok {
+		return rf(ctx, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.PromoCode); ok {
+		r0 = rf(ctx, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.PromoCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *PromoRepository) GetByID(ctx context.Context, id int) (*domain.PromoCode, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByID")
+	}
+
+	var r0 *domain.PromoCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*domain.PromoCode, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *domain.PromoCode); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.PromoCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, page
+func (_m *PromoRepository) List(ctx context.Context, page domain.PageRequest) ([]*domain.PromoCode, error) {
+	ret := _m.Called(ctx, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []*domain.PromoCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) ([]*domain.PromoCode, error)); ok {
+		return rf(ctx, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) []*domain.PromoCode); ok {
+		r0 = rf(ctx, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.PromoCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PageRequest) error); ok {
+		r1 = rf(ctx, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, p
+func (_m *PromoRepository) Update(ctx context.Context, p *domain.PromoCode) error {
+	ret := _m.Called(ctx, p)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.PromoCode) error); ok {
+		r0 = rf(ctx, p)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewPromoRepository creates a new instance of PromoRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPromoRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PromoRepository {
+	mock := &PromoRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
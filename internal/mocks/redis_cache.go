@@ -73,6 +73,34 @@ func (_m *RedisCache) Set(key string, value string, ttlSeconds int) error {
 	return r0
 }
 
+// SetNX provides a mock function with given fields: key, value, ttlSeconds
+func (_m *RedisCache) SetNX(key string, value string, ttlSeconds int) (bool, error) {
+	ret := _m.Called(key, value, ttlSeconds)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetNX")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, int) (bool, error)); ok {
+		return rf(key, value, ttlSeconds)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, int) bool); ok {
+		r0 = rf(key, value, ttlSeconds)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, int) error); ok {
+		r1 = rf(key, value, ttlSeconds)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // TTL provides a mock function with given fields: key
 func (_m *RedisCache) TTL(key string) (int64, error) {
 	ret := _m.Called(key)
@@ -0,0 +1,132 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// ReservationRepository is an autogenerated mock type for the ReservationRepository type
+type ReservationRepository struct {
+	mock.Mock
+}
+
+// Release provides a mock function with given fields: ctx, bookID, holder
+func (_m *ReservationRepository) Release(ctx context.Context, bookID int, holder string) error {
+	ret := _m.Called(ctx, bookID, holder)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Release")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, bookID, holder)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Reserve provides a mock function with given fields: ctx, bookID, holder, quantity, stock, ttl
+func (_m *ReservationRepository) Reserve(ctx context.Context, bookID int, holder string, quantity int, stock int, ttl time.Duration) (bool, error) {
+	ret := _m.Called(ctx, bookID, holder, quantity, stock, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reserve")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, int, int, time.Duration) (bool, error)); ok {
+		return rf(ctx, bookID, holder, quantity, stock, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, int, int, time.Duration) bool); ok {
+		r0 = rf(ctx, bookID, holder, quantity, stock, ttl)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, int, int, time.Duration) error); ok {
+		r1 = rf(ctx, bookID, holder, quantity, stock, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReservedByOthers provides a mock function with given fields: ctx, bookID, holder
+func (_m *ReservationRepository) ReservedByOthers(ctx context.Context, bookID int, holder string) (int, error) {
+	ret := _m.Called(ctx, bookID, holder)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReservedByOthers")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) (int, error)); ok {
+		return rf(ctx, bookID, holder)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) int); ok {
+		r0 = rf(ctx, bookID, holder)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, bookID, holder)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Transfer provides a mock function with given fields: ctx, bookID, from, to, quantity, stock, ttl
+func (_m *ReservationRepository) Transfer(ctx context.Context, bookID int, from string, to string, quantity int, stock int, ttl time.Duration) (bool, error) {
+	ret := _m.Called(ctx, bookID, from, to, quantity, stock, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Transfer")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, int, int, time.Duration) (bool, error)); ok {
+		return rf(ctx, bookID, from, to, quantity, stock, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string, int, int, time.Duration) bool); ok {
+		r0 = rf(ctx, bookID, from, to, quantity, stock, ttl)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string, int, int, time.Duration) error); ok {
+		r1 = rf(ctx, bookID, from, to, quantity, stock, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewReservationRepository creates a new instance of ReservationRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewReservationRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ReservationRepository {
+	mock := &ReservationRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
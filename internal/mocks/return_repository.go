@@ -0,0 +1,227 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	domain "github.com/yourorg/bookshop/internal/domain"
+)
+
+// ReturnRepository is an autogenerated mock type for the ReturnRepository type
+type ReturnRepository struct {
+	mock.Mock
+}
+
+// Approve provides a mock function with given fields: ctx, id, actor, note
+func (_m *ReturnRepository) Approve(ctx context.Context, id int, actor string, note string) (*domain.Return, error) {
+	ret := _m.Called(ctx, id, actor, note)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Approve")
+	}
+
+	var r0 *domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) (*domain.Return, error)); ok {
+		return rf(ctx, id, actor, note)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) *domain.Return); ok {
+		r0 = rf(ctx, id, actor, note)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string) error); ok {
+		r1 = rf(ctx, id, actor, note)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Create provides a mock function with given fields: ctx, ret
+func (_m *ReturnRepository) Create(ctx context.Context, ret *domain.Return) error {
+	ret_2 := _m.Called(ctx, ret)
+
+	if len(ret_2) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret_2.Get(0).(func(context.Context, *domain.Return) error); ok {
+		r0 = rf(ctx, ret)
+	} else {
+		r0 = ret_2.Error(0)
+	}
+
+	return r0
+}
+
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *ReturnRepository) GetByID(ctx context.Context, id int) (*domain.Return, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByID")
+	}
+
+	var r0 *domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*domain.Return, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *domain.Return); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, status, page
+func (_m *ReturnRepository) List(ctx context.Context, status string, page domain.PageRequest) ([]*domain.Return, error) {
+	ret := _m.Called(ctx, status, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []*domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PageRequest) ([]*domain.Return, error)); ok {
+		return rf(ctx, status, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PageRequest) []*domain.Return); ok {
+		r0 = rf(ctx, status, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.PageRequest) error); ok {
+		r1 = rf(ctx, status, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListByOrder provides a mock function with given fields: ctx, orderID
+func (_m *ReturnRepository) ListByOrder(ctx context.Context, orderID int) ([]*domain.Return, error) {
+	ret := _m.Called(ctx, orderID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByOrder")
+	}
+
+	var r0 []*domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]*domain.Return, error)); ok {
+		return rf(ctx, orderID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*domain.Return); ok {
+		r0 = rf(ctx, orderID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, orderID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkRefunded provides a mock function with given fields: ctx, id, paymentID, actor
+func (_m *ReturnRepository) MarkRefunded(ctx context.Context, id int, paymentID int, actor string) (*domain.Return, error) {
+	ret := _m.Called(ctx, id, paymentID, actor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkRefunded")
+	}
+
+	var r0 *domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, string) (*domain.Return, error)); ok {
+		return rf(ctx, id, paymentID, actor)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, string) *domain.Return); ok {
+		r0 = rf(ctx, id, paymentID, actor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, string) error); ok {
+		r1 = rf(ctx, id, paymentID, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Reject provides a mock function with given fields: ctx, id, actor, note
+func (_m *ReturnRepository) Reject(ctx context.Context, id int, actor string, note string) (*domain.Return, error) {
+	ret := _m.Called(ctx, id, actor, note)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reject")
+	}
+
+	var r0 *domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) (*domain.Return, error)); ok {
+		return rf(ctx, id, actor, note)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) *domain.Return); ok {
+		r0 = rf(ctx, id, actor, note)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string) error); ok {
+		r1 = rf(ctx, id, actor, note)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewReturnRepository creates a new instance of ReturnRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewReturnRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ReturnRepository {
+	mock := &ReturnRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,209 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	domain "github.com/yourorg/bookshop/internal/domain"
+)
+
+// ReturnService is an autogenerated mock type for the ReturnService type
+type ReturnService struct {
+	mock.Mock
+}
+
+// Approve provides a mock function with given fields: ctx, id, actor, note
+func (_m *ReturnService) Approve(ctx context.Context, id int, actor string, note string) (*domain.Return, error) {
+	ret := _m.Called(ctx, id, actor, note)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Approve")
+	}
+
+	var r0 *domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) (*domain.Return, error)); ok {
+		return rf(ctx, id, actor, note)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) *domain.Return); ok {
+		r0 = rf(ctx, id, actor, note)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string) error); ok {
+		r1 = rf(ctx, id, actor, note)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Get provides a mock function with given fields: ctx, id, actor, isAdmin
+func (_m *ReturnService) Get(ctx context.Context, id int, actor string, isAdmin bool) (*domain.Return, error) {
+	ret := _m.Called(ctx, id, actor, isAdmin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, bool) (*domain.Return, error)); ok {
+		return rf(ctx, id, actor, isAdmin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, bool) *domain.Return); ok {
+		r0 = rf(ctx, id, actor, isAdmin)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, bool) error); ok {
+		r1 = rf(ctx, id, actor, isAdmin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, status, page
+func (_m *ReturnService) List(ctx context.Context, status string, page domain.PageRequest) (*domain.ReturnList, error) {
+	ret := _m.Called(ctx, status, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 *domain.ReturnList
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PageRequest) (*domain.ReturnList, error)); ok {
+		return rf(ctx, status, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.PageRequest) *domain.ReturnList); ok {
+		r0 = rf(ctx, status, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ReturnList)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.PageRequest) error); ok {
+		r1 = rf(ctx, status, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListByOrder provides a mock function with given fields: ctx, orderID, actor, isAdmin
+func (_m *ReturnService) ListByOrder(ctx context.Context, orderID int, actor string, isAdmin bool) ([]*domain.Return, error) {
+	ret := _m.Called(ctx, orderID, actor, isAdmin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByOrder")
+	}
+
+	var r0 []*domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, bool) ([]*domain.Return, error)); ok {
+		return rf(ctx, orderID, actor, isAdmin)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, bool) []*domain.Return); ok {
+		r0 = rf(ctx, orderID, actor, isAdmin)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, bool) error); ok {
+		r1 = rf(ctx, orderID, actor, isAdmin)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Reject provides a mock function with given fields: ctx, id, actor, note
+func (_m *ReturnService) Reject(ctx context.Context, id int, actor string, note string) (*domain.Return, error) {
+	ret := _m.Called(ctx, id, actor, note)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reject")
+	}
+
+	var r0 *domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) (*domain.Return, error)); ok {
+		return rf(ctx, id, actor, note)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) *domain.Return); ok {
+		r0 = rf(ctx, id, actor, note)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string) error); ok {
+		r1 = rf(ctx, id, actor, note)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Request provides a mock function with given fields: ctx, orderID, userID, req
+func (_m *ReturnService) Request(ctx context.Context, orderID int, userID string, req domain.ReturnRequest) (*domain.Return, error) {
+	ret := _m.Called(ctx, orderID, userID, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Request")
+	}
+
+	var r0 *domain.Return
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, domain.ReturnRequest) (*domain.Return, error)); ok {
+		return rf(ctx, orderID, userID, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, domain.ReturnRequest) *domain.Return); ok {
+		r0 = rf(ctx, orderID, userID, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Return)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, domain.ReturnRequest) error); ok {
+		r1 = rf(ctx, orderID, userID, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewReturnService creates a new instance of ReturnService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewReturnService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ReturnService {
+	mock := &ReturnService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,109 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	domain "github.com/yourorg/bookshop/internal/domain"
+
+	time "time"
+)
+
+// TaxRepository is an autogenerated mock type for the TaxRepository type
+type TaxRepository struct {
+	mock.Mock
+}
+
+// Create provides a mock function with given fields: ctx, rate
+func (_m *TaxRepository) Create(ctx context.Context, rate *domain.TaxRate) error {
+	ret := _m.Called(ctx, rate)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.TaxRate) error); ok {
+		r0 = rf(ctx, rate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Effective provides a mock function with given fields: ctx, country, at
+func (_m *TaxRepository) Effective(ctx context.Context, country string, at time.Time) ([]*domain.TaxRate, error) {
+	ret := _m.Called(ctx, country, at)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Effective")
+	}
+
+	var r0 []*domain.TaxRate
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) ([]*domain.TaxRate, error)); ok {
+		return rf(ctx, country, at)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) []*domain.TaxRate); ok {
+		r0 = rf(ctx, country, at)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.TaxRate)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, country, at)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, page
+func (_m *TaxRepository) List(ctx context.Context, page domain.PageRequest) ([]*domain.TaxRate, error) {
+	ret := _m.Called(ctx, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []*domain.TaxRate
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) ([]*domain.TaxRate, error)); ok {
+		return rf(ctx, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) []*domain.TaxRate); ok {
+		r0 = rf(ctx, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.TaxRate)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PageRequest) error); ok {
+		r1 = rf(ctx, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewTaxRepository creates a new instance of TaxRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewTaxRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *TaxRepository {
+	mock := &TaxRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,77 @@
+// Code generated by mockery v2.53.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+	domain "github.com/yourorg/bookshop/internal/domain"
+)
+
+// TaxService is an autogenerated mock type for the TaxService type
+type TaxService struct {
+	mock.Mock
+}
+
+// Create provides a mock function with given fields: ctx, rate
+func (_m *TaxService) Create(ctx context.Context, rate *domain.TaxRate) error {
+	ret := _m.Called(ctx, rate)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.TaxRate) error); ok {
+		r0 = rf(ctx, rate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// List provides a mock function with given fields: ctx, page
+func (_m *TaxService) List(ctx context.Context, page domain.PageRequest) (*domain.TaxRateList, error) {
+	ret := _m.Called(ctx, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 *domain.TaxRateList
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) (*domain.TaxRateList, error)); ok {
+		return rf(ctx, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PageRequest) *domain.TaxRateList); ok {
+		r0 = rf(ctx, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.TaxRateList)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PageRequest) error); ok {
+		r1 = rf(ctx, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewTaxService creates a new instance of TaxService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewTaxService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *TaxService {
+	mock := &TaxService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -92,6 +92,24 @@ func (_m *UserRepository) GetByID(ctx context.Context, id string) (*domain.User,
 	return r0, r1
 }
 
+// Upsert provides a mock function with given fields: ctx, user
+func (_m *UserRepository) Upsert(ctx context.Context, user *domain.User) error {
+	ret := _m.Called(ctx, user)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Upsert")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.User) error); ok {
+		r0 = rf(ctx, user)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewUserRepository creates a new instance of UserRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserRepository(t interface {
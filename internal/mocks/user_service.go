@@ -14,6 +14,36 @@ type UserService struct {
 	mock.Mock
 }
 
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *UserService) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByID")
+	}
+
+	var r0 *domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetOrCreate provides a mock function with given fields: ctx, id, email, isAdmin
 func (_m *UserService) GetOrCreate(ctx context.Context, id string, email string, isAdmin bool) (*domain.User, error) {
 	ret := _m.Called(ctx, id, email, isAdmin)
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yourorg/bookshop/internal/domain"
@@ -17,8 +20,11 @@ func NewBookPostgres(db *pgxpool.Pool) *BookPostgres {
 	return &BookPostgres{db: db}
 }
 
+// GetByID возвращает цену, действующую в момент запроса, даже если
+// ApplyDuePrices ещё не перенёс её в books.price.
 func (r *BookPostgres) GetByID(ctx context.Context, id int) (*domain.Book, error) {
-	row := r.db.QueryRow(ctx, `SELECT id, title, author, year, price, category_id, inventory, created_at, updated_at FROM books WHERE id=$1`, id)
+	row := r.db.QueryRow(ctx, `SELECT b.id, b.title, b.author, b.year, `+currentPrice+`,
+		b.category_id, b.inventory, b.created_at, b.updated_at FROM books b WHERE b.id=$1`, id)
 	var b domain.Book
 	if err := row.Scan(&b.ID, &b.Title, &b.Author, &b.Year, &b.Price, &b.CategoryID, &b.Inventory, &b.CreatedAt, &b.UpdatedAt); err != nil {
 		return nil, fmt.Errorf("get by id: %w", err)
@@ -26,24 +32,112 @@ func (r *BookPostgres) GetByID(ctx context.Context, id int) (*domain.Book, error
 	return &b, nil
 }
 
-func (r *BookPostgres) List(ctx context.Context, categoryIDs []int, limit, offset int) ([]*domain.Book, error) {
-	q := `SELECT id, title, author, year, price, category_id, inventory, created_at, updated_at FROM books WHERE inventory > 0`
-	args := []interface{}{}
-	paramCount := 0
+// bookWhere строит условие WHERE по фильтру. Параметры нумеруются с $1.
+func bookWhere(f domain.BookFilter) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if !f.IncludeSoldOut {
+		conds = append(conds, "b.inventory > 0")
+	}
+	if len(f.CategoryIDs) > 0 {
+		conds = append(conds, "b.category_id = ANY("+arg(f.CategoryIDs)+")")
+	}
+	if f.MinPrice != nil {
+		conds = append(conds, "b.price >= "+arg(*f.MinPrice))
+	}
+	if f.MaxPrice != nil {
+		conds = append(conds, "b.price <= "+arg(*f.MaxPrice))
+	}
+	if f.MinYear != nil {
+		conds = append(conds, "b.year >= "+arg(*f.MinYear))
+	}
+	if f.MaxYear != nil {
+		conds = append(conds, "b.year <= "+arg(*f.MaxYear))
+	}
+	if f.Author != "" {
+		conds = append(conds, "b.author ILIKE "+arg("%"+likeEscaper.Replace(f.Author)+"%"))
+	}
+	if len(conds) == 0 {
+		return "TRUE", args
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func bookOrderBy(sort string) string {
+	dir := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		dir = "DESC"
+		sort = sort[1:]
+	}
+	switch sort {
+	case "price", "year", "title", "created_at":
+		return "b." + sort + " " + dir + ", b.id " + dir
+	}
+	return "b.id"
+}
 
-	if len(categoryIDs) > 0 {
-		paramCount++
-		q += " AND category_id = ANY($" + strconv.Itoa(paramCount) + ")"
-		args = append(args, categoryIDs)
+// bookKeyset строит условие "строго после курсора" в порядке bookOrderBy.
+// n — число уже занятых параметров запроса.
+func bookKeyset(after *domain.Cursor, sort string, n int) (string, []interface{}, error) {
+	op := ">"
+	field := strings.TrimPrefix(sort, "-")
+	if strings.HasPrefix(sort, "-") {
+		op = "<"
+	}
+	var value interface{}
+	var err error
+	switch field {
+	case "":
+		return "b.id > $" + strconv.Itoa(n+1), []interface{}{after.ID}, nil
+	case "price":
+		value, err = domain.ParseMoney(after.Value)
+	case "year":
+		value, err = strconv.Atoi(after.Value)
+	case "title":
+		value = after.Value
+	case "created_at":
+		value, err = time.Parse(time.RFC3339Nano, after.Value)
+	default:
+		err = fmt.Errorf("unknown sort %q", sort)
 	}
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return "(b." + field + ", b.id) " + op + " ($" + strconv.Itoa(n+1) + ", $" + strconv.Itoa(n+2) + ")",
+		[]interface{}{value, after.ID}, nil
+}
 
-	paramCount++
-	q += " ORDER BY id LIMIT $" + strconv.Itoa(paramCount)
-	args = append(args, limit)
+func (r *BookPostgres) Count(ctx context.Context, filter domain.BookFilter) (int, error) {
+	where, args := bookWhere(filter)
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM books b WHERE `+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count books: %w", err)
+	}
+	return total, nil
+}
 
-	paramCount++
-	q += " OFFSET $" + strconv.Itoa(paramCount)
-	args = append(args, offset)
+func (r *BookPostgres) List(ctx context.Context, filter domain.BookFilter) ([]*domain.Book, error) {
+	where, args := bookWhere(filter)
+	if filter.After != nil {
+		cond, keyArgs, err := bookKeyset(filter.After, filter.Sort, len(args))
+		if err != nil {
+			return nil, fmt.Errorf("list books: %w", err)
+		}
+		where += " AND " + cond
+		args = append(args, keyArgs...)
+	}
+	q := `SELECT b.id, b.title, b.author, b.year, b.price, b.category_id, b.inventory, b.created_at, b.updated_at FROM books b WHERE ` + where +
+		` ORDER BY ` + bookOrderBy(filter.Sort)
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		q += " LIMIT $" + strconv.Itoa(len(args))
+	}
 
 	rows, err := r.db.Query(ctx, q, args...)
 	if err != nil {
@@ -64,22 +158,123 @@ func (r *BookPostgres) List(ctx context.Context, categoryIDs []int, limit, offse
 	return books, nil
 }
 
+func (r *BookPostgres) Facets(ctx context.Context, filter domain.BookFilter) (*domain.BookFacets, error) {
+	facets := &domain.BookFacets{
+		Categories:   make([]domain.CategoryFacet, 0),
+		PriceBuckets: make([]domain.PriceBucketFacet, 0, len(domain.PriceBucketBounds)+1),
+	}
+
+	byCategory := filter
+	byCategory.CategoryIDs = nil
+	where, args := bookWhere(byCategory)
+	rows, err := r.db.Query(ctx, `SELECT c.id, c.name, COUNT(*) FROM books b JOIN categories c ON c.id = b.category_id
+		WHERE `+where+` GROUP BY c.id, c.name ORDER BY c.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("category facets: %w", err)
+	}
+	for rows.Next() {
+		var f domain.CategoryFacet
+		if err := rows.Scan(&f.CategoryID, &f.Name, &f.Count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan category facet: %w", err)
+		}
+		facets.Categories = append(facets.Categories, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("category facets: %w", err)
+	}
+
+	byPrice := filter
+	byPrice.MinPrice, byPrice.MaxPrice = nil, nil
+	where, args = bookWhere(byPrice)
+	args = append(args, domain.PriceBucketBounds)
+	// width_bucket возвращает 0..len(bounds): номер диапазона по возрастанию цены
+	rows, err = r.db.Query(ctx, `SELECT width_bucket(b.price::float8, $`+strconv.Itoa(len(args))+`::float8[]), COUNT(*) FROM books b
+		WHERE `+where+` GROUP BY 1`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("price facets: %w", err)
+	}
+	defer rows.Close()
+	counts := make([]int, len(domain.PriceBucketBounds)+1)
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, fmt.Errorf("scan price facet: %w", err)
+		}
+		counts[bucket] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("price facets: %w", err)
+	}
+	for i, count := range counts {
+		var f domain.PriceBucketFacet
+		if i > 0 {
+			f.Min = domain.PriceBucketBounds[i-1]
+		}
+		if i < len(domain.PriceBucketBounds) {
+			max := domain.PriceBucketBounds[i]
+			f.Max = &max
+		}
+		f.Count = count
+		facets.PriceBuckets = append(facets.PriceBuckets, f)
+	}
+	return facets, nil
+}
+
 func (r *BookPostgres) Create(ctx context.Context, book *domain.Book) error {
-	err := r.db.QueryRow(ctx, `INSERT INTO books (title, author, year, price, category_id, inventory) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id, created_at, updated_at`,
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	err = tx.QueryRow(ctx, `INSERT INTO books (title, author, year, price, category_id, inventory) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id, created_at, updated_at`,
 		book.Title, book.Author, book.Year, book.Price, book.CategoryID, book.Inventory,
 	).Scan(&book.ID, &book.CreatedAt, &book.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("create book: %w", err)
 	}
+	if err := insertPrice(ctx, tx, &domain.BookPrice{BookID: book.ID, Price: book.Price}); err != nil {
+		return err
+	}
+	// Начальный остаток тоже проходит через журнал
+	if book.Inventory > 0 {
+		m := &domain.InventoryMovement{BookID: book.ID, Delta: book.Inventory, Kind: domain.MovementInitial, BalanceAfter: book.Inventory}
+		if err := insertMovement(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
 	return nil
 }
 
+// Update меняет книгу; новая цена записывается в историю как действующая
+// с этого момента и перекрывает запланированные раньше.
 func (r *BookPostgres) Update(ctx context.Context, book *domain.Book) error {
-	_, err := r.db.Exec(ctx, `UPDATE books SET title=$1, author=$2, year=$3, price=$4, category_id=$5, updated_at=NOW() WHERE id=$6`,
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	var price domain.Money
+	if err := tx.QueryRow(ctx, `SELECT price FROM books WHERE id=$1 FOR UPDATE`, book.ID).Scan(&price); err != nil {
+		return fmt.Errorf("lock book: %w", err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE books SET title=$1, author=$2, year=$3, price=$4, category_id=$5, updated_at=NOW() WHERE id=$6`,
 		book.Title, book.Author, book.Year, book.Price, book.CategoryID, book.ID)
 	if err != nil {
 		return fmt.Errorf("update book: %w", err)
 	}
+	if price.Amount != book.Price.Amount {
+		if err := insertPrice(ctx, tx, &domain.BookPrice{BookID: book.ID, Price: book.Price}); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
 	return nil
 }
 
@@ -90,3 +285,55 @@ func (r *BookPostgres) Delete(ctx context.Context, id int) error {
 	}
 	return nil
 }
+
+// maxSearchTerms ограничивает размер tsquery для очень длинных запросов.
+const maxSearchTerms = 8
+
+// Search ищет книги по названию и автору. Каждое слово запроса ищется
+// как префикс в русской и английской морфологии, слова объединяются по AND.
+func (r *BookPostgres) Search(ctx context.Context, query string, limit, offset int) ([]*domain.Book, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return make([]*domain.Book, 0), nil
+	}
+	args := []interface{}{}
+	var parts []string
+	for _, term := range terms {
+		args = append(args, term+":*")
+		n := strconv.Itoa(len(args))
+		parts = append(parts, "(to_tsquery('russian', $"+n+") || to_tsquery('english', $"+n+"))")
+	}
+	tsq := strings.Join(parts, " && ")
+	args = append(args, limit, offset)
+	q := `SELECT id, title, author, year, price, category_id, inventory, created_at, updated_at FROM books
+		WHERE inventory > 0 AND search_vector @@ (` + tsq + `)
+		ORDER BY ts_rank(search_vector, ` + tsq + `) DESC, id
+		LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search books: %w", err)
+	}
+	defer rows.Close()
+	books := make([]*domain.Book, 0)
+	for rows.Next() {
+		var b domain.Book
+		if err := rows.Scan(&b.ID, &b.Title, &b.Author, &b.Year, &b.Price, &b.CategoryID, &b.Inventory, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan book: %w", err)
+		}
+		books = append(books, &b)
+	}
+	return books, nil
+}
+
+// searchTerms разбивает запрос на слова, отбрасывая всё, кроме букв и цифр,
+// чтобы пользовательский ввод не попадал в синтаксис tsquery.
+func searchTerms(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(fields) > maxSearchTerms {
+		fields = fields[:maxSearchTerms]
+	}
+	return fields
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"война", "и", "мир"}, searchTerms("Война и  мир"))
+	assert.Equal(t, []string{"tolstoy", "war"}, searchTerms("Tolstoy: war!"))
+	// Операторы tsquery не должны проходить в запрос
+	assert.Equal(t, []string{"a", "b", "c"}, searchTerms("a & b | !c:*"))
+	assert.Empty(t, searchTerms("&|!()"))
+	assert.Len(t, searchTerms("a b c d e f g h i j"), maxSearchTerms)
+}
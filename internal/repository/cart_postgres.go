@@ -19,9 +19,9 @@ func NewCartPostgres(db *pgxpool.Pool) *CartPostgres {
 }
 
 func (r *CartPostgres) GetByUserID(ctx context.Context, userID string) (*domain.Cart, error) {
-	row := r.db.QueryRow(ctx, `SELECT id, user_id, created_at, updated_at FROM carts WHERE user_id=$1`, userID)
+	row := r.db.QueryRow(ctx, `SELECT id, user_id, promo_code, created_at, updated_at FROM carts WHERE user_id=$1`, userID)
 	var c domain.Cart
-	if err := row.Scan(&c.ID, &c.UserID, &c.CreatedAt, &c.UpdatedAt); err != nil {
+	if err := row.Scan(&c.ID, &c.UserID, &c.PromoCode, &c.CreatedAt, &c.UpdatedAt); err != nil {
 		return nil, fmt.Errorf("get by user: %w", err)
 	}
 	return &c, nil
@@ -37,12 +37,19 @@ func (r *CartPostgres) AddItem(ctx context.Context, userID string, bookID int) e
 			return fmt.Errorf("add item: %w", err)
 		}
 	}
-	// Пытаемся увеличить quantity, если книга уже есть
-	res, err := r.db.Exec(ctx, `UPDATE cart_items SET quantity = quantity + 1 WHERE cart_id=$1 AND book_id=$2`, cart.ID, bookID)
-	n := res.RowsAffected()
-	if n == 0 {
+	ttl := domain.CartReservationTTL.Milliseconds()
+	// Пытаемся увеличить quantity, если книга уже есть; просроченная позиция
+	// начинается заново — с новым снимком цены
+	res, err := r.db.Exec(ctx, `UPDATE cart_items SET quantity = CASE WHEN expires_at > NOW() THEN quantity + 1 ELSE 1 END,
+		price_snapshot = CASE WHEN expires_at > NOW() THEN price_snapshot ELSE (SELECT `+currentPrice+` FROM books b WHERE b.id=$2) END,
+		reserved_at = NOW(), expires_at = NOW() + $3 * INTERVAL '1 millisecond' WHERE cart_id=$1 AND book_id=$2`, cart.ID, bookID, ttl)
+	if err != nil {
+		return fmt.Errorf("add item: %w", err)
+	}
+	if res.RowsAffected() == 0 {
 		// если не было — вставляем новую строку
-		_, err = r.db.Exec(ctx, `INSERT INTO cart_items (cart_id, book_id, quantity) VALUES ($1, $2, 1)`, cart.ID, bookID)
+		_, err = r.db.Exec(ctx, `INSERT INTO cart_items (cart_id, book_id, quantity, expires_at, price_snapshot)
+			SELECT $1, $2, 1, NOW() + $3 * INTERVAL '1 millisecond', `+currentPrice+` FROM books b WHERE b.id=$2`, cart.ID, bookID, ttl)
 	}
 	if err != nil {
 		return fmt.Errorf("add item: %w", err)
@@ -84,6 +91,22 @@ func (r *CartPostgres) Clear(ctx context.Context, userID string) error {
 	if err != nil {
 		return fmt.Errorf("clear: %w", err)
 	}
+	// Промокод применяется к содержимому корзины и уходит вместе с ним
+	if _, err := r.db.Exec(ctx, `UPDATE carts SET promo_code='', updated_at=NOW() WHERE id=$1`, cart.ID); err != nil {
+		return fmt.Errorf("clear: %w", err)
+	}
+	return nil
+}
+
+// SetPromoCode сохраняет промокод корзины; пустой code убирает его.
+func (r *CartPostgres) SetPromoCode(ctx context.Context, userID, code string) error {
+	res, err := r.db.Exec(ctx, `UPDATE carts SET promo_code=$2, updated_at=NOW() WHERE user_id=$1`, userID, code)
+	if err != nil {
+		return fmt.Errorf("set promo code: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return fmt.Errorf("set promo code: %w", pgx.ErrNoRows)
+	}
 	return nil
 }
 
@@ -92,7 +115,7 @@ func (r *CartPostgres) ListItems(ctx context.Context, userID string) ([]*domain.
 	if err != nil {
 		return nil, fmt.Errorf("list items: %w", err)
 	}
-	rows, err := r.db.Query(ctx, `SELECT id, cart_id, book_id, quantity, reserved_at FROM cart_items WHERE cart_id=$1`, cart.ID)
+	rows, err := r.db.Query(ctx, `SELECT id, cart_id, book_id, quantity, price_snapshot, reserved_at, expires_at FROM cart_items WHERE cart_id=$1 AND expires_at > NOW()`, cart.ID)
 	if err != nil {
 		return nil, fmt.Errorf("list items: %w", err)
 	}
@@ -100,7 +123,7 @@ func (r *CartPostgres) ListItems(ctx context.Context, userID string) ([]*domain.
 	var items []*domain.CartItem
 	for rows.Next() {
 		var it domain.CartItem
-		if err := rows.Scan(&it.ID, &it.CartID, &it.BookID, &it.Quantity, &it.ReservedAt); err != nil {
+		if err := rows.Scan(&it.ID, &it.CartID, &it.BookID, &it.Quantity, &it.PriceSnapshot, &it.ReservedAt, &it.ExpiresAt); err != nil {
 			return nil, fmt.Errorf("scan item: %w", err)
 		}
 		items = append(items, &it)
@@ -117,7 +140,7 @@ func (r *CartPostgres) GetItemQuantity(ctx context.Context, userID string, bookI
 		return 0, err
 	}
 	var quantity int
-	err = r.db.QueryRow(ctx, `SELECT quantity FROM cart_items WHERE cart_id=$1 AND book_id=$2`, cart.ID, bookID).Scan(&quantity)
+	err = r.db.QueryRow(ctx, `SELECT quantity FROM cart_items WHERE cart_id=$1 AND book_id=$2 AND expires_at > NOW()`, cart.ID, bookID).Scan(&quantity)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return 0, nil
 	}
@@ -126,3 +149,128 @@ func (r *CartPostgres) GetItemQuantity(ctx context.Context, userID string, bookI
 	}
 	return quantity, nil
 }
+
+// DeleteExpired удаляет позиции корзин с истёкшим резервом.
+func (r *CartPostgres) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM cart_items WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MoveItem в одной транзакции удаляет книгу из корзины from и задаёт её
+// количество в корзине to. Возвращает false, если в корзине from книги
+// уже нет (например, её перенёс параллельный запрос); корзина to тогда не
+// меняется.
+func (r *CartPostgres) MoveItem(ctx context.Context, from, to string, bookID, quantity int) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM cart_items WHERE book_id=$2 AND expires_at > NOW() AND cart_id IN (SELECT id FROM carts WHERE user_id=$1)`, from, bookID)
+	if err != nil {
+		return false, fmt.Errorf("move item: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	var cartID int
+	err = tx.QueryRow(ctx, `SELECT id FROM carts WHERE user_id=$1`, to).Scan(&cartID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = tx.QueryRow(ctx, `INSERT INTO carts (user_id) VALUES ($1) RETURNING id`, to).Scan(&cartID)
+	}
+	if err != nil {
+		return false, fmt.Errorf("move item: %w", err)
+	}
+	// Снимок цены у неистёкшей позиции пользователя сохраняется
+	_, err = tx.Exec(ctx, `INSERT INTO cart_items (cart_id, book_id, quantity, expires_at, price_snapshot)
+		SELECT $1, $2, $3, NOW() + $4 * INTERVAL '1 millisecond', `+currentPrice+` FROM books b WHERE b.id=$2
+		ON CONFLICT (cart_id, book_id) DO UPDATE SET quantity = EXCLUDED.quantity, reserved_at = NOW(), expires_at = EXCLUDED.expires_at,
+			price_snapshot = CASE WHEN cart_items.expires_at > NOW() THEN cart_items.price_snapshot ELSE EXCLUDED.price_snapshot END`,
+		cartID, bookID, quantity, domain.CartReservationTTL.Milliseconds())
+	if err != nil {
+		return false, fmt.Errorf("move item: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	return true, nil
+}
+
+// SetItems в одной транзакции проверяет остаток и устанавливает количество
+// нескольких книг в корзине. Книги блокируются FOR SHARE, чтобы остаток не
+// изменился до конца транзакции. Если каких-то книг не хватает, ничего не
+// меняется и возвращается *domain.OutOfStockError со всеми такими позициями.
+func (r *CartPostgres) SetItems(ctx context.Context, userID string, items []domain.CartItemQuantity) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var cartID int
+	err = tx.QueryRow(ctx, `SELECT id FROM carts WHERE user_id=$1`, userID).Scan(&cartID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = tx.QueryRow(ctx, `INSERT INTO carts (user_id) VALUES ($1) RETURNING id`, userID).Scan(&cartID)
+	}
+	if err != nil {
+		return fmt.Errorf("set items: %w", err)
+	}
+
+	ids := make([]int, 0, len(items))
+	for _, it := range items {
+		ids = append(ids, it.BookID)
+	}
+	rows, err := tx.Query(ctx, `SELECT b.id, b.inventory, `+currentPrice+` FROM books b WHERE b.id = ANY($1) ORDER BY b.id FOR SHARE`, ids)
+	if err != nil {
+		return fmt.Errorf("lock books: %w", err)
+	}
+	inventory := map[int]int{}
+	price := map[int]domain.Money{}
+	for rows.Next() {
+		var id, inv int
+		var p domain.Money
+		if err := rows.Scan(&id, &inv, &p); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan book: %w", err)
+		}
+		inventory[id] = inv
+		price[id] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("lock books: %w", err)
+	}
+	var short []domain.OutOfStockItem
+	for _, it := range items {
+		if it.Quantity > inventory[it.BookID] {
+			short = append(short, domain.OutOfStockItem{BookID: it.BookID, Requested: it.Quantity, Available: inventory[it.BookID]})
+		}
+	}
+	if len(short) > 0 {
+		return &domain.OutOfStockError{Items: short}
+	}
+
+	ttl := domain.CartReservationTTL.Milliseconds()
+	for _, it := range items {
+		if it.Quantity == 0 {
+			_, err = tx.Exec(ctx, `DELETE FROM cart_items WHERE cart_id=$1 AND book_id=$2`, cartID, it.BookID)
+		} else {
+			// Снимок цены сохраняется, пока позиция не истекла
+			_, err = tx.Exec(ctx, `INSERT INTO cart_items (cart_id, book_id, quantity, expires_at, price_snapshot) VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 millisecond', $5)
+				ON CONFLICT (cart_id, book_id) DO UPDATE SET quantity = EXCLUDED.quantity, reserved_at = NOW(), expires_at = EXCLUDED.expires_at,
+					price_snapshot = CASE WHEN cart_items.expires_at > NOW() THEN cart_items.price_snapshot ELSE EXCLUDED.price_snapshot END`,
+				cartID, it.BookID, it.Quantity, ttl, price[it.BookID])
+		}
+		if err != nil {
+			return fmt.Errorf("set items: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
@@ -8,6 +8,11 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// CartRedis — счётный резерв книг в корзинах. На каждую книгу два ключа:
+// ZSET держатель -> срок резерва (unix ms) и HASH держатель -> количество.
+// Проверка остатка и запись резерва делаются одним Lua-скриптом, поэтому
+// два покупателя не могут одновременно занять последний экземпляр.
+// Время берётся из Redis (TIME), а не с инстансов сервиса.
 type CartRedis struct {
 	rdb *redis.Client
 }
@@ -16,24 +21,126 @@ func NewCartRedis(rdb *redis.Client) *CartRedis {
 	return &CartRedis{rdb: rdb}
 }
 
-func (r *CartRedis) SetReservation(ctx context.Context, userID string, bookID int, ttlSeconds int) error {
-	key := fmt.Sprintf("reserve:book:%d", bookID)
-	return r.rdb.Set(ctx, key, userID, time.Duration(ttlSeconds)*time.Second).Err()
+// Общий для скриптов фрагмент: текущее время и удаление истёкших держателей.
+const reservePrune = `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local expired = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now)
+for _, h in ipairs(expired) do
+	redis.call('HDEL', KEYS[2], h)
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local held = 0
+local all = redis.call('HGETALL', KEYS[2])
+for i = 1, #all, 2 do
+	if all[i] ~= ARGV[1] then
+		held = held + tonumber(all[i + 1])
+	end
+end
+`
+
+// Общий для скриптов фрагмент: ключи живут, пока жив самый поздний резерв.
+const reserveExpire = `
+local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+local ttl = tonumber(last[2]) - now
+redis.call('PEXPIRE', KEYS[1], ttl)
+redis.call('PEXPIRE', KEYS[2], ttl)
+`
+
+// ARGV: держатель, количество, остаток на складе, ttl в мс.
+// Возвращает 1, если резерв записан, и 0, если не хватает книг.
+var reserveScript = redis.NewScript(reservePrune + `
+local quantity = tonumber(ARGV[2])
+local own = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+-- уменьшение своего резерва разрешено, даже если остаток упал
+if quantity > own and held + quantity > tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[4]), ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], quantity)
+` + reserveExpire + `
+return 1
+`)
+
+// ARGV: получатель, отдающий, количество, остаток на складе, ttl в мс.
+// Резерв отдающего не считается чужим: он переходит получателю.
+// Возвращает 1, если резерв перенесён, и 0, если не хватает книг.
+var transferScript = redis.NewScript(reservePrune + `
+local from = tonumber(redis.call('HGET', KEYS[2], ARGV[2]) or '0')
+held = held - from
+local quantity = tonumber(ARGV[3])
+local own = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+if quantity > own and held + quantity > tonumber(ARGV[4]) then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[2])
+redis.call('HDEL', KEYS[2], ARGV[2])
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[5]), ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], quantity)
+` + reserveExpire + `
+return 1
+`)
+
+// ARGV: держатель, чей резерв не учитывается.
+var reservedByOthersScript = redis.NewScript(reservePrune + `
+return held
+`)
+
+func reserveKeys(bookID int) []string {
+	// hash tag держит оба ключа в одном слоте Redis Cluster
+	return []string{
+		fmt.Sprintf("reserve:{book:%d}:expires", bookID),
+		fmt.Sprintf("reserve:{book:%d}:qty", bookID),
+	}
+}
+
+// Reserve устанавливает резерв holder на книгу ровно в quantity экземпляров
+// на ttl, если вместе с чужими активными резервами это не больше stock.
+// Возвращает false, если книг не хватает; прежний резерв holder при этом
+// не меняется. Уменьшение резерва всегда успешно, quantity <= 0 снимает его.
+func (r *CartRedis) Reserve(ctx context.Context, bookID int, holder string, quantity, stock int, ttl time.Duration) (bool, error) {
+	if quantity <= 0 {
+		return true, r.Release(ctx, bookID, holder)
+	}
+	ok, err := reserveScript.Run(ctx, r.rdb, reserveKeys(bookID), holder, quantity, stock, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("reserve book: %w", err)
+	}
+	return ok == 1, nil
 }
 
-func (r *CartRedis) RemoveReservation(ctx context.Context, userID string, bookID int) error {
-	key := fmt.Sprintf("reserve:book:%d", bookID)
-	return r.rdb.Del(ctx, key).Err()
+// Transfer атомарно снимает резерв from и устанавливает резерв to ровно в
+// quantity экземпляров на ttl, если вместе с резервами остальных это не
+// больше stock. Между снятием и записью никто не может занять освободившиеся
+// книги. Возвращает false, если книг не хватает; резервы тогда не меняются.
+func (r *CartRedis) Transfer(ctx context.Context, bookID int, from, to string, quantity, stock int, ttl time.Duration) (bool, error) {
+	ok, err := transferScript.Run(ctx, r.rdb, reserveKeys(bookID), to, from, quantity, stock, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("transfer reservation: %w", err)
+	}
+	return ok == 1, nil
 }
 
-func (r *CartRedis) IsReserved(ctx context.Context, bookID int) (bool, error) {
-	key := fmt.Sprintf("reserve:book:%d", bookID)
-	val, err := r.rdb.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return false, nil
+// Release снимает резерв holder на книгу.
+func (r *CartRedis) Release(ctx context.Context, bookID int, holder string) error {
+	keys := reserveKeys(bookID)
+	_, err := r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZRem(ctx, keys[0], holder)
+		p.HDel(ctx, keys[1], holder)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("release reservation: %w", err)
 	}
+	return nil
+}
+
+// ReservedByOthers возвращает, сколько экземпляров книги держат активные
+// резервы всех, кроме holder.
+func (r *CartRedis) ReservedByOthers(ctx context.Context, bookID int, holder string) (int, error) {
+	held, err := reservedByOthersScript.Run(ctx, r.rdb, reserveKeys(bookID), holder).Int()
 	if err != nil {
-		return false, err
+		return 0, fmt.Errorf("reserved by others: %w", err)
 	}
-	return val != "", nil
+	return held, nil
 }
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRedis подключается к Redis из TEST_REDIS_ADDR; без него тесты пропускаются.
+func testRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { rdb.Close() })
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+	return rdb
+}
+
+// testBookID выдаёт id книги, не пересекающийся с другими прогонами.
+func testBookID(t *testing.T, rdb *redis.Client) int {
+	t.Helper()
+	id := int(time.Now().UnixNano() % 1_000_000_000)
+	t.Cleanup(func() { rdb.Del(context.Background(), reserveKeys(id)...) })
+	return id
+}
+
+func TestCartRedis_Reserve_LastCopyUnderConcurrency(t *testing.T) {
+	rdb := testRedis(t)
+	store := NewCartRedis(rdb)
+	ctx := context.Background()
+	bookID := testBookID(t, rdb)
+
+	const stock, buyers = 3, 20
+	var wg sync.WaitGroup
+	var granted atomic.Int32
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, err := store.Reserve(ctx, bookID, fmt.Sprintf("user-%d", i), 1, stock, time.Minute)
+			assert.NoError(t, err)
+			if ok {
+				granted.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, int32(stock), granted.Load())
+
+	held, err := store.ReservedByOthers(ctx, bookID, "nobody")
+	require.NoError(t, err)
+	require.Equal(t, stock, held)
+}
+
+func TestCartRedis_Reserve_SetsAbsoluteQuantity(t *testing.T) {
+	rdb := testRedis(t)
+	store := NewCartRedis(rdb)
+	ctx := context.Background()
+	bookID := testBookID(t, rdb)
+
+	ok, err := store.Reserve(ctx, bookID, "user-1", 2, 3, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	// Повторный резерв того же держателя заменяет, а не складывает количество
+	ok, err = store.Reserve(ctx, bookID, "user-1", 3, 3, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = store.Reserve(ctx, bookID, "user-2", 1, 3, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, store.Release(ctx, bookID, "user-1"))
+	ok, err = store.Reserve(ctx, bookID, "user-2", 1, 3, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestCartRedis_Reserve_ExpiredHolderFreesStock(t *testing.T) {
+	rdb := testRedis(t)
+	store := NewCartRedis(rdb)
+	ctx := context.Background()
+	bookID := testBookID(t, rdb)
+
+	ok, err := store.Reserve(ctx, bookID, "user-1", 1, 1, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = store.Reserve(ctx, bookID, "user-2", 1, 1, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	time.Sleep(100 * time.Millisecond)
+	ok, err = store.Reserve(ctx, bookID, "user-2", 1, 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	held, err := store.ReservedByOthers(ctx, bookID, "user-2")
+	require.NoError(t, err)
+	require.Zero(t, held)
+}
+
+func TestCartRedis_Reserve_ShrinkAlwaysAllowed(t *testing.T) {
+	rdb := testRedis(t)
+	store := NewCartRedis(rdb)
+	ctx := context.Background()
+	bookID := testBookID(t, rdb)
+
+	ok, err := store.Reserve(ctx, bookID, "user-1", 3, 3, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	// Остаток уменьшили до 1 — резерв всё равно можно сократить
+	ok, err = store.Reserve(ctx, bookID, "user-1", 2, 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	held, err := store.ReservedByOthers(ctx, bookID, "nobody")
+	require.NoError(t, err)
+	require.Equal(t, 2, held)
+}
+
+func TestCartRedis_Transfer_KeepsLastCopy(t *testing.T) {
+	rdb := testRedis(t)
+	store := NewCartRedis(rdb)
+	ctx := context.Background()
+	bookID := testBookID(t, rdb)
+
+	ok, err := store.Reserve(ctx, bookID, "guest-1", 1, 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	// Последний экземпляр держит гость — он переходит пользователю, а не
+	// освобождается для других
+	ok, err = store.Transfer(ctx, bookID, "guest-1", "user-1", 1, 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	held, err := store.ReservedByOthers(ctx, bookID, "user-1")
+	require.NoError(t, err)
+	require.Zero(t, held)
+	ok, err = store.Reserve(ctx, bookID, "user-2", 1, 1, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Не хватает книг — резервы не меняются
+	ok, err = store.Reserve(ctx, bookID, "guest-2", 1, 2, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = store.Transfer(ctx, bookID, "guest-2", "user-2", 2, 2, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+	held, err = store.ReservedByOthers(ctx, bookID, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, 1, held)
+}
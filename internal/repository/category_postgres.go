@@ -25,8 +25,18 @@ func (r *CategoryPostgres) GetByID(ctx context.Context, id int) (*domain.Categor
 	return &c, nil
 }
 
-func (r *CategoryPostgres) List(ctx context.Context) ([]*domain.Category, error) {
-	rows, err := r.db.Query(ctx, `SELECT id, name FROM categories ORDER BY id`)
+func (r *CategoryPostgres) List(ctx context.Context, page domain.PageRequest) ([]*domain.Category, error) {
+	after := 0
+	if page.After != nil {
+		after = page.After.ID
+	}
+	q := `SELECT id, name FROM categories WHERE id > $1 ORDER BY id`
+	args := []interface{}{after}
+	if page.Limit > 0 {
+		q += " LIMIT $2"
+		args = append(args, page.Limit)
+	}
+	rows, err := r.db.Query(ctx, q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list categories: %w", err)
 	}
@@ -42,6 +52,14 @@ func (r *CategoryPostgres) List(ctx context.Context) ([]*domain.Category, error)
 	return cats, nil
 }
 
+func (r *CategoryPostgres) Count(ctx context.Context) (int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM categories`).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count categories: %w", err)
+	}
+	return total, nil
+}
+
 func (r *CategoryPostgres) Create(ctx context.Context, category *domain.Category) error {
 	if err := r.db.QueryRow(ctx, `INSERT INTO categories (name) VALUES ($1) RETURNING id`, category.Name).Scan(&category.ID); err != nil {
 		return fmt.Errorf("create category: %w", err)
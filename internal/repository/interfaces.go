@@ -2,21 +2,35 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/yourorg/bookshop/internal/domain"
 )
 
 type BookRepository interface {
 	GetByID(ctx context.Context, id int) (*domain.Book, error)
-	List(ctx context.Context, categoryIDs []int, limit, offset int) ([]*domain.Book, error)
+	List(ctx context.Context, filter domain.BookFilter) ([]*domain.Book, error)
+	Count(ctx context.Context, filter domain.BookFilter) (int, error)
+	Facets(ctx context.Context, filter domain.BookFilter) (*domain.BookFacets, error)
+	Search(ctx context.Context, query string, limit, offset int) ([]*domain.Book, error)
 	Create(ctx context.Context, book *domain.Book) error
 	Update(ctx context.Context, book *domain.Book) error
 	Delete(ctx context.Context, id int) error
+	AdjustInventory(ctx context.Context, m *domain.InventoryMovement) error
+	ListMovements(ctx context.Context, bookID int, page domain.PageRequest) ([]*domain.InventoryMovement, error)
+	CountMovements(ctx context.Context, bookID int) (int, error)
+	SchedulePrice(ctx context.Context, p *domain.BookPrice) error
+	CancelPrice(ctx context.Context, bookID, priceID int, now time.Time) error
+	ListPrices(ctx context.Context, bookID int, page domain.PageRequest) ([]*domain.BookPrice, error)
+	CountPrices(ctx context.Context, bookID int) (int, error)
+	ApplyDuePrices(ctx context.Context, now time.Time) ([]*domain.Book, error)
+	NextPriceChange(ctx context.Context, now time.Time) (*time.Time, error)
 }
 
 type CategoryRepository interface {
 	GetByID(ctx context.Context, id int) (*domain.Category, error)
-	List(ctx context.Context) ([]*domain.Category, error)
+	List(ctx context.Context, page domain.PageRequest) ([]*domain.Category, error)
+	Count(ctx context.Context) (int, error)
 	Create(ctx context.Context, category *domain.Category) error
 	Update(ctx context.Context, category *domain.Category) error
 	Delete(ctx context.Context, id int) error
@@ -27,6 +41,7 @@ type UserRepository interface {
 	GetByID(ctx context.Context, id string) (*domain.User, error)
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
 	CreateIfNotExists(ctx context.Context, user *domain.User) error
+	Upsert(ctx context.Context, user *domain.User) error
 }
 
 type CartRepository interface {
@@ -36,9 +51,67 @@ type CartRepository interface {
 	Clear(ctx context.Context, userID string) error
 	ListItems(ctx context.Context, userID string) ([]*domain.CartItem, error)
 	GetItemQuantity(ctx context.Context, userID string, bookID int) (int, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+	MoveItem(ctx context.Context, from, to string, bookID, quantity int) (bool, error)
+	SetItems(ctx context.Context, userID string, items []domain.CartItemQuantity) error
+	SetPromoCode(ctx context.Context, userID, code string) error
+}
+
+type PromoRepository interface {
+	Create(ctx context.Context, p *domain.PromoCode) error
+	Update(ctx context.Context, p *domain.PromoCode) error
+	GetByID(ctx context.Context, id int) (*domain.PromoCode, error)
+	GetByCode(ctx context.Context, code string) (*domain.PromoCode, error)
+	List(ctx context.Context, page domain.PageRequest) ([]*domain.PromoCode, error)
+	CountRedemptions(ctx context.Context, promoID int, userID string) (int, error)
+}
+
+type TaxRepository interface {
+	Create(ctx context.Context, rate *domain.TaxRate) error
+	List(ctx context.Context, page domain.PageRequest) ([]*domain.TaxRate, error)
+	Effective(ctx context.Context, country string, at time.Time) ([]*domain.TaxRate, error)
+}
+
+// ReservationRepository — счётный резерв книг в корзинах с TTL на держателя.
+type ReservationRepository interface {
+	Reserve(ctx context.Context, bookID int, holder string, quantity, stock int, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, bookID int, holder string) error
+	ReservedByOthers(ctx context.Context, bookID int, holder string) (int, error)
+	Transfer(ctx context.Context, bookID int, from, to string, quantity, stock int, ttl time.Duration) (bool, error)
 }
 
 type OrderRepository interface {
 	Create(ctx context.Context, order *domain.Order) error
-	ListByUser(ctx context.Context, userID string) ([]*domain.Order, error)
+	ListByUser(ctx context.Context, userID string, page domain.PageRequest) ([]*domain.Order, error)
+	CountByUser(ctx context.Context, userID string) (int, error)
+	GetByID(ctx context.Context, id int) (*domain.Order, error)
+	UpdateStatus(ctx context.Context, orderID int, from, to, actor, reason string) (*domain.OrderTransition, error)
+	Cancel(ctx context.Context, orderID int, from, actor, reason string) (*domain.OrderTransition, error)
+	ListTransitions(ctx context.Context, orderID int) ([]*domain.OrderTransition, error)
+}
+
+type OutboxRepository interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxMessage, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, backoff time.Duration, lastErr string) error
+	Release(ctx context.Context, ids []int64, delay time.Duration) error
+}
+
+type PaymentRepository interface {
+	Create(ctx context.Context, p *domain.Payment) error
+	GetActiveByOrder(ctx context.Context, orderID int) (*domain.Payment, error)
+	GetByProviderID(ctx context.Context, providerID string) (*domain.Payment, error)
+	ListUnsettled(ctx context.Context, limit int) ([]*domain.Payment, error)
+	UpdateStatus(ctx context.Context, id int, from, to, providerID, reason string) error
+	Capture(ctx context.Context, id int, actor string) (*domain.OrderTransition, error)
+}
+
+type ReturnRepository interface {
+	Create(ctx context.Context, ret *domain.Return) error
+	GetByID(ctx context.Context, id int) (*domain.Return, error)
+	ListByOrder(ctx context.Context, orderID int) ([]*domain.Return, error)
+	List(ctx context.Context, status string, page domain.PageRequest) ([]*domain.Return, error)
+	Approve(ctx context.Context, id int, actor, note string) (*domain.Return, error)
+	Reject(ctx context.Context, id int, actor, note string) (*domain.Return, error)
+	MarkRefunded(ctx context.Context, id, paymentID int, actor string) (*domain.Return, error)
 }
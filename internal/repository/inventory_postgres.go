@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+// AdjustInventory меняет остаток книги на m.Delta и записывает движение в
+// журнал в одной транзакции. Строка книги блокируется, поэтому параллельные
+// корректировки не теряются, а остаток не уходит в минус.
+func (r *BookPostgres) AdjustInventory(ctx context.Context, m *domain.InventoryMovement) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	var inventory int
+	err = tx.QueryRow(ctx, `SELECT inventory FROM books WHERE id=$1 FOR UPDATE`, m.BookID).Scan(&inventory)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("book not found: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("lock book: %w", err)
+	}
+	if inventory+m.Delta < 0 {
+		return fmt.Errorf("not enough books in stock: %w", errors.New("not enough books in stock"))
+	}
+	if _, err := tx.Exec(ctx, `UPDATE books SET inventory = inventory + $2, updated_at=NOW() WHERE id=$1`, m.BookID, m.Delta); err != nil {
+		return fmt.Errorf("update inventory: %w", err)
+	}
+	m.BalanceAfter = inventory + m.Delta
+	if err := insertMovement(ctx, tx, m); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// insertMovement пишет движение в журнал. Вызывающий сам меняет
+// books.inventory в той же транзакции и заполняет m.BalanceAfter.
+func insertMovement(ctx context.Context, tx pgx.Tx, m *domain.InventoryMovement) error {
+	err := tx.QueryRow(ctx, `INSERT INTO inventory_movements (book_id, delta, kind, reason, actor, order_id, balance_after) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id, created_at`,
+		m.BookID, m.Delta, m.Kind, m.Reason, m.Actor, m.OrderID, m.BalanceAfter,
+	).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert inventory movement: %w", err)
+	}
+	return nil
+}
+
+// ListMovements возвращает движения по книге от новых к старым.
+func (r *BookPostgres) ListMovements(ctx context.Context, bookID int, page domain.PageRequest) ([]*domain.InventoryMovement, error) {
+	q := `SELECT id, book_id, delta, kind, reason, actor, order_id, balance_after, created_at FROM inventory_movements WHERE book_id=$1`
+	args := []interface{}{bookID}
+	if page.After != nil {
+		args = append(args, page.After.ID)
+		q += " AND id < $" + strconv.Itoa(len(args))
+	}
+	q += " ORDER BY id DESC"
+	if page.Limit > 0 {
+		args = append(args, page.Limit)
+		q += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list movements: %w", err)
+	}
+	defer rows.Close()
+	movements := make([]*domain.InventoryMovement, 0)
+	for rows.Next() {
+		var m domain.InventoryMovement
+		if err := rows.Scan(&m.ID, &m.BookID, &m.Delta, &m.Kind, &m.Reason, &m.Actor, &m.OrderID, &m.BalanceAfter, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan movement: %w", err)
+		}
+		movements = append(movements, &m)
+	}
+	return movements, rows.Err()
+}
+
+func (r *BookPostgres) CountMovements(ctx context.Context, bookID int) (int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM inventory_movements WHERE book_id=$1`, bookID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count movements: %w", err)
+	}
+	return total, nil
+}
@@ -2,8 +2,11 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yourorg/bookshop/internal/domain"
 )
@@ -16,26 +19,70 @@ func NewOrderPostgres(db *pgxpool.Pool) *OrderPostgres {
 	return &OrderPostgres{db: db}
 }
 
+// Create сохраняет заказ и списывает остатки в одной транзакции. Строки
+// книг блокируются (FOR UPDATE, по возрастанию id — без взаимных
+// блокировок), поэтому параллельные заказы не продают больше, чем есть.
+// Если остатка не хватает, возвращается *domain.OutOfStockError со всеми
+// такими книгами, и ничего не сохраняется.
 func (r *OrderPostgres) Create(ctx context.Context, order *domain.Order) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback(ctx)
-	row := tx.QueryRow(ctx, `INSERT INTO orders (user_id) VALUES ($1) RETURNING id, created_at`, order.UserID)
-	if err := row.Scan(&order.ID, &order.CreatedAt); err != nil {
+	if err := reserveStock(ctx, tx, order.Items); err != nil {
+		return err
+	}
+	row := tx.QueryRow(ctx, `INSERT INTO orders (user_id, status, subtotal, discount, promo_code, shipping, tax, total, shipping_address, phone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, status, created_at, updated_at`,
+		order.UserID, domain.OrderPending, order.Subtotal, order.Discount, order.PromoCode, order.Shipping, order.Tax, order.Total, order.ShippingAddress, order.Phone)
+	if err := row.Scan(&order.ID, &order.Status, &order.CreatedAt, &order.UpdatedAt); err != nil {
 		return fmt.Errorf("insert order: %w", err)
 	}
-	for _, item := range order.Items {
-		row := tx.QueryRow(ctx, `INSERT INTO order_items (order_id, book_id, price, quantity) VALUES ($1, $2, $3, $4) RETURNING id`, order.ID, item.BookID, item.Price, item.Quantity)
+	if order.PromoCode != "" {
+		if err := redeemPromo(ctx, tx, order); err != nil {
+			return err
+		}
+	}
+	if _, err := insertTransition(ctx, tx, order.ID, "", order.Status, order.UserID, ""); err != nil {
+		return err
+	}
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.OrderID = order.ID
+		row := tx.QueryRow(ctx, `INSERT INTO order_items (order_id, book_id, price, quantity, discount, tax_rate, tax) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			order.ID, item.BookID, item.Price, item.Quantity, item.Discount, item.TaxRate, item.Tax)
 		if err := row.Scan(&item.ID); err != nil {
 			return fmt.Errorf("insert item: %w", err)
 		}
-		// Списываем остаток
-		_, err := tx.Exec(ctx, `UPDATE books SET inventory = inventory - 1 WHERE id=$1 AND inventory > 0`, item.BookID)
+		// Списываем остаток; строка уже заблокирована, но проверяем количество обновлённых строк
+		var balance int
+		err := tx.QueryRow(ctx, `UPDATE books SET inventory = inventory - $2, updated_at=NOW() WHERE id=$1 AND inventory >= $2 RETURNING inventory`, item.BookID, item.Quantity).Scan(&balance)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &domain.OutOfStockError{Items: []domain.OutOfStockItem{{BookID: item.BookID, Requested: item.Quantity}}}
+		}
 		if err != nil {
 			return fmt.Errorf("update inventory: %w", err)
 		}
+		m := &domain.InventoryMovement{BookID: item.BookID, Delta: -item.Quantity, Kind: domain.MovementOrder, OrderID: &order.ID, BalanceAfter: balance}
+		if err := insertMovement(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+	evt := domain.OrderPlacedEvent{OrderID: order.ID, UserID: order.UserID}
+	for _, item := range order.Items {
+		evt.Books = append(evt.Books, domain.OrderEventBook{BookID: item.BookID, Quantity: item.Quantity})
+	}
+	if err := insertOutbox(ctx, tx, domain.EventOrderPlaced, evt); err != nil {
+		return err
+	}
+	// Корзина очищается в той же транзакции: иначе после сбоя очистки
+	// повтор запроса оформил бы тот же заказ второй раз
+	if _, err := tx.Exec(ctx, `DELETE FROM cart_items WHERE cart_id IN (SELECT id FROM carts WHERE user_id=$1)`, order.UserID); err != nil {
+		return fmt.Errorf("clear cart: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE carts SET promo_code='', updated_at=NOW() WHERE user_id=$1`, order.UserID); err != nil {
+		return fmt.Errorf("clear cart: %w", err)
 	}
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("commit: %w", err)
@@ -43,8 +90,63 @@ func (r *OrderPostgres) Create(ctx context.Context, order *domain.Order) error {
 	return nil
 }
 
-func (r *OrderPostgres) ListByUser(ctx context.Context, userID string) ([]*domain.Order, error) {
-	rows, err := r.db.Query(ctx, `SELECT id, created_at FROM orders WHERE user_id=$1 ORDER BY created_at DESC`, userID)
+// reserveStock блокирует книги заказа и проверяет остатки по всем позициям сразу.
+func reserveStock(ctx context.Context, tx pgx.Tx, items []domain.OrderItem) error {
+	requested := map[int]int{}
+	var ids []int
+	for _, it := range items {
+		if it.Quantity <= 0 {
+			return fmt.Errorf("invalid quantity %d for book %d", it.Quantity, it.BookID)
+		}
+		if _, ok := requested[it.BookID]; !ok {
+			ids = append(ids, it.BookID)
+		}
+		requested[it.BookID] += it.Quantity
+	}
+	rows, err := tx.Query(ctx, `SELECT id, inventory FROM books WHERE id = ANY($1) ORDER BY id FOR UPDATE`, ids)
+	if err != nil {
+		return fmt.Errorf("lock books: %w", err)
+	}
+	available := map[int]int{}
+	for rows.Next() {
+		var id, inventory int
+		if err := rows.Scan(&id, &inventory); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan book: %w", err)
+		}
+		available[id] = inventory
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("lock books: %w", err)
+	}
+	var short []domain.OutOfStockItem
+	for _, id := range ids {
+		if available[id] < requested[id] {
+			short = append(short, domain.OutOfStockItem{BookID: id, Requested: requested[id], Available: available[id]})
+		}
+	}
+	if len(short) > 0 {
+		return &domain.OutOfStockError{Items: short}
+	}
+	return nil
+}
+
+// ListByUser возвращает заказы от новых к старым. Курсор — id последнего
+// заказа предыдущей страницы: id растут вместе с created_at.
+func (r *OrderPostgres) ListByUser(ctx context.Context, userID string, page domain.PageRequest) ([]*domain.Order, error) {
+	q := `SELECT ` + orderColumns + ` FROM orders WHERE user_id=$1`
+	args := []interface{}{userID}
+	if page.After != nil {
+		args = append(args, page.After.ID)
+		q += " AND id < $" + strconv.Itoa(len(args))
+	}
+	q += " ORDER BY id DESC"
+	if page.Limit > 0 {
+		args = append(args, page.Limit)
+		q += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	rows, err := r.db.Query(ctx, q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list by user: %w", err)
 	}
@@ -52,25 +154,213 @@ func (r *OrderPostgres) ListByUser(ctx context.Context, userID string) ([]*domai
 	var orders []*domain.Order
 	for rows.Next() {
 		var o domain.Order
-		o.UserID = userID
-		if err := rows.Scan(&o.ID, &o.CreatedAt); err != nil {
+		if err := scanOrder(rows, &o); err != nil {
 			return nil, fmt.Errorf("scan order: %w", err)
 		}
-		// Получаем order_items
-		itemRows, err := r.db.Query(ctx, `SELECT id, order_id, book_id, price, quantity FROM order_items WHERE order_id=$1`, o.ID)
-		if err != nil {
-			return nil, fmt.Errorf("get order items: %w", err)
-		}
-		for itemRows.Next() {
-			var it domain.OrderItem
-			if err := itemRows.Scan(&it.ID, &it.OrderID, &it.BookID, &it.Price, &it.Quantity); err != nil {
-				itemRows.Close()
-				return nil, fmt.Errorf("scan item: %w", err)
-			}
-			o.Items = append(o.Items, it)
+		if o.Items, err = r.items(ctx, o.ID); err != nil {
+			return nil, err
 		}
-		itemRows.Close()
 		orders = append(orders, &o)
 	}
 	return orders, nil
 }
+
+func (r *OrderPostgres) GetByID(ctx context.Context, id int) (*domain.Order, error) {
+	var o domain.Order
+	if err := scanOrder(r.db.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE id=$1`, id), &o); err != nil {
+		return nil, fmt.Errorf("get order: %w", err)
+	}
+	var err error
+	if o.Items, err = r.items(ctx, o.ID); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+const orderColumns = `id, user_id, status, subtotal, discount, promo_code, shipping, tax, total, shipping_address, phone, created_at, updated_at`
+
+func scanOrder(row pgx.Row, o *domain.Order) error {
+	return row.Scan(&o.ID, &o.UserID, &o.Status, &o.Subtotal, &o.Discount, &o.PromoCode, &o.Shipping, &o.Tax, &o.Total, &o.ShippingAddress, &o.Phone, &o.CreatedAt, &o.UpdatedAt)
+}
+
+func (r *OrderPostgres) items(ctx context.Context, orderID int) ([]domain.OrderItem, error) {
+	rows, err := r.db.Query(ctx, `SELECT id, order_id, book_id, price, quantity, discount, tax_rate, tax FROM order_items WHERE order_id=$1 ORDER BY id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("get order items: %w", err)
+	}
+	defer rows.Close()
+	var items []domain.OrderItem
+	for rows.Next() {
+		var it domain.OrderItem
+		if err := rows.Scan(&it.ID, &it.OrderID, &it.BookID, &it.Price, &it.Quantity, &it.Discount, &it.TaxRate, &it.Tax); err != nil {
+			return nil, fmt.Errorf("scan item: %w", err)
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// UpdateStatus переводит заказ из from в to и пишет переход в журнал.
+// Обновление условное (status = from), поэтому из двух параллельных
+// переходов применится только один. При переходе в refunded списанный
+// платёж в той же транзакции становится refunding.
+func (r *OrderPostgres) UpdateStatus(ctx context.Context, orderID int, from, to, actor, reason string) (*domain.OrderTransition, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	userID, err := setStatus(ctx, tx, orderID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	t, err := insertTransition(ctx, tx, orderID, from, to, actor, reason)
+	if err != nil {
+		return nil, err
+	}
+	if to == domain.OrderRefunded {
+		if err := requestRefund(ctx, tx, orderID); err != nil {
+			return nil, err
+		}
+	}
+	if err := insertOutbox(ctx, tx, domain.EventOrderStatusChanged, statusChangedEvent(userID, t)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return t, nil
+}
+
+// Cancel переводит заказ из from в cancelled, возвращает позиции на склад,
+// отмечает списанный платёж к возврату (refunding) и закрывает платёж,
+// до которого шлюз не ответил, в одной транзакции.
+func (r *OrderPostgres) Cancel(ctx context.Context, orderID int, from, actor, reason string) (*domain.OrderTransition, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	userID, err := setStatus(ctx, tx, orderID, from, domain.OrderCancelled)
+	if err != nil {
+		return nil, err
+	}
+	t, err := insertTransition(ctx, tx, orderID, from, domain.OrderCancelled, actor, reason)
+	if err != nil {
+		return nil, err
+	}
+	// Порядок по book_id — тот же, что при списании в Create
+	rows, err := tx.Query(ctx, `SELECT book_id, SUM(quantity) FROM order_items WHERE order_id=$1 GROUP BY book_id ORDER BY book_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("get order items: %w", err)
+	}
+	var returned []domain.OrderItem
+	for rows.Next() {
+		var it domain.OrderItem
+		if err := rows.Scan(&it.BookID, &it.Quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan item: %w", err)
+		}
+		returned = append(returned, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get order items: %w", err)
+	}
+	for _, it := range returned {
+		var balance int
+		err := tx.QueryRow(ctx, `UPDATE books SET inventory = inventory + $2, updated_at=NOW() WHERE id=$1 RETURNING inventory`, it.BookID, it.Quantity).Scan(&balance)
+		if err != nil {
+			return nil, fmt.Errorf("restore inventory: %w", err)
+		}
+		m := &domain.InventoryMovement{BookID: it.BookID, Delta: it.Quantity, Kind: domain.MovementCancel, Reason: reason, Actor: actor, OrderID: &orderID, BalanceAfter: balance}
+		if err := insertMovement(ctx, tx, m); err != nil {
+			return nil, err
+		}
+	}
+	// Отменённый заказ не расходует лимиты промокода
+	if err := releasePromo(ctx, tx, orderID); err != nil {
+		return nil, err
+	}
+	if err := requestRefund(ctx, tx, orderID); err != nil {
+		return nil, err
+	}
+	if err := cancelUnstartedPayment(ctx, tx, orderID); err != nil {
+		return nil, err
+	}
+	if err := insertOutbox(ctx, tx, domain.EventOrderStatusChanged, statusChangedEvent(userID, t)); err != nil {
+		return nil, err
+	}
+	cancelled := domain.OrderCancelledEvent{OrderID: orderID, UserID: userID, Actor: actor, Reason: reason, CancelledAt: t.CreatedAt}
+	for _, it := range returned {
+		cancelled.Books = append(cancelled.Books, domain.OrderEventBook{BookID: it.BookID, Quantity: it.Quantity})
+	}
+	if err := insertOutbox(ctx, tx, domain.EventOrderCancelled, cancelled); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return t, nil
+}
+
+// setStatus условно меняет статус заказа и возвращает его владельца.
+func setStatus(ctx context.Context, tx pgx.Tx, orderID int, from, to string) (string, error) {
+	var userID string
+	err := tx.QueryRow(ctx, `UPDATE orders SET status=$3, updated_at=NOW() WHERE id=$1 AND status=$2 RETURNING user_id`, orderID, from, to).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("order status changed concurrently: %w", errors.New("order status changed concurrently"))
+	}
+	if err != nil {
+		return "", fmt.Errorf("update status: %w", err)
+	}
+	return userID, nil
+}
+
+func statusChangedEvent(userID string, t *domain.OrderTransition) domain.OrderStatusChangedEvent {
+	return domain.OrderStatusChangedEvent{
+		OrderID:   t.OrderID,
+		UserID:    userID,
+		From:      t.From,
+		To:        t.To,
+		Actor:     t.Actor,
+		Reason:    t.Reason,
+		ChangedAt: t.CreatedAt,
+	}
+}
+
+func insertTransition(ctx context.Context, tx pgx.Tx, orderID int, from, to, actor, reason string) (*domain.OrderTransition, error) {
+	t := &domain.OrderTransition{OrderID: orderID, From: from, To: to, Actor: actor, Reason: reason}
+	err := tx.QueryRow(ctx, `INSERT INTO order_transitions (order_id, from_status, to_status, actor, reason) VALUES ($1,$2,$3,$4,$5) RETURNING id, created_at`,
+		orderID, from, to, actor, reason,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert transition: %w", err)
+	}
+	return t, nil
+}
+
+func (r *OrderPostgres) ListTransitions(ctx context.Context, orderID int) ([]*domain.OrderTransition, error) {
+	rows, err := r.db.Query(ctx, `SELECT id, order_id, from_status, to_status, actor, reason, created_at FROM order_transitions WHERE order_id=$1 ORDER BY id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("list transitions: %w", err)
+	}
+	defer rows.Close()
+	transitions := make([]*domain.OrderTransition, 0)
+	for rows.Next() {
+		var t domain.OrderTransition
+		if err := rows.Scan(&t.ID, &t.OrderID, &t.From, &t.To, &t.Actor, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan transition: %w", err)
+		}
+		transitions = append(transitions, &t)
+	}
+	return transitions, rows.Err()
+}
+
+func (r *OrderPostgres) CountByUser(ctx context.Context, userID string) (int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM orders WHERE user_id=$1`, userID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count orders: %w", err)
+	}
+	return total, nil
+}
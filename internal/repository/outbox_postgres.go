@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+type OutboxPostgres struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxPostgres(db *pgxpool.Pool) *OutboxPostgres {
+	return &OutboxPostgres{db: db}
+}
+
+// insertOutbox пишет событие в outbox в транзакции вызывающего.
+func insertOutbox(ctx context.Context, tx pgx.Tx, eventType string, event interface{}) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", eventType, err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO outbox (event_type, payload) VALUES ($1, $2)`, eventType, payload); err != nil {
+		return fmt.Errorf("insert outbox: %w", err)
+	}
+	return nil
+}
+
+// Claim забирает до limit готовых к отправке событий в порядке записи и
+// откладывает их на lease: другой экземпляр relay не возьмёт те же
+// события, пока этот их публикует. Если процесс упадёт, события вернутся
+// в очередь по истечении lease.
+func (r *OutboxPostgres) Claim(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxMessage, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE outbox SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE sent_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, payload, attempts, created_at`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox: %w", err)
+	}
+	defer rows.Close()
+	var msgs []*domain.OutboxMessage
+	for rows.Next() {
+		var m domain.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Type, &m.Payload, &m.Attempts, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan outbox: %w", err)
+		}
+		msgs = append(msgs, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim outbox: %w", err)
+	}
+	// UPDATE ... RETURNING не гарантирует порядок строк
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	return msgs, nil
+}
+
+func (r *OutboxPostgres) MarkSent(ctx context.Context, id int64) error {
+	if _, err := r.db.Exec(ctx, `UPDATE outbox SET sent_at=NOW(), attempts=attempts+1, last_error='' WHERE id=$1`, id); err != nil {
+		return fmt.Errorf("mark outbox sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed откладывает повторную отправку события на backoff.
+func (r *OutboxPostgres) MarkFailed(ctx context.Context, id int64, backoff time.Duration, lastErr string) error {
+	_, err := r.db.Exec(ctx, `UPDATE outbox SET attempts=attempts+1, last_error=$2, next_attempt_at=NOW() + $3 * INTERVAL '1 millisecond' WHERE id=$1`,
+		id, lastErr, backoff.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("mark outbox failed: %w", err)
+	}
+	return nil
+}
+
+// Release возвращает забранные, но не отправленные события в очередь через delay.
+func (r *OutboxPostgres) Release(ctx context.Context, ids []int64, delay time.Duration) error {
+	_, err := r.db.Exec(ctx, `UPDATE outbox SET next_attempt_at=NOW() + $2 * INTERVAL '1 millisecond' WHERE id = ANY($1)`, ids, delay.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("release outbox: %w", err)
+	}
+	return nil
+}
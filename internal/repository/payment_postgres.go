@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+type PaymentPostgres struct {
+	db *pgxpool.Pool
+}
+
+func NewPaymentPostgres(db *pgxpool.Pool) *PaymentPostgres {
+	return &PaymentPostgres{db: db}
+}
+
+const paymentColumns = `id, order_id, provider_id, status, amount, refunded, currency, decline_reason, created_at, updated_at`
+
+func scanPayment(row pgx.Row) (*domain.Payment, error) {
+	var p domain.Payment
+	var currency string
+	if err := row.Scan(&p.ID, &p.OrderID, &p.ProviderID, &p.Status, &p.Amount, &p.Refunded, &currency, &p.DeclineReason, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	p.Amount.Currency = currency
+	p.Refunded.Currency = currency
+	return &p, nil
+}
+
+// Create сохраняет новый платёж. Если у заказа уже есть незавершённый или
+// успешный платёж, возвращает ошибку "payment already in progress".
+func (r *PaymentPostgres) Create(ctx context.Context, p *domain.Payment) error {
+	err := r.db.QueryRow(ctx, `INSERT INTO payments (order_id, status, amount, currency) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`,
+		p.OrderID, p.Status, p.Amount, p.Amount.Currency).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("payment already in progress: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("insert payment: %w", err)
+	}
+	return nil
+}
+
+// GetActiveByOrder возвращает незавершённый или успешный платёж заказа.
+func (r *PaymentPostgres) GetActiveByOrder(ctx context.Context, orderID int) (*domain.Payment, error) {
+	p, err := scanPayment(r.db.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments
+		WHERE order_id=$1 AND status IN ('pending', 'authorized', 'captured', 'refunding')`, orderID))
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	return p, nil
+}
+
+// ListUnsettled возвращает платежи отменённых и возвращённых заказов, по
+// которым деньги ещё не вернулись покупателю или сумма ещё заблокирована
+// на карте, — сначала самые давние.
+func (r *PaymentPostgres) ListUnsettled(ctx context.Context, limit int) ([]*domain.Payment, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+paymentColumns+` FROM payments
+		WHERE status=$1 OR (status=$2 AND order_id IN (SELECT id FROM orders WHERE status=$3))
+		ORDER BY updated_at LIMIT $4`, domain.PaymentRefunding, domain.PaymentAuthorized, domain.OrderCancelled, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list unsettled payments: %w", err)
+	}
+	defer rows.Close()
+	var payments []*domain.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan payment: %w", err)
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// requestRefund в транзакции смены статуса заказа отмечает, что списанные
+// за него деньги нужно вернуть.
+func requestRefund(ctx context.Context, tx pgx.Tx, orderID int) error {
+	_, err := tx.Exec(ctx, `UPDATE payments SET status=$2, updated_at=NOW() WHERE order_id=$1 AND status=$3`, orderID, domain.PaymentRefunding, domain.PaymentCaptured)
+	if err != nil {
+		return fmt.Errorf("request refund: %w", err)
+	}
+	return nil
+}
+
+// cancelUnstartedPayment в транзакции отмены заказа закрывает платёж, до
+// которого шлюз так и не ответил (pending без provider_id): повторять его
+// уже незачем, а активным он остался бы навсегда.
+func cancelUnstartedPayment(ctx context.Context, tx pgx.Tx, orderID int) error {
+	_, err := tx.Exec(ctx, `UPDATE payments SET status=$2, decline_reason='order cancelled', updated_at=NOW()
+		WHERE order_id=$1 AND status=$3 AND provider_id=''`, orderID, domain.PaymentCancelled, domain.PaymentPending)
+	if err != nil {
+		return fmt.Errorf("cancel payment: %w", err)
+	}
+	return nil
+}
+
+func (r *PaymentPostgres) GetByProviderID(ctx context.Context, providerID string) (*domain.Payment, error) {
+	p, err := scanPayment(r.db.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments WHERE provider_id=$1`, providerID))
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	return p, nil
+}
+
+// UpdateStatus условно переводит платёж из from в to. Непустой providerID
+// запоминается — по нему потом находятся вебхуки. Переход в refunded
+// означает, что возвращена вся сумма.
+func (r *PaymentPostgres) UpdateStatus(ctx context.Context, id int, from, to, providerID, reason string) error {
+	tag, err := r.db.Exec(ctx, `UPDATE payments SET status=$3, provider_id=COALESCE(NULLIF($4, ''), provider_id), decline_reason=$5,
+			refunded=CASE WHEN $3='refunded' THEN amount ELSE refunded END, updated_at=NOW()
+		WHERE id=$1 AND status=$2`, id, from, to, providerID, reason)
+	if err != nil {
+		return fmt.Errorf("update payment: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("payment status changed concurrently: %w", errors.New("payment status changed concurrently"))
+	}
+	return nil
+}
+
+// Capture отмечает платёж списанным и переводит заказ из pending в paid в
+// одной транзакции: заказ не станет оплаченным без списания, а повторное
+// уведомление о списании ничего не изменит.
+func (r *PaymentPostgres) Capture(ctx context.Context, id int, actor string) (*domain.OrderTransition, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	var orderID int
+	var providerID string
+	err = tx.QueryRow(ctx, `UPDATE payments SET status=$2, updated_at=NOW() WHERE id=$1 AND status=$3 RETURNING order_id, provider_id`,
+		id, domain.PaymentCaptured, domain.PaymentAuthorized).Scan(&orderID, &providerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("payment status changed concurrently: %w", errors.New("payment status changed concurrently"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("capture payment: %w", err)
+	}
+	userID, err := setStatus(ctx, tx, orderID, domain.OrderPending, domain.OrderPaid)
+	if err != nil {
+		return nil, err
+	}
+	t, err := insertTransition(ctx, tx, orderID, domain.OrderPending, domain.OrderPaid, actor, "payment "+providerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertOutbox(ctx, tx, domain.EventOrderStatusChanged, statusChangedEvent(userID, t)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+func TestPaymentPostgres_Capture_MarksOrderPaidOnce(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	books := NewBookPostgres(db)
+	orders := NewOrderPostgres(db)
+	payments := NewPaymentPostgres(db)
+
+	book := &domain.Book{Title: "Payment", Author: "Test", Year: 2024, Price: domain.NewMoney(10000), CategoryID: 1, Inventory: 5}
+	require.NoError(t, books.Create(ctx, book))
+	order := &domain.Order{
+		UserID: "00000000-0000-0000-0000-000000000001",
+		Items:  []domain.OrderItem{{BookID: book.ID, Price: book.Price, Quantity: 1}},
+		Total:  domain.NewMoney(10000),
+	}
+	require.NoError(t, orders.Create(ctx, order))
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM orders WHERE id=$1`, order.ID)
+		db.Exec(ctx, `DELETE FROM books WHERE id=$1`, book.ID)
+	})
+
+	providerID := fmt.Sprintf("pay_test_%d", order.ID)
+	p := &domain.Payment{OrderID: order.ID, Status: domain.PaymentPending, Amount: order.Total}
+	require.NoError(t, payments.Create(ctx, p))
+	// Второй активный платёж по тому же заказу не создаётся
+	err := payments.Create(ctx, &domain.Payment{OrderID: order.ID, Status: domain.PaymentPending, Amount: order.Total})
+	require.ErrorContains(t, err, "payment already in progress")
+
+	require.NoError(t, payments.UpdateStatus(ctx, p.ID, domain.PaymentPending, domain.PaymentAuthorized, providerID, ""))
+	tr, err := payments.Capture(ctx, p.ID, "payment")
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderPaid, tr.To)
+
+	got, err := orders.GetByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderPaid, got.Status)
+	stored, err := payments.GetByProviderID(ctx, providerID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentCaptured, stored.Status)
+	assert.Equal(t, order.Total, stored.Amount)
+
+	// Повторное списание (например, дубль вебхука) ничего не меняет
+	_, err = payments.Capture(ctx, p.ID, "payment")
+	require.ErrorContains(t, err, "payment status changed concurrently")
+}
+
+// Отмена заказа оставляет платёж незавершённым, пока деньги не вернулись
+// или блокировка не снята.
+func TestPaymentPostgres_ListUnsettled(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	books := NewBookPostgres(db)
+	orders := NewOrderPostgres(db)
+	payments := NewPaymentPostgres(db)
+
+	book := &domain.Book{Title: "Unsettled", Author: "Test", Year: 2024, Price: domain.NewMoney(10000), CategoryID: 1, Inventory: 5}
+	require.NoError(t, books.Create(ctx, book))
+	newOrder := func() *domain.Order {
+		o := &domain.Order{
+			UserID: "00000000-0000-0000-0000-000000000001",
+			Items:  []domain.OrderItem{{BookID: book.ID, Price: book.Price, Quantity: 1}},
+			Total:  domain.NewMoney(10000),
+		}
+		require.NoError(t, orders.Create(ctx, o))
+		t.Cleanup(func() { db.Exec(ctx, `DELETE FROM orders WHERE id=$1`, o.ID) })
+		return o
+	}
+	t.Cleanup(func() { db.Exec(ctx, `DELETE FROM books WHERE id=$1`, book.ID) })
+
+	// Авторизован, заказ отменён до списания
+	voided := newOrder()
+	pv := &domain.Payment{OrderID: voided.ID, Status: domain.PaymentPending, Amount: voided.Total}
+	require.NoError(t, payments.Create(ctx, pv))
+	require.NoError(t, payments.UpdateStatus(ctx, pv.ID, domain.PaymentPending, domain.PaymentAuthorized, fmt.Sprintf("pay_void_%d", voided.ID), ""))
+	_, err := orders.Cancel(ctx, voided.ID, domain.OrderPending, "user-1", "")
+	require.NoError(t, err)
+
+	// Оплачен и отменён — деньги нужно вернуть
+	refunded := newOrder()
+	pr := &domain.Payment{OrderID: refunded.ID, Status: domain.PaymentPending, Amount: refunded.Total}
+	require.NoError(t, payments.Create(ctx, pr))
+	require.NoError(t, payments.UpdateStatus(ctx, pr.ID, domain.PaymentPending, domain.PaymentAuthorized, fmt.Sprintf("pay_refund_%d", refunded.ID), ""))
+	_, err = payments.Capture(ctx, pr.ID, "payment")
+	require.NoError(t, err)
+	_, err = orders.Cancel(ctx, refunded.ID, domain.OrderPaid, "admin-1", "")
+	require.NoError(t, err)
+
+	// Авторизован, заказ ещё ждёт списания — не трогаем
+	waiting := newOrder()
+	pw := &domain.Payment{OrderID: waiting.ID, Status: domain.PaymentPending, Amount: waiting.Total}
+	require.NoError(t, payments.Create(ctx, pw))
+	require.NoError(t, payments.UpdateStatus(ctx, pw.ID, domain.PaymentPending, domain.PaymentAuthorized, fmt.Sprintf("pay_wait_%d", waiting.ID), ""))
+
+	unsettled, err := payments.ListUnsettled(ctx, 1000)
+	require.NoError(t, err)
+	statuses := map[int]string{}
+	for _, p := range unsettled {
+		statuses[p.ID] = p.Status
+	}
+	assert.Equal(t, domain.PaymentAuthorized, statuses[pv.ID])
+	assert.Equal(t, domain.PaymentRefunding, statuses[pr.ID])
+	assert.NotContains(t, statuses, pw.ID)
+}
+
+// Платёж, до которого шлюз не ответил, закрывается вместе с отменой
+// заказа и не остаётся активным.
+func TestOrderPostgres_Cancel_ClosesUnstartedPayment(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	books := NewBookPostgres(db)
+	orders := NewOrderPostgres(db)
+	payments := NewPaymentPostgres(db)
+
+	book := &domain.Book{Title: "Unstarted", Author: "Test", Year: 2024, Price: domain.NewMoney(10000), CategoryID: 1, Inventory: 5}
+	require.NoError(t, books.Create(ctx, book))
+	order := &domain.Order{
+		UserID: "00000000-0000-0000-0000-000000000001",
+		Items:  []domain.OrderItem{{BookID: book.ID, Price: book.Price, Quantity: 1}},
+		Total:  domain.NewMoney(10000),
+	}
+	require.NoError(t, orders.Create(ctx, order))
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM orders WHERE id=$1`, order.ID)
+		db.Exec(ctx, `DELETE FROM books WHERE id=$1`, book.ID)
+	})
+
+	p := &domain.Payment{OrderID: order.ID, Status: domain.PaymentPending, Amount: order.Total}
+	require.NoError(t, payments.Create(ctx, p))
+	_, err := orders.Cancel(ctx, order.ID, domain.OrderPending, "user-1", "")
+	require.NoError(t, err)
+
+	_, err = payments.GetActiveByOrder(ctx, order.ID)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	var status string
+	require.NoError(t, db.QueryRow(ctx, `SELECT status FROM payments WHERE id=$1`, p.ID).Scan(&status))
+	assert.Equal(t, domain.PaymentCancelled, status)
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+// effectivePrice — подзапрос цены книги b, действующей в момент at (SQL-
+// выражение типа TIMESTAMP в UTC): из покрывающих момент цен побеждает
+// начавшаяся позже, при равенстве — записанная позже.
+func effectivePrice(at string) string {
+	return `SELECT p.price FROM book_prices p
+		WHERE p.book_id = b.id AND p.effective_from <= ` + at + ` AND (p.effective_to IS NULL OR p.effective_to > ` + at + `)
+		ORDER BY p.effective_from DESC, p.id DESC LIMIT 1`
+}
+
+// nowUTC — текущий момент в том виде, в каком время лежит в book_prices.
+const nowUTC = `(NOW() AT TIME ZONE 'UTC')`
+
+// currentPrice — цена книги b на момент запроса. books.price может отставать
+// до срабатывания планировщика, поэтому цену для продажи берём отсюда.
+var currentPrice = `COALESCE((` + effectivePrice(nowUTC) + `), b.price)`
+
+// SchedulePrice записывает цену в расписание. books.price не меняется —
+// его обновляет ApplyDuePrices, когда цена вступает в силу.
+func (r *BookPostgres) SchedulePrice(ctx context.Context, p *domain.BookPrice) error {
+	err := r.db.QueryRow(ctx, `INSERT INTO book_prices (book_id, price, effective_from, effective_to, reason, actor) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id, created_at`,
+		p.BookID, p.Price, p.EffectiveFrom, p.EffectiveTo, p.Reason, p.Actor,
+	).Scan(&p.ID, &p.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return fmt.Errorf("book not found: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("insert book price: %w", err)
+	}
+	return nil
+}
+
+// insertPrice пишет цену, вступающую в силу сразу, в транзакции, которая
+// сама меняет books.price.
+func insertPrice(ctx context.Context, tx pgx.Tx, p *domain.BookPrice) error {
+	err := tx.QueryRow(ctx, `INSERT INTO book_prices (book_id, price, effective_from, reason, actor) VALUES ($1,$2,`+nowUTC+`,$3,$4) RETURNING id, effective_from, created_at`,
+		p.BookID, p.Price, p.Reason, p.Actor,
+	).Scan(&p.ID, &p.EffectiveFrom, &p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert book price: %w", err)
+	}
+	return nil
+}
+
+// CancelPrice удаляет из расписания цену, которая ещё не вступила в силу.
+func (r *BookPostgres) CancelPrice(ctx context.Context, bookID, priceID int, now time.Time) error {
+	var from time.Time
+	err := r.db.QueryRow(ctx, `SELECT effective_from FROM book_prices WHERE id=$1 AND book_id=$2`, priceID, bookID).Scan(&from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("price not found: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("get book price: %w", err)
+	}
+	if !from.After(now) {
+		return fmt.Errorf("price already in effect: %w", errors.New("price already in effect"))
+	}
+	if _, err := r.db.Exec(ctx, `DELETE FROM book_prices WHERE id=$1`, priceID); err != nil {
+		return fmt.Errorf("delete book price: %w", err)
+	}
+	return nil
+}
+
+// ListPrices возвращает цены книги, включая запланированные, от поздних
+// к ранним по effective_from.
+func (r *BookPostgres) ListPrices(ctx context.Context, bookID int, page domain.PageRequest) ([]*domain.BookPrice, error) {
+	q := `SELECT id, book_id, price, effective_from, effective_to, reason, actor, created_at FROM book_prices WHERE book_id=$1`
+	args := []interface{}{bookID}
+	if page.After != nil {
+		from, err := time.Parse(time.RFC3339Nano, page.After.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		args = append(args, from, page.After.ID)
+		q += " AND (effective_from, id) < ($2, $3)"
+	}
+	q += " ORDER BY effective_from DESC, id DESC"
+	if page.Limit > 0 {
+		args = append(args, page.Limit)
+		q += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list book prices: %w", err)
+	}
+	defer rows.Close()
+	prices := make([]*domain.BookPrice, 0)
+	for rows.Next() {
+		var p domain.BookPrice
+		if err := rows.Scan(&p.ID, &p.BookID, &p.Price, &p.EffectiveFrom, &p.EffectiveTo, &p.Reason, &p.Actor, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan book price: %w", err)
+		}
+		prices = append(prices, &p)
+	}
+	return prices, rows.Err()
+}
+
+func (r *BookPostgres) CountPrices(ctx context.Context, bookID int) (int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM book_prices WHERE book_id=$1`, bookID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count book prices: %w", err)
+	}
+	return total, nil
+}
+
+// ApplyDuePrices переносит в books.price цены, действующие в момент now, и
+// возвращает книги, у которых цена изменилась. Повторный вызов ничего не
+// меняет, поэтому его можно запускать с нескольких инстансов.
+func (r *BookPostgres) ApplyDuePrices(ctx context.Context, now time.Time) ([]*domain.Book, error) {
+	rows, err := r.db.Query(ctx, `UPDATE books b SET price = e.price, updated_at = NOW()
+		FROM (SELECT b.id, (`+effectivePrice("$1")+`) AS price FROM books b) e
+		WHERE e.id = b.id AND e.price IS NOT NULL AND e.price <> b.price
+		RETURNING b.id, b.category_id, b.price`, now)
+	if err != nil {
+		return nil, fmt.Errorf("apply due prices: %w", err)
+	}
+	defer rows.Close()
+	var books []*domain.Book
+	for rows.Next() {
+		var b domain.Book
+		if err := rows.Scan(&b.ID, &b.CategoryID, &b.Price); err != nil {
+			return nil, fmt.Errorf("scan book: %w", err)
+		}
+		books = append(books, &b)
+	}
+	return books, rows.Err()
+}
+
+// NextPriceChange — ближайший после now момент, когда какая-то цена
+// начинается или заканчивается; nil, если таких нет.
+func (r *BookPostgres) NextPriceChange(ctx context.Context, now time.Time) (*time.Time, error) {
+	var next *time.Time
+	err := r.db.QueryRow(ctx, `SELECT MIN(t) FROM (
+			SELECT MIN(effective_from) AS t FROM book_prices WHERE effective_from > $1
+			UNION ALL
+			SELECT MIN(effective_to) FROM book_prices WHERE effective_to > $1
+		) s`, now).Scan(&next)
+	if err != nil {
+		return nil, fmt.Errorf("next price change: %w", err)
+	}
+	return next, nil
+}
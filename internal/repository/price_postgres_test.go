@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+func TestBookPostgres_Prices_SaleOverlaysBasePrice(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	books := NewBookPostgres(db)
+
+	book := &domain.Book{Title: "Prices", Author: "Test", Year: 2024, Price: domain.NewMoney(50000), CategoryID: 1, Inventory: 1}
+	require.NoError(t, books.Create(ctx, book))
+	t.Cleanup(func() { db.Exec(ctx, `DELETE FROM books WHERE id=$1`, book.ID) })
+
+	// Распродажа начинается сразу после цены из Create и идёт час
+	base, err := books.ListPrices(ctx, book.ID, domain.PageRequest{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, base, 1)
+	now := base[0].EffectiveFrom.Add(time.Microsecond)
+	end := now.Add(time.Hour)
+	sale := &domain.BookPrice{BookID: book.ID, Price: domain.NewMoney(35000), EffectiveFrom: now, EffectiveTo: &end, Reason: "sale"}
+	require.NoError(t, books.SchedulePrice(ctx, sale))
+
+	// GetByID видит цену распродажи до того, как её применил планировщик
+	got, err := books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(35000), got.Price)
+
+	changed, err := books.ApplyDuePrices(ctx, now)
+	require.NoError(t, err)
+	assert.Contains(t, bookIDs(changed), book.ID)
+	changed, err = books.ApplyDuePrices(ctx, now)
+	require.NoError(t, err)
+	assert.NotContains(t, bookIDs(changed), book.ID)
+
+	next, err := books.NextPriceChange(ctx, now)
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.False(t, next.After(end))
+
+	// После конца распродажи возвращается базовая цена
+	changed, err = books.ApplyDuePrices(ctx, end)
+	require.NoError(t, err)
+	assert.Contains(t, bookIDs(changed), book.ID)
+	var price domain.Money
+	require.NoError(t, db.QueryRow(ctx, `SELECT price FROM books WHERE id=$1`, book.ID).Scan(&price))
+	assert.Equal(t, domain.NewMoney(50000), price)
+
+	prices, err := books.ListPrices(ctx, book.ID, domain.PageRequest{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, prices, 2)
+	assert.Equal(t, sale.ID, prices[0].ID)
+
+	// Начавшуюся цену отменить нельзя
+	assert.ErrorContains(t, books.CancelPrice(ctx, book.ID, sale.ID, now), "price already in effect")
+}
+
+func TestBookPostgres_Update_RecordsPriceChange(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	books := NewBookPostgres(db)
+
+	book := &domain.Book{Title: "Update", Author: "Test", Year: 2024, Price: domain.NewMoney(10000), CategoryID: 1}
+	require.NoError(t, books.Create(ctx, book))
+	t.Cleanup(func() { db.Exec(ctx, `DELETE FROM books WHERE id=$1`, book.ID) })
+
+	book.Title = "Update 2"
+	require.NoError(t, books.Update(ctx, book))
+	book.Price = domain.NewMoney(12000)
+	require.NoError(t, books.Update(ctx, book))
+
+	n, err := books.CountPrices(ctx, book.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	got, err := books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(12000), got.Price)
+}
+
+func bookIDs(books []*domain.Book) []int {
+	ids := make([]int, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
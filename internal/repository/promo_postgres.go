@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+type PromoPostgres struct {
+	db *pgxpool.Pool
+}
+
+func NewPromoPostgres(db *pgxpool.Pool) *PromoPostgres {
+	return &PromoPostgres{db: db}
+}
+
+const promoColumns = `id, code, kind, percent, amount, min_subtotal, category_id, book_id, starts_at, ends_at,
+	per_user_limit, usage_limit, used_count, active, created_at, updated_at`
+
+func scanPromo(row pgx.Row) (*domain.PromoCode, error) {
+	var p domain.PromoCode
+	err := row.Scan(&p.ID, &p.Code, &p.Kind, &p.Percent, &p.Amount, &p.MinSubtotal, &p.CategoryID, &p.BookID, &p.StartsAt, &p.EndsAt,
+		&p.PerUserLimit, &p.UsageLimit, &p.UsedCount, &p.Active, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Create сохраняет промокод; занятый код — ошибка "promo code already exists".
+func (r *PromoPostgres) Create(ctx context.Context, p *domain.PromoCode) error {
+	err := r.db.QueryRow(ctx, `INSERT INTO promo_codes (code, kind, percent, amount, min_subtotal, category_id, book_id, starts_at, ends_at, per_user_limit, usage_limit, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, used_count, created_at, updated_at`,
+		p.Code, p.Kind, p.Percent, p.Amount, p.MinSubtotal, p.CategoryID, p.BookID, p.StartsAt, p.EndsAt, p.PerUserLimit, p.UsageLimit, p.Active).
+		Scan(&p.ID, &p.UsedCount, &p.CreatedAt, &p.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("promo code already exists: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("insert promo code: %w", err)
+	}
+	return nil
+}
+
+// Update меняет условия промокода. Код и счётчик использований не
+// меняются: код уже может лежать в корзинах, счётчик ведут заказы.
+func (r *PromoPostgres) Update(ctx context.Context, p *domain.PromoCode) error {
+	updated, err := scanPromo(r.db.QueryRow(ctx, `UPDATE promo_codes SET kind=$2, percent=$3, amount=$4, min_subtotal=$5, category_id=$6, book_id=$7,
+		starts_at=$8, ends_at=$9, per_user_limit=$10, usage_limit=$11, active=$12, updated_at=NOW()
+		WHERE id=$1 RETURNING `+promoColumns,
+		p.ID, p.Kind, p.Percent, p.Amount, p.MinSubtotal, p.CategoryID, p.BookID, p.StartsAt, p.EndsAt, p.PerUserLimit, p.UsageLimit, p.Active))
+	if err != nil {
+		return fmt.Errorf("update promo code: %w", err)
+	}
+	*p = *updated
+	return nil
+}
+
+func (r *PromoPostgres) GetByID(ctx context.Context, id int) (*domain.PromoCode, error) {
+	p, err := scanPromo(r.db.QueryRow(ctx, `SELECT `+promoColumns+` FROM promo_codes WHERE id=$1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("get promo code: %w", err)
+	}
+	return p, nil
+}
+
+func (r *PromoPostgres) GetByCode(ctx context.Context, code string) (*domain.PromoCode, error) {
+	p, err := scanPromo(r.db.QueryRow(ctx, `SELECT `+promoColumns+` FROM promo_codes WHERE code=$1`, code))
+	if err != nil {
+		return nil, fmt.Errorf("get promo code: %w", err)
+	}
+	return p, nil
+}
+
+// List возвращает промокоды от новых к старым.
+func (r *PromoPostgres) List(ctx context.Context, page domain.PageRequest) ([]*domain.PromoCode, error) {
+	before := 0
+	if page.After != nil {
+		before = page.After.ID
+	}
+	rows, err := r.db.Query(ctx, `SELECT `+promoColumns+` FROM promo_codes WHERE $1 = 0 OR id < $1 ORDER BY id DESC LIMIT $2`, before, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list promo codes: %w", err)
+	}
+	defer rows.Close()
+	var promos []*domain.PromoCode
+	for rows.Next() {
+		p, err := scanPromo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan promo code: %w", err)
+		}
+		promos = append(promos, p)
+	}
+	return promos, rows.Err()
+}
+
+// CountRedemptions — сколько заказов покупатель оформил с промокодом.
+func (r *PromoPostgres) CountRedemptions(ctx context.Context, promoID int, userID string) (int, error) {
+	var n int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM promo_redemptions WHERE promo_id=$1 AND user_id=$2`, promoID, userID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count redemptions: %w", err)
+	}
+	return n, nil
+}
+
+// redeemPromo гасит промокод заказа в транзакции оформления. Строка
+// промокода блокируется до конца транзакции, поэтому параллельные заказы
+// проверяют и увеличивают счётчики по очереди и не превысят лимиты.
+// Окно действия и сумму скидки уже проверил сервис.
+func redeemPromo(ctx context.Context, tx pgx.Tx, order *domain.Order) error {
+	var promoID, perUser, limit, used int
+	var active bool
+	err := tx.QueryRow(ctx, `SELECT id, per_user_limit, usage_limit, used_count, active FROM promo_codes WHERE code=$1 FOR UPDATE`, order.PromoCode).
+		Scan(&promoID, &perUser, &limit, &used, &active)
+	if err != nil {
+		return fmt.Errorf("lock promo code: %w", err)
+	}
+	if !active {
+		return &domain.PromoError{Code: order.PromoCode, Reason: domain.PromoInactive}
+	}
+	if limit > 0 && used >= limit {
+		return &domain.PromoError{Code: order.PromoCode, Reason: domain.PromoUsageLimit}
+	}
+	if perUser > 0 {
+		var n int
+		if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM promo_redemptions WHERE promo_id=$1 AND user_id=$2`, promoID, order.UserID).Scan(&n); err != nil {
+			return fmt.Errorf("count redemptions: %w", err)
+		}
+		if n >= perUser {
+			return &domain.PromoError{Code: order.PromoCode, Reason: domain.PromoUserLimit}
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE promo_codes SET used_count = used_count + 1, updated_at=NOW() WHERE id=$1`, promoID); err != nil {
+		return fmt.Errorf("redeem promo code: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO promo_redemptions (promo_id, user_id, order_id) VALUES ($1, $2, $3)`, promoID, order.UserID, order.ID); err != nil {
+		return fmt.Errorf("insert redemption: %w", err)
+	}
+	return nil
+}
+
+// releasePromo возвращает использование промокода отменённого заказа.
+func releasePromo(ctx context.Context, tx pgx.Tx, orderID int) error {
+	var promoID int
+	err := tx.QueryRow(ctx, `DELETE FROM promo_redemptions WHERE order_id=$1 RETURNING promo_id`, orderID).Scan(&promoID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("delete redemption: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE promo_codes SET used_count = used_count - 1, updated_at=NOW() WHERE id=$1`, promoID); err != nil {
+		return fmt.Errorf("release promo code: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+func TestPromoPostgres_Redeem_LimitsHoldUnderConcurrency(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	books := NewBookPostgres(db)
+	orders := NewOrderPostgres(db)
+	promos := NewPromoPostgres(db)
+
+	book := &domain.Book{Title: "Promo", Author: "Test", Year: 2024, Price: domain.NewMoney(10000), CategoryID: 1, Inventory: 100}
+	require.NoError(t, books.Create(ctx, book))
+	promo := &domain.PromoCode{Code: fmt.Sprintf("TEST%d", time.Now().UnixNano()), Kind: domain.PromoPercent, Percent: 10, UsageLimit: 5, PerUserLimit: 2, Active: true}
+	require.NoError(t, promos.Create(ctx, promo))
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM orders WHERE id IN (SELECT order_id FROM order_items WHERE book_id=$1)`, book.ID)
+		db.Exec(ctx, `DELETE FROM promo_codes WHERE id=$1`, promo.ID)
+		db.Exec(ctx, `DELETE FROM books WHERE id=$1`, book.ID)
+	})
+
+	// Четыре покупателя по три заказа: личный лимит 2, общий 5
+	users := []string{
+		"00000000-0000-0000-0000-00000000000a",
+		"00000000-0000-0000-0000-00000000000b",
+		"00000000-0000-0000-0000-00000000000c",
+		"00000000-0000-0000-0000-00000000000d",
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	placed := map[string][]int{}
+	reasons := map[string]int{}
+	for _, user := range users {
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(user string) {
+				defer wg.Done()
+				order := &domain.Order{
+					UserID:    user,
+					Items:     []domain.OrderItem{{BookID: book.ID, Price: book.Price, Quantity: 1, Discount: domain.NewMoney(1000)}},
+					Discount:  domain.NewMoney(1000),
+					PromoCode: promo.Code,
+				}
+				err := orders.Create(ctx, order)
+				mu.Lock()
+				defer mu.Unlock()
+				var promoErr *domain.PromoError
+				switch {
+				case err == nil:
+					placed[user] = append(placed[user], order.ID)
+				case errors.As(err, &promoErr):
+					reasons[promoErr.Reason]++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}(user)
+		}
+	}
+	wg.Wait()
+
+	total := 0
+	for user, ids := range placed {
+		assert.LessOrEqual(t, len(ids), 2, user)
+		total += len(ids)
+	}
+	assert.Equal(t, 5, total)
+	assert.Equal(t, 7, reasons[domain.PromoUsageLimit]+reasons[domain.PromoUserLimit])
+	got, err := promos.GetByID(ctx, promo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 5, got.UsedCount)
+
+	// Отмена заказа возвращает использование
+	var user string
+	for u := range placed {
+		user = u
+		break
+	}
+	_, err = orders.Cancel(ctx, placed[user][0], domain.OrderPending, user, "")
+	require.NoError(t, err)
+	got, err = promos.GetByID(ctx, promo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, got.UsedCount)
+	n, err := promos.CountRedemptions(ctx, promo.ID, user)
+	require.NoError(t, err)
+	assert.Equal(t, len(placed[user])-1, n)
+
+	order, err := orders.GetByID(ctx, placed[user][1%len(placed[user])])
+	require.NoError(t, err)
+	assert.Equal(t, promo.Code, order.PromoCode)
+	assert.Equal(t, domain.NewMoney(1000), order.Discount)
+	assert.Equal(t, domain.NewMoney(1000), order.Items[0].Discount)
+}
@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+type ReturnPostgres struct {
+	db *pgxpool.Pool
+}
+
+func NewReturnPostgres(db *pgxpool.Pool) *ReturnPostgres {
+	return &ReturnPostgres{db: db}
+}
+
+// Create сохраняет заявку на возврат. Строка заказа блокируется, поэтому
+// параллельные заявки не вернут одну позицию дважды: количество в заявке
+// не больше купленного за вычетом уже заявленного в неотклонённых
+// возвратах. BookID, Price, Discount и RefundAmount берутся из позиций
+// заказа.
+func (r *ReturnPostgres) Create(ctx context.Context, ret *domain.Return) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM orders WHERE id=$1 FOR UPDATE`, ret.OrderID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("order not found: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("lock order: %w", err)
+	}
+	if status != domain.OrderDelivered {
+		return fmt.Errorf("order cannot be returned: %w", fmt.Errorf("status is %s", status))
+	}
+
+	type orderItem struct {
+		bookID    int
+		price     domain.Money
+		discount  domain.Money
+		quantity  int
+		remaining int
+	}
+	rows, err := tx.Query(ctx, `SELECT oi.id, oi.book_id, oi.price, oi.discount, oi.quantity, oi.quantity - COALESCE(SUM(ri.quantity) FILTER (WHERE rt.status <> 'rejected'), 0)
+		FROM order_items oi
+		LEFT JOIN return_items ri ON ri.order_item_id = oi.id
+		LEFT JOIN returns rt ON rt.id = ri.return_id
+		WHERE oi.order_id=$1
+		GROUP BY oi.id`, ret.OrderID)
+	if err != nil {
+		return fmt.Errorf("get order items: %w", err)
+	}
+	items := map[int]orderItem{}
+	for rows.Next() {
+		var id int
+		var it orderItem
+		if err := rows.Scan(&id, &it.bookID, &it.price, &it.discount, &it.quantity, &it.remaining); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan item: %w", err)
+		}
+		items[id] = it
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("get order items: %w", err)
+	}
+	for i := range ret.Items {
+		ri := &ret.Items[i]
+		it, ok := items[ri.OrderItemID]
+		if !ok {
+			return fmt.Errorf("invalid return: %w", fmt.Errorf("order item %d is not in order %d", ri.OrderItemID, ret.OrderID))
+		}
+		if ri.Quantity > it.remaining {
+			return fmt.Errorf("return quantity exceeds remaining: %w", fmt.Errorf("order item %d: requested %d, remaining %d", ri.OrderItemID, ri.Quantity, it.remaining))
+		}
+		ri.BookID, ri.Price = it.bookID, it.price
+		ri.Discount = domain.ItemDiscount(it.discount, ri.Quantity, it.quantity)
+	}
+	ret.Status = domain.ReturnRequested
+	ret.RefundAmount = domain.ReturnRefund(ret.Items)
+	err = tx.QueryRow(ctx, `INSERT INTO returns (order_id, user_id, status, reason, refund_amount) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`,
+		ret.OrderID, ret.UserID, ret.Status, ret.Reason, ret.RefundAmount).Scan(&ret.ID, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("insert return: %w", err)
+	}
+	for i := range ret.Items {
+		ri := &ret.Items[i]
+		err := tx.QueryRow(ctx, `INSERT INTO return_items (return_id, order_item_id, quantity) VALUES ($1, $2, $3) RETURNING id`,
+			ret.ID, ri.OrderItemID, ri.Quantity).Scan(&ri.ID)
+		if err != nil {
+			return fmt.Errorf("insert return item: %w", err)
+		}
+	}
+	if err := insertOutbox(ctx, tx, domain.EventReturnRequested, returnEvent(ret, ret.UserID, ret.CreatedAt)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+const returnColumns = `id, order_id, user_id, status, reason, refund_amount, resolved_by, resolution, created_at, updated_at`
+
+func scanReturn(row pgx.Row) (*domain.Return, error) {
+	var ret domain.Return
+	if err := row.Scan(&ret.ID, &ret.OrderID, &ret.UserID, &ret.Status, &ret.Reason, &ret.RefundAmount, &ret.ResolvedBy, &ret.Resolution, &ret.CreatedAt, &ret.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// returnItemsQuery — позиции возврата по возрастанию book_id.
+const returnItemsQuery = `SELECT ri.id, ri.order_item_id, oi.book_id, oi.price, ri.quantity, oi.discount, oi.quantity
+	FROM return_items ri JOIN order_items oi ON oi.id = ri.order_item_id
+	WHERE ri.return_id=$1 ORDER BY oi.book_id, ri.id`
+
+// scanReturnItems читает результат returnItemsQuery; вызывается как
+// scanReturnItems(db.Query(ctx, returnItemsQuery, id)) с пулом или транзакцией.
+func scanReturnItems(rows pgx.Rows, err error) ([]domain.ReturnItem, error) {
+	if err != nil {
+		return nil, fmt.Errorf("get return items: %w", err)
+	}
+	defer rows.Close()
+	var items []domain.ReturnItem
+	for rows.Next() {
+		var it domain.ReturnItem
+		var lineDiscount domain.Money
+		var ordered int
+		if err := rows.Scan(&it.ID, &it.OrderItemID, &it.BookID, &it.Price, &it.Quantity, &lineDiscount, &ordered); err != nil {
+			return nil, fmt.Errorf("scan return item: %w", err)
+		}
+		it.Discount = domain.ItemDiscount(lineDiscount, it.Quantity, ordered)
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+func (r *ReturnPostgres) GetByID(ctx context.Context, id int) (*domain.Return, error) {
+	ret, err := scanReturn(r.db.QueryRow(ctx, `SELECT `+returnColumns+` FROM returns WHERE id=$1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("get return: %w", err)
+	}
+	if ret.Items, err = scanReturnItems(r.db.Query(ctx, returnItemsQuery, ret.ID)); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (r *ReturnPostgres) ListByOrder(ctx context.Context, orderID int) ([]*domain.Return, error) {
+	return r.list(ctx, `SELECT `+returnColumns+` FROM returns WHERE order_id=$1 ORDER BY id`, orderID)
+}
+
+// List возвращает возвраты от новых к старым, с пустым status — все.
+func (r *ReturnPostgres) List(ctx context.Context, status string, page domain.PageRequest) ([]*domain.Return, error) {
+	q := `SELECT ` + returnColumns + ` FROM returns WHERE ($1 = '' OR status = $1)`
+	args := []interface{}{status}
+	if page.After != nil {
+		args = append(args, page.After.ID)
+		q += " AND id < $" + strconv.Itoa(len(args))
+	}
+	q += " ORDER BY id DESC"
+	if page.Limit > 0 {
+		args = append(args, page.Limit)
+		q += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	return r.list(ctx, q, args...)
+}
+
+func (r *ReturnPostgres) list(ctx context.Context, q string, args ...interface{}) ([]*domain.Return, error) {
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list returns: %w", err)
+	}
+	returns := make([]*domain.Return, 0)
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan return: %w", err)
+		}
+		returns = append(returns, ret)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list returns: %w", err)
+	}
+	for _, ret := range returns {
+		if ret.Items, err = scanReturnItems(r.db.Query(ctx, returnItemsQuery, ret.ID)); err != nil {
+			return nil, err
+		}
+	}
+	return returns, nil
+}
+
+// Approve одобряет заявку и возвращает книги на склад в одной транзакции.
+func (r *ReturnPostgres) Approve(ctx context.Context, id int, actor, note string) (*domain.Return, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	ret, err := resolveReturn(ctx, tx, id, domain.ReturnRequested, domain.ReturnApproved, actor, note)
+	if err != nil {
+		return nil, err
+	}
+	// Позиции отсортированы по book_id — тот же порядок блокировок, что в заказах
+	for _, it := range ret.Items {
+		var balance int
+		err := tx.QueryRow(ctx, `UPDATE books SET inventory = inventory + $2, updated_at=NOW() WHERE id=$1 RETURNING inventory`, it.BookID, it.Quantity).Scan(&balance)
+		if err != nil {
+			return nil, fmt.Errorf("restock: %w", err)
+		}
+		m := &domain.InventoryMovement{BookID: it.BookID, Delta: it.Quantity, Kind: domain.MovementReturn, Reason: "return " + strconv.Itoa(id), Actor: actor, OrderID: &ret.OrderID, BalanceAfter: balance}
+		if err := insertMovement(ctx, tx, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := insertOutbox(ctx, tx, domain.EventReturnApproved, returnEvent(ret, actor, ret.UpdatedAt)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return ret, nil
+}
+
+func (r *ReturnPostgres) Reject(ctx context.Context, id int, actor, note string) (*domain.Return, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	ret, err := resolveReturn(ctx, tx, id, domain.ReturnRequested, domain.ReturnRejected, actor, note)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertOutbox(ctx, tx, domain.EventReturnRejected, returnEvent(ret, actor, ret.UpdatedAt)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return ret, nil
+}
+
+// MarkRefunded отмечает, что деньги по одобренному возврату вернулись, и
+// добавляет сумму к возвращённому по платежу paymentID (0 — заказ оплачен
+// без платежа). Когда возвращено всё, платёж становится refunded.
+func (r *ReturnPostgres) MarkRefunded(ctx context.Context, id, paymentID int, actor string) (*domain.Return, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	ret, err := scanReturn(tx.QueryRow(ctx, `UPDATE returns SET status=$3, updated_at=NOW() WHERE id=$1 AND status=$2 RETURNING `+returnColumns,
+		id, domain.ReturnApproved, domain.ReturnRefunded))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("return status changed concurrently: %w", errors.New("return status changed concurrently"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update return: %w", err)
+	}
+	if ret.Items, err = scanReturnItems(tx.Query(ctx, returnItemsQuery, id)); err != nil {
+		return nil, err
+	}
+	if paymentID != 0 {
+		tag, err := tx.Exec(ctx, `UPDATE payments SET refunded = refunded + $2,
+				status = CASE WHEN refunded + $2 >= amount THEN 'refunded' ELSE status END, updated_at=NOW()
+			WHERE id=$1 AND status='captured'`, paymentID, ret.RefundAmount)
+		if err != nil {
+			return nil, fmt.Errorf("update payment: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return nil, fmt.Errorf("payment status changed concurrently: %w", errors.New("payment status changed concurrently"))
+		}
+	}
+	if err := insertOutbox(ctx, tx, domain.EventReturnRefunded, returnEvent(ret, actor, ret.UpdatedAt)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return ret, nil
+}
+
+// resolveReturn условно переводит возврат из from в to с решением админа.
+func resolveReturn(ctx context.Context, tx pgx.Tx, id int, from, to, actor, note string) (*domain.Return, error) {
+	ret, err := scanReturn(tx.QueryRow(ctx, `UPDATE returns SET status=$3, resolved_by=$4, resolution=$5, updated_at=NOW()
+		WHERE id=$1 AND status=$2 RETURNING `+returnColumns, id, from, to, actor, note))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("return status changed concurrently: %w", errors.New("return status changed concurrently"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update return: %w", err)
+	}
+	if ret.Items, err = scanReturnItems(tx.Query(ctx, returnItemsQuery, id)); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func returnEvent(ret *domain.Return, actor string, at time.Time) domain.ReturnEvent {
+	evt := domain.ReturnEvent{
+		ReturnID:     ret.ID,
+		OrderID:      ret.OrderID,
+		UserID:       ret.UserID,
+		Status:       ret.Status,
+		Actor:        actor,
+		Reason:       ret.Reason,
+		RefundAmount: ret.RefundAmount,
+		ChangedAt:    at,
+	}
+	for _, it := range ret.Items {
+		evt.Books = append(evt.Books, domain.OrderEventBook{BookID: it.BookID, Quantity: it.Quantity})
+	}
+	return evt
+}
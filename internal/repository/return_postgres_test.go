@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+func TestReturnPostgres_ApproveRestocksAndRefundsPartially(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	books := NewBookPostgres(db)
+	orders := NewOrderPostgres(db)
+	payments := NewPaymentPostgres(db)
+	returns := NewReturnPostgres(db)
+
+	book := &domain.Book{Title: "Return", Author: "Test", Year: 2024, Price: domain.NewMoney(25000), CategoryID: 1, Inventory: 5}
+	require.NoError(t, books.Create(ctx, book))
+	order := &domain.Order{
+		UserID: "00000000-0000-0000-0000-000000000001",
+		Items:  []domain.OrderItem{{BookID: book.ID, Price: book.Price, Quantity: 3}},
+		Total:  domain.NewMoney(75000),
+	}
+	require.NoError(t, orders.Create(ctx, order))
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM orders WHERE id=$1`, order.ID)
+		db.Exec(ctx, `DELETE FROM books WHERE id=$1`, book.ID)
+	})
+	itemID := order.Items[0].ID
+	newReturn := func(qty int) *domain.Return {
+		return &domain.Return{OrderID: order.ID, UserID: order.UserID, Reason: "брак", Items: []domain.ReturnItem{{OrderItemID: itemID, Quantity: qty}}}
+	}
+
+	// Вернуть можно только доставленный заказ
+	require.ErrorContains(t, returns.Create(ctx, newReturn(1)), "order cannot be returned")
+	_, err := db.Exec(ctx, `UPDATE orders SET status='delivered' WHERE id=$1`, order.ID)
+	require.NoError(t, err)
+	p := &domain.Payment{OrderID: order.ID, Status: domain.PaymentPending, Amount: order.Total}
+	require.NoError(t, payments.Create(ctx, p))
+	require.NoError(t, payments.UpdateStatus(ctx, p.ID, domain.PaymentPending, domain.PaymentCaptured, fmt.Sprintf("pay_test_%d", order.ID), ""))
+
+	ret := newReturn(2)
+	require.NoError(t, returns.Create(ctx, ret))
+	assert.Equal(t, domain.NewMoney(50000), ret.RefundAmount)
+	assert.Equal(t, book.ID, ret.Items[0].BookID)
+	// Из трёх книг две уже заявлены
+	require.ErrorContains(t, returns.Create(ctx, newReturn(2)), "return quantity exceeds remaining")
+
+	_, err = returns.Approve(ctx, ret.ID, "admin-1", "")
+	require.NoError(t, err)
+	got, err := books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, got.Inventory)
+	_, err = returns.Approve(ctx, ret.ID, "admin-1", "")
+	require.ErrorContains(t, err, "return status changed concurrently")
+
+	refunded, err := returns.MarkRefunded(ctx, ret.ID, p.ID, "admin-1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ReturnRefunded, refunded.Status)
+	stored, err := payments.GetActiveByOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentCaptured, stored.Status)
+	assert.Equal(t, domain.NewMoney(50000), stored.Refunded)
+
+	var events []string
+	rows, err := db.Query(ctx, `SELECT event_type FROM outbox WHERE event_type LIKE 'return_%' AND (payload->>'return_id')::int=$1 ORDER BY id`, ret.ID)
+	require.NoError(t, err)
+	for rows.Next() {
+		var e string
+		require.NoError(t, rows.Scan(&e))
+		events = append(events, e)
+	}
+	rows.Close()
+	assert.Equal(t, []string{domain.EventReturnRequested, domain.EventReturnApproved, domain.EventReturnRefunded}, events)
+}
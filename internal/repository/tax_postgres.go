@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourorg/bookshop/internal/domain"
+)
+
+type TaxPostgres struct {
+	db *pgxpool.Pool
+}
+
+func NewTaxPostgres(db *pgxpool.Pool) *TaxPostgres {
+	return &TaxPostgres{db: db}
+}
+
+const taxRateColumns = `id, country, region, category_id, rate, effective_from, actor, created_at`
+
+func scanTaxRate(row pgx.Row) (*domain.TaxRate, error) {
+	var r domain.TaxRate
+	if err := row.Scan(&r.ID, &r.Country, &r.Region, &r.CategoryID, &r.Rate, &r.EffectiveFrom, &r.Actor, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Create добавляет новую версию ставки.
+func (r *TaxPostgres) Create(ctx context.Context, rate *domain.TaxRate) error {
+	err := r.db.QueryRow(ctx, `INSERT INTO tax_rates (country, region, category_id, rate, effective_from, actor) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		rate.Country, rate.Region, rate.CategoryID, rate.Rate, rate.EffectiveFrom, rate.Actor).
+		Scan(&rate.ID, &rate.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return fmt.Errorf("category not found: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("insert tax rate: %w", err)
+	}
+	return nil
+}
+
+// List возвращает все версии ставок от новых к старым.
+func (r *TaxPostgres) List(ctx context.Context, page domain.PageRequest) ([]*domain.TaxRate, error) {
+	before := 0
+	if page.After != nil {
+		before = page.After.ID
+	}
+	rows, err := r.db.Query(ctx, `SELECT `+taxRateColumns+` FROM tax_rates WHERE $1 = 0 OR id < $1 ORDER BY id DESC LIMIT $2`, before, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list tax rates: %w", err)
+	}
+	defer rows.Close()
+	var rates []*domain.TaxRate
+	for rows.Next() {
+		rate, err := scanTaxRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan tax rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// Effective возвращает ставки страны, действующие в момент at: для каждой
+// пары регион/категория — версию с самым поздним effective_from не позже at.
+func (r *TaxPostgres) Effective(ctx context.Context, country string, at time.Time) ([]*domain.TaxRate, error) {
+	rows, err := r.db.Query(ctx, `SELECT DISTINCT ON (region, category_id) `+taxRateColumns+` FROM tax_rates
+		WHERE country=$1 AND effective_from <= $2
+		ORDER BY region, category_id, effective_from DESC, id DESC`, country, at)
+	if err != nil {
+		return nil, fmt.Errorf("effective tax rates: %w", err)
+	}
+	defer rows.Close()
+	var rates []*domain.TaxRate
+	for rows.Next() {
+		rate, err := scanTaxRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan tax rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
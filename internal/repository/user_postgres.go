@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yourorg/bookshop/internal/domain"
 )
@@ -17,18 +19,18 @@ func NewUserPostgres(db *pgxpool.Pool) *UserPostgres {
 }
 
 func (r *UserPostgres) GetByID(ctx context.Context, id string) (*domain.User, error) {
-	row := r.db.QueryRow(ctx, `SELECT id, email, is_admin FROM users WHERE id=$1`, id)
+	row := r.db.QueryRow(ctx, `SELECT id, email, is_admin, created_at, updated_at FROM users WHERE id=$1`, id)
 	var u domain.User
-	if err := row.Scan(&u.ID, &u.Email, &u.IsAdmin); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.IsAdmin, &u.CreatedAt, &u.UpdatedAt); err != nil {
 		return nil, fmt.Errorf("get by id: %w", err)
 	}
 	return &u, nil
 }
 
 func (r *UserPostgres) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	row := r.db.QueryRow(ctx, `SELECT id, email, is_admin FROM users WHERE email=$1`, email)
+	row := r.db.QueryRow(ctx, `SELECT id, email, is_admin, created_at, updated_at FROM users WHERE email=$1`, email)
 	var u domain.User
-	if err := row.Scan(&u.ID, &u.Email, &u.IsAdmin); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.IsAdmin, &u.CreatedAt, &u.UpdatedAt); err != nil {
 		return nil, fmt.Errorf("get by email: %w", err)
 	}
 	return &u, nil
@@ -41,3 +43,20 @@ func (r *UserPostgres) CreateIfNotExists(ctx context.Context, user *domain.User)
 	}
 	return nil
 }
+
+// Upsert создаёт пользователя или обновляет email и флаг администратора,
+// если они изменились в Keycloak.
+func (r *UserPostgres) Upsert(ctx context.Context, user *domain.User) error {
+	row := r.db.QueryRow(ctx, `INSERT INTO users (id, email, is_admin) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET email=EXCLUDED.email, is_admin=EXCLUDED.is_admin, updated_at=NOW()
+		WHERE users.email IS DISTINCT FROM EXCLUDED.email OR users.is_admin IS DISTINCT FROM EXCLUDED.is_admin
+		RETURNING created_at, updated_at`, user.ID, user.Email, user.IsAdmin)
+	if err := row.Scan(&user.CreatedAt, &user.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Ничего не изменилось
+			return nil
+		}
+		return fmt.Errorf("upsert user: %w", err)
+	}
+	return nil
+}
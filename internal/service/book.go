@@ -5,37 +5,81 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/yourorg/bookshop/internal/domain"
 	"github.com/yourorg/bookshop/internal/integration"
 	"github.com/yourorg/bookshop/internal/repository"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	defaultPageLimit = 20
+	// bookListCachedLimit — размер страницы витрины по умолчанию, только он кэшируется.
+	bookListCachedLimit = 100
 )
 
 type BookServiceImpl struct {
 	bookRepo     repository.BookRepository
 	categoryRepo repository.CategoryRepository
 	redis        integration.RedisCache
+	Logger       *slog.Logger
+	// pricesChanged будит RunPriceScheduler, когда расписание цен изменилось.
+	pricesChanged chan struct{}
 }
 
-func NewBookService(bookRepo repository.BookRepository, categoryRepo repository.CategoryRepository, redis integration.RedisCache) *BookServiceImpl {
+func NewBookService(bookRepo repository.BookRepository, categoryRepo repository.CategoryRepository, redis integration.RedisCache, logger *slog.Logger) *BookServiceImpl {
 	return &BookServiceImpl{
-		bookRepo:     bookRepo,
-		categoryRepo: categoryRepo,
-		redis:        redis,
+		bookRepo:      bookRepo,
+		categoryRepo:  categoryRepo,
+		redis:         redis,
+		Logger:        logger,
+		pricesChanged: make(chan struct{}, 1),
 	}
 }
 
+// GetByID возвращает книгу с ценой, действующей сейчас.
 func (s *BookServiceImpl) GetByID(ctx context.Context, id int) (*domain.Book, error) {
 	return s.bookRepo.GetByID(ctx, id)
 }
 
-func (s *BookServiceImpl) List(ctx context.Context, categoryIDs []int, limit, offset int) ([]*domain.Book, error) {
-	if limit != 100 || offset != 0 {
-		return s.bookRepo.List(ctx, categoryIDs, limit, offset)
+func (s *BookServiceImpl) List(ctx context.Context, filter domain.BookFilter) (*domain.BookList, error) {
+	if !domain.ValidBookSort(filter.Sort) {
+		return nil, fmt.Errorf("invalid sort: %w", errors.New("invalid sort"))
+	}
+	if filter.After != nil && filter.After.Sort != filter.Sort {
+		return nil, fmt.Errorf("invalid cursor: %w", errors.New("cursor was issued for another sort"))
+	}
+	// Запрашиваем на одну книгу больше, чтобы понять, есть ли следующая страница
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	filter.Limit = limit + 1
+	books, err := s.listBooks(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
-	key := "books:all"
-	if len(categoryIDs) == 1 {
-		key = "books:cat:" + fmt.Sprint(categoryIDs[0])
+	list := &domain.BookList{Items: books}
+	if len(books) > limit {
+		list.Items = books[:limit]
+		list.Next = domain.BookCursor(books[limit-1], filter.Sort)
+	}
+	if filter.WithTotal {
+		total, err := s.bookRepo.Count(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("count books: %w", err)
+		}
+		list.Total = &total
+	}
+	return list, nil
+}
+
+func (s *BookServiceImpl) listBooks(ctx context.Context, filter domain.BookFilter) ([]*domain.Book, error) {
+	key, ok := bookListCacheKey(filter)
+	if !ok {
+		return s.bookRepo.List(ctx, filter)
 	}
 	if cached, err := s.redis.Get(key); err == nil && cached != "" {
 		var books []*domain.Book
@@ -43,7 +87,7 @@ func (s *BookServiceImpl) List(ctx context.Context, categoryIDs []int, limit, of
 			return books, nil
 		}
 	}
-	books, err := s.bookRepo.List(ctx, categoryIDs, limit, offset)
+	books, err := s.bookRepo.List(ctx, filter)
 	if err == nil {
 		if data, err := json.Marshal(books); err == nil {
 			s.redis.Set(key, string(data), 300) // 5 минут
@@ -52,6 +96,44 @@ func (s *BookServiceImpl) List(ctx context.Context, categoryIDs []int, limit, of
 	return books, err
 }
 
+// bookListCacheKey: кэшируется только первая страница витрины без фильтров,
+// кроме одной категории, — именно эти ключи сбрасываются при изменении книг.
+func bookListCacheKey(f domain.BookFilter) (string, bool) {
+	if f.Limit != bookListCachedLimit+1 || f.After != nil || f.Sort != "" || f.IncludeSoldOut || f.Author != "" ||
+		f.MinPrice != nil || f.MaxPrice != nil || f.MinYear != nil || f.MaxYear != nil || len(f.CategoryIDs) > 1 {
+		return "", false
+	}
+	if len(f.CategoryIDs) == 1 {
+		return "books:cat:" + fmt.Sprint(f.CategoryIDs[0]), true
+	}
+	return "books:all", true
+}
+
+func (s *BookServiceImpl) Facets(ctx context.Context, filter domain.BookFilter) (*domain.BookFacets, error) {
+	facets, err := s.bookRepo.Facets(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("book facets: %w", err)
+	}
+	return facets, nil
+}
+
+func (s *BookServiceImpl) Search(ctx context.Context, query string, limit, offset int) ([]*domain.Book, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query required: %w", errors.New("query required"))
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	books, err := s.bookRepo.Search(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("search books: %w", err)
+	}
+	return books, nil
+}
+
 func (s *BookServiceImpl) Create(ctx context.Context, book *domain.Book) error {
 	if book.Inventory < 0 {
 		return fmt.Errorf("inventory must be >= 0: %w", errors.New("inventory must be >= 0"))
@@ -72,6 +154,7 @@ func (s *BookServiceImpl) Update(ctx context.Context, book *domain.Book) error {
 	if err != nil {
 		return fmt.Errorf("book not found: %w", err)
 	}
+	// Остаток меняется только через журнал движений (AdjustInventory)
 	book.Inventory = old.Inventory
 	if err := s.bookRepo.Update(ctx, book); err != nil {
 		return fmt.Errorf("update book: %w", err)
@@ -92,3 +175,202 @@ func (s *BookServiceImpl) Delete(ctx context.Context, id int) error {
 	}
 	return nil
 }
+
+// AdjustInventory проводит ручное движение по складу. Для restock и damage
+// quantity — положительное количество, для correction — изменение остатка
+// с любым знаком.
+func (s *BookServiceImpl) AdjustInventory(ctx context.Context, bookID int, kind string, quantity int, reason, actor string) (*domain.InventoryMovement, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("reason required: %w", errors.New("reason required"))
+	}
+	delta := quantity
+	switch kind {
+	case domain.MovementRestock, domain.MovementDamage:
+		if quantity <= 0 {
+			return nil, fmt.Errorf("quantity must be positive: %w", errors.New("quantity must be positive"))
+		}
+		if kind == domain.MovementDamage {
+			delta = -quantity
+		}
+	case domain.MovementCorrection:
+		if quantity == 0 {
+			return nil, fmt.Errorf("quantity must be non-zero: %w", errors.New("quantity must be non-zero"))
+		}
+	default:
+		return nil, fmt.Errorf("invalid adjustment type: %w", fmt.Errorf("unknown type %q", kind))
+	}
+	book, err := s.bookRepo.GetByID(ctx, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("book not found: %w", err)
+	}
+	m := &domain.InventoryMovement{BookID: bookID, Delta: delta, Kind: kind, Reason: reason, Actor: actor}
+	if err := s.bookRepo.AdjustInventory(ctx, m); err != nil {
+		return nil, fmt.Errorf("adjust inventory: %w", err)
+	}
+	// Витрина скрывает книги без остатка — сбрасываем кэш
+	s.redis.Del("books:all")
+	s.redis.Del("books:cat:" + fmt.Sprint(book.CategoryID))
+	return m, nil
+}
+
+func (s *BookServiceImpl) InventoryHistory(ctx context.Context, bookID int, page domain.PageRequest) (*domain.InventoryMovementList, error) {
+	if _, err := s.bookRepo.GetByID(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("book not found: %w", err)
+	}
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	page.Limit = limit + 1
+	movements, err := s.bookRepo.ListMovements(ctx, bookID, page)
+	if err != nil {
+		return nil, fmt.Errorf("inventory history: %w", err)
+	}
+	list := &domain.InventoryMovementList{Items: movements}
+	if len(movements) > limit {
+		list.Items = movements[:limit]
+		list.Next = &domain.Cursor{ID: movements[limit-1].ID}
+	}
+	if page.WithTotal {
+		total, err := s.bookRepo.CountMovements(ctx, bookID)
+		if err != nil {
+			return nil, fmt.Errorf("count movements: %w", err)
+		}
+		list.Total = &total
+	}
+	return list, nil
+}
+
+// SchedulePrice планирует цену книги. Цена без effective_from вступает в
+// силу сразу; остальные применяет RunPriceScheduler.
+func (s *BookServiceImpl) SchedulePrice(ctx context.Context, bookID int, req domain.SchedulePriceRequest, actor string) (*domain.BookPrice, error) {
+	if _, err := s.bookRepo.GetByID(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("book not found: %w", err)
+	}
+	now := time.Now().UTC()
+	p := &domain.BookPrice{
+		BookID:        bookID,
+		Price:         req.Price,
+		EffectiveFrom: now,
+		EffectiveTo:   req.EffectiveTo,
+		Reason:        strings.TrimSpace(req.Reason),
+		Actor:         actor,
+	}
+	if req.EffectiveFrom != nil {
+		p.EffectiveFrom = *req.EffectiveFrom
+	}
+	p.Normalize()
+	if err := p.Validate(now); err != nil {
+		return nil, err
+	}
+	if err := s.bookRepo.SchedulePrice(ctx, p); err != nil {
+		return nil, fmt.Errorf("schedule price: %w", err)
+	}
+	if !p.EffectiveFrom.After(now) {
+		if _, err := s.ApplyDuePrices(ctx, now); err != nil {
+			// Цена уже в расписании, её применит планировщик
+			s.Logger.Error("failed to apply price", "book_id", bookID, "err", err)
+		}
+	}
+	s.wakePriceScheduler()
+	return p, nil
+}
+
+// CancelPrice снимает с расписания цену, которая ещё не вступила в силу.
+func (s *BookServiceImpl) CancelPrice(ctx context.Context, bookID, priceID int) error {
+	if err := s.bookRepo.CancelPrice(ctx, bookID, priceID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("cancel price: %w", err)
+	}
+	s.wakePriceScheduler()
+	return nil
+}
+
+func (s *BookServiceImpl) PriceHistory(ctx context.Context, bookID int, page domain.PageRequest) (*domain.BookPriceList, error) {
+	if _, err := s.bookRepo.GetByID(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("book not found: %w", err)
+	}
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	page.Limit = limit + 1
+	prices, err := s.bookRepo.ListPrices(ctx, bookID, page)
+	if err != nil {
+		return nil, fmt.Errorf("price history: %w", err)
+	}
+	list := &domain.BookPriceList{Items: prices}
+	if len(prices) > limit {
+		last := prices[limit-1]
+		list.Items = prices[:limit]
+		list.Next = &domain.Cursor{Value: last.EffectiveFrom.Format(time.RFC3339Nano), ID: last.ID}
+	}
+	if page.WithTotal {
+		total, err := s.bookRepo.CountPrices(ctx, bookID)
+		if err != nil {
+			return nil, fmt.Errorf("count book prices: %w", err)
+		}
+		list.Total = &total
+	}
+	return list, nil
+}
+
+// ApplyDuePrices переносит в книги цены, действующие в момент now, и
+// сбрасывает кэш витрины для изменившихся категорий.
+func (s *BookServiceImpl) ApplyDuePrices(ctx context.Context, now time.Time) (int, error) {
+	books, err := s.bookRepo.ApplyDuePrices(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	if len(books) == 0 {
+		return 0, nil
+	}
+	s.redis.Del("books:all")
+	seen := make(map[int]bool)
+	for _, b := range books {
+		if !seen[b.CategoryID] {
+			seen[b.CategoryID] = true
+			s.redis.Del("books:cat:" + fmt.Sprint(b.CategoryID))
+		}
+	}
+	return len(books), nil
+}
+
+// RunPriceScheduler применяет цены в момент смены, пока не отменён ctx:
+// спит до ближайшего начала или конца цены, но не дольше maxWait — так
+// подхватываются цены, запланированные другими инстансами.
+func (s *BookServiceImpl) RunPriceScheduler(ctx context.Context, maxWait time.Duration) {
+	for {
+		n, err := s.ApplyDuePrices(ctx, time.Now().UTC())
+		switch {
+		case err != nil && ctx.Err() == nil:
+			s.Logger.Error("failed to apply scheduled prices", "err", err)
+		case n > 0:
+			s.Logger.Info("applied scheduled prices", "count", n)
+		}
+		wait := maxWait
+		next, err := s.bookRepo.NextPriceChange(ctx, time.Now().UTC())
+		if err != nil && ctx.Err() == nil {
+			s.Logger.Error("failed to get next price change", "err", err)
+		}
+		if next != nil {
+			wait = min(wait, max(time.Until(*next), 0))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.pricesChanged:
+			timer.Stop()
+		}
+	}
+}
+
+func (s *BookServiceImpl) wakePriceScheduler() {
+	select {
+	case s.pricesChanged <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/mocks"
+	"golang.org/x/exp/slog"
+)
+
+func TestBookService_Search_EmptyQuery(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	_, err := svc.Search(context.Background(), "   ", 20, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "query required")
+	bookRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBookService_Search_ClampsLimit(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	books := []*domain.Book{{ID: 1, Title: "Война и мир"}}
+	bookRepo.On("Search", mock.Anything, "войн", 20, 0).Return(books, nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	res, err := svc.Search(context.Background(), "войн", 1000, -5)
+	require.NoError(t, err)
+	assert.Equal(t, books, res)
+	bookRepo.AssertExpectations(t)
+}
+
+func TestBookService_List_CachesDefaultPage(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	redis := new(mocks.RedisCache)
+	filter := domain.BookFilter{CategoryIDs: []int{3}, Limit: 100}
+	books := []*domain.Book{{ID: 1}}
+	redis.On("Get", "books:cat:3").Return("", nil)
+	bookRepo.On("List", mock.Anything, domain.BookFilter{CategoryIDs: []int{3}, Limit: 101}).Return(books, nil)
+	redis.On("Set", "books:cat:3", mock.Anything, 300).Return(nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), redis, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	res, err := svc.List(context.Background(), filter)
+	require.NoError(t, err)
+	assert.Equal(t, books, res.Items)
+	assert.Nil(t, res.Next)
+	redis.AssertExpectations(t)
+}
+
+func TestBookService_List_FilteredBypassesCache(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	redis := new(mocks.RedisCache)
+	minPrice := domain.NewMoney(50000)
+	filter := domain.BookFilter{Limit: 100, MinPrice: &minPrice, Sort: "-price"}
+	bookRepo.On("List", mock.Anything, domain.BookFilter{Limit: 101, MinPrice: &minPrice, Sort: "-price"}).Return([]*domain.Book{}, nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), redis, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	_, err := svc.List(context.Background(), filter)
+	require.NoError(t, err)
+	redis.AssertNotCalled(t, "Get", mock.Anything)
+	redis.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBookService_List_InvalidSort(t *testing.T) {
+	svc := NewBookService(new(mocks.BookRepository), new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	_, err := svc.List(context.Background(), domain.BookFilter{Limit: 100, Sort: "inventory"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid sort")
+}
+
+func TestBookService_List_NextCursor(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	after := &domain.Cursor{Sort: "-price", Value: "900", ID: 5}
+	filter := domain.BookFilter{Limit: 2, Sort: "-price", After: after, WithTotal: true}
+	books := []*domain.Book{{ID: 4, Price: domain.NewMoney(80000)}, {ID: 9, Price: domain.NewMoney(75050)}, {ID: 2, Price: domain.NewMoney(70000)}}
+	bookRepo.On("List", mock.Anything, domain.BookFilter{Limit: 3, Sort: "-price", After: after, WithTotal: true}).Return(books, nil)
+	bookRepo.On("Count", mock.Anything, mock.Anything).Return(12, nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	res, err := svc.List(context.Background(), filter)
+	require.NoError(t, err)
+	assert.Equal(t, books[:2], res.Items)
+	assert.Equal(t, &domain.Cursor{Sort: "-price", Value: "750.5", ID: 9}, res.Next)
+	require.NotNil(t, res.Total)
+	assert.Equal(t, 12, *res.Total)
+}
+
+func TestBookService_List_CursorForAnotherSort(t *testing.T) {
+	svc := NewBookService(new(mocks.BookRepository), new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	_, err := svc.List(context.Background(), domain.BookFilter{Limit: 10, Sort: "year", After: &domain.Cursor{Sort: "price", ID: 1}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid cursor")
+}
+
+func TestBookService_AdjustInventory_Damage(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	redis := new(mocks.RedisCache)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, CategoryID: 2, Inventory: 5}, nil)
+	bookRepo.On("AdjustInventory", mock.Anything, mock.MatchedBy(func(m *domain.InventoryMovement) bool {
+		return m.BookID == 1 && m.Delta == -2 && m.Kind == domain.MovementDamage && m.Reason == "залило водой" && m.Actor == "admin-1"
+	})).Return(nil)
+	redis.On("Del", "books:all").Return(nil)
+	redis.On("Del", "books:cat:2").Return(nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), redis, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m, err := svc.AdjustInventory(context.Background(), 1, domain.MovementDamage, 2, " залило водой ", "admin-1")
+	require.NoError(t, err)
+	assert.Equal(t, -2, m.Delta)
+	bookRepo.AssertExpectations(t)
+	redis.AssertExpectations(t)
+}
+
+func TestBookService_AdjustInventory_Validation(t *testing.T) {
+	cases := []struct {
+		name     string
+		kind     string
+		quantity int
+		reason   string
+		wantErr  string
+	}{
+		{"no reason", domain.MovementRestock, 1, "  ", "reason required"},
+		{"negative restock", domain.MovementRestock, -1, "поставка", "quantity must be positive"},
+		{"zero damage", domain.MovementDamage, 0, "брак", "quantity must be positive"},
+		{"zero correction", domain.MovementCorrection, 0, "инвентаризация", "quantity must be non-zero"},
+		{"order kind", domain.MovementOrder, 1, "вручную", "invalid adjustment type"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bookRepo := new(mocks.BookRepository)
+			svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+			_, err := svc.AdjustInventory(context.Background(), 1, tc.kind, tc.quantity, tc.reason, "admin-1")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+			bookRepo.AssertNotCalled(t, "AdjustInventory", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestBookService_AdjustInventory_NotEnoughStock(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, Inventory: 1}, nil)
+	bookRepo.On("AdjustInventory", mock.Anything, mock.Anything).Return(errors.New("not enough books in stock"))
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	_, err := svc.AdjustInventory(context.Background(), 1, domain.MovementCorrection, -3, "пересчёт", "admin-1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not enough books in stock")
+}
+
+func TestBookService_InventoryHistory_NextCursor(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1}, nil)
+	movements := []*domain.InventoryMovement{{ID: 9}, {ID: 7}, {ID: 4}}
+	bookRepo.On("ListMovements", mock.Anything, 1, domain.PageRequest{Limit: 3}).Return(movements, nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	list, err := svc.InventoryHistory(context.Background(), 1, domain.PageRequest{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 2)
+	assert.Equal(t, &domain.Cursor{ID: 7}, list.Next)
+}
+
+func TestBookService_SchedulePrice_ImmediateAppliesAndInvalidatesCache(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	redis := new(mocks.RedisCache)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, CategoryID: 3}, nil)
+	bookRepo.On("SchedulePrice", mock.Anything, mock.MatchedBy(func(p *domain.BookPrice) bool {
+		return p.BookID == 1 && p.Price == domain.NewMoney(49900) && p.EffectiveTo == nil && p.Reason == "переоценка" && p.Actor == "admin-1"
+	})).Return(nil)
+	// Две книги одной категории: ключ категории сбрасывается один раз
+	bookRepo.On("ApplyDuePrices", mock.Anything, mock.Anything).Return([]*domain.Book{{ID: 1, CategoryID: 3}, {ID: 2, CategoryID: 3}}, nil)
+	redis.On("Del", "books:all").Return(nil).Once()
+	redis.On("Del", "books:cat:3").Return(nil).Once()
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), redis, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	p, err := svc.SchedulePrice(context.Background(), 1, domain.SchedulePriceRequest{Price: domain.NewMoney(49900), Reason: " переоценка "}, "admin-1")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, p.EffectiveFrom.Location())
+	bookRepo.AssertExpectations(t)
+	redis.AssertExpectations(t)
+}
+
+func TestBookService_SchedulePrice_FutureWaitsForScheduler(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1}, nil)
+	bookRepo.On("SchedulePrice", mock.Anything, mock.Anything).Return(nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	msk := time.FixedZone("MSK", 3*60*60)
+	from := time.Now().In(msk).Add(24 * time.Hour).Truncate(time.Second)
+	to := from.Add(72 * time.Hour)
+	p, err := svc.SchedulePrice(context.Background(), 1, domain.SchedulePriceRequest{Price: domain.NewMoney(29900), EffectiveFrom: &from, EffectiveTo: &to}, "admin-1")
+	require.NoError(t, err)
+	// Время хранится в UTC, момент не сдвигается
+	assert.Equal(t, time.UTC, p.EffectiveFrom.Location())
+	assert.True(t, p.EffectiveFrom.Equal(from))
+	assert.True(t, p.EffectiveTo.Equal(to))
+	bookRepo.AssertNotCalled(t, "ApplyDuePrices", mock.Anything, mock.Anything)
+}
+
+func TestBookService_SchedulePrice_Validation(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1}, nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	past := time.Now().Add(-time.Hour)
+	_, err := svc.SchedulePrice(context.Background(), 1, domain.SchedulePriceRequest{Price: domain.NewMoney(-1), EffectiveFrom: &past, EffectiveTo: &past}, "admin-1")
+	var invalid *domain.ValidationError
+	require.True(t, errors.As(err, &invalid), "%v", err)
+	var fields []string
+	for _, f := range invalid.Fields {
+		fields = append(fields, f.Field)
+	}
+	assert.Equal(t, []string{"price", "effective_from", "effective_to"}, fields)
+	bookRepo.AssertNotCalled(t, "SchedulePrice", mock.Anything, mock.Anything)
+}
+
+func TestBookService_RunPriceScheduler_AppliesAtNextChange(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	redis := new(mocks.RedisCache)
+	next := time.Now().Add(50 * time.Millisecond)
+	bookRepo.On("ApplyDuePrices", mock.Anything, mock.Anything).Return(nil, nil).Once()
+	bookRepo.On("NextPriceChange", mock.Anything, mock.Anything).Return(&next, nil).Once()
+	bookRepo.On("ApplyDuePrices", mock.Anything, mock.Anything).Return([]*domain.Book{{ID: 1, CategoryID: 3}}, nil).Once()
+	bookRepo.On("ApplyDuePrices", mock.Anything, mock.Anything).Return(nil, nil)
+	bookRepo.On("NextPriceChange", mock.Anything, mock.Anything).Return(nil, nil)
+	applied := make(chan struct{})
+	redis.On("Del", "books:all").Return(nil)
+	redis.On("Del", "books:cat:3").Return(nil).Run(func(mock.Arguments) { close(applied) })
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), redis, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Опрос раз в час: кэш сбрасывается в момент смены цены, а не по опросу
+		svc.RunPriceScheduler(ctx, time.Hour)
+	}()
+	select {
+	case <-applied:
+	case <-time.After(2 * time.Second):
+		t.Fatal("scheduled price was not applied")
+	}
+	assert.False(t, time.Now().Before(next))
+	cancel()
+	<-done
+}
+
+func TestBookService_PriceHistory_NextCursor(t *testing.T) {
+	bookRepo := new(mocks.BookRepository)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1}, nil)
+	from := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	prices := []*domain.BookPrice{{ID: 9, EffectiveFrom: from.Add(48 * time.Hour)}, {ID: 4, EffectiveFrom: from}, {ID: 2}}
+	bookRepo.On("ListPrices", mock.Anything, 1, domain.PageRequest{Limit: 3}).Return(prices, nil)
+	svc := NewBookService(bookRepo, new(mocks.CategoryRepository), new(mocks.RedisCache), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	list, err := svc.PriceHistory(context.Background(), 1, domain.PageRequest{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 2)
+	assert.Equal(t, &domain.Cursor{Value: "2025-06-01T09:00:00Z", ID: 4}, list.Next)
+}
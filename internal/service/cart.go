@@ -4,33 +4,79 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 
 	"github.com/yourorg/bookshop/internal/domain"
-	"github.com/yourorg/bookshop/internal/integration"
 	"github.com/yourorg/bookshop/internal/repository"
 	"golang.org/x/exp/slog"
 )
 
 type CartServiceImpl struct {
-	cartRepo repository.CartRepository
-	bookRepo repository.BookRepository
-	redis    integration.RedisCache
-	Logger   *slog.Logger
+	cartRepo     repository.CartRepository
+	bookRepo     repository.BookRepository
+	reservations repository.ReservationRepository
+	promos       repository.PromoRepository
+	taxes        *TaxServiceImpl
+	Logger       *slog.Logger
 }
 
-func NewCartService(cartRepo repository.CartRepository, bookRepo repository.BookRepository, redis integration.RedisCache, logger *slog.Logger) *CartServiceImpl {
+func NewCartService(cartRepo repository.CartRepository, bookRepo repository.BookRepository, reservations repository.ReservationRepository, promos repository.PromoRepository, taxes *TaxServiceImpl, logger *slog.Logger) *CartServiceImpl {
 	return &CartServiceImpl{
-		cartRepo: cartRepo,
-		bookRepo: bookRepo,
-		redis:    redis,
-		Logger:   logger,
+		cartRepo:     cartRepo,
+		bookRepo:     bookRepo,
+		reservations: reservations,
+		promos:       promos,
+		taxes:        taxes,
+		Logger:       logger,
 	}
 }
 
+// GetByUserID возвращает корзину с НДС для региона по умолчанию.
 func (s *CartServiceImpl) GetByUserID(ctx context.Context, userID string) (*domain.Cart, error) {
+	return s.GetForRegion(ctx, userID, s.taxes.defaultRegion)
+}
+
+// GetForRegion возвращает корзину по текущим ценам со скидкой по
+// промокоду и НДС для региона доставки region.
+func (s *CartServiceImpl) GetForRegion(ctx context.Context, userID string, region domain.TaxRegion) (*domain.Cart, error) {
+	cart, err := s.priceCart(ctx, userID)
+	if err != nil || len(cart.Items) == 0 {
+		return cart, err
+	}
+	lines := make([]domain.TaxLine, len(cart.Items))
+	for i, item := range cart.Items {
+		lines[i] = cartTaxLine(item)
+	}
+	taxes, total, err := s.taxes.LineTaxes(ctx, region, lines)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cart.Items {
+		cart.Items[i].TaxRate = taxes[i].Rate
+		cart.Items[i].Tax = taxes[i].Tax
+	}
+	cart.Tax = total
+	cart.TaxRegion = &region
+	return cart, nil
+}
+
+func cartTaxLine(item domain.CartItem) domain.TaxLine {
+	line := domain.TaxLine{Amount: item.LineTotal.Sub(item.Discount)}
+	if item.Book != nil {
+		line.CategoryID = item.Book.CategoryID
+	}
+	return line
+}
+
+// priceCart считает позиции корзины по текущим ценам и скидку по промокоду.
+func (s *CartServiceImpl) priceCart(ctx context.Context, userID string) (*domain.Cart, error) {
 	cart, err := s.cartRepo.GetByUserID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Корзина создаётся при первом добавлении — до этого она пустая
+		return &domain.Cart{UserID: userID}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -43,16 +89,139 @@ func (s *CartServiceImpl) GetByUserID(ctx context.Context, userID string) (*doma
 		if err == nil {
 			item.Book = book
 		}
+		priceItem(item)
 	}
 	cart.Items = nil
 	for _, item := range items {
 		if item != nil {
 			cart.Items = append(cart.Items, *item)
+			cart.Subtotal = cart.Subtotal.Add(item.LineTotal)
+			cart.Stale = cart.Stale || item.PriceChanged || item.Unavailable
 		}
 	}
+	cart.Total = cart.Subtotal
+	if cart.PromoCode == "" || len(cart.Items) == 0 {
+		return cart, nil
+	}
+	lines := make([]domain.PromoLine, len(cart.Items))
+	for i, item := range cart.Items {
+		lines[i] = cartPromoLine(item)
+	}
+	_, discounts, err := s.promoDiscount(ctx, userID, cart.PromoCode, lines)
+	var promoErr *domain.PromoError
+	if errors.As(err, &promoErr) {
+		// Промокод остаётся в корзине: покупатель видит, почему скидки нет
+		cart.PromoReason = promoErr.Reason
+		return cart, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range cart.Items {
+		cart.Items[i].Discount = discounts[i]
+		cart.Discount = cart.Discount.Add(discounts[i])
+	}
+	cart.Total = cart.Subtotal.Sub(cart.Discount)
 	return cart, nil
 }
 
+func cartPromoLine(item domain.CartItem) domain.PromoLine {
+	line := domain.PromoLine{BookID: item.BookID, Total: item.LineTotal}
+	if item.Book != nil {
+		line.CategoryID = item.Book.CategoryID
+	}
+	return line
+}
+
+// ApplyPromo проверяет промокод на текущей корзине и сохраняет его.
+// Неизвестный код — "promo code not found", неподходящий —
+// *domain.PromoError.
+func (s *CartServiceImpl) ApplyPromo(ctx context.Context, userID, code string) (*domain.Cart, error) {
+	code = domain.NormalizePromoCode(code)
+	if code == "" {
+		return nil, fmt.Errorf("invalid promo code: %w", errors.New("code is required"))
+	}
+	cart, err := s.priceCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, fmt.Errorf("cart is empty: %w", errors.New("cart is empty"))
+	}
+	lines := make([]domain.PromoLine, len(cart.Items))
+	for i, item := range cart.Items {
+		lines[i] = cartPromoLine(item)
+	}
+	if _, _, err := s.promoDiscount(ctx, userID, code, lines); err != nil {
+		return nil, err
+	}
+	if err := s.cartRepo.SetPromoCode(ctx, userID, code); err != nil {
+		return nil, fmt.Errorf("apply promo code: %w", err)
+	}
+	return s.GetByUserID(ctx, userID)
+}
+
+func (s *CartServiceImpl) RemovePromo(ctx context.Context, userID string) error {
+	err := s.cartRepo.SetPromoCode(ctx, userID, "")
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("remove promo code: %w", err)
+	}
+	return nil
+}
+
+// CheckoutDiscount считает скидку по промокоду корзины для позиций
+// оформляемого заказа. Без промокода возвращает nil.
+func (s *CartServiceImpl) CheckoutDiscount(ctx context.Context, userID string, lines []domain.PromoLine) (*domain.PromoCode, []domain.Money, error) {
+	cart, err := s.cartRepo.GetByUserID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("get cart: %w", err)
+	}
+	if cart.PromoCode == "" {
+		return nil, nil, nil
+	}
+	return s.promoDiscount(ctx, userID, cart.PromoCode, lines)
+}
+
+// promoDiscount — скидка по коду для позиций lines с учётом личного лимита
+// покупателя. Счётчики здесь только читаются: гасит промокод транзакция
+// заказа.
+func (s *CartServiceImpl) promoDiscount(ctx context.Context, userID, code string, lines []domain.PromoLine) (*domain.PromoCode, []domain.Money, error) {
+	promo, err := s.promos.GetByCode(ctx, code)
+	if err != nil {
+		return nil, nil, fmt.Errorf("promo code not found: %w", err)
+	}
+	discounts, err := promo.Discount(lines, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if promo.PerUserLimit > 0 {
+		n, err := s.promos.CountRedemptions(ctx, promo.ID, userID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("count redemptions: %w", err)
+		}
+		if n >= promo.PerUserLimit {
+			return nil, nil, &domain.PromoError{Code: promo.Code, Reason: domain.PromoUserLimit}
+		}
+	}
+	return promo, discounts, nil
+}
+
+// priceItem считает сумму позиции по текущей цене и помечает позиции,
+// у которых цена отличается от снимка или не хватает остатка.
+func priceItem(item *domain.CartItem) {
+	if item.Book == nil {
+		item.Unavailable = true
+		return
+	}
+	item.Price = item.Book.Price
+	item.LineTotal = item.Price.Mul(item.Quantity)
+	item.PriceChanged = item.Price != item.PriceSnapshot
+	item.Unavailable = item.Book.Inventory < item.Quantity
+}
+
 func (s *CartServiceImpl) AddItem(ctx context.Context, userID string, bookID int) error {
 	book, err := s.bookRepo.GetByID(ctx, bookID)
 	if err != nil {
@@ -69,27 +238,133 @@ func (s *CartServiceImpl) AddItem(ctx context.Context, userID string, bookID int
 			return fmt.Errorf("get item quantity: %w", err)
 		}
 	}
-	if quantity >= book.Inventory {
+	// Резерв в Redis атомарно проверяет остаток с учётом чужих корзин
+	ok, err := s.reservations.Reserve(ctx, bookID, userID, quantity+1, book.Inventory, domain.CartReservationTTL)
+	if err != nil {
+		return fmt.Errorf("reserve book: %w", err)
+	}
+	if !ok {
 		return fmt.Errorf("not enough books in stock: %w", errors.New("not enough books in stock"))
 	}
 	if err := s.cartRepo.AddItem(ctx, userID, bookID); err != nil {
+		// Возвращаем резерв к прежнему количеству
+		if _, rerr := s.reservations.Reserve(ctx, bookID, userID, quantity, book.Inventory, domain.CartReservationTTL); rerr != nil {
+			s.Logger.Error("failed to roll back reservation", "book_id", bookID, "err", rerr)
+		}
 		return fmt.Errorf("add to cart: %w", err)
 	}
-	// Резервируем книгу в Redis на 30 минут
-	reserveKey := fmt.Sprintf("reserve:%s:%d", userID, bookID)
-	s.redis.Set(reserveKey, "1", 1800)
 	return nil
 }
 
 func (s *CartServiceImpl) RemoveItem(ctx context.Context, userID string, bookID int) error {
+	quantity, err := s.cartRepo.GetItemQuantity(ctx, userID, bookID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("get item quantity: %w", err)
+	}
 	if err := s.cartRepo.RemoveItem(ctx, userID, bookID); err != nil {
 		return fmt.Errorf("remove item: %w", err)
 	}
-	reserveKey := fmt.Sprintf("reserve:%s:%d", userID, bookID)
-	s.redis.Del(reserveKey)
+	if quantity <= 1 {
+		s.reservations.Release(ctx, bookID, userID)
+		return nil
+	}
+	// Резерв уменьшается вместе с позицией; уменьшение не проверяет остаток
+	if _, err := s.reservations.Reserve(ctx, bookID, userID, quantity-1, 0, domain.CartReservationTTL); err != nil {
+		s.Logger.Error("failed to shrink reservation", "book_id", bookID, "err", err)
+	}
+	return nil
+}
+
+const maxCartBatch = 100
+
+// SetItemQuantity устанавливает абсолютное количество книги в корзине;
+// 0 удаляет позицию.
+func (s *CartServiceImpl) SetItemQuantity(ctx context.Context, userID string, bookID, quantity int) error {
+	return s.SetItems(ctx, userID, []domain.CartItemQuantity{{BookID: bookID, Quantity: quantity}})
+}
+
+// SetItems устанавливает количество нескольких книг за один вызов. Сначала
+// резервы в Redis (чужие корзины), затем одна транзакция в Postgres
+// (остаток на складе). Если не хватает хотя бы одной книги, корзина и
+// резервы не меняются, а ошибка *domain.OutOfStockError перечисляет все
+// такие позиции.
+func (s *CartServiceImpl) SetItems(ctx context.Context, userID string, items []domain.CartItemQuantity) error {
+	if len(items) == 0 {
+		return fmt.Errorf("batch is empty: %w", errors.New("batch is empty"))
+	}
+	if len(items) > maxCartBatch {
+		return fmt.Errorf("batch is too large: %w", errors.New("batch is too large"))
+	}
+	seen := map[int]bool{}
+	for _, it := range items {
+		if it.BookID <= 0 || it.Quantity < 0 {
+			return fmt.Errorf("invalid quantity: %w", errors.New("invalid quantity"))
+		}
+		if seen[it.BookID] {
+			return fmt.Errorf("duplicate book in batch: %w", errors.New("duplicate book in batch"))
+		}
+		seen[it.BookID] = true
+	}
+
+	stock := map[int]int{}
+	for _, it := range items {
+		if it.Quantity == 0 {
+			continue
+		}
+		book, err := s.bookRepo.GetByID(ctx, it.BookID)
+		if err != nil {
+			return fmt.Errorf("book not found: %w", err)
+		}
+		stock[it.BookID] = book.Inventory
+	}
+	current, err := s.cartRepo.ListItems(ctx, userID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("list items: %w", err)
+	}
+	previous := map[int]int{}
+	for _, it := range current {
+		previous[it.BookID] = it.Quantity
+	}
+
+	var reserved []domain.CartItemQuantity
+	var short []domain.OutOfStockItem
+	for _, it := range items {
+		ok, err := s.reservations.Reserve(ctx, it.BookID, userID, it.Quantity, stock[it.BookID], domain.CartReservationTTL)
+		if err != nil {
+			s.restoreReservations(ctx, userID, reserved, previous, stock)
+			return fmt.Errorf("reserve book: %w", err)
+		}
+		if ok {
+			reserved = append(reserved, it)
+			continue
+		}
+		others, err := s.reservations.ReservedByOthers(ctx, it.BookID, userID)
+		if err != nil {
+			s.restoreReservations(ctx, userID, reserved, previous, stock)
+			return fmt.Errorf("reserved by others: %w", err)
+		}
+		short = append(short, domain.OutOfStockItem{BookID: it.BookID, Requested: it.Quantity, Available: max(stock[it.BookID]-others, 0)})
+	}
+	if len(short) > 0 {
+		s.restoreReservations(ctx, userID, reserved, previous, stock)
+		return &domain.OutOfStockError{Items: short}
+	}
+	if err := s.cartRepo.SetItems(ctx, userID, items); err != nil {
+		s.restoreReservations(ctx, userID, reserved, previous, stock)
+		return fmt.Errorf("set items: %w", err)
+	}
 	return nil
 }
 
+// restoreReservations возвращает резервы к количествам до SetItems.
+func (s *CartServiceImpl) restoreReservations(ctx context.Context, userID string, items []domain.CartItemQuantity, previous, stock map[int]int) {
+	for _, it := range items {
+		if _, err := s.reservations.Reserve(ctx, it.BookID, userID, previous[it.BookID], stock[it.BookID], domain.CartReservationTTL); err != nil {
+			s.Logger.Error("failed to restore reservation", "book_id", it.BookID, "err", err)
+		}
+	}
+}
+
 func (s *CartServiceImpl) Clear(ctx context.Context, userID string) error {
 	items, _ := s.cartRepo.ListItems(ctx, userID)
 	if err := s.cartRepo.Clear(ctx, userID); err != nil {
@@ -97,8 +372,7 @@ func (s *CartServiceImpl) Clear(ctx context.Context, userID string) error {
 	}
 	// Удаляем все резервы пользователя
 	for _, item := range items {
-		reserveKey := fmt.Sprintf("reserve:%s:%d", userID, item.BookID)
-		s.redis.Del(reserveKey)
+		s.reservations.Release(ctx, item.BookID, userID)
 	}
 	return nil
 }
@@ -113,6 +387,132 @@ func (s *CartServiceImpl) ListItems(ctx context.Context, userID string) ([]*doma
 		if err == nil {
 			item.Book = book
 		}
+		priceItem(item)
 	}
 	return items, nil
 }
+
+// MergeGuest переносит гостевую корзину в корзину пользователя. Количество
+// каждой книги ограничивается тем, что не держат другие корзины; позиции,
+// которые не поместились целиком, возвращаются в Conflicts. Гостевая
+// корзина очищается только после переноса всех позиций: при ошибке она
+// остаётся как есть, и перенос можно повторить.
+func (s *CartServiceImpl) MergeGuest(ctx context.Context, guestID, userID string) (*domain.CartMergeResult, error) {
+	items, err := s.cartRepo.ListItems(ctx, guestID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &domain.CartMergeResult{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("merge guest cart: %w", err)
+	}
+	res := &domain.CartMergeResult{}
+	for _, item := range items {
+		current, err := s.cartRepo.GetItemQuantity(ctx, userID, item.BookID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("merge guest cart: %w", err)
+		}
+		requested := current + item.Quantity
+		available := 0
+		book, err := s.bookRepo.GetByID(ctx, item.BookID)
+		if err == nil {
+			reserved, err := s.reservations.ReservedByOthers(ctx, item.BookID, userID)
+			if err != nil {
+				return nil, fmt.Errorf("merge guest cart: %w", err)
+			}
+			// Резерв гостя переходит пользователю и чужим не считается
+			available = max(book.Inventory-max(reserved-item.Quantity, 0), 0)
+		}
+		// Уже лежащее в корзине пользователя не уменьшаем
+		merged := max(min(requested, available), current)
+		if merged > current {
+			ok, err := s.reservations.Transfer(ctx, item.BookID, guestID, userID, merged, book.Inventory, domain.CartReservationTTL)
+			if err != nil {
+				return nil, fmt.Errorf("merge guest cart: %w", err)
+			}
+			if !ok {
+				// Остаток успела занять другая корзина
+				merged = current
+			} else {
+				// Позиция уходит из гостевой корзины вместе с записью в корзину
+				// пользователя, поэтому повтор после ошибки её не задвоит
+				moved, err := s.cartRepo.MoveItem(ctx, guestID, userID, item.BookID, merged)
+				if err != nil {
+					return nil, fmt.Errorf("merge guest cart: %w", err)
+				}
+				if !moved {
+					// Позицию уже перенёс параллельный запрос: резерв — по его итогу
+					s.syncReservation(ctx, userID, item.BookID, book.Inventory)
+					continue
+				}
+			}
+		}
+		if merged > current {
+			res.Merged++
+		}
+		if merged < requested {
+			res.Conflicts = append(res.Conflicts, domain.CartMergeConflict{
+				BookID:    item.BookID,
+				Requested: requested,
+				Merged:    merged,
+				Available: available,
+			})
+		}
+	}
+	if len(items) > 0 {
+		if err := s.cartRepo.Clear(ctx, guestID); err != nil {
+			return nil, fmt.Errorf("merge guest cart: %w", err)
+		}
+	}
+	// Резервы перенесённых позиций уже у пользователя, остальные гостю не нужны
+	for _, item := range items {
+		s.reservations.Release(ctx, item.BookID, guestID)
+	}
+	return res, nil
+}
+
+// syncReservation приводит резерв пользователя на книгу к количеству в
+// его корзине.
+func (s *CartServiceImpl) syncReservation(ctx context.Context, userID string, bookID, stock int) {
+	quantity, err := s.cartRepo.GetItemQuantity(ctx, userID, bookID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		s.Logger.Error("failed to get cart item quantity", "book_id", bookID, "err", err)
+		return
+	}
+	if _, err := s.reservations.Reserve(ctx, bookID, userID, quantity, stock, domain.CartReservationTTL); err != nil {
+		s.Logger.Error("failed to restore reservation", "book_id", bookID, "err", err)
+	}
+}
+
+// ReleaseExpired удаляет позиции корзин с истёкшим резервом.
+func (s *CartServiceImpl) ReleaseExpired(ctx context.Context) (int64, error) {
+	n, err := s.cartRepo.DeleteExpired(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("release expired: %w", err)
+	}
+	return n, nil
+}
+
+// RunExpirySweeper раз в interval освобождает просроченные резервы, пока
+// не отменён ctx. Истёкшие позиции и так не видны в корзине и не
+// учитываются в остатке — sweeper только чистит таблицу.
+func (s *CartServiceImpl) RunExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := s.ReleaseExpired(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.Logger.Error("failed to release expired cart items", "err", err)
+			}
+			continue
+		}
+		if n > 0 {
+			s.Logger.Info("released expired cart items", "count", n)
+		}
+	}
+}
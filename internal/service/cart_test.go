@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"io"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/yourorg/bookshop/internal/domain"
@@ -16,7 +20,7 @@ import (
 func TestCartService_AddItem_Success(t *testing.T) {
 	cartRepo := new(mocks.CartRepository)
 	bookRepo := new(mocks.BookRepository)
-	redis := new(mocks.RedisCache)
+	reservations := new(mocks.ReservationRepository)
 
 	userID := "user-1"
 	bookID := 42
@@ -24,9 +28,9 @@ func TestCartService_AddItem_Success(t *testing.T) {
 	bookRepo.On("GetByID", mock.Anything, bookID).Return(&domain.Book{ID: bookID, Inventory: 1}, nil)
 	cartRepo.On("AddItem", mock.Anything, userID, bookID).Return(nil)
 	cartRepo.On("GetItemQuantity", mock.Anything, userID, bookID).Return(0, nil)
-	redis.On("Set", "reserve:user-1:42", "1", 1800).Return(nil)
+	reservations.On("Reserve", mock.Anything, bookID, userID, 1, 1, domain.CartReservationTTL).Return(true, nil)
 
-	svc := &CartServiceImpl{cartRepo, bookRepo, redis, slog.New(slog.NewTextHandler(io.Discard, nil))}
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
 	err := svc.AddItem(context.Background(), userID, bookID)
 	require.NoError(t, err)
 	bookRepo.AssertExpectations(t)
@@ -36,25 +40,46 @@ func TestCartService_AddItem_Success(t *testing.T) {
 func TestCartService_AddItem_AlreadyReserved(t *testing.T) {
 	cartRepo := new(mocks.CartRepository)
 	bookRepo := new(mocks.BookRepository)
-	redis := new(mocks.RedisCache)
+	reservations := new(mocks.ReservationRepository)
 
 	userID := "user-1"
 	bookID := 42
 
 	bookRepo.On("GetByID", mock.Anything, bookID).Return(&domain.Book{ID: bookID, Inventory: 1}, nil)
 	cartRepo.On("GetItemQuantity", mock.Anything, userID, bookID).Return(1, nil)
+	reservations.On("Reserve", mock.Anything, bookID, userID, 2, 1, domain.CartReservationTTL).Return(false, nil)
 
-	svc := &CartServiceImpl{cartRepo, bookRepo, redis, slog.New(slog.NewTextHandler(io.Discard, nil))}
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
 	err := svc.AddItem(context.Background(), userID, bookID)
 	require.Error(t, err)
 	require.Equal(t, "not enough books in stock: not enough books in stock", err.Error())
 	cartRepo.AssertExpectations(t)
 }
 
+func TestCartService_AddItem_ReservedByOtherCarts(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	userID := "user-1"
+	bookID := 42
+
+	bookRepo.On("GetByID", mock.Anything, bookID).Return(&domain.Book{ID: bookID, Inventory: 2}, nil)
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, bookID).Return(0, nil)
+	// Остальные два экземпляра держат другие корзины
+	reservations.On("Reserve", mock.Anything, bookID, userID, 1, 2, domain.CartReservationTTL).Return(false, nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	err := svc.AddItem(context.Background(), userID, bookID)
+	require.Error(t, err)
+	require.Equal(t, "not enough books in stock: not enough books in stock", err.Error())
+	cartRepo.AssertNotCalled(t, "AddItem", mock.Anything, userID, bookID)
+}
+
 func TestCartService_AddItem_ReservesInRedis(t *testing.T) {
 	cartRepo := new(mocks.CartRepository)
 	bookRepo := new(mocks.BookRepository)
-	redis := new(mocks.RedisCache)
+	reservations := new(mocks.ReservationRepository)
 
 	userID := "user-1"
 	bookID := 42
@@ -62,45 +87,520 @@ func TestCartService_AddItem_ReservesInRedis(t *testing.T) {
 	bookRepo.On("GetByID", mock.Anything, bookID).Return(&domain.Book{ID: bookID, Inventory: 1}, nil)
 	cartRepo.On("AddItem", mock.Anything, userID, bookID).Return(nil)
 	cartRepo.On("GetItemQuantity", mock.Anything, userID, bookID).Return(0, nil)
-	redis.On("Set", "reserve:user-1:42", "1", 1800).Return(nil)
+	reservations.On("Reserve", mock.Anything, bookID, userID, 1, 1, domain.CartReservationTTL).Return(true, nil)
 
-	svc := &CartServiceImpl{cartRepo, bookRepo, redis, slog.New(slog.NewTextHandler(io.Discard, nil))}
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
 	err := svc.AddItem(context.Background(), userID, bookID)
 	require.NoError(t, err)
-	redis.AssertExpectations(t)
+	reservations.AssertExpectations(t)
+}
+
+func TestCartService_AddItem_RollsBackReserveOnError(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	userID := "user-1"
+	bookID := 42
+
+	bookRepo.On("GetByID", mock.Anything, bookID).Return(&domain.Book{ID: bookID, Inventory: 5}, nil)
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, bookID).Return(1, nil)
+	cartRepo.On("AddItem", mock.Anything, userID, bookID).Return(errors.New("db down"))
+	reservations.On("Reserve", mock.Anything, bookID, userID, 2, 5, domain.CartReservationTTL).Return(true, nil)
+	reservations.On("Reserve", mock.Anything, bookID, userID, 1, 5, domain.CartReservationTTL).Return(true, nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	err := svc.AddItem(context.Background(), userID, bookID)
+	require.Error(t, err)
+	reservations.AssertExpectations(t)
 }
 
 func TestCartService_RemoveItem_RemovesReserve(t *testing.T) {
 	cartRepo := new(mocks.CartRepository)
 	bookRepo := new(mocks.BookRepository)
-	redis := new(mocks.RedisCache)
+	reservations := new(mocks.ReservationRepository)
 
 	userID := "user-1"
 	bookID := 42
 
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, bookID).Return(1, nil)
 	cartRepo.On("RemoveItem", mock.Anything, userID, bookID).Return(nil)
-	redis.On("Del", "reserve:user-1:42").Return(nil)
+	reservations.On("Release", mock.Anything, bookID, userID).Return(nil)
 
-	svc := &CartServiceImpl{cartRepo, bookRepo, redis, slog.New(slog.NewTextHandler(io.Discard, nil))}
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
 	err := svc.RemoveItem(context.Background(), userID, bookID)
 	require.NoError(t, err)
-	redis.AssertExpectations(t)
+	reservations.AssertExpectations(t)
 }
 
 func TestCartService_Clear_RemovesAllReserves(t *testing.T) {
 	cartRepo := new(mocks.CartRepository)
 	bookRepo := new(mocks.BookRepository)
-	redis := new(mocks.RedisCache)
+	reservations := new(mocks.ReservationRepository)
 
 	userID := "user-1"
 	items := []*domain.CartItem{{BookID: 1}, {BookID: 2}}
 	cartRepo.On("ListItems", mock.Anything, userID).Return(items, nil)
 	cartRepo.On("Clear", mock.Anything, userID).Return(nil)
-	redis.On("Del", "reserve:user-1:1").Return(nil)
-	redis.On("Del", "reserve:user-1:2").Return(nil)
+	reservations.On("Release", mock.Anything, 1, userID).Return(nil)
+	reservations.On("Release", mock.Anything, 2, userID).Return(nil)
 
-	svc := &CartServiceImpl{cartRepo, bookRepo, redis, slog.New(slog.NewTextHandler(io.Discard, nil))}
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
 	err := svc.Clear(context.Background(), userID)
 	require.NoError(t, err)
-	redis.AssertExpectations(t)
+	reservations.AssertExpectations(t)
+}
+
+func TestCartService_ReleaseExpired(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	cartRepo.On("DeleteExpired", mock.Anything).Return(int64(3), nil)
+
+	svc := &CartServiceImpl{cartRepo, new(mocks.BookRepository), new(mocks.ReservationRepository), new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	n, err := svc.ReleaseExpired(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+}
+
+func TestCartService_RunExpirySweeper_StopsOnCancel(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	ctx, cancel := context.WithCancel(context.Background())
+	swept := make(chan struct{}, 1)
+	cartRepo.On("DeleteExpired", mock.Anything).Return(int64(1), nil).Run(func(mock.Arguments) {
+		select {
+		case swept <- struct{}{}:
+		default:
+		}
+	})
+
+	svc := &CartServiceImpl{cartRepo, new(mocks.BookRepository), new(mocks.ReservationRepository), new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.RunExpirySweeper(ctx, time.Millisecond)
+	}()
+
+	select {
+	case <-swept:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not run")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop")
+	}
+}
+
+func TestCartService_MergeGuest_CapsAtAvailable(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	guestID, userID := "guest-1", "user-1"
+	cartRepo.On("ListItems", mock.Anything, guestID).Return([]*domain.CartItem{
+		{BookID: 1, Quantity: 2},
+		{BookID: 2, Quantity: 3},
+	}, nil)
+
+	// Книга 1 помещается целиком
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, 1).Return(1, nil)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, Inventory: 10}, nil)
+	reservations.On("ReservedByOthers", mock.Anything, 1, userID).Return(2, nil)
+	reservations.On("Transfer", mock.Anything, 1, guestID, userID, 3, 10, domain.CartReservationTTL).Return(true, nil)
+	cartRepo.On("MoveItem", mock.Anything, guestID, userID, 1, 3).Return(true, nil)
+
+	// Книги 2 свободно только 2 из 5: 3 держат другие корзины, ещё 3 — сам гость
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, 2).Return(0, nil)
+	bookRepo.On("GetByID", mock.Anything, 2).Return(&domain.Book{ID: 2, Inventory: 5}, nil)
+	reservations.On("ReservedByOthers", mock.Anything, 2, userID).Return(6, nil)
+	reservations.On("Transfer", mock.Anything, 2, guestID, userID, 2, 5, domain.CartReservationTTL).Return(true, nil)
+	cartRepo.On("MoveItem", mock.Anything, guestID, userID, 2, 2).Return(true, nil)
+
+	cartRepo.On("Clear", mock.Anything, guestID).Return(nil)
+	reservations.On("Release", mock.Anything, 1, guestID).Return(nil)
+	reservations.On("Release", mock.Anything, 2, guestID).Return(nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	res, err := svc.MergeGuest(context.Background(), guestID, userID)
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Merged)
+	require.Equal(t, []domain.CartMergeConflict{{BookID: 2, Requested: 3, Merged: 2, Available: 2}}, res.Conflicts)
+	cartRepo.AssertExpectations(t)
+	reservations.AssertExpectations(t)
+}
+
+func TestCartService_MergeGuest_DeletedBookIsConflict(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	guestID, userID := "guest-1", "user-1"
+	cartRepo.On("ListItems", mock.Anything, guestID).Return([]*domain.CartItem{{BookID: 9, Quantity: 1}}, nil)
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, 9).Return(0, nil)
+	bookRepo.On("GetByID", mock.Anything, 9).Return(nil, errors.New("book not found"))
+	cartRepo.On("Clear", mock.Anything, guestID).Return(nil)
+	reservations.On("Release", mock.Anything, 9, guestID).Return(nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	res, err := svc.MergeGuest(context.Background(), guestID, userID)
+	require.NoError(t, err)
+	require.Zero(t, res.Merged)
+	require.Equal(t, []domain.CartMergeConflict{{BookID: 9, Requested: 1, Merged: 0, Available: 0}}, res.Conflicts)
+	cartRepo.AssertNotCalled(t, "MoveItem", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Сбой на второй позиции не теряет гостевую корзину: первая уже перенесена
+// вместе со своим резервом, вторая остаётся у гостя до следующего запроса.
+func TestCartService_MergeGuest_FailureKeepsRemainingItems(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	guestID, userID := "guest-1", "user-1"
+	cartRepo.On("ListItems", mock.Anything, guestID).Return([]*domain.CartItem{
+		{BookID: 1, Quantity: 1},
+		{BookID: 2, Quantity: 1},
+	}, nil)
+	for _, id := range []int{1, 2} {
+		cartRepo.On("GetItemQuantity", mock.Anything, userID, id).Return(0, nil)
+		bookRepo.On("GetByID", mock.Anything, id).Return(&domain.Book{ID: id, Inventory: 5}, nil)
+		reservations.On("ReservedByOthers", mock.Anything, id, userID).Return(1, nil)
+		reservations.On("Transfer", mock.Anything, id, guestID, userID, 1, 5, domain.CartReservationTTL).Return(true, nil)
+	}
+	cartRepo.On("MoveItem", mock.Anything, guestID, userID, 1, 1).Return(true, nil)
+	cartRepo.On("MoveItem", mock.Anything, guestID, userID, 2, 1).Return(false, errors.New("db down"))
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	_, err := svc.MergeGuest(context.Background(), guestID, userID)
+	require.Error(t, err)
+	cartRepo.AssertNotCalled(t, "Clear", mock.Anything, guestID)
+	reservations.AssertNotCalled(t, "Release", mock.Anything, mock.Anything, guestID)
+
+	// Повтор видит только непереехавшую позицию
+	cartRepo.ExpectedCalls = nil
+	cartRepo.On("ListItems", mock.Anything, guestID).Return([]*domain.CartItem{{BookID: 2, Quantity: 1}}, nil)
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, 2).Return(0, nil)
+	cartRepo.On("MoveItem", mock.Anything, guestID, userID, 2, 1).Return(true, nil)
+	cartRepo.On("Clear", mock.Anything, guestID).Return(nil)
+	reservations.On("Release", mock.Anything, 2, guestID).Return(nil)
+
+	res, err := svc.MergeGuest(context.Background(), guestID, userID)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Merged)
+	require.Empty(t, res.Conflicts)
+	cartRepo.AssertExpectations(t)
+}
+
+// Позицию уже перенёс параллельный запрос: резерв пользователя выравнивается
+// по его корзине, позиция не считается перенесённой второй раз.
+func TestCartService_MergeGuest_AlreadyMovedByConcurrentRequest(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	guestID, userID := "guest-1", "user-1"
+	cartRepo.On("ListItems", mock.Anything, guestID).Return([]*domain.CartItem{{BookID: 1, Quantity: 2}}, nil)
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, 1).Return(2, nil).Once()
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, Inventory: 10}, nil)
+	reservations.On("ReservedByOthers", mock.Anything, 1, userID).Return(0, nil)
+	reservations.On("Transfer", mock.Anything, 1, guestID, userID, 4, 10, domain.CartReservationTTL).Return(true, nil)
+	cartRepo.On("MoveItem", mock.Anything, guestID, userID, 1, 4).Return(false, nil)
+	cartRepo.On("GetItemQuantity", mock.Anything, userID, 1).Return(2, nil).Once()
+	reservations.On("Reserve", mock.Anything, 1, userID, 2, 10, domain.CartReservationTTL).Return(true, nil)
+	cartRepo.On("Clear", mock.Anything, guestID).Return(nil)
+	reservations.On("Release", mock.Anything, 1, guestID).Return(nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	res, err := svc.MergeGuest(context.Background(), guestID, userID)
+	require.NoError(t, err)
+	require.Zero(t, res.Merged)
+	require.Empty(t, res.Conflicts)
+	cartRepo.AssertExpectations(t)
+	reservations.AssertExpectations(t)
+}
+
+func TestCartService_SetItems_Success(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	userID := "user-1"
+	items := []domain.CartItemQuantity{{BookID: 1, Quantity: 5}, {BookID: 2, Quantity: 0}}
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, Inventory: 10}, nil)
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{{BookID: 2, Quantity: 1}}, nil)
+	reservations.On("Reserve", mock.Anything, 1, userID, 5, 10, domain.CartReservationTTL).Return(true, nil)
+	reservations.On("Reserve", mock.Anything, 2, userID, 0, 0, domain.CartReservationTTL).Return(true, nil)
+	cartRepo.On("SetItems", mock.Anything, userID, items).Return(nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	require.NoError(t, svc.SetItems(context.Background(), userID, items))
+	cartRepo.AssertExpectations(t)
+	reservations.AssertExpectations(t)
+}
+
+func TestCartService_SetItems_ReportsAllShortagesAndRestores(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	userID := "user-1"
+	items := []domain.CartItemQuantity{{BookID: 1, Quantity: 2}, {BookID: 2, Quantity: 4}, {BookID: 3, Quantity: 9}}
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, Inventory: 10}, nil)
+	bookRepo.On("GetByID", mock.Anything, 2).Return(&domain.Book{ID: 2, Inventory: 5}, nil)
+	bookRepo.On("GetByID", mock.Anything, 3).Return(&domain.Book{ID: 3, Inventory: 3}, nil)
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{{BookID: 1, Quantity: 1}}, nil)
+	reservations.On("Reserve", mock.Anything, 1, userID, 2, 10, domain.CartReservationTTL).Return(true, nil).Once()
+	reservations.On("Reserve", mock.Anything, 2, userID, 4, 5, domain.CartReservationTTL).Return(false, nil)
+	reservations.On("ReservedByOthers", mock.Anything, 2, userID).Return(2, nil)
+	reservations.On("Reserve", mock.Anything, 3, userID, 9, 3, domain.CartReservationTTL).Return(false, nil)
+	reservations.On("ReservedByOthers", mock.Anything, 3, userID).Return(0, nil)
+	// Резерв книги 1 возвращается к прежнему количеству
+	reservations.On("Reserve", mock.Anything, 1, userID, 1, 10, domain.CartReservationTTL).Return(true, nil).Once()
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	err := svc.SetItems(context.Background(), userID, items)
+	var outOfStock *domain.OutOfStockError
+	require.ErrorAs(t, err, &outOfStock)
+	require.Equal(t, []domain.OutOfStockItem{{BookID: 2, Requested: 4, Available: 3}, {BookID: 3, Requested: 9, Available: 3}}, outOfStock.Items)
+	cartRepo.AssertNotCalled(t, "SetItems", mock.Anything, mock.Anything, mock.Anything)
+	reservations.AssertExpectations(t)
+}
+
+func TestCartService_SetItems_DatabaseShortageRestores(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	userID := "user-1"
+	items := []domain.CartItemQuantity{{BookID: 1, Quantity: 3}}
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, Inventory: 3}, nil)
+	cartRepo.On("ListItems", mock.Anything, userID).Return(nil, nil)
+	reservations.On("Reserve", mock.Anything, 1, userID, 3, 3, domain.CartReservationTTL).Return(true, nil)
+	outOfStock := &domain.OutOfStockError{Items: []domain.OutOfStockItem{{BookID: 1, Requested: 3, Available: 2}}}
+	cartRepo.On("SetItems", mock.Anything, userID, items).Return(outOfStock)
+	reservations.On("Reserve", mock.Anything, 1, userID, 0, 3, domain.CartReservationTTL).Return(true, nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	err := svc.SetItems(context.Background(), userID, items)
+	require.ErrorIs(t, err, outOfStock)
+	reservations.AssertExpectations(t)
+}
+
+func TestCartService_SetItems_Validation(t *testing.T) {
+	svc := &CartServiceImpl{new(mocks.CartRepository), new(mocks.BookRepository), new(mocks.ReservationRepository), new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	for name, items := range map[string][]domain.CartItemQuantity{
+		"batch is empty":          nil,
+		"invalid quantity":        {{BookID: 1, Quantity: -1}},
+		"duplicate book in batch": {{BookID: 1, Quantity: 1}, {BookID: 1, Quantity: 2}},
+		"batch is too large":      make([]domain.CartItemQuantity, maxCartBatch+1),
+	} {
+		err := svc.SetItems(context.Background(), "user-1", items)
+		require.ErrorContains(t, err, name)
+	}
+}
+
+func TestCartService_RemoveItem_ShrinksReserve(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	cartRepo.On("GetItemQuantity", mock.Anything, "user-1", 42).Return(3, nil)
+	cartRepo.On("RemoveItem", mock.Anything, "user-1", 42).Return(nil)
+	reservations.On("Reserve", mock.Anything, 42, "user-1", 2, 0, domain.CartReservationTTL).Return(true, nil)
+
+	svc := &CartServiceImpl{cartRepo, new(mocks.BookRepository), reservations, new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	require.NoError(t, svc.RemoveItem(context.Background(), "user-1", 42))
+	reservations.AssertExpectations(t)
+}
+
+func TestCartService_GetByUserID_PricesAndFlags(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+
+	userID := "user-1"
+	cartRepo.On("GetByUserID", mock.Anything, userID).Return(&domain.Cart{ID: 1, UserID: userID}, nil)
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{
+		{BookID: 1, Quantity: 3, PriceSnapshot: domain.NewMoney(10)},
+		{BookID: 2, Quantity: 1, PriceSnapshot: domain.NewMoney(50000)},
+		{BookID: 3, Quantity: 2, PriceSnapshot: domain.NewMoney(10000)},
+		{BookID: 4, Quantity: 1, PriceSnapshot: domain.NewMoney(5000)},
+	}, nil)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, Price: domain.NewMoney(10), Inventory: 10}, nil)
+	bookRepo.On("GetByID", mock.Anything, 2).Return(&domain.Book{ID: 2, Price: domain.NewMoney(45000), Inventory: 10}, nil)
+	bookRepo.On("GetByID", mock.Anything, 3).Return(&domain.Book{ID: 3, Price: domain.NewMoney(10000), Inventory: 1}, nil)
+	bookRepo.On("GetByID", mock.Anything, 4).Return(nil, errors.New("book not found"))
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, new(mocks.ReservationRepository), new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cart, err := svc.GetByUserID(context.Background(), userID)
+	require.NoError(t, err)
+	require.Len(t, cart.Items, 4)
+
+	// 0.1 * 3 без округления дало бы 0.30000000000000004
+	require.Equal(t, domain.NewMoney(30), cart.Items[0].LineTotal)
+	require.False(t, cart.Items[0].PriceChanged)
+	require.False(t, cart.Items[0].Unavailable)
+
+	require.Equal(t, domain.NewMoney(45000), cart.Items[1].Price)
+	require.True(t, cart.Items[1].PriceChanged)
+
+	require.Equal(t, domain.NewMoney(20000), cart.Items[2].LineTotal)
+	require.True(t, cart.Items[2].Unavailable)
+
+	require.True(t, cart.Items[3].Unavailable)
+	require.Zero(t, cart.Items[3].LineTotal)
+
+	require.Equal(t, domain.NewMoney(65030), cart.Subtotal)
+	require.True(t, cart.Stale)
+}
+
+func TestCartService_GetByUserID_NoCartIsEmpty(t *testing.T) {
+	cartRepo := new(mocks.CartRepository)
+	cartRepo.On("GetByUserID", mock.Anything, "guest-1").Return(nil, fmt.Errorf("get by user: %w", pgx.ErrNoRows))
+
+	svc := &CartServiceImpl{cartRepo, new(mocks.BookRepository), new(mocks.ReservationRepository), new(mocks.PromoRepository), testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cart, err := svc.GetByUserID(context.Background(), "guest-1")
+	require.NoError(t, err)
+	require.Empty(t, cart.Items)
+	require.False(t, cart.Stale)
+}
+
+// testTaxes — налоговый сервис без ставок: НДС в тестах нулевой.
+func testTaxes() *TaxServiceImpl {
+	taxes := new(mocks.TaxRepository)
+	taxes.On("Effective", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	return NewTaxService(taxes, domain.TaxRegion{Country: "RU"})
+}
+
+// promoCart — корзина user-1 с промокодом code: книга 1 (категория 5) за 300 ₽ и книга 2 (категория 7) за 700 ₽.
+func promoCart(code string) (*mocks.CartRepository, *mocks.BookRepository) {
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	cartRepo.On("GetByUserID", mock.Anything, "user-1").Return(&domain.Cart{ID: 1, UserID: "user-1", PromoCode: code}, nil)
+	cartRepo.On("ListItems", mock.Anything, "user-1").Return([]*domain.CartItem{
+		{BookID: 1, Quantity: 1, PriceSnapshot: domain.NewMoney(30000)},
+		{BookID: 2, Quantity: 1, PriceSnapshot: domain.NewMoney(70000)},
+	}, nil)
+	bookRepo.On("GetByID", mock.Anything, 1).Return(&domain.Book{ID: 1, CategoryID: 5, Price: domain.NewMoney(30000), Inventory: 10}, nil)
+	bookRepo.On("GetByID", mock.Anything, 2).Return(&domain.Book{ID: 2, CategoryID: 7, Price: domain.NewMoney(70000), Inventory: 10}, nil)
+	return cartRepo, bookRepo
+}
+
+func TestCartService_GetByUserID_AppliesPromo(t *testing.T) {
+	category := 5
+	cartRepo, bookRepo := promoCart("FANTASY20")
+	promos := new(mocks.PromoRepository)
+	promos.On("GetByCode", mock.Anything, "FANTASY20").Return(&domain.PromoCode{ID: 3, Code: "FANTASY20", Kind: domain.PromoPercent, Percent: 20, CategoryID: &category, Active: true}, nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, new(mocks.ReservationRepository), promos, testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cart, err := svc.GetByUserID(context.Background(), "user-1")
+	require.NoError(t, err)
+	require.Equal(t, domain.NewMoney(6000), cart.Items[0].Discount)
+	require.Zero(t, cart.Items[1].Discount.Amount)
+	require.Equal(t, domain.NewMoney(6000), cart.Discount)
+	require.Equal(t, domain.NewMoney(94000), cart.Total)
+	require.Empty(t, cart.PromoReason)
+}
+
+func TestCartService_GetByUserID_ExpiredPromoKeepsCodeWithReason(t *testing.T) {
+	ended := time.Now().Add(-time.Hour)
+	cartRepo, bookRepo := promoCart("SPRING")
+	promos := new(mocks.PromoRepository)
+	promos.On("GetByCode", mock.Anything, "SPRING").Return(&domain.PromoCode{ID: 3, Code: "SPRING", Kind: domain.PromoFixed, Amount: domain.NewMoney(10000), EndsAt: &ended, Active: true}, nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, new(mocks.ReservationRepository), promos, testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cart, err := svc.GetByUserID(context.Background(), "user-1")
+	require.NoError(t, err)
+	require.Equal(t, "SPRING", cart.PromoCode)
+	require.Equal(t, domain.PromoExpired, cart.PromoReason)
+	require.Zero(t, cart.Discount.Amount)
+	require.Equal(t, cart.Subtotal, cart.Total)
+}
+
+func TestCartService_ApplyPromo(t *testing.T) {
+	cartRepo, bookRepo := promoCart("")
+	promos := new(mocks.PromoRepository)
+	promos.On("GetByCode", mock.Anything, "BIG").Return(&domain.PromoCode{ID: 3, Code: "BIG", Kind: domain.PromoFixed, Amount: domain.NewMoney(10000), MinSubtotal: domain.NewMoney(200000), Active: true}, nil)
+	promos.On("GetByCode", mock.Anything, "ONCE").Return(&domain.PromoCode{ID: 4, Code: "ONCE", Kind: domain.PromoPercent, Percent: 5, PerUserLimit: 1, Active: true}, nil)
+	promos.On("CountRedemptions", mock.Anything, 4, "user-1").Return(1, nil)
+	promos.On("GetByCode", mock.Anything, "NOPE").Return(nil, fmt.Errorf("get promo code: %w", pgx.ErrNoRows))
+	promos.On("GetByCode", mock.Anything, "SALE10").Return(&domain.PromoCode{ID: 5, Code: "SALE10", Kind: domain.PromoPercent, Percent: 10, Active: true}, nil)
+	cartRepo.On("SetPromoCode", mock.Anything, "user-1", "SALE10").Return(nil).Once()
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, new(mocks.ReservationRepository), promos, testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	ctx := context.Background()
+	for code, reason := range map[string]string{"big": domain.PromoMinSubtotal, "ONCE": domain.PromoUserLimit} {
+		_, err := svc.ApplyPromo(ctx, "user-1", code)
+		var promoErr *domain.PromoError
+		require.True(t, errors.As(err, &promoErr), code)
+		require.Equal(t, reason, promoErr.Reason)
+	}
+	_, err := svc.ApplyPromo(ctx, "user-1", "nope")
+	require.ErrorContains(t, err, "promo code not found")
+	_, err = svc.ApplyPromo(ctx, "user-1", "  ")
+	require.ErrorContains(t, err, "invalid promo code")
+
+	// Код нормализуется и сохраняется только после проверки
+	_, err = svc.ApplyPromo(ctx, "user-1", " sale10 ")
+	require.NoError(t, err)
+	cartRepo.AssertExpectations(t)
+	cartRepo.AssertNumberOfCalls(t, "SetPromoCode", 1)
+}
+
+// Скидка при оформлении считается по промокоду корзины; переставший
+// действовать код — ошибка, а не оформление без скидки.
+func TestCartService_CheckoutDiscount(t *testing.T) {
+	lines := []domain.PromoLine{{BookID: 42, Total: domain.NewMoney(200000)}, {BookID: 43, Total: domain.NewMoney(100000)}}
+	promos := new(mocks.PromoRepository)
+	promos.On("GetByCode", mock.Anything, "SALE10").Return(&domain.PromoCode{ID: 3, Code: "SALE10", Kind: domain.PromoPercent, Percent: 10, Active: true}, nil)
+	promos.On("GetByCode", mock.Anything, "GONE").Return(&domain.PromoCode{ID: 4, Code: "GONE", Kind: domain.PromoPercent, Percent: 10}, nil)
+	cartRepo := new(mocks.CartRepository)
+	cartRepo.On("GetByUserID", mock.Anything, "user-1").Return(&domain.Cart{ID: 1, UserID: "user-1", PromoCode: "SALE10"}, nil)
+	cartRepo.On("GetByUserID", mock.Anything, "user-2").Return(&domain.Cart{ID: 2, UserID: "user-2", PromoCode: "GONE"}, nil)
+	cartRepo.On("GetByUserID", mock.Anything, "user-3").Return(&domain.Cart{ID: 3, UserID: "user-3"}, nil)
+	cartRepo.On("GetByUserID", mock.Anything, "user-4").Return(nil, fmt.Errorf("get cart: %w", pgx.ErrNoRows))
+
+	svc := &CartServiceImpl{cartRepo, new(mocks.BookRepository), new(mocks.ReservationRepository), promos, testTaxes(), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	ctx := context.Background()
+	promo, discounts, err := svc.CheckoutDiscount(ctx, "user-1", lines)
+	require.NoError(t, err)
+	require.Equal(t, "SALE10", promo.Code)
+	require.Equal(t, []domain.Money{domain.NewMoney(20000), domain.NewMoney(10000)}, discounts)
+
+	_, _, err = svc.CheckoutDiscount(ctx, "user-2", lines)
+	var promoErr *domain.PromoError
+	require.True(t, errors.As(err, &promoErr))
+	require.Equal(t, domain.PromoInactive, promoErr.Reason)
+
+	for _, userID := range []string{"user-3", "user-4"} {
+		promo, discounts, err := svc.CheckoutDiscount(ctx, userID, lines)
+		require.NoError(t, err, userID)
+		require.Nil(t, promo, userID)
+		require.Nil(t, discounts, userID)
+	}
+}
+
+func TestCartService_GetForRegion_TaxOnDiscountedLines(t *testing.T) {
+	category, ebooks := 5, 7
+	cartRepo, bookRepo := promoCart("FANTASY20")
+	promos := new(mocks.PromoRepository)
+	promos.On("GetByCode", mock.Anything, "FANTASY20").Return(&domain.PromoCode{ID: 3, Code: "FANTASY20", Kind: domain.PromoPercent, Percent: 20, CategoryID: &category, Active: true}, nil)
+	taxRepo := new(mocks.TaxRepository)
+	taxRepo.On("Effective", mock.Anything, "KZ", mock.Anything).Return([]*domain.TaxRate{
+		{Country: "KZ", Rate: 1000},
+		{Country: "KZ", CategoryID: &ebooks, Rate: 2000},
+	}, nil)
+
+	svc := &CartServiceImpl{cartRepo, bookRepo, new(mocks.ReservationRepository), promos, NewTaxService(taxRepo, domain.TaxRegion{Country: "RU"}), slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cart, err := svc.GetForRegion(context.Background(), "user-1", domain.TaxRegion{Country: "KZ"})
+	require.NoError(t, err)
+	// НДС выделяется из суммы после скидки: 240.00 * 10/110 = 21.818 -> 21.82
+	require.Equal(t, domain.Percent(1000), cart.Items[0].TaxRate)
+	require.Equal(t, domain.NewMoney(2182), cart.Items[0].Tax)
+	// 700.00 * 20/120 = 116.666 -> 116.67
+	require.Equal(t, domain.Percent(2000), cart.Items[1].TaxRate)
+	require.Equal(t, domain.NewMoney(11667), cart.Items[1].Tax)
+	require.Equal(t, domain.NewMoney(13849), cart.Tax)
+	// Налог включён в цены и итог не меняет
+	require.Equal(t, domain.NewMoney(94000), cart.Total)
+	require.Equal(t, &domain.TaxRegion{Country: "KZ"}, cart.TaxRegion)
 }
@@ -25,8 +25,32 @@ func (s *CategoryServiceImpl) GetByID(ctx context.Context, id int) (*domain.Cate
 	return s.categoryRepo.GetByID(ctx, id)
 }
 
-func (s *CategoryServiceImpl) List(ctx context.Context) ([]*domain.Category, error) {
-	return s.categoryRepo.List(ctx)
+func (s *CategoryServiceImpl) List(ctx context.Context, page domain.PageRequest) (*domain.CategoryList, error) {
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	page.Limit = limit + 1
+	categories, err := s.categoryRepo.List(ctx, page)
+	if err != nil {
+		return nil, fmt.Errorf("list categories: %w", err)
+	}
+	list := &domain.CategoryList{Items: categories}
+	if list.Items == nil {
+		list.Items = make([]*domain.Category, 0)
+	}
+	if len(categories) > limit {
+		list.Items = categories[:limit]
+		list.Next = &domain.Cursor{ID: categories[limit-1].ID}
+	}
+	if page.WithTotal {
+		total, err := s.categoryRepo.Count(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("count categories: %w", err)
+		}
+		list.Total = &total
+	}
+	return list, nil
 }
 
 func (s *CategoryServiceImpl) Create(ctx context.Context, category *domain.Category) error {
@@ -56,7 +80,7 @@ func (s *CategoryServiceImpl) Delete(ctx context.Context, id int) error {
 		return fmt.Errorf("find 'Без категории': %w", err)
 	}
 	// Перевести книги в "без категории"
-	books, err := s.bookRepo.List(ctx, []int{id}, 10000, 0)
+	books, err := s.bookRepo.List(ctx, domain.BookFilter{CategoryIDs: []int{id}, IncludeSoldOut: true})
 	if err != nil {
 		return fmt.Errorf("list books: %w", err)
 	}
@@ -8,15 +8,22 @@ import (
 
 type BookService interface {
 	GetByID(ctx context.Context, id int) (*domain.Book, error)
-	List(ctx context.Context, categoryIDs []int, limit, offset int) ([]*domain.Book, error)
+	List(ctx context.Context, filter domain.BookFilter) (*domain.BookList, error)
+	Facets(ctx context.Context, filter domain.BookFilter) (*domain.BookFacets, error)
+	Search(ctx context.Context, query string, limit, offset int) ([]*domain.Book, error)
 	Create(ctx context.Context, book *domain.Book) error
 	Update(ctx context.Context, book *domain.Book) error
 	Delete(ctx context.Context, id int) error
+	AdjustInventory(ctx context.Context, bookID int, kind string, quantity int, reason, actor string) (*domain.InventoryMovement, error)
+	InventoryHistory(ctx context.Context, bookID int, page domain.PageRequest) (*domain.InventoryMovementList, error)
+	SchedulePrice(ctx context.Context, bookID int, req domain.SchedulePriceRequest, actor string) (*domain.BookPrice, error)
+	CancelPrice(ctx context.Context, bookID, priceID int) error
+	PriceHistory(ctx context.Context, bookID int, page domain.PageRequest) (*domain.BookPriceList, error)
 }
 
 type CategoryService interface {
 	GetByID(ctx context.Context, id int) (*domain.Category, error)
-	List(ctx context.Context) ([]*domain.Category, error)
+	List(ctx context.Context, page domain.PageRequest) (*domain.CategoryList, error)
 	Create(ctx context.Context, category *domain.Category) error
 	Update(ctx context.Context, category *domain.Category) error
 	Delete(ctx context.Context, id int) error
@@ -24,17 +31,62 @@ type CategoryService interface {
 
 type CartService interface {
 	GetByUserID(ctx context.Context, userID string) (*domain.Cart, error)
+	GetForRegion(ctx context.Context, userID string, region domain.TaxRegion) (*domain.Cart, error)
 	AddItem(ctx context.Context, userID string, bookID int) error
 	RemoveItem(ctx context.Context, userID string, bookID int) error
 	Clear(ctx context.Context, userID string) error
 	ListItems(ctx context.Context, userID string) ([]*domain.CartItem, error)
+	MergeGuest(ctx context.Context, guestID, userID string) (*domain.CartMergeResult, error)
+	SetItemQuantity(ctx context.Context, userID string, bookID, quantity int) error
+	SetItems(ctx context.Context, userID string, items []domain.CartItemQuantity) error
+	ApplyPromo(ctx context.Context, userID, code string) (*domain.Cart, error)
+	RemovePromo(ctx context.Context, userID string) error
+}
+
+// CheckoutDiscounter считает скидку по промокоду корзины для позиций
+// оформляемого заказа.
+type CheckoutDiscounter interface {
+	CheckoutDiscount(ctx context.Context, userID string, lines []domain.PromoLine) (*domain.PromoCode, []domain.Money, error)
+}
+
+type PromoService interface {
+	Create(ctx context.Context, p *domain.PromoCode) error
+	Update(ctx context.Context, p *domain.PromoCode) error
+	Get(ctx context.Context, id int) (*domain.PromoCode, error)
+	List(ctx context.Context, page domain.PageRequest) (*domain.PromoCodeList, error)
+}
+
+type TaxService interface {
+	Create(ctx context.Context, rate *domain.TaxRate) error
+	List(ctx context.Context, page domain.PageRequest) (*domain.TaxRateList, error)
+}
+
+// LineTaxer считает НДС, включённый в суммы позиций, по ставкам региона.
+type LineTaxer interface {
+	LineTaxes(ctx context.Context, region domain.TaxRegion, lines []domain.TaxLine) ([]domain.LineTax, domain.Money, error)
 }
 
 type OrderService interface {
-	Create(ctx context.Context, userID string) (*domain.Order, error)
-	ListByUser(ctx context.Context, userID string) ([]*domain.Order, error)
+	Create(ctx context.Context, userID string, req domain.PlaceOrderRequest) (*domain.Order, error)
+	Get(ctx context.Context, orderID int, actor string, isAdmin bool) (*domain.Order, error)
+	ListByUser(ctx context.Context, userID string, page domain.PageRequest) (*domain.OrderList, error)
+	Transition(ctx context.Context, orderID int, to, actor, reason string) (*domain.Order, error)
+	Transitions(ctx context.Context, orderID int) ([]*domain.OrderTransition, error)
+	Cancel(ctx context.Context, orderID int, actor string, isAdmin bool, reason string) (*domain.Order, error)
+	Pay(ctx context.Context, orderID int, userID string, req domain.PayOrderRequest) (*domain.Payment, error)
+	HandlePaymentEvent(ctx context.Context, evt domain.PaymentEvent) error
+}
+
+type ReturnService interface {
+	Request(ctx context.Context, orderID int, userID string, req domain.ReturnRequest) (*domain.Return, error)
+	Get(ctx context.Context, id int, actor string, isAdmin bool) (*domain.Return, error)
+	ListByOrder(ctx context.Context, orderID int, actor string, isAdmin bool) ([]*domain.Return, error)
+	List(ctx context.Context, status string, page domain.PageRequest) (*domain.ReturnList, error)
+	Approve(ctx context.Context, id int, actor, note string) (*domain.Return, error)
+	Reject(ctx context.Context, id int, actor, note string) (*domain.Return, error)
 }
 
 type UserService interface {
+	GetByID(ctx context.Context, id string) (*domain.User, error)
 	GetOrCreate(ctx context.Context, id, email string, isAdmin bool) (*domain.User, error)
 }
@@ -4,31 +4,59 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/yourorg/bookshop/internal/domain"
 	"github.com/yourorg/bookshop/internal/integration"
 	"github.com/yourorg/bookshop/internal/repository"
+	"golang.org/x/exp/slog"
 )
 
+// OrderServiceImpl не публикует события сам: репозиторий пишет их в
+// outbox в транзакции заказа, в Kafka их отправляет OutboxRelay.
 type OrderServiceImpl struct {
-	orderRepo repository.OrderRepository
-	cartRepo  repository.CartRepository
-	bookRepo  repository.BookRepository
-	kafka     integration.KafkaProducer
-	redis     integration.RedisCache
+	orderRepo    repository.OrderRepository
+	cartRepo     repository.CartRepository
+	bookRepo     repository.BookRepository
+	reservations repository.ReservationRepository
+	payments     repository.PaymentRepository
+	provider     integration.PaymentProvider
+	discounts    CheckoutDiscounter
+	taxes        LineTaxer
+	Logger       *slog.Logger
 }
 
-func NewOrderService(orderRepo repository.OrderRepository, cartRepo repository.CartRepository, bookRepo repository.BookRepository, kafka integration.KafkaProducer, redis integration.RedisCache) *OrderServiceImpl {
+func NewOrderService(orderRepo repository.OrderRepository, cartRepo repository.CartRepository, bookRepo repository.BookRepository, reservations repository.ReservationRepository, payments repository.PaymentRepository, provider integration.PaymentProvider, discounts CheckoutDiscounter, taxes LineTaxer, logger *slog.Logger) *OrderServiceImpl {
 	return &OrderServiceImpl{
-		orderRepo: orderRepo,
-		cartRepo:  cartRepo,
-		bookRepo:  bookRepo,
-		kafka:     kafka,
-		redis:     redis,
+		orderRepo:    orderRepo,
+		cartRepo:     cartRepo,
+		bookRepo:     bookRepo,
+		reservations: reservations,
+		payments:     payments,
+		provider:     provider,
+		discounts:    discounts,
+		taxes:        taxes,
+		Logger:       logger,
 	}
 }
 
-func (s *OrderServiceImpl) Create(ctx context.Context, userID string) (*domain.Order, error) {
+// Create оформляет заказ из корзины по текущим ценам. Адрес и телефон
+// проверяются по правилам страны доставки (*domain.ValidationError). Если
+// цена какой-то позиции изменилась после добавления в корзину, а
+// req.ConfirmPrices не задан, возвращает *domain.StaleCartError. Скидку
+// по промокоду корзины считает CartServiceImpl, гасит — транзакция заказа.
+// НДС позиций считается по ставкам страны и региона доставки. Корзина
+// очищается в той же транзакции, что и сохраняется заказ.
+func (s *OrderServiceImpl) Create(ctx context.Context, userID string, req domain.PlaceOrderRequest) (*domain.Order, error) {
+	if req.ShippingAddress != nil {
+		addr := *req.ShippingAddress
+		addr.Normalize()
+		req.ShippingAddress = &addr
+	}
+	req.Phone = domain.NormalizePhone(req.Phone)
+	if err := domain.ValidateShipping(req.ShippingAddress, req.Phone); err != nil {
+		return nil, err
+	}
 	items, err := s.ListItems(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("get cart items: %w", err)
@@ -37,42 +65,187 @@ func (s *OrderServiceImpl) Create(ctx context.Context, userID string) (*domain.O
 		return nil, fmt.Errorf("cart is empty: %w", errors.New("cart is empty"))
 	}
 	var orderItems []domain.OrderItem
-	var books []integration.OrderPlacedBook
+	var lines []domain.PromoLine
+	var changed []domain.PriceChange
 	for _, item := range items {
 		book, err := s.bookRepo.GetByID(ctx, item.BookID)
 		if err != nil {
 			return nil, fmt.Errorf("book not found: %w", err)
 		}
-		if book.Inventory < item.Quantity {
-			return nil, fmt.Errorf("book out of stock: %d", book.ID)
+		if book.Price != item.PriceSnapshot {
+			changed = append(changed, domain.PriceChange{BookID: book.ID, PriceSnapshot: item.PriceSnapshot, Price: book.Price})
 		}
+		// Остаток проверяется и списывается в транзакции заказа (orderRepo.Create)
 		orderItems = append(orderItems, domain.OrderItem{BookID: book.ID, Price: book.Price, Quantity: item.Quantity})
-		books = append(books, integration.OrderPlacedBook{BookID: book.ID, Quantity: item.Quantity})
+		lines = append(lines, domain.PromoLine{BookID: book.ID, CategoryID: book.CategoryID, Total: book.Price.Mul(item.Quantity)})
+	}
+	if len(changed) > 0 && !req.ConfirmPrices {
+		return nil, &domain.StaleCartError{Items: changed}
+	}
+	// Промокод, переставший действовать, не снимается молча: покупатель
+	// получает *domain.PromoError и решает сам
+	promo, discounts, err := s.discounts.CheckoutDiscount(ctx, userID, lines)
+	if err != nil {
+		return nil, err
+	}
+	order := &domain.Order{UserID: userID, Items: orderItems, ShippingAddress: req.ShippingAddress, Phone: req.Phone}
+	order.Subtotal = domain.OrderSubtotal(orderItems)
+	order.Discount = domain.NewMoney(0)
+	if promo != nil {
+		order.PromoCode = promo.Code
+		for i := range orderItems {
+			orderItems[i].Discount = discounts[i]
+			order.Discount = order.Discount.Add(discounts[i])
+		}
 	}
-	order := &domain.Order{UserID: userID, Items: orderItems}
+	// Бесплатная доставка считается от суммы со скидкой
+	order.Shipping = domain.ShippingCost(req.ShippingAddress.Country, order.Subtotal.Sub(order.Discount))
+	// Цены книг включают НДС: налог выделяется из позиций и в Total не добавляется
+	taxLines := make([]domain.TaxLine, len(orderItems))
+	for i, it := range orderItems {
+		taxLines[i] = domain.TaxLine{CategoryID: lines[i].CategoryID, Amount: lines[i].Total.Sub(it.Discount)}
+	}
+	region := domain.TaxRegion{Country: req.ShippingAddress.Country, Region: req.ShippingAddress.Region}
+	taxes, tax, err := s.taxes.LineTaxes(ctx, region, taxLines)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orderItems {
+		orderItems[i].TaxRate = taxes[i].Rate
+		orderItems[i].Tax = taxes[i].Tax
+	}
+	order.Tax = tax
+	order.Total = order.Subtotal.Sub(order.Discount).Add(order.Shipping)
 	if err := s.orderRepo.Create(ctx, order); err != nil {
 		return nil, fmt.Errorf("create order: %w", err)
 	}
-	if err := s.cartRepo.Clear(ctx, userID); err != nil {
-		return nil, fmt.Errorf("clear cart: %w", err)
-	}
-	// Удаляем все резервы пользователя
+	// Книги списаны со склада — резервы пользователя больше не нужны
 	for _, item := range items {
-		reserveKey := fmt.Sprintf("reserve:%s:%d", userID, item.BookID)
-		s.redis.Del(reserveKey)
+		s.reservations.Release(ctx, item.BookID, userID)
 	}
-	if err := s.kafka.PublishOrderPlaced(ctx, order.ID, userID, books); err != nil {
-		return nil, fmt.Errorf("publish kafka: %w", err)
+	return order, nil
+}
+
+// Get возвращает заказ с данными книг. Покупатель видит только свои
+// заказы, админ — любые.
+func (s *OrderServiceImpl) Get(ctx context.Context, orderID int, actor string, isAdmin bool) (*domain.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	if !isAdmin && order.UserID != actor {
+		return nil, fmt.Errorf("order not found: %w", errors.New("order belongs to another user"))
+	}
+	for i := range order.Items {
+		// Удалённая книга не мешает показать заказ: цена и количество в позиции
+		if book, err := s.bookRepo.GetByID(ctx, order.Items[i].BookID); err == nil {
+			order.Items[i].Book = book
+		}
 	}
 	return order, nil
 }
 
-func (s *OrderServiceImpl) ListByUser(ctx context.Context, userID string) ([]*domain.Order, error) {
-	orders, err := s.orderRepo.ListByUser(ctx, userID)
+func (s *OrderServiceImpl) ListByUser(ctx context.Context, userID string, page domain.PageRequest) (*domain.OrderList, error) {
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	page.Limit = limit + 1
+	orders, err := s.orderRepo.ListByUser(ctx, userID, page)
 	if err != nil {
 		return nil, fmt.Errorf("list orders: %w", err)
 	}
-	return orders, nil
+	list := &domain.OrderList{Items: orders}
+	if list.Items == nil {
+		list.Items = make([]*domain.Order, 0)
+	}
+	if len(orders) > limit {
+		list.Items = orders[:limit]
+		list.Next = &domain.Cursor{ID: orders[limit-1].ID}
+	}
+	if page.WithTotal {
+		total, err := s.orderRepo.CountByUser(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("count orders: %w", err)
+		}
+		list.Total = &total
+	}
+	return list, nil
+}
+
+// Transition меняет статус заказа, если переход разрешён domain.CanTransition.
+func (s *OrderServiceImpl) Transition(ctx context.Context, orderID int, to, actor, reason string) (*domain.Order, error) {
+	if !domain.ValidOrderStatus(to) {
+		return nil, fmt.Errorf("invalid status: %w", fmt.Errorf("unknown status %q", to))
+	}
+	if to == domain.OrderCancelled {
+		// Отмена возвращает книги на склад — только через Cancel
+		return s.Cancel(ctx, orderID, actor, true, reason)
+	}
+	if to == domain.OrderPaid {
+		return nil, fmt.Errorf("illegal transition: %w", errors.New("orders become paid only when a payment is captured"))
+	}
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	if !domain.CanTransition(order.Status, to) {
+		return nil, fmt.Errorf("illegal transition: %w", fmt.Errorf("%s -> %s", order.Status, to))
+	}
+	t, err := s.orderRepo.UpdateStatus(ctx, orderID, order.Status, to, actor, strings.TrimSpace(reason))
+	if err != nil {
+		return nil, fmt.Errorf("update order status: %w", err)
+	}
+	if to == domain.OrderRefunded {
+		s.settleOrderPayment(ctx, orderID)
+	}
+	order.Status = to
+	order.UpdatedAt = t.CreatedAt
+	return order, nil
+}
+
+// Cancel отменяет заказ и возвращает книги на склад. Покупатель может
+// отменить только свой заказ и только в статусе pending, админ — в любом
+// статусе, из которого разрешён переход в cancelled.
+func (s *OrderServiceImpl) Cancel(ctx context.Context, orderID int, actor string, isAdmin bool, reason string) (*domain.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	if !isAdmin {
+		if order.UserID != actor {
+			// Чужие заказы не раскрываем
+			return nil, fmt.Errorf("order not found: %w", errors.New("order belongs to another user"))
+		}
+		if order.Status != domain.OrderPending {
+			return nil, fmt.Errorf("order cannot be cancelled: %w", fmt.Errorf("status is %s", order.Status))
+		}
+	}
+	if !domain.CanTransition(order.Status, domain.OrderCancelled) {
+		return nil, fmt.Errorf("illegal transition: %w", fmt.Errorf("%s -> %s", order.Status, domain.OrderCancelled))
+	}
+	t, err := s.orderRepo.Cancel(ctx, orderID, order.Status, actor, strings.TrimSpace(reason))
+	if err != nil {
+		return nil, fmt.Errorf("cancel order: %w", err)
+	}
+	// Деньги за оплаченный заказ возвращаются, а блокировка на карте за
+	// неоплаченный снимается только после отмены: если заказ успели
+	// оплатить, отмена не пройдёт и платёж останется нетронутым
+	s.settleOrderPayment(ctx, orderID)
+	order.Status = domain.OrderCancelled
+	order.UpdatedAt = t.CreatedAt
+	return order, nil
+}
+
+func (s *OrderServiceImpl) Transitions(ctx context.Context, orderID int) ([]*domain.OrderTransition, error) {
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	transitions, err := s.orderRepo.ListTransitions(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("list transitions: %w", err)
+	}
+	return transitions, nil
 }
 
 func (s *OrderServiceImpl) ListItems(ctx context.Context, userID string) ([]*domain.CartItem, error) {
@@ -2,64 +2,406 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/yourorg/bookshop/internal/domain"
-	"github.com/yourorg/bookshop/internal/integration"
 	"github.com/yourorg/bookshop/internal/mocks"
+	"golang.org/x/exp/slog"
 )
 
+// testPlaceOrder — запрос с корректными данными доставки по России.
+func testPlaceOrder() domain.PlaceOrderRequest {
+	return domain.PlaceOrderRequest{
+		ShippingAddress: &domain.ShippingAddress{Recipient: "Иван Петров", Country: "RU", City: "Москва", PostalCode: "101000", Street: "ул. Мясницкая, 1"},
+		Phone:           "+79161234567",
+	}
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// noDiscount — скидка при оформлении для корзины без промокода.
+func noDiscount() *mocks.CheckoutDiscounter {
+	discounts := new(mocks.CheckoutDiscounter)
+	discounts.On("CheckoutDiscount", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil, nil).Maybe()
+	return discounts
+}
+
 func TestOrderService_Create_Success(t *testing.T) {
 	orderRepo := new(mocks.OrderRepository)
 	cartRepo := new(mocks.CartRepository)
 	bookRepo := new(mocks.BookRepository)
-	kafka := new(mocks.KafkaProducer)
-	redis := new(mocks.RedisCache)
+	reservations := new(mocks.ReservationRepository)
 
 	userID := "user-1"
 	// cartItems := []*domain.CartItem{{ID: 1, BookID: 42}}
 
-	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 1, Price: 10.0}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 1, Price: domain.NewMoney(1000)}, nil)
 	orderRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil)
-	cartRepo.On("Clear", mock.Anything, userID).Return(nil)
-	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{{ID: 1, BookID: 42, Quantity: 1}}, nil)
-	kafka.On("PublishOrderPlaced", mock.Anything, mock.Anything, userID, []integration.OrderPlacedBook{{BookID: 42, Quantity: 1}}).Return(nil)
-	redis.On("Del", "reserve:user-1:42").Return(nil)
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{{ID: 1, BookID: 42, Quantity: 1, PriceSnapshot: domain.NewMoney(1000)}}, nil)
+	reservations.On("Release", mock.Anything, 42, userID).Return(nil)
 
-	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, kafka, redis}
-	res, err := svc.Create(context.Background(), userID)
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, reservations, new(mocks.PaymentRepository), new(mocks.PaymentProvider), noDiscount(), testTaxes(), testLogger()}
+	res, err := svc.Create(context.Background(), userID, testPlaceOrder())
 	require.NoError(t, err)
 	assert.NotNil(t, res)
 	orderRepo.AssertExpectations(t)
 	cartRepo.AssertExpectations(t)
-	kafka.AssertExpectations(t)
-	redis.AssertExpectations(t)
+	reservations.AssertExpectations(t)
 }
 
 func TestOrderService_Create_RemovesReserves(t *testing.T) {
 	orderRepo := new(mocks.OrderRepository)
 	cartRepo := new(mocks.CartRepository)
 	bookRepo := new(mocks.BookRepository)
-	kafka := new(mocks.KafkaProducer)
-	redis := new(mocks.RedisCache)
+	reservations := new(mocks.ReservationRepository)
 
 	userID := "user-1"
-	items := []*domain.CartItem{{BookID: 42, Quantity: 1}, {BookID: 43, Quantity: 2}}
+	items := []*domain.CartItem{{BookID: 42, Quantity: 1, PriceSnapshot: domain.NewMoney(1000)}, {BookID: 43, Quantity: 2, PriceSnapshot: domain.NewMoney(2000)}}
 
-	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 10, Price: 10.0}, nil)
-	bookRepo.On("GetByID", mock.Anything, 43).Return(&domain.Book{ID: 43, Inventory: 10, Price: 20.0}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 10, Price: domain.NewMoney(1000)}, nil)
+	bookRepo.On("GetByID", mock.Anything, 43).Return(&domain.Book{ID: 43, Inventory: 10, Price: domain.NewMoney(2000)}, nil)
 	orderRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil)
-	cartRepo.On("Clear", mock.Anything, userID).Return(nil)
 	cartRepo.On("ListItems", mock.Anything, userID).Return(items, nil)
-	kafka.On("PublishOrderPlaced", mock.Anything, mock.Anything, userID, mock.Anything).Return(nil)
-	redis.On("Del", "reserve:user-1:42").Return(nil)
-	redis.On("Del", "reserve:user-1:43").Return(nil)
+	reservations.On("Release", mock.Anything, 42, userID).Return(nil)
+	reservations.On("Release", mock.Anything, 43, userID).Return(nil)
+
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, reservations, new(mocks.PaymentRepository), new(mocks.PaymentProvider), noDiscount(), testTaxes(), testLogger()}
+	order, err := svc.Create(context.Background(), userID, testPlaceOrder())
+	require.NoError(t, err)
+	require.Equal(t, domain.NewMoney(5000), order.Subtotal)
+	// Доставка по России 300 ₽, бесплатно от 3000 ₽
+	require.Equal(t, domain.NewMoney(30000), order.Shipping)
+	require.Equal(t, domain.NewMoney(35000), order.Total)
+	reservations.AssertExpectations(t)
+}
+
+func TestOrderService_Create_OutOfStock(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	userID := "user-1"
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{{BookID: 42, Quantity: 3, PriceSnapshot: domain.NewMoney(1000)}}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 5, Price: domain.NewMoney(1000)}, nil)
+	outOfStock := &domain.OutOfStockError{Items: []domain.OutOfStockItem{{BookID: 42, Requested: 3, Available: 1}}}
+	orderRepo.On("Create", mock.Anything, mock.MatchedBy(func(o *domain.Order) bool {
+		return len(o.Items) == 1 && o.Items[0].Quantity == 3
+	})).Return(outOfStock)
+
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, reservations, new(mocks.PaymentRepository), new(mocks.PaymentProvider), noDiscount(), testTaxes(), testLogger()}
+	_, err := svc.Create(context.Background(), userID, testPlaceOrder())
+	var target *domain.OutOfStockError
+	require.ErrorAs(t, err, &target)
+	assert.Equal(t, 42, target.Items[0].BookID)
+}
+
+func TestOrderService_Create_StaleCartRejected(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+
+	userID := "user-1"
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{
+		{BookID: 42, Quantity: 1, PriceSnapshot: domain.NewMoney(1000)},
+		{BookID: 43, Quantity: 1, PriceSnapshot: domain.NewMoney(2000)},
+	}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 5, Price: domain.NewMoney(1250)}, nil)
+	bookRepo.On("GetByID", mock.Anything, 43).Return(&domain.Book{ID: 43, Inventory: 5, Price: domain.NewMoney(2000)}, nil)
+
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, new(mocks.ReservationRepository), new(mocks.PaymentRepository), new(mocks.PaymentProvider), noDiscount(), testTaxes(), testLogger()}
+	_, err := svc.Create(context.Background(), userID, testPlaceOrder())
+	var stale *domain.StaleCartError
+	require.ErrorAs(t, err, &stale)
+	assert.Equal(t, []domain.PriceChange{{BookID: 42, PriceSnapshot: domain.NewMoney(1000), Price: domain.NewMoney(1250)}}, stale.Items)
+	orderRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestOrderService_Create_StaleCartConfirmed(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	userID := "user-1"
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{{BookID: 42, Quantity: 2, PriceSnapshot: domain.NewMoney(1000)}}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 5, Price: domain.NewMoney(1250)}, nil)
+	// Заказ оформляется по новой цене
+	orderRepo.On("Create", mock.Anything, mock.MatchedBy(func(o *domain.Order) bool {
+		return len(o.Items) == 1 && o.Items[0].Price == domain.NewMoney(1250)
+	})).Return(nil)
+	reservations.On("Release", mock.Anything, 42, userID).Return(nil)
 
-	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, kafka, redis}
-	_, err := svc.Create(context.Background(), userID)
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, reservations, new(mocks.PaymentRepository), new(mocks.PaymentProvider), noDiscount(), testTaxes(), testLogger()}
+	req := testPlaceOrder()
+	req.ConfirmPrices = true
+	_, err := svc.Create(context.Background(), userID, req)
 	require.NoError(t, err)
-	redis.AssertExpectations(t)
+	orderRepo.AssertExpectations(t)
+}
+
+func TestOrderService_Create_InvalidShipping(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	cartRepo := new(mocks.CartRepository)
+	svc := &OrderServiceImpl{orderRepo, cartRepo, new(mocks.BookRepository), new(mocks.ReservationRepository), new(mocks.PaymentRepository), new(mocks.PaymentProvider), noDiscount(), testTaxes(), testLogger()}
+
+	req := testPlaceOrder()
+	req.ShippingAddress.PostalCode = "1010"
+	req.Phone = "+375 29 123-45-67"
+	_, err := svc.Create(context.Background(), "user-1", req)
+	var invalid *domain.ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []domain.FieldError{
+		{Field: "shipping_address.postal_code", Reason: "invalid format for RU"},
+		{Field: "phone", Reason: "invalid format for RU"},
+	}, invalid.Fields)
+	cartRepo.AssertNotCalled(t, "ListItems", mock.Anything, mock.Anything)
+}
+
+func TestOrderService_Create_StoresNormalizedShipping(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+
+	userID := "user-1"
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{{BookID: 42, Quantity: 2, PriceSnapshot: domain.NewMoney(150000)}}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 5, Price: domain.NewMoney(150000)}, nil)
+	orderRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil)
+	reservations.On("Release", mock.Anything, 42, userID).Return(nil)
+
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, reservations, new(mocks.PaymentRepository), new(mocks.PaymentProvider), noDiscount(), testTaxes(), testLogger()}
+	req := testPlaceOrder()
+	req.ShippingAddress.Country = " ru "
+	req.Phone = "+7 (916) 123-45-67"
+	order, err := svc.Create(context.Background(), userID, req)
+	require.NoError(t, err)
+	assert.Equal(t, "RU", order.ShippingAddress.Country)
+	assert.Equal(t, "+79161234567", order.Phone)
+	// Заказ от 3000 ₽ доставляется бесплатно
+	assert.Equal(t, domain.NewMoney(300000), order.Subtotal)
+	assert.Equal(t, domain.NewMoney(0), order.Shipping)
+	assert.Equal(t, domain.NewMoney(300000), order.Total)
+	// Запрос вызывающего не меняется
+	assert.Equal(t, " ru ", req.ShippingAddress.Country)
+}
+
+func TestOrderService_Create_PersistsPromoDiscount(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+	discounts := new(mocks.CheckoutDiscounter)
+
+	userID := "user-1"
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{
+		{BookID: 42, Quantity: 2, PriceSnapshot: domain.NewMoney(100000)},
+		{BookID: 43, Quantity: 1, PriceSnapshot: domain.NewMoney(100000)},
+	}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 5, Price: domain.NewMoney(100000)}, nil)
+	bookRepo.On("GetByID", mock.Anything, 43).Return(&domain.Book{ID: 43, Inventory: 5, Price: domain.NewMoney(100000)}, nil)
+	discounts.On("CheckoutDiscount", mock.Anything, userID, []domain.PromoLine{
+		{BookID: 42, Total: domain.NewMoney(200000)},
+		{BookID: 43, Total: domain.NewMoney(100000)},
+	}).Return(&domain.PromoCode{ID: 3, Code: "SALE10", Kind: domain.PromoPercent, Percent: 10, Active: true}, []domain.Money{domain.NewMoney(20000), domain.NewMoney(10000)}, nil)
+	orderRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil)
+	reservations.On("Release", mock.Anything, mock.Anything, userID).Return(nil)
+
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, reservations, new(mocks.PaymentRepository), new(mocks.PaymentProvider), discounts, testTaxes(), testLogger()}
+	order, err := svc.Create(context.Background(), userID, testPlaceOrder())
+	require.NoError(t, err)
+	assert.Equal(t, "SALE10", order.PromoCode)
+	assert.Equal(t, domain.NewMoney(20000), order.Items[0].Discount)
+	assert.Equal(t, domain.NewMoney(10000), order.Items[1].Discount)
+	assert.Equal(t, domain.NewMoney(30000), order.Discount)
+	// 3000 ₽ со скидкой — уже 2700 ₽: бесплатная доставка считается от суммы со скидкой
+	assert.Equal(t, domain.NewMoney(300000), order.Subtotal)
+	assert.False(t, order.Shipping.IsZero())
+	assert.Equal(t, domain.NewMoney(270000).Add(order.Shipping), order.Total)
+}
+
+func TestOrderService_Create_PersistsLineTaxes(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	reservations := new(mocks.ReservationRepository)
+	taxRepo := new(mocks.TaxRepository)
+
+	userID := "user-1"
+	fiction := 7
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{
+		{BookID: 42, Quantity: 1, PriceSnapshot: domain.NewMoney(120000)},
+		{BookID: 43, Quantity: 2, PriceSnapshot: domain.NewMoney(55000)},
+	}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, CategoryID: fiction, Inventory: 5, Price: domain.NewMoney(120000)}, nil)
+	bookRepo.On("GetByID", mock.Anything, 43).Return(&domain.Book{ID: 43, CategoryID: 1, Inventory: 5, Price: domain.NewMoney(55000)}, nil)
+	// Ставки берутся по стране адреса доставки, а не по стране по умолчанию
+	taxRepo.On("Effective", mock.Anything, "RU", mock.Anything).Return([]*domain.TaxRate{
+		{Country: "RU", Rate: 1000},
+		{Country: "RU", CategoryID: &fiction, Rate: 2000},
+	}, nil).Once()
+	orderRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil)
+	reservations.On("Release", mock.Anything, mock.Anything, userID).Return(nil)
+
+	taxes := NewTaxService(taxRepo, domain.TaxRegion{Country: "KZ"})
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, reservations, new(mocks.PaymentRepository), new(mocks.PaymentProvider), noDiscount(), taxes, testLogger()}
+	order, err := svc.Create(context.Background(), userID, testPlaceOrder())
+	require.NoError(t, err)
+	assert.Equal(t, domain.Percent(2000), order.Items[0].TaxRate)
+	assert.Equal(t, domain.NewMoney(20000), order.Items[0].Tax)
+	assert.Equal(t, domain.Percent(1000), order.Items[1].TaxRate)
+	assert.Equal(t, domain.NewMoney(10000), order.Items[1].Tax)
+	assert.Equal(t, domain.NewMoney(30000), order.Tax)
+	// НДС уже в цене: к итогу не добавляется
+	assert.Equal(t, order.Subtotal.Add(order.Shipping), order.Total)
+	taxRepo.AssertExpectations(t)
+}
+
+func TestOrderService_Create_PromoNoLongerAppliesRejected(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	cartRepo := new(mocks.CartRepository)
+	bookRepo := new(mocks.BookRepository)
+	discounts := new(mocks.CheckoutDiscounter)
+
+	userID := "user-1"
+	cartRepo.On("ListItems", mock.Anything, userID).Return([]*domain.CartItem{{BookID: 42, Quantity: 1, PriceSnapshot: domain.NewMoney(100000)}}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Inventory: 5, Price: domain.NewMoney(100000)}, nil)
+	discounts.On("CheckoutDiscount", mock.Anything, userID, mock.Anything).Return(nil, nil, &domain.PromoError{Code: "GONE", Reason: domain.PromoInactive})
+
+	svc := &OrderServiceImpl{orderRepo, cartRepo, bookRepo, new(mocks.ReservationRepository), new(mocks.PaymentRepository), new(mocks.PaymentProvider), discounts, testTaxes(), testLogger()}
+	_, err := svc.Create(context.Background(), userID, testPlaceOrder())
+	var promoErr *domain.PromoError
+	require.True(t, errors.As(err, &promoErr))
+	assert.Equal(t, domain.PromoInactive, promoErr.Reason)
+	orderRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestOrderService_Get_OwnerAndAdmin(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	bookRepo := new(mocks.BookRepository)
+	orderRepo.On("GetByID", mock.Anything, 5).Return(&domain.Order{ID: 5, UserID: "user-1", Items: []domain.OrderItem{{BookID: 42}, {BookID: 43}}}, nil)
+	bookRepo.On("GetByID", mock.Anything, 42).Return(&domain.Book{ID: 42, Title: "Go"}, nil)
+	bookRepo.On("GetByID", mock.Anything, 43).Return(nil, errors.New("no rows"))
+	svc := &OrderServiceImpl{orderRepo, new(mocks.CartRepository), bookRepo, new(mocks.ReservationRepository), new(mocks.PaymentRepository), new(mocks.PaymentProvider), nil, nil, testLogger()}
+
+	order, err := svc.Get(context.Background(), 5, "user-1", false)
+	require.NoError(t, err)
+	assert.Equal(t, "Go", order.Items[0].Book.Title)
+	assert.Nil(t, order.Items[1].Book)
+
+	_, err = svc.Get(context.Background(), 5, "user-2", false)
+	require.ErrorContains(t, err, "order not found")
+	_, err = svc.Get(context.Background(), 5, "admin", true)
+	require.NoError(t, err)
+}
+
+func TestOrderService_Transition_Success(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	changedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	orderRepo.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPaid}, nil)
+	orderRepo.On("UpdateStatus", mock.Anything, 7, domain.OrderPaid, domain.OrderShipped, "admin-1", "трек 123").
+		Return(&domain.OrderTransition{ID: 3, OrderID: 7, From: domain.OrderPaid, To: domain.OrderShipped, Actor: "admin-1", Reason: "трек 123", CreatedAt: changedAt}, nil)
+
+	svc := NewOrderService(orderRepo, new(mocks.CartRepository), new(mocks.BookRepository), new(mocks.ReservationRepository), new(mocks.PaymentRepository), new(mocks.PaymentProvider), nil, nil, testLogger())
+	order, err := svc.Transition(context.Background(), 7, domain.OrderShipped, "admin-1", " трек 123 ")
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderShipped, order.Status)
+	assert.Equal(t, changedAt, order.UpdatedAt)
+	orderRepo.AssertExpectations(t)
+}
+
+func TestOrderService_Transition_Rejected(t *testing.T) {
+	cases := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr string
+	}{
+		{"unknown status", domain.OrderPending, "lost", "invalid status"},
+		{"skip payment", domain.OrderPending, domain.OrderShipped, "illegal transition"},
+		{"paid without capture", domain.OrderPending, domain.OrderPaid, "illegal transition"},
+		{"from terminal", domain.OrderCancelled, domain.OrderPaid, "illegal transition"},
+		{"back in time", domain.OrderDelivered, domain.OrderShipped, "illegal transition"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			orderRepo := new(mocks.OrderRepository)
+			orderRepo.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, Status: tc.from}, nil)
+			svc := NewOrderService(orderRepo, new(mocks.CartRepository), new(mocks.BookRepository), new(mocks.ReservationRepository), new(mocks.PaymentRepository), new(mocks.PaymentProvider), nil, nil, testLogger())
+			_, err := svc.Transition(context.Background(), 7, tc.to, "admin-1", "")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+			orderRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOrderService_Cancel_CustomerPending(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	order := &domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPending, Items: []domain.OrderItem{{BookID: 42, Quantity: 2}}}
+
+	orderRepo.On("GetByID", mock.Anything, 7).Return(order, nil)
+	orderRepo.On("Cancel", mock.Anything, 7, domain.OrderPending, "user-1", "передумал").
+		Return(&domain.OrderTransition{OrderID: 7, From: domain.OrderPending, To: domain.OrderCancelled, Actor: "user-1", Reason: "передумал"}, nil)
+	payments := new(mocks.PaymentRepository)
+	payments.On("GetActiveByOrder", mock.Anything, 7).Return(nil, fmt.Errorf("get payment: %w", pgx.ErrNoRows))
+
+	svc := NewOrderService(orderRepo, new(mocks.CartRepository), new(mocks.BookRepository), new(mocks.ReservationRepository), payments, new(mocks.PaymentProvider), nil, nil, testLogger())
+	res, err := svc.Cancel(context.Background(), 7, "user-1", false, "передумал")
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderCancelled, res.Status)
+	orderRepo.AssertExpectations(t)
+}
+
+func TestOrderService_Cancel_Rejected(t *testing.T) {
+	cases := []struct {
+		name    string
+		order   *domain.Order
+		actor   string
+		isAdmin bool
+		wantErr string
+	}{
+		{"customer after payment", &domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPaid}, "user-1", false, "order cannot be cancelled"},
+		{"someone else's order", &domain.Order{ID: 7, UserID: "user-2", Status: domain.OrderPending}, "user-1", false, "order not found"},
+		{"admin after delivery", &domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderDelivered}, "admin-1", true, "illegal transition"},
+		{"already cancelled", &domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderCancelled}, "admin-1", true, "illegal transition"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			orderRepo := new(mocks.OrderRepository)
+			orderRepo.On("GetByID", mock.Anything, 7).Return(tc.order, nil)
+			svc := NewOrderService(orderRepo, new(mocks.CartRepository), new(mocks.BookRepository), new(mocks.ReservationRepository), new(mocks.PaymentRepository), new(mocks.PaymentProvider), nil, nil, testLogger())
+			_, err := svc.Cancel(context.Background(), 7, tc.actor, tc.isAdmin, "")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+			orderRepo.AssertNotCalled(t, "Cancel", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOrderService_Transition_CancelRestoresStock(t *testing.T) {
+	orderRepo := new(mocks.OrderRepository)
+	orderRepo.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderShipped}, nil)
+	orderRepo.On("Cancel", mock.Anything, 7, domain.OrderShipped, "admin-1", "потеряна почтой").
+		Return(&domain.OrderTransition{OrderID: 7, From: domain.OrderShipped, To: domain.OrderCancelled}, nil)
+	// Заказ оплачен до появления платежей — возвращать через провайдера нечего
+	payments := new(mocks.PaymentRepository)
+	payments.On("GetActiveByOrder", mock.Anything, 7).Return(nil, fmt.Errorf("get payment: %w", pgx.ErrNoRows))
+
+	svc := NewOrderService(orderRepo, new(mocks.CartRepository), new(mocks.BookRepository), new(mocks.ReservationRepository), payments, new(mocks.PaymentProvider), nil, nil, testLogger())
+	_, err := svc.Transition(context.Background(), 7, domain.OrderCancelled, "admin-1", "потеряна почтой")
+	require.NoError(t, err)
+	orderRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	orderRepo.AssertExpectations(t)
 }
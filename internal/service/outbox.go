@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+	"github.com/yourorg/bookshop/internal/repository"
+	"golang.org/x/exp/slog"
+)
+
+type OutboxRelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Lease — на сколько забранные события скрываются от других экземпляров.
+	Lease      time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// OutboxRelay публикует события из outbox в Kafka. Доставка «хотя бы
+// один раз»: если процесс упадёт между публикацией и MarkSent, событие
+// уйдёт повторно, поэтому потребители дедуплицируют по order_id и типу.
+type OutboxRelay struct {
+	repo   repository.OutboxRepository
+	kafka  integration.KafkaProducer
+	logger *slog.Logger
+	cfg    OutboxRelayConfig
+}
+
+func NewOutboxRelay(repo repository.OutboxRepository, kafka integration.KafkaProducer, logger *slog.Logger, cfg OutboxRelayConfig) *OutboxRelay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 30 * time.Second
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	return &OutboxRelay{repo: repo, kafka: kafka, logger: logger, cfg: cfg}
+}
+
+// Run публикует события, пока не отменён ctx.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		n, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("outbox relay failed", "err", err)
+		}
+		// Полная пачка — скорее всего, есть ещё события, не ждём
+		if err == nil && n == r.cfg.BatchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(r.cfg.PollInterval)
+		}
+	}
+}
+
+// RelayOnce обрабатывает одну пачку событий и возвращает их количество.
+// На первой ошибке Kafka пачка прерывается, чтобы не отправлять следующие
+// события раньше неудавшегося.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	msgs, err := r.repo.Claim(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("claim outbox: %w", err)
+	}
+	for i, m := range msgs {
+		publishErr := r.publish(ctx, m)
+		if publishErr == nil {
+			if err := r.repo.MarkSent(ctx, m.ID); err != nil {
+				return len(msgs), err
+			}
+			continue
+		}
+		backoff := r.backoff(m.Attempts)
+		r.logger.Warn("outbox publish failed", "id", m.ID, "type", m.Type, "attempt", m.Attempts+1, "retry_in", backoff, "err", publishErr)
+		if err := r.repo.MarkFailed(ctx, m.ID, backoff, publishErr.Error()); err != nil {
+			return len(msgs), err
+		}
+		if _, ok := publishErr.(*malformedEventError); ok {
+			continue
+		}
+		// Остаток пачки вернётся в очередь одновременно с упавшим событием
+		var rest []int64
+		for _, m := range msgs[i+1:] {
+			rest = append(rest, m.ID)
+		}
+		if len(rest) > 0 {
+			if err := r.repo.Release(ctx, rest, backoff); err != nil {
+				return len(msgs), err
+			}
+		}
+		break
+	}
+	return len(msgs), nil
+}
+
+// malformedEventError — событие, которое нельзя отправить ни с какой
+// попытки. Оно не останавливает пачку.
+type malformedEventError struct{ err error }
+
+func (e *malformedEventError) Error() string { return e.err.Error() }
+
+func (r *OutboxRelay) publish(ctx context.Context, m *domain.OutboxMessage) error {
+	switch m.Type {
+	case domain.EventOrderPlaced:
+		var evt domain.OrderPlacedEvent
+		if err := json.Unmarshal(m.Payload, &evt); err != nil {
+			return &malformedEventError{fmt.Errorf("decode %s: %w", m.Type, err)}
+		}
+		return r.kafka.PublishOrderPlaced(ctx, evt.OrderID, evt.UserID, evt.Books)
+	case domain.EventOrderStatusChanged:
+		var evt domain.OrderStatusChangedEvent
+		if err := json.Unmarshal(m.Payload, &evt); err != nil {
+			return &malformedEventError{fmt.Errorf("decode %s: %w", m.Type, err)}
+		}
+		return r.kafka.PublishOrderStatusChanged(ctx, evt)
+	case domain.EventOrderCancelled:
+		var evt domain.OrderCancelledEvent
+		if err := json.Unmarshal(m.Payload, &evt); err != nil {
+			return &malformedEventError{fmt.Errorf("decode %s: %w", m.Type, err)}
+		}
+		return r.kafka.PublishOrderCancelled(ctx, evt)
+	case domain.EventReturnRequested, domain.EventReturnApproved, domain.EventReturnRejected, domain.EventReturnRefunded:
+		var evt domain.ReturnEvent
+		if err := json.Unmarshal(m.Payload, &evt); err != nil {
+			return &malformedEventError{fmt.Errorf("decode %s: %w", m.Type, err)}
+		}
+		return r.kafka.PublishReturnEvent(ctx, evt)
+	}
+	return &malformedEventError{fmt.Errorf("unknown event type %q", m.Type)}
+}
+
+// backoff растёт вдвое с каждой попыткой от MinBackoff до MaxBackoff.
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	d := r.cfg.MinBackoff
+	for i := 0; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+	return d
+}
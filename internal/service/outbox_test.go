@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+	"golang.org/x/exp/slog"
+)
+
+// memOutbox — outbox в памяти с той же семантикой Claim/MarkSent/MarkFailed.
+type memOutbox struct {
+	mu     sync.Mutex
+	now    time.Time
+	msgs   []*domain.OutboxMessage
+	sent   map[int64]bool
+	notBef map[int64]time.Time
+	errs   map[int64]string
+}
+
+func newMemOutbox() *memOutbox {
+	return &memOutbox{now: time.Unix(0, 0), sent: map[int64]bool{}, notBef: map[int64]time.Time{}, errs: map[int64]string{}}
+}
+
+func (o *memOutbox) add(t *testing.T, eventType string, event interface{}) {
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.msgs = append(o.msgs, &domain.OutboxMessage{ID: int64(len(o.msgs) + 1), Type: eventType, Payload: payload})
+}
+
+func (o *memOutbox) advance(d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.now = o.now.Add(d)
+}
+
+func (o *memOutbox) pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.msgs) - len(o.sent)
+}
+
+func (o *memOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var res []*domain.OutboxMessage
+	for _, m := range o.msgs {
+		if len(res) == limit {
+			break
+		}
+		if o.sent[m.ID] || o.notBef[m.ID].After(o.now) {
+			continue
+		}
+		o.notBef[m.ID] = o.now.Add(lease)
+		c := *m
+		res = append(res, &c)
+	}
+	return res, nil
+}
+
+func (o *memOutbox) MarkSent(ctx context.Context, id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent[id] = true
+	return nil
+}
+
+func (o *memOutbox) Release(ctx context.Context, ids []int64, delay time.Duration) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, id := range ids {
+		o.notBef[id] = o.now.Add(delay)
+	}
+	return nil
+}
+
+func (o *memOutbox) MarkFailed(ctx context.Context, id int64, backoff time.Duration, lastErr string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.msgs[id-1].Attempts++
+	o.notBef[id] = o.now.Add(backoff)
+	o.errs[id] = lastErr
+	return nil
+}
+
+// memProducer записывает опубликованные события; первые failures вызовов
+// завершаются ошибкой, как при недоступной Kafka.
+type memProducer struct {
+	mu        sync.Mutex
+	failures  int
+	placed    []domain.OrderPlacedEvent
+	changed   []domain.OrderStatusChangedEvent
+	cancelled []domain.OrderCancelledEvent
+	returns   []domain.ReturnEvent
+}
+
+var errKafkaDown = errors.New("kafka: connection refused")
+
+func (p *memProducer) fail() bool {
+	if p.failures > 0 {
+		p.failures--
+		return true
+	}
+	return false
+}
+
+func (p *memProducer) PublishOrderPlaced(ctx context.Context, orderID int, userID string, books []integration.OrderPlacedBook) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail() {
+		return errKafkaDown
+	}
+	p.placed = append(p.placed, domain.OrderPlacedEvent{OrderID: orderID, UserID: userID, Books: books})
+	return nil
+}
+
+func (p *memProducer) PublishOrderStatusChanged(ctx context.Context, evt domain.OrderStatusChangedEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail() {
+		return errKafkaDown
+	}
+	p.changed = append(p.changed, evt)
+	return nil
+}
+
+func (p *memProducer) PublishOrderCancelled(ctx context.Context, evt domain.OrderCancelledEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail() {
+		return errKafkaDown
+	}
+	p.cancelled = append(p.cancelled, evt)
+	return nil
+}
+
+func (p *memProducer) PublishReturnEvent(ctx context.Context, evt domain.ReturnEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail() {
+		return errKafkaDown
+	}
+	p.returns = append(p.returns, evt)
+	return nil
+}
+
+func newTestRelay(outbox *memOutbox, producer *memProducer) *OutboxRelay {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewOutboxRelay(outbox, producer, logger, OutboxRelayConfig{BatchSize: 10, MinBackoff: time.Second, MaxBackoff: 8 * time.Second})
+}
+
+func TestOutboxRelay_PublishesAllEventTypes(t *testing.T) {
+	outbox := newMemOutbox()
+	outbox.add(t, domain.EventOrderPlaced, domain.OrderPlacedEvent{OrderID: 1, UserID: "user-1", Books: []domain.OrderEventBook{{BookID: 42, Quantity: 2}}})
+	outbox.add(t, domain.EventOrderStatusChanged, domain.OrderStatusChangedEvent{OrderID: 1, From: domain.OrderPending, To: domain.OrderCancelled})
+	outbox.add(t, domain.EventOrderCancelled, domain.OrderCancelledEvent{OrderID: 1, Books: []domain.OrderEventBook{{BookID: 42, Quantity: 2}}})
+	outbox.add(t, domain.EventReturnApproved, domain.ReturnEvent{ReturnID: 3, OrderID: 1, Status: domain.ReturnApproved, RefundAmount: domain.NewMoney(50000)})
+	producer := &memProducer{}
+
+	n, err := newTestRelay(outbox, producer).RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, 0, outbox.pending())
+	require.Len(t, producer.placed, 1)
+	assert.Equal(t, []integration.OrderPlacedBook{{BookID: 42, Quantity: 2}}, producer.placed[0].Books)
+	require.Len(t, producer.changed, 1)
+	assert.Equal(t, domain.OrderCancelled, producer.changed[0].To)
+	require.Len(t, producer.cancelled, 1)
+	require.Len(t, producer.returns, 1)
+	assert.Equal(t, domain.NewMoney(50000), producer.returns[0].RefundAmount)
+}
+
+func TestOutboxRelay_RetriesWithBackoffWhenKafkaIsDown(t *testing.T) {
+	outbox := newMemOutbox()
+	outbox.add(t, domain.EventOrderPlaced, domain.OrderPlacedEvent{OrderID: 1, UserID: "user-1"})
+	outbox.add(t, domain.EventOrderPlaced, domain.OrderPlacedEvent{OrderID: 2, UserID: "user-1"})
+	producer := &memProducer{failures: 2}
+	relay := newTestRelay(outbox, producer)
+	ctx := context.Background()
+
+	// Первая попытка падает, и пачка прерывается: второе событие не обгоняет первое
+	_, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, producer.placed)
+	assert.Equal(t, 2, outbox.pending())
+	assert.Contains(t, outbox.errs[1], "connection refused")
+
+	// До истечения backoff события не берутся повторно
+	outbox.advance(500 * time.Millisecond)
+	relay.RelayOnce(ctx)
+	assert.Equal(t, 1, outbox.msgs[0].Attempts)
+
+	// Вторая неудача — backoff удваивается до 2s
+	outbox.advance(500 * time.Millisecond)
+	relay.RelayOnce(ctx)
+	assert.Equal(t, 2, outbox.msgs[0].Attempts)
+	assert.Equal(t, 0, outbox.msgs[1].Attempts)
+	outbox.advance(time.Second)
+	relay.RelayOnce(ctx)
+	assert.Empty(t, producer.placed)
+
+	outbox.advance(time.Second)
+	_, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, outbox.pending())
+	require.Len(t, producer.placed, 2)
+	assert.Equal(t, 1, producer.placed[0].OrderID)
+	assert.Equal(t, 2, producer.placed[1].OrderID)
+}
+
+func TestOutboxRelay_MalformedEventDoesNotBlockQueue(t *testing.T) {
+	outbox := newMemOutbox()
+	outbox.add(t, "order_lost", map[string]int{"order_id": 1})
+	outbox.add(t, domain.EventOrderPlaced, domain.OrderPlacedEvent{OrderID: 2, UserID: "user-1"})
+	producer := &memProducer{}
+
+	_, err := newTestRelay(outbox, producer).RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, outbox.errs[1], "unknown event type")
+	require.Len(t, producer.placed, 1)
+	assert.Equal(t, 2, producer.placed[0].OrderID)
+}
+
+func TestOutboxRelay_Backoff(t *testing.T) {
+	relay := newTestRelay(newMemOutbox(), &memProducer{})
+	assert.Equal(t, time.Second, relay.backoff(0))
+	assert.Equal(t, 2*time.Second, relay.backoff(1))
+	assert.Equal(t, 4*time.Second, relay.backoff(2))
+	assert.Equal(t, 8*time.Second, relay.backoff(3))
+	assert.Equal(t, 8*time.Second, relay.backoff(50))
+}
+
+func TestOutboxRelay_RunStopsOnCancel(t *testing.T) {
+	outbox := newMemOutbox()
+	outbox.add(t, domain.EventOrderPlaced, domain.OrderPlacedEvent{OrderID: 1, UserID: "user-1"})
+	producer := &memProducer{}
+	relay := newTestRelay(outbox, producer)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return outbox.pending() == 0 }, time.Second, 10*time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay did not stop")
+	}
+}
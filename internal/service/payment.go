@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+)
+
+// paymentActor — автор переходов заказа, сделанных платёжным шлюзом.
+const paymentActor = "payment"
+
+// settleBatchSize — сколько незавершённых возвратов RunPaymentSettler
+// обрабатывает за проход.
+const settleBatchSize = 100
+
+// Pay оплачивает заказ покупателя: авторизует сумму заказа у провайдера и
+// сразу её списывает. Заказ становится paid только после успешного
+// списания. Если провайдер подтверждает авторизацию позже, возвращается
+// платёж в статусе pending, а заказ оплачивается по вебхуку
+// (HandlePaymentEvent). Повторный вызов продолжает незавершённый платёж,
+// поэтому после таймаута запрос можно просто повторить; отменённый заказ
+// не оплачивается, даже если платёж уже начат.
+func (s *OrderServiceImpl) Pay(ctx context.Context, orderID int, userID string, req domain.PayOrderRequest) (*domain.Payment, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	if order.UserID != userID {
+		return nil, fmt.Errorf("order not found: %w", errors.New("order belongs to another user"))
+	}
+	p, err := s.payments.GetActiveByOrder(ctx, orderID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if err == nil && p.Status == domain.PaymentCaptured {
+		// Повтор после успешной оплаты
+		return p, nil
+	}
+	// Начатый платёж отменённого заказа не продолжается: блокировку на
+	// карте снимает Cancel или SettlePayments
+	if order.Status != domain.OrderPending {
+		return nil, fmt.Errorf("order cannot be paid: %w", fmt.Errorf("status is %s", order.Status))
+	}
+	if err != nil {
+		if strings.TrimSpace(req.PaymentToken) == "" {
+			return nil, fmt.Errorf("payment token required: %w", errors.New("payment token required"))
+		}
+		p = &domain.Payment{OrderID: orderID, Status: domain.PaymentPending, Amount: order.Total}
+		if err := s.payments.Create(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	switch p.Status {
+	case domain.PaymentPending:
+		if p.ProviderID != "" {
+			// Провайдер ещё не подтвердил авторизацию — ждём вебхук
+			return p, nil
+		}
+		res, err := s.provider.Authorize(ctx, integration.PaymentAuthorization{
+			IdempotencyKey: "payment-" + strconv.Itoa(p.ID),
+			OrderID:        orderID,
+			Amount:         p.Amount,
+			Token:          strings.TrimSpace(req.PaymentToken),
+		})
+		if err != nil {
+			// Платёж остаётся pending: повтор уйдёт с тем же ключом идемпотентности
+			return nil, fmt.Errorf("payment provider unavailable: %w", err)
+		}
+		return s.applyAuthorization(ctx, p, res.ID, res.Status, res.DeclineReason)
+	case domain.PaymentAuthorized:
+		return s.capture(ctx, p)
+	}
+	return p, nil
+}
+
+// applyAuthorization записывает ответ провайдера на авторизацию и, если
+// она прошла, списывает деньги.
+func (s *OrderServiceImpl) applyAuthorization(ctx context.Context, p *domain.Payment, providerID, status, reason string) (*domain.Payment, error) {
+	switch status {
+	case domain.PaymentDeclined:
+		if err := s.payments.UpdateStatus(ctx, p.ID, p.Status, domain.PaymentDeclined, providerID, reason); err != nil {
+			return nil, err
+		}
+		return nil, &domain.PaymentDeclinedError{Reason: reason}
+	case domain.PaymentPending:
+		if err := s.payments.UpdateStatus(ctx, p.ID, p.Status, domain.PaymentPending, providerID, ""); err != nil {
+			return nil, err
+		}
+		p.ProviderID = providerID
+		return p, nil
+	case domain.PaymentAuthorized:
+		if err := s.payments.UpdateStatus(ctx, p.ID, p.Status, domain.PaymentAuthorized, providerID, ""); err != nil {
+			return nil, err
+		}
+		p.Status, p.ProviderID = domain.PaymentAuthorized, providerID
+		return s.capture(ctx, p)
+	}
+	return nil, fmt.Errorf("payment provider unavailable: %w", fmt.Errorf("unexpected authorization status %q", status))
+}
+
+// capture списывает авторизованную сумму и переводит заказ в paid.
+func (s *OrderServiceImpl) capture(ctx context.Context, p *domain.Payment) (*domain.Payment, error) {
+	res, err := s.provider.Capture(ctx, p.ProviderID, p.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("payment provider unavailable: %w", err)
+	}
+	switch res.Status {
+	case domain.PaymentDeclined:
+		if err := s.payments.UpdateStatus(ctx, p.ID, domain.PaymentAuthorized, domain.PaymentDeclined, "", res.DeclineReason); err != nil {
+			return nil, err
+		}
+		return nil, &domain.PaymentDeclinedError{Reason: res.DeclineReason}
+	case domain.PaymentCaptured:
+	default:
+		return nil, fmt.Errorf("payment provider unavailable: %w", fmt.Errorf("unexpected capture status %q", res.Status))
+	}
+	t, err := s.payments.Capture(ctx, p.ID, paymentActor)
+	if err != nil {
+		if strings.Contains(err.Error(), "payment status changed concurrently") {
+			// Списание уже записал параллельный запрос или вебхук
+			return s.payments.GetByProviderID(ctx, p.ProviderID)
+		}
+		if strings.Contains(err.Error(), "order status changed concurrently") {
+			// Заказ отменили, пока списывались деньги, — возвращаем их;
+			// если возврат не пройдёт, его повторит SettlePayments
+			rerr := s.payments.UpdateStatus(ctx, p.ID, domain.PaymentAuthorized, domain.PaymentRefunding, "", "order cancelled")
+			if rerr == nil {
+				p.Status = domain.PaymentRefunding
+				rerr = s.settlePayment(ctx, p)
+			}
+			if rerr != nil {
+				s.Logger.Error("failed to refund payment of cancelled order", "payment_id", p.ID, "err", rerr)
+			}
+			return nil, fmt.Errorf("order cannot be paid: %w", err)
+		}
+		return nil, fmt.Errorf("capture payment: %w", err)
+	}
+	p.Status = domain.PaymentCaptured
+	p.UpdatedAt = t.CreatedAt
+	return p, nil
+}
+
+// HandlePaymentEvent применяет уведомление провайдера. Повторные и
+// устаревшие уведомления игнорируются.
+func (s *OrderServiceImpl) HandlePaymentEvent(ctx context.Context, evt domain.PaymentEvent) error {
+	p, err := s.payments.GetByProviderID(ctx, evt.PaymentID)
+	if err != nil {
+		return fmt.Errorf("payment not found: %w", err)
+	}
+	if p.Status != domain.PaymentPending || (evt.Status != domain.PaymentAuthorized && evt.Status != domain.PaymentDeclined) {
+		return nil
+	}
+	if evt.Status == domain.PaymentAuthorized {
+		order, err := s.orderRepo.GetByID(ctx, p.OrderID)
+		if err != nil {
+			return fmt.Errorf("order not found: %w", err)
+		}
+		if order.Status != domain.OrderPending {
+			// Заказ отменён, пока ждали банк: авторизацию не списываем, а
+			// снимаем; если не вышло, её снимет SettlePayments
+			if err := s.payments.UpdateStatus(ctx, p.ID, domain.PaymentPending, domain.PaymentAuthorized, "", ""); err != nil {
+				return err
+			}
+			p.Status = domain.PaymentAuthorized
+			if err := s.settlePayment(ctx, p); err != nil {
+				s.Logger.Error("failed to void payment of cancelled order", "payment_id", p.ID, "err", err)
+			}
+			return nil
+		}
+	}
+	_, err = s.applyAuthorization(ctx, p, p.ProviderID, evt.Status, evt.DeclineReason)
+	var declined *domain.PaymentDeclinedError
+	if errors.As(err, &declined) {
+		return nil
+	}
+	return err
+}
+
+// settleOrderPayment возвращает деньги за заказ, который уже переведён в
+// cancelled или refunded, или снимает блокировку, если заказ отменили до
+// списания. Ошибка не откатывает смену статуса: платёж остаётся refunding
+// или authorized, и его завершит SettlePayments. Заказы, оплаченные без
+// платежа (до появления платежей), возвращаются без обращения к провайдеру.
+func (s *OrderServiceImpl) settleOrderPayment(ctx context.Context, orderID int) {
+	p, err := s.payments.GetActiveByOrder(ctx, orderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err == nil {
+		err = s.settlePayment(ctx, p)
+	}
+	if err != nil {
+		s.Logger.Error("failed to settle payment", "order_id", orderID, "err", err)
+	}
+}
+
+// settlePayment возвращает покупателю ещё не возвращённую по возвратам
+// книг часть платежа в статусе refunding, а у авторизованного платежа
+// снимает блокировку. Ключ идемпотентности один на платёж, поэтому повтор
+// после потерянного ответа не вернёт деньги дважды. Вызывается только для
+// уже отменённых или возвращённых заказов.
+func (s *OrderServiceImpl) settlePayment(ctx context.Context, p *domain.Payment) error {
+	switch p.Status {
+	case domain.PaymentAuthorized:
+		res, err := s.provider.Void(ctx, p.ProviderID, p.Amount)
+		if err != nil {
+			return fmt.Errorf("payment void failed: %w", err)
+		}
+		if res.Status != domain.PaymentCancelled {
+			return fmt.Errorf("payment void failed: %w", fmt.Errorf("status %q", res.Status))
+		}
+		return s.payments.UpdateStatus(ctx, p.ID, domain.PaymentAuthorized, domain.PaymentCancelled, "", "order cancelled")
+	case domain.PaymentRefunding:
+	default:
+		return nil
+	}
+	if rest := p.Amount.Sub(p.Refunded); rest.Amount > 0 {
+		res, err := s.provider.Refund(ctx, p.ProviderID, "refund-"+p.ProviderID, rest)
+		if err != nil {
+			return fmt.Errorf("payment refund failed: %w", err)
+		}
+		if res.Status != domain.PaymentRefunded {
+			return fmt.Errorf("payment refund failed: %w", fmt.Errorf("status %q", res.Status))
+		}
+	}
+	return s.payments.UpdateStatus(ctx, p.ID, domain.PaymentRefunding, domain.PaymentRefunded, "", "")
+}
+
+// SettlePayments повторяет возвраты денег и снятие блокировок, не
+// прошедшие сразу после отмены или возврата заказа, и возвращает, сколько
+// платежей завершено.
+func (s *OrderServiceImpl) SettlePayments(ctx context.Context, limit int) (int, error) {
+	payments, err := s.payments.ListUnsettled(ctx, limit)
+	if err != nil {
+		return 0, fmt.Errorf("list unsettled payments: %w", err)
+	}
+	settled := 0
+	for _, p := range payments {
+		if err := s.settlePayment(ctx, p); err != nil {
+			s.Logger.Error("failed to settle payment", "payment_id", p.ID, "err", err)
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+// RunPaymentSettler раз в interval вызывает SettlePayments, пока не
+// отменён ctx.
+func (s *OrderServiceImpl) RunPaymentSettler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := s.SettlePayments(ctx, settleBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.Logger.Error("failed to settle payments", "err", err)
+			}
+			continue
+		}
+		if n > 0 {
+			s.Logger.Info("settled payments", "count", n)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+	"github.com/yourorg/bookshop/internal/mocks"
+)
+
+type paymentMocks struct {
+	orders   *mocks.OrderRepository
+	payments *mocks.PaymentRepository
+	provider *mocks.PaymentProvider
+	svc      *OrderServiceImpl
+}
+
+func newPaymentMocks() *paymentMocks {
+	m := &paymentMocks{orders: new(mocks.OrderRepository), payments: new(mocks.PaymentRepository), provider: new(mocks.PaymentProvider)}
+	m.svc = NewOrderService(m.orders, new(mocks.CartRepository), new(mocks.BookRepository), new(mocks.ReservationRepository), m.payments, m.provider, nil, nil, testLogger())
+	return m
+}
+
+// expectNewPayment — у заказа 7 нет платежа, новому платежу присваивается id 9.
+func (m *paymentMocks) expectNewPayment() {
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPending, Total: domain.NewMoney(35000)}, nil)
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(nil, fmt.Errorf("get payment: %w", pgx.ErrNoRows)).Once()
+	m.payments.On("Create", mock.Anything, mock.MatchedBy(func(p *domain.Payment) bool {
+		return p.OrderID == 7 && p.Status == domain.PaymentPending && p.Amount == domain.NewMoney(35000)
+	})).Run(func(args mock.Arguments) { args.Get(1).(*domain.Payment).ID = 9 }).Return(nil).Once()
+}
+
+func authorization(token string) interface{} {
+	return integration.PaymentAuthorization{IdempotencyKey: "payment-9", OrderID: 7, Amount: domain.NewMoney(35000), Token: token}
+}
+
+func TestOrderService_Pay_CapturesThenMarksPaid(t *testing.T) {
+	m := newPaymentMocks()
+	m.expectNewPayment()
+	m.provider.On("Authorize", mock.Anything, authorization("tok_ok")).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentAuthorized}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentPending, domain.PaymentAuthorized, "pay_1", "").Return(nil)
+	m.provider.On("Capture", mock.Anything, "pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentCaptured}, nil)
+	m.payments.On("Capture", mock.Anything, 9, "payment").Return(&domain.OrderTransition{OrderID: 7, From: domain.OrderPending, To: domain.OrderPaid}, nil)
+
+	p, err := m.svc.Pay(context.Background(), 7, "user-1", domain.PayOrderRequest{PaymentToken: " tok_ok "})
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentCaptured, p.Status)
+	assert.Equal(t, "pay_1", p.ProviderID)
+	m.provider.AssertExpectations(t)
+	m.payments.AssertExpectations(t)
+}
+
+func TestOrderService_Pay_Declined(t *testing.T) {
+	m := newPaymentMocks()
+	m.expectNewPayment()
+	m.provider.On("Authorize", mock.Anything, authorization("tok_decline")).
+		Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentDeclined, DeclineReason: "insufficient_funds"}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentPending, domain.PaymentDeclined, "pay_1", "insufficient_funds").Return(nil)
+
+	_, err := m.svc.Pay(context.Background(), 7, "user-1", domain.PayOrderRequest{PaymentToken: "tok_decline"})
+	var declined *domain.PaymentDeclinedError
+	require.ErrorAs(t, err, &declined)
+	assert.Equal(t, "insufficient_funds", declined.Reason)
+	m.provider.AssertNotCalled(t, "Capture", mock.Anything, mock.Anything, mock.Anything)
+	m.payments.AssertNotCalled(t, "Capture", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOrderService_Pay_TimeoutRetriedWithSameKey(t *testing.T) {
+	m := newPaymentMocks()
+	m.expectNewPayment()
+	m.provider.On("Authorize", mock.Anything, authorization("tok_ok")).Return(nil, context.DeadlineExceeded).Once()
+
+	_, err := m.svc.Pay(context.Background(), 7, "user-1", domain.PayOrderRequest{PaymentToken: "tok_ok"})
+	require.ErrorContains(t, err, "payment provider unavailable")
+	m.payments.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// Повтор продолжает тот же платёж: ключ идемпотентности не меняется
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(&domain.Payment{ID: 9, OrderID: 7, Status: domain.PaymentPending, Amount: domain.NewMoney(35000)}, nil)
+	m.provider.On("Authorize", mock.Anything, authorization("tok_ok")).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentAuthorized}, nil).Once()
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentPending, domain.PaymentAuthorized, "pay_1", "").Return(nil)
+	m.provider.On("Capture", mock.Anything, "pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentCaptured}, nil)
+	m.payments.On("Capture", mock.Anything, 9, "payment").Return(&domain.OrderTransition{OrderID: 7}, nil)
+
+	p, err := m.svc.Pay(context.Background(), 7, "user-1", domain.PayOrderRequest{PaymentToken: "tok_ok"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentCaptured, p.Status)
+	m.payments.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestOrderService_Pay_DelayedConfirmationViaWebhook(t *testing.T) {
+	m := newPaymentMocks()
+	m.expectNewPayment()
+	m.provider.On("Authorize", mock.Anything, authorization("tok_delayed")).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentPending}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentPending, domain.PaymentPending, "pay_1", "").Return(nil)
+
+	p, err := m.svc.Pay(context.Background(), 7, "user-1", domain.PayOrderRequest{PaymentToken: "tok_delayed"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.PaymentPending, p.Status)
+	m.provider.AssertNotCalled(t, "Capture", mock.Anything, mock.Anything, mock.Anything)
+
+	// Банк подтвердил — вебхук списывает деньги и оплачивает заказ
+	m.payments.On("GetByProviderID", mock.Anything, "pay_1").Return(&domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentPending, Amount: domain.NewMoney(35000)}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentPending, domain.PaymentAuthorized, "pay_1", "").Return(nil)
+	m.provider.On("Capture", mock.Anything, "pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentCaptured}, nil)
+	m.payments.On("Capture", mock.Anything, 9, "payment").Return(&domain.OrderTransition{OrderID: 7}, nil)
+
+	require.NoError(t, m.svc.HandlePaymentEvent(context.Background(), domain.PaymentEvent{PaymentID: "pay_1", Status: domain.PaymentAuthorized}))
+	m.payments.AssertCalled(t, "Capture", mock.Anything, 9, "payment")
+}
+
+func TestOrderService_HandlePaymentEvent_DuplicateIgnored(t *testing.T) {
+	m := newPaymentMocks()
+	m.payments.On("GetByProviderID", mock.Anything, "pay_1").Return(&domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentCaptured}, nil)
+
+	require.NoError(t, m.svc.HandlePaymentEvent(context.Background(), domain.PaymentEvent{PaymentID: "pay_1", Status: domain.PaymentAuthorized}))
+	m.provider.AssertNotCalled(t, "Capture", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Авторизация пришла после отмены заказа: сумму не списываем, а снимаем
+// блокировку с карты.
+func TestOrderService_HandlePaymentEvent_OrderCancelledMeanwhile(t *testing.T) {
+	m := newPaymentMocks()
+	m.payments.On("GetByProviderID", mock.Anything, "pay_1").Return(&domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentPending, Amount: domain.NewMoney(35000)}, nil)
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, Status: domain.OrderCancelled}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentPending, domain.PaymentAuthorized, "", "").Return(nil)
+	m.provider.On("Void", mock.Anything, "pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentCancelled}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentAuthorized, domain.PaymentCancelled, "", "order cancelled").Return(nil)
+
+	require.NoError(t, m.svc.HandlePaymentEvent(context.Background(), domain.PaymentEvent{PaymentID: "pay_1", Status: domain.PaymentAuthorized}))
+	m.provider.AssertNotCalled(t, "Capture", mock.Anything, mock.Anything, mock.Anything)
+	m.provider.AssertExpectations(t)
+	m.payments.AssertExpectations(t)
+}
+
+// Покупатель отменил заказ между авторизацией и списанием: блокировка
+// снимается после отмены, а если шлюз не ответил — в SettlePayments.
+func TestOrderService_Cancel_VoidsAuthorizedPayment(t *testing.T) {
+	m := newPaymentMocks()
+	authorized := &domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentAuthorized, Amount: domain.NewMoney(35000)}
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPending}, nil)
+	m.orders.On("Cancel", mock.Anything, 7, domain.OrderPending, "user-1", "").
+		Return(&domain.OrderTransition{OrderID: 7, From: domain.OrderPending, To: domain.OrderCancelled}, nil)
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(authorized, nil)
+	m.provider.On("Void", mock.Anything, "pay_1", domain.NewMoney(35000)).Return(nil, errors.New("gateway down")).Once()
+
+	order, err := m.svc.Cancel(context.Background(), 7, "user-1", false, "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderCancelled, order.Status)
+	m.payments.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	m.payments.On("ListUnsettled", mock.Anything, 100).Return([]*domain.Payment{authorized}, nil)
+	m.provider.On("Void", mock.Anything, "pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentCancelled}, nil).Once()
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentAuthorized, domain.PaymentCancelled, "", "order cancelled").Return(nil)
+	n, err := m.svc.SettlePayments(context.Background(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	m.provider.AssertNotCalled(t, "Refund", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	m.provider.AssertExpectations(t)
+	m.payments.AssertExpectations(t)
+}
+
+// Заказ отменили, пока шлюз списывал деньги: списанное возвращается.
+func TestOrderService_Pay_CancelledDuringCaptureRefunds(t *testing.T) {
+	m := newPaymentMocks()
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPending}, nil)
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(&domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentAuthorized, Amount: domain.NewMoney(35000)}, nil)
+	m.provider.On("Capture", mock.Anything, "pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentCaptured}, nil)
+	m.payments.On("Capture", mock.Anything, 9, "payment").Return(nil, fmt.Errorf("order status changed concurrently: %w", errors.New("order status changed concurrently")))
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentAuthorized, domain.PaymentRefunding, "", "order cancelled").Return(nil)
+	m.provider.On("Refund", mock.Anything, "pay_1", "refund-pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentRefunded}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentRefunding, domain.PaymentRefunded, "", "").Return(nil)
+
+	_, err := m.svc.Pay(context.Background(), 7, "user-1", domain.PayOrderRequest{})
+	require.ErrorContains(t, err, "order cannot be paid")
+	m.provider.AssertNotCalled(t, "Void", mock.Anything, mock.Anything, mock.Anything)
+	m.provider.AssertExpectations(t)
+	m.payments.AssertExpectations(t)
+}
+
+func TestOrderService_Pay_Rejected(t *testing.T) {
+	m := newPaymentMocks()
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPending}, nil)
+	m.orders.On("GetByID", mock.Anything, 8).Return(&domain.Order{ID: 8, UserID: "user-1", Status: domain.OrderCancelled}, nil)
+	m.payments.On("GetActiveByOrder", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("get payment: %w", pgx.ErrNoRows))
+
+	_, err := m.svc.Pay(context.Background(), 7, "user-2", domain.PayOrderRequest{PaymentToken: "tok_ok"})
+	require.ErrorContains(t, err, "order not found")
+	_, err = m.svc.Pay(context.Background(), 8, "user-1", domain.PayOrderRequest{PaymentToken: "tok_ok"})
+	require.ErrorContains(t, err, "order cannot be paid")
+	_, err = m.svc.Pay(context.Background(), 7, "user-1", domain.PayOrderRequest{})
+	require.ErrorContains(t, err, "payment token required")
+	m.payments.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// Заказ отменили после таймаута авторизации: повтор оплаты не обращается
+// к шлюзу, даже если платёж ещё числится начатым.
+func TestOrderService_Pay_CancelledOrderNotRetried(t *testing.T) {
+	m := newPaymentMocks()
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderCancelled}, nil)
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(&domain.Payment{ID: 9, OrderID: 7, Status: domain.PaymentPending, Amount: domain.NewMoney(35000)}, nil)
+
+	_, err := m.svc.Pay(context.Background(), 7, "user-1", domain.PayOrderRequest{PaymentToken: "tok_ok"})
+	require.ErrorContains(t, err, "order cannot be paid")
+	m.provider.AssertNotCalled(t, "Authorize", mock.Anything, mock.Anything)
+	m.provider.AssertNotCalled(t, "Capture", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOrderService_Transition_RefundedRefundsPayment(t *testing.T) {
+	m := newPaymentMocks()
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderDelivered}, nil)
+	m.orders.On("UpdateStatus", mock.Anything, 7, domain.OrderDelivered, domain.OrderRefunded, "admin-1", "брак").
+		Return(&domain.OrderTransition{OrderID: 7, From: domain.OrderDelivered, To: domain.OrderRefunded}, nil)
+	// Платёж стал refunding в транзакции смены статуса
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(&domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentRefunding, Amount: domain.NewMoney(35000)}, nil)
+	m.provider.On("Refund", mock.Anything, "pay_1", "refund-pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentRefunded}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentRefunding, domain.PaymentRefunded, "", "").Return(nil)
+
+	order, err := m.svc.Transition(context.Background(), 7, domain.OrderRefunded, "admin-1", "брак")
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderRefunded, order.Status)
+	m.provider.AssertExpectations(t)
+	m.payments.AssertExpectations(t)
+}
+
+// Заказ поменяли параллельно: деньги не возвращаются, раз статус не сменился.
+func TestOrderService_Transition_ConcurrentChangeDoesNotRefund(t *testing.T) {
+	m := newPaymentMocks()
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPaid}, nil)
+	m.orders.On("UpdateStatus", mock.Anything, 7, domain.OrderPaid, domain.OrderRefunded, "admin-1", "").
+		Return(nil, fmt.Errorf("order status changed concurrently: %w", errors.New("order status changed concurrently")))
+
+	_, err := m.svc.Transition(context.Background(), 7, domain.OrderRefunded, "admin-1", "")
+	require.ErrorContains(t, err, "status changed concurrently")
+	m.provider.AssertNotCalled(t, "Refund", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Провайдер не ответил: заказ всё равно возвращён, а деньги возвращает
+// SettlePayments с тем же ключом идемпотентности.
+func TestOrderService_Transition_RefundFailureSettledLater(t *testing.T) {
+	m := newPaymentMocks()
+	refunding := &domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentRefunding, Amount: domain.NewMoney(35000)}
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderPaid}, nil)
+	m.orders.On("UpdateStatus", mock.Anything, 7, domain.OrderPaid, domain.OrderRefunded, "admin-1", "").
+		Return(&domain.OrderTransition{OrderID: 7, From: domain.OrderPaid, To: domain.OrderRefunded}, nil)
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(refunding, nil)
+	m.provider.On("Refund", mock.Anything, "pay_1", "refund-pay_1", domain.NewMoney(35000)).Return(nil, errors.New("gateway down")).Once()
+
+	order, err := m.svc.Transition(context.Background(), 7, domain.OrderRefunded, "admin-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderRefunded, order.Status)
+	m.payments.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	m.payments.On("ListUnsettled", mock.Anything, 100).Return([]*domain.Payment{refunding}, nil)
+	m.provider.On("Refund", mock.Anything, "pay_1", "refund-pay_1", domain.NewMoney(35000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentRefunded}, nil).Once()
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentRefunding, domain.PaymentRefunded, "", "").Return(nil)
+	n, err := m.svc.SettlePayments(context.Background(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	m.provider.AssertExpectations(t)
+	m.payments.AssertExpectations(t)
+}
+
+func TestOrderService_Transition_RefundedRefundsOnlyRemainder(t *testing.T) {
+	m := newPaymentMocks()
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderDelivered}, nil)
+	m.orders.On("UpdateStatus", mock.Anything, 7, domain.OrderDelivered, domain.OrderRefunded, "admin-1", "").
+		Return(&domain.OrderTransition{OrderID: 7, From: domain.OrderDelivered, To: domain.OrderRefunded}, nil)
+	// 150 ₽ уже вернули по возврату книг
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(&domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentRefunding, Amount: domain.NewMoney(35000), Refunded: domain.NewMoney(15000)}, nil)
+	m.provider.On("Refund", mock.Anything, "pay_1", "refund-pay_1", domain.NewMoney(20000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentRefunded}, nil)
+	m.payments.On("UpdateStatus", mock.Anything, 9, domain.PaymentRefunding, domain.PaymentRefunded, "", "").Return(nil)
+
+	_, err := m.svc.Transition(context.Background(), 7, domain.OrderRefunded, "admin-1", "")
+	require.NoError(t, err)
+	m.provider.AssertExpectations(t)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/repository"
+)
+
+// PromoServiceImpl — управление промокодами для админа. Скидку по
+// промокоду считает CartServiceImpl.
+type PromoServiceImpl struct {
+	promos repository.PromoRepository
+}
+
+func NewPromoService(promos repository.PromoRepository) *PromoServiceImpl {
+	return &PromoServiceImpl{promos: promos}
+}
+
+// Create проверяет и сохраняет промокод; ошибки полей — *domain.ValidationError.
+func (s *PromoServiceImpl) Create(ctx context.Context, p *domain.PromoCode) error {
+	p.Normalize()
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return s.promos.Create(ctx, p)
+}
+
+// Update меняет условия промокода; код не меняется.
+func (s *PromoServiceImpl) Update(ctx context.Context, p *domain.PromoCode) error {
+	current, err := s.promos.GetByID(ctx, p.ID)
+	if err != nil {
+		return fmt.Errorf("promo code not found: %w", err)
+	}
+	p.Normalize()
+	p.Code = current.Code
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return s.promos.Update(ctx, p)
+}
+
+func (s *PromoServiceImpl) Get(ctx context.Context, id int) (*domain.PromoCode, error) {
+	p, err := s.promos.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("promo code not found: %w", err)
+	}
+	return p, nil
+}
+
+func (s *PromoServiceImpl) List(ctx context.Context, page domain.PageRequest) (*domain.PromoCodeList, error) {
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	page.Limit = limit + 1
+	promos, err := s.promos.List(ctx, page)
+	if err != nil {
+		return nil, fmt.Errorf("list promo codes: %w", err)
+	}
+	list := &domain.PromoCodeList{Items: promos}
+	if list.Items == nil {
+		list.Items = make([]*domain.PromoCode, 0)
+	}
+	if len(promos) > limit {
+		list.Items = promos[:limit]
+		list.Next = &domain.Cursor{ID: promos[limit-1].ID}
+	}
+	return list, nil
+}
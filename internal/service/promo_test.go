@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/mocks"
+)
+
+func TestPromoService_Create_NormalizesAndValidates(t *testing.T) {
+	promos := new(mocks.PromoRepository)
+	endsAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	promos.On("Create", mock.Anything, mock.MatchedBy(func(p *domain.PromoCode) bool {
+		return p.Code == "SALE10" && p.EndsAt.Location() == time.UTC && p.EndsAt.Equal(endsAt)
+	})).Return(nil).Once()
+	svc := NewPromoService(promos)
+
+	require.NoError(t, svc.Create(context.Background(), &domain.PromoCode{Code: " sale10 ", Kind: domain.PromoPercent, Percent: 10, EndsAt: &endsAt}))
+
+	err := svc.Create(context.Background(), &domain.PromoCode{Code: "HALF", Kind: domain.PromoPercent, Percent: 150})
+	var invalid *domain.ValidationError
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, "percent", invalid.Fields[0].Field)
+	promos.AssertExpectations(t)
+}
+
+func TestPromoService_Update_KeepsCode(t *testing.T) {
+	promos := new(mocks.PromoRepository)
+	promos.On("GetByID", mock.Anything, 3).Return(&domain.PromoCode{ID: 3, Code: "SALE10", Kind: domain.PromoPercent, Percent: 10, UsedCount: 7}, nil)
+	promos.On("Update", mock.Anything, mock.MatchedBy(func(p *domain.PromoCode) bool {
+		return p.Code == "SALE10" && p.Percent == 15 && !p.Active
+	})).Return(nil).Once()
+
+	err := NewPromoService(promos).Update(context.Background(), &domain.PromoCode{ID: 3, Code: "OTHER", Kind: domain.PromoPercent, Percent: 15})
+	require.NoError(t, err)
+	promos.AssertExpectations(t)
+}
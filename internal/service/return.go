@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+	"github.com/yourorg/bookshop/internal/repository"
+)
+
+// ReturnServiceImpl ведёт возвраты доставленных заказов: покупатель
+// создаёт заявку, админ одобряет её (книги возвращаются на склад, деньги —
+// покупателю) или отклоняет. События пишет в outbox репозиторий.
+type ReturnServiceImpl struct {
+	orderRepo repository.OrderRepository
+	returns   repository.ReturnRepository
+	payments  repository.PaymentRepository
+	provider  integration.PaymentProvider
+}
+
+func NewReturnService(orderRepo repository.OrderRepository, returns repository.ReturnRepository, payments repository.PaymentRepository, provider integration.PaymentProvider) *ReturnServiceImpl {
+	return &ReturnServiceImpl{
+		orderRepo: orderRepo,
+		returns:   returns,
+		payments:  payments,
+		provider:  provider,
+	}
+}
+
+// Request создаёт заявку на возврат позиций заказа покупателя. Вернуть
+// можно только доставленный заказ и не больше, чем куплено, с учётом
+// прежних неотклонённых заявок.
+func (s *ReturnServiceImpl) Request(ctx context.Context, orderID int, userID string, req domain.ReturnRequest) (*domain.Return, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("invalid return: %w", errors.New("reason is required"))
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("invalid return: %w", errors.New("no items"))
+	}
+	seen := map[int]bool{}
+	ret := &domain.Return{OrderID: orderID, UserID: userID, Reason: reason}
+	for _, it := range req.Items {
+		if it.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid return: %w", fmt.Errorf("quantity %d for order item %d", it.Quantity, it.OrderItemID))
+		}
+		if seen[it.OrderItemID] {
+			return nil, fmt.Errorf("invalid return: %w", fmt.Errorf("order item %d listed twice", it.OrderItemID))
+		}
+		seen[it.OrderItemID] = true
+		ret.Items = append(ret.Items, domain.ReturnItem{OrderItemID: it.OrderItemID, Quantity: it.Quantity})
+	}
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	if order.UserID != userID {
+		return nil, fmt.Errorf("order not found: %w", errors.New("order belongs to another user"))
+	}
+	if order.Status != domain.OrderDelivered {
+		return nil, fmt.Errorf("order cannot be returned: %w", fmt.Errorf("status is %s", order.Status))
+	}
+	if err := s.returns.Create(ctx, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Get возвращает заявку её автору или админу; для остальных — "return not found".
+func (s *ReturnServiceImpl) Get(ctx context.Context, id int, actor string, isAdmin bool) (*domain.Return, error) {
+	ret, err := s.returns.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("return not found: %w", err)
+	}
+	if !isAdmin && ret.UserID != actor {
+		return nil, fmt.Errorf("return not found: %w", errors.New("return belongs to another user"))
+	}
+	return ret, nil
+}
+
+func (s *ReturnServiceImpl) ListByOrder(ctx context.Context, orderID int, actor string, isAdmin bool) ([]*domain.Return, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("order not found: %w", err)
+	}
+	if !isAdmin && order.UserID != actor {
+		return nil, fmt.Errorf("order not found: %w", errors.New("order belongs to another user"))
+	}
+	return s.returns.ListByOrder(ctx, orderID)
+}
+
+// List — очередь возвратов для админа, от новых к старым.
+func (s *ReturnServiceImpl) List(ctx context.Context, status string, page domain.PageRequest) (*domain.ReturnList, error) {
+	switch status {
+	case "", domain.ReturnRequested, domain.ReturnApproved, domain.ReturnRejected, domain.ReturnRefunded:
+	default:
+		return nil, fmt.Errorf("invalid status: %w", fmt.Errorf("unknown return status %q", status))
+	}
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	page.Limit = limit + 1
+	returns, err := s.returns.List(ctx, status, page)
+	if err != nil {
+		return nil, fmt.Errorf("list returns: %w", err)
+	}
+	list := &domain.ReturnList{Items: returns}
+	if len(returns) > limit {
+		list.Items = returns[:limit]
+		list.Next = &domain.Cursor{ID: returns[limit-1].ID}
+	}
+	return list, nil
+}
+
+// Approve одобряет заявку: книги возвращаются на склад, затем сумма
+// позиций возвращается покупателю через платёжный шлюз. Если шлюз не
+// ответил, заявка остаётся approved ("payment refund failed"), и повторный
+// Approve только повторяет возврат денег — с тем же ключом идемпотентности.
+func (s *ReturnServiceImpl) Approve(ctx context.Context, id int, actor, note string) (*domain.Return, error) {
+	ret, err := s.returns.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("return not found: %w", err)
+	}
+	switch ret.Status {
+	case domain.ReturnRequested:
+		if ret, err = s.returns.Approve(ctx, id, actor, strings.TrimSpace(note)); err != nil {
+			return nil, err
+		}
+	case domain.ReturnApproved:
+	default:
+		return nil, fmt.Errorf("return cannot be approved: %w", fmt.Errorf("status is %s", ret.Status))
+	}
+	return s.refund(ctx, ret, actor)
+}
+
+func (s *ReturnServiceImpl) Reject(ctx context.Context, id int, actor, note string) (*domain.Return, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, fmt.Errorf("invalid return: %w", errors.New("rejection reason is required"))
+	}
+	ret, err := s.returns.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("return not found: %w", err)
+	}
+	if ret.Status != domain.ReturnRequested {
+		return nil, fmt.Errorf("return cannot be rejected: %w", fmt.Errorf("status is %s", ret.Status))
+	}
+	return s.returns.Reject(ctx, id, actor, note)
+}
+
+// refund возвращает деньги по одобренной заявке. Без списанного платежа
+// (заказ оплачен до появления платежей или уже возвращён целиком) шлюз не
+// вызывается.
+func (s *ReturnServiceImpl) refund(ctx context.Context, ret *domain.Return, actor string) (*domain.Return, error) {
+	paymentID := 0
+	p, err := s.payments.GetActiveByOrder(ctx, ret.OrderID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if err == nil && p.Status == domain.PaymentCaptured && !ret.RefundAmount.IsZero() {
+		res, err := s.provider.Refund(ctx, p.ProviderID, "return-"+strconv.Itoa(ret.ID), ret.RefundAmount)
+		if err != nil {
+			return nil, fmt.Errorf("payment refund failed: %w", err)
+		}
+		if res.Status != domain.PaymentRefunded {
+			return nil, fmt.Errorf("payment refund failed: %w", fmt.Errorf("status %q", res.Status))
+		}
+		paymentID = p.ID
+	}
+	return s.returns.MarkRefunded(ctx, ret.ID, paymentID, actor)
+}
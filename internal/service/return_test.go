@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
+	"github.com/yourorg/bookshop/internal/mocks"
+)
+
+type returnMocks struct {
+	orders   *mocks.OrderRepository
+	returns  *mocks.ReturnRepository
+	payments *mocks.PaymentRepository
+	provider *mocks.PaymentProvider
+	svc      *ReturnServiceImpl
+}
+
+func newReturnMocks() *returnMocks {
+	m := &returnMocks{orders: new(mocks.OrderRepository), returns: new(mocks.ReturnRepository), payments: new(mocks.PaymentRepository), provider: new(mocks.PaymentProvider)}
+	m.svc = NewReturnService(m.orders, m.returns, m.payments, m.provider)
+	return m
+}
+
+// testReturn — заявка 3 по заказу 7 на две книги по 250 ₽.
+func testReturn(status string) *domain.Return {
+	return &domain.Return{
+		ID: 3, OrderID: 7, UserID: "user-1", Status: status, Reason: "брак",
+		Items:        []domain.ReturnItem{{ID: 1, OrderItemID: 11, BookID: 42, Price: domain.NewMoney(25000), Quantity: 2}},
+		RefundAmount: domain.NewMoney(50000),
+	}
+}
+
+func TestReturnService_Request_ValidatesBeforeStorage(t *testing.T) {
+	m := newReturnMocks()
+	item := domain.ReturnItemRequest{OrderItemID: 11, Quantity: 1}
+	cases := map[string]domain.ReturnRequest{
+		"no reason":     {Items: []domain.ReturnItemRequest{item}, Reason: "  "},
+		"no items":      {Reason: "брак"},
+		"zero quantity": {Items: []domain.ReturnItemRequest{{OrderItemID: 11}}, Reason: "брак"},
+		"duplicate":     {Items: []domain.ReturnItemRequest{item, item}, Reason: "брак"},
+	}
+	for name, req := range cases {
+		_, err := m.svc.Request(context.Background(), 7, "user-1", req)
+		assert.ErrorContains(t, err, "invalid return", name)
+	}
+	m.orders.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	m.returns.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestReturnService_Request(t *testing.T) {
+	m := newReturnMocks()
+	m.orders.On("GetByID", mock.Anything, 7).Return(&domain.Order{ID: 7, UserID: "user-1", Status: domain.OrderDelivered}, nil)
+	m.orders.On("GetByID", mock.Anything, 8).Return(&domain.Order{ID: 8, UserID: "user-1", Status: domain.OrderShipped}, nil)
+	m.orders.On("GetByID", mock.Anything, 9).Return(&domain.Order{ID: 9, UserID: "user-2", Status: domain.OrderDelivered}, nil)
+	m.returns.On("Create", mock.Anything, mock.MatchedBy(func(ret *domain.Return) bool {
+		return ret.OrderID == 7 && ret.UserID == "user-1" && ret.Reason == "брак" &&
+			len(ret.Items) == 1 && ret.Items[0] == domain.ReturnItem{OrderItemID: 11, Quantity: 2}
+	})).Return(nil).Once()
+	req := domain.ReturnRequest{Items: []domain.ReturnItemRequest{{OrderItemID: 11, Quantity: 2}}, Reason: " брак "}
+
+	ret, err := m.svc.Request(context.Background(), 7, "user-1", req)
+	require.NoError(t, err)
+	assert.Equal(t, 7, ret.OrderID)
+
+	_, err = m.svc.Request(context.Background(), 8, "user-1", req)
+	assert.ErrorContains(t, err, "order cannot be returned")
+	_, err = m.svc.Request(context.Background(), 9, "user-1", req)
+	assert.ErrorContains(t, err, "order not found")
+	m.returns.AssertExpectations(t)
+}
+
+func TestReturnService_Approve_RestocksThenRefundsPartially(t *testing.T) {
+	m := newReturnMocks()
+	m.returns.On("GetByID", mock.Anything, 3).Return(testReturn(domain.ReturnRequested), nil)
+	m.returns.On("Approve", mock.Anything, 3, "admin-1", "ok").Return(testReturn(domain.ReturnApproved), nil).Once()
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(&domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentCaptured, Amount: domain.NewMoney(80000)}, nil)
+	m.provider.On("Refund", mock.Anything, "pay_1", "return-3", domain.NewMoney(50000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentRefunded}, nil).Once()
+	m.returns.On("MarkRefunded", mock.Anything, 3, 9, "admin-1").Return(testReturn(domain.ReturnRefunded), nil).Once()
+
+	ret, err := m.svc.Approve(context.Background(), 3, "admin-1", " ok ")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ReturnRefunded, ret.Status)
+	m.returns.AssertExpectations(t)
+	m.provider.AssertExpectations(t)
+}
+
+func TestReturnService_Approve_RefundFailureIsRetriedWithoutRestocking(t *testing.T) {
+	m := newReturnMocks()
+	m.returns.On("GetByID", mock.Anything, 3).Return(testReturn(domain.ReturnRequested), nil).Once()
+	m.returns.On("Approve", mock.Anything, 3, "admin-1", "").Return(testReturn(domain.ReturnApproved), nil).Once()
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(&domain.Payment{ID: 9, OrderID: 7, ProviderID: "pay_1", Status: domain.PaymentCaptured, Amount: domain.NewMoney(80000)}, nil)
+	m.provider.On("Refund", mock.Anything, "pay_1", "return-3", domain.NewMoney(50000)).Return(nil, errors.New("gateway down")).Once()
+
+	_, err := m.svc.Approve(context.Background(), 3, "admin-1", "")
+	require.ErrorContains(t, err, "payment refund failed")
+	m.returns.AssertNotCalled(t, "MarkRefunded", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// Повтор: книги уже на складе, повторяется только возврат денег с тем же ключом
+	m.returns.On("GetByID", mock.Anything, 3).Return(testReturn(domain.ReturnApproved), nil).Once()
+	m.provider.On("Refund", mock.Anything, "pay_1", "return-3", domain.NewMoney(50000)).Return(&integration.PaymentResult{ID: "pay_1", Status: domain.PaymentRefunded}, nil).Once()
+	m.returns.On("MarkRefunded", mock.Anything, 3, 9, "admin-1").Return(testReturn(domain.ReturnRefunded), nil).Once()
+	ret, err := m.svc.Approve(context.Background(), 3, "admin-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ReturnRefunded, ret.Status)
+	m.returns.AssertNumberOfCalls(t, "Approve", 1)
+}
+
+func TestReturnService_Approve_WithoutPaymentSkipsProvider(t *testing.T) {
+	m := newReturnMocks()
+	m.returns.On("GetByID", mock.Anything, 3).Return(testReturn(domain.ReturnRequested), nil)
+	m.returns.On("Approve", mock.Anything, 3, "admin-1", "").Return(testReturn(domain.ReturnApproved), nil)
+	m.payments.On("GetActiveByOrder", mock.Anything, 7).Return(nil, fmt.Errorf("get payment: %w", pgx.ErrNoRows))
+	m.returns.On("MarkRefunded", mock.Anything, 3, 0, "admin-1").Return(testReturn(domain.ReturnRefunded), nil)
+
+	_, err := m.svc.Approve(context.Background(), 3, "admin-1", "")
+	require.NoError(t, err)
+	m.provider.AssertNotCalled(t, "Refund", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReturnService_ResolvedReturnsAreFinal(t *testing.T) {
+	m := newReturnMocks()
+	m.returns.On("GetByID", mock.Anything, 3).Return(testReturn(domain.ReturnRejected), nil)
+
+	_, err := m.svc.Approve(context.Background(), 3, "admin-1", "")
+	assert.ErrorContains(t, err, "return cannot be approved")
+	_, err = m.svc.Reject(context.Background(), 3, "admin-1", "не наш товар")
+	assert.ErrorContains(t, err, "return cannot be rejected")
+	_, err = m.svc.Reject(context.Background(), 3, "admin-1", "")
+	assert.ErrorContains(t, err, "invalid return")
+	m.returns.AssertNotCalled(t, "Reject", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/repository"
+)
+
+// TaxServiceImpl ведёт ставки НДС и считает налог позиций корзины и
+// заказа по ставкам, действующим в момент расчёта.
+type TaxServiceImpl struct {
+	taxes repository.TaxRepository
+	// defaultRegion — регион для корзины, пока покупатель не указал свой.
+	defaultRegion domain.TaxRegion
+}
+
+func NewTaxService(taxes repository.TaxRepository, defaultRegion domain.TaxRegion) *TaxServiceImpl {
+	return &TaxServiceImpl{taxes: taxes, defaultRegion: defaultRegion}
+}
+
+// Create добавляет новую версию ставки; ошибки полей — *domain.ValidationError.
+func (s *TaxServiceImpl) Create(ctx context.Context, rate *domain.TaxRate) error {
+	now := time.Now().UTC()
+	if rate.EffectiveFrom.IsZero() {
+		rate.EffectiveFrom = now
+	}
+	rate.Normalize()
+	if err := rate.Validate(now); err != nil {
+		return err
+	}
+	return s.taxes.Create(ctx, rate)
+}
+
+func (s *TaxServiceImpl) List(ctx context.Context, page domain.PageRequest) (*domain.TaxRateList, error) {
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	page.Limit = limit + 1
+	rates, err := s.taxes.List(ctx, page)
+	if err != nil {
+		return nil, fmt.Errorf("list tax rates: %w", err)
+	}
+	list := &domain.TaxRateList{Items: rates}
+	if list.Items == nil {
+		list.Items = make([]*domain.TaxRate, 0)
+	}
+	if len(rates) > limit {
+		list.Items = rates[:limit]
+		list.Next = &domain.Cursor{ID: rates[limit-1].ID}
+	}
+	return list, nil
+}
+
+// LineTaxes считает НДС, включённый в суммы позиций, по ставкам региона.
+func (s *TaxServiceImpl) LineTaxes(ctx context.Context, region domain.TaxRegion, lines []domain.TaxLine) ([]domain.LineTax, domain.Money, error) {
+	rates, err := s.taxes.Effective(ctx, region.Country, time.Now().UTC())
+	if err != nil {
+		return nil, domain.Money{}, fmt.Errorf("get tax rates: %w", err)
+	}
+	taxes, total := domain.LineTaxes(rates, region, lines)
+	return taxes, total, nil
+}
@@ -2,19 +2,59 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/integration"
 	"github.com/yourorg/bookshop/internal/repository"
 )
 
 type UserServiceImpl struct {
-	repo repository.UserRepository
+	repo  repository.UserRepository
+	redis integration.RedisCache
 }
 
-func NewUserService(repo repository.UserRepository) *UserServiceImpl {
-	return &UserServiceImpl{repo: repo}
+func NewUserService(repo repository.UserRepository, redis integration.RedisCache) *UserServiceImpl {
+	return &UserServiceImpl{repo: repo, redis: redis}
 }
 
+func (s *UserServiceImpl) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	return user, nil
+}
+
+// GetOrCreate вызывается на каждый аутентифицированный запрос, поэтому
+// синхронизированный профиль кэшируется в Redis, и в базу ходим только
+// при первом входе или когда email/роль в токене поменялись.
 func (s *UserServiceImpl) GetOrCreate(ctx context.Context, id, email string, isAdmin bool) (*domain.User, error) {
-	return nil, nil
+	if id == "" {
+		return nil, fmt.Errorf("user id required: %w", errors.New("user id required"))
+	}
+	key := "user:" + id
+	if cached, err := s.redis.Get(key); err == nil && cached != "" {
+		var user domain.User
+		if err := json.Unmarshal([]byte(cached), &user); err == nil && user.Email == email && user.IsAdmin == isAdmin {
+			return &user, nil
+		}
+	}
+	if err := s.repo.Upsert(ctx, &domain.User{ID: id, Email: email, IsAdmin: isAdmin}); err != nil {
+		return nil, fmt.Errorf("upsert user: %w", err)
+	}
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if data, err := json.Marshal(user); err == nil {
+		s.redis.Set(key, string(data), 600) // 10 минут
+	}
+	return user, nil
 }
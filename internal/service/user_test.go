@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yourorg/bookshop/internal/domain"
+	"github.com/yourorg/bookshop/internal/mocks"
+)
+
+func TestUserService_GetOrCreate_FirstLogin(t *testing.T) {
+	repo := new(mocks.UserRepository)
+	redis := new(mocks.RedisCache)
+	userID := "user-1"
+
+	redis.On("Get", "user:user-1").Return("", errors.New("redis: nil"))
+	repo.On("Upsert", mock.Anything, &domain.User{ID: userID, Email: "user@ex.com"}).Return(nil)
+	repo.On("GetByID", mock.Anything, userID).Return(&domain.User{ID: userID, Email: "user@ex.com"}, nil)
+	redis.On("Set", "user:user-1", mock.Anything, 600).Return(nil)
+
+	svc := NewUserService(repo, redis)
+	user, err := svc.GetOrCreate(context.Background(), userID, "user@ex.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, "user@ex.com", user.Email)
+	repo.AssertExpectations(t)
+	redis.AssertExpectations(t)
+}
+
+func TestUserService_GetOrCreate_CachedProfileSkipsDB(t *testing.T) {
+	repo := new(mocks.UserRepository)
+	redis := new(mocks.RedisCache)
+
+	redis.On("Get", "user:user-1").Return(`{"id":"user-1","email":"user@ex.com","is_admin":true}`, nil)
+
+	svc := NewUserService(repo, redis)
+	user, err := svc.GetOrCreate(context.Background(), "user-1", "user@ex.com", true)
+	require.NoError(t, err)
+	assert.True(t, user.IsAdmin)
+	repo.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+}
+
+func TestUserService_GetOrCreate_SyncsChangedClaims(t *testing.T) {
+	repo := new(mocks.UserRepository)
+	redis := new(mocks.RedisCache)
+
+	redis.On("Get", "user:user-1").Return(`{"id":"user-1","email":"old@ex.com","is_admin":false}`, nil)
+	repo.On("Upsert", mock.Anything, &domain.User{ID: "user-1", Email: "new@ex.com", IsAdmin: true}).Return(nil)
+	repo.On("GetByID", mock.Anything, "user-1").Return(&domain.User{ID: "user-1", Email: "new@ex.com", IsAdmin: true}, nil)
+	redis.On("Set", "user:user-1", mock.Anything, 600).Return(nil)
+
+	svc := NewUserService(repo, redis)
+	user, err := svc.GetOrCreate(context.Background(), "user-1", "new@ex.com", true)
+	require.NoError(t, err)
+	assert.Equal(t, "new@ex.com", user.Email)
+	assert.True(t, user.IsAdmin)
+	repo.AssertExpectations(t)
+}
+
+func TestUserService_GetOrCreate_EmptyID(t *testing.T) {
+	svc := NewUserService(new(mocks.UserRepository), new(mocks.RedisCache))
+	_, err := svc.GetOrCreate(context.Background(), "", "user@ex.com", false)
+	require.Error(t, err)
+}
+
+func TestUserService_GetByID_NotFoundOnlyForMissingRow(t *testing.T) {
+	repo := new(mocks.UserRepository)
+	repo.On("GetByID", mock.Anything, "gone").Return(nil, fmt.Errorf("get by id: %w", pgx.ErrNoRows))
+	repo.On("GetByID", mock.Anything, "user-1").Return(nil, errors.New("connection refused"))
+	svc := NewUserService(repo, new(mocks.RedisCache))
+
+	_, err := svc.GetByID(context.Background(), "gone")
+	assert.Contains(t, err.Error(), "user not found")
+	_, err = svc.GetByID(context.Background(), "user-1")
+	assert.NotContains(t, err.Error(), "user not found")
+}
@@ -0,0 +1,11 @@
+-- Полнотекстовый поиск по названию и автору. Каталог двуязычный,
+-- поэтому индексируем и русскую, и английскую морфологию.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(author, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(author, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_books_search ON books USING GIN (search_vector);
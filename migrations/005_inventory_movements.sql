@@ -0,0 +1,21 @@
+-- Журнал движений по складу: books.inventory = SUM(delta)
+CREATE TABLE IF NOT EXISTS inventory_movements (
+    id SERIAL PRIMARY KEY,
+    book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    delta INT NOT NULL CHECK (delta <> 0),
+    kind TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    order_id INT REFERENCES orders(id) ON DELETE SET NULL,
+    balance_after INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_book ON inventory_movements (book_id, id);
+
+-- Остатки, появившиеся до журнала, записываем одним начальным движением
+INSERT INTO inventory_movements (book_id, delta, kind, reason, balance_after)
+SELECT b.id, b.inventory, 'initial', 'opening balance', b.inventory
+FROM books b
+WHERE b.inventory > 0
+  AND NOT EXISTS (SELECT 1 FROM inventory_movements m WHERE m.book_id = b.id);
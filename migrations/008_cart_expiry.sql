@@ -0,0 +1,5 @@
+-- Резерв позиции корзины истекает; просроченные позиции удаляет sweeper
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NOT NULL DEFAULT NOW() + INTERVAL '30 minutes';
+
+CREATE INDEX IF NOT EXISTS idx_cart_items_expires_at ON cart_items (expires_at);
+CREATE INDEX IF NOT EXISTS idx_cart_items_book ON cart_items (book_id);
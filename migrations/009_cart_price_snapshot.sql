@@ -0,0 +1,8 @@
+-- Цена книги на момент добавления в корзину: по ней видно, что цена изменилась
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS price_snapshot NUMERIC(10,2);
+
+UPDATE cart_items ci SET price_snapshot = b.price
+FROM books b
+WHERE b.id = ci.book_id AND ci.price_snapshot IS NULL;
+
+ALTER TABLE cart_items ALTER COLUMN price_snapshot SET NOT NULL;
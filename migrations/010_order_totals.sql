@@ -0,0 +1,15 @@
+-- Суммы заказа, адрес доставки и контактный телефон на момент оформления
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS subtotal NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS total NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_address JSONB;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS phone TEXT NOT NULL DEFAULT '';
+
+-- Старые заказы: доставки и налога не было, итог равен сумме позиций.
+-- Миграции применяются повторно, а нулевой итог бывает и у новых заказов
+-- (промокод на 100% и бесплатная доставка), поэтому трогаем только заказы
+-- без адреса, ещё не получившие subtotal: адрес обязателен с этой миграции.
+UPDATE orders o SET subtotal = s.sum, total = s.sum
+FROM (SELECT order_id, SUM(price * quantity) AS sum FROM order_items GROUP BY order_id) s
+WHERE s.order_id = o.id AND o.shipping_address IS NULL AND o.subtotal = 0;
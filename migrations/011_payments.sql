@@ -0,0 +1,20 @@
+-- Платежи по заказам
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    provider_id TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    amount NUMERIC(12,2) NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'RUB',
+    decline_reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- У заказа не больше одного незавершённого или успешного платежа;
+-- после отказа можно платить заново. Платёж в статусе refunding тоже
+-- активен: деньги за отменённый заказ возвращаются после смены его статуса
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_active_order ON payments (order_id)
+    WHERE status IN ('pending', 'authorized', 'captured', 'refunding');
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_provider ON payments (provider_id) WHERE provider_id <> '';
+CREATE INDEX IF NOT EXISTS idx_payments_refunding ON payments (updated_at) WHERE status = 'refunding';
@@ -0,0 +1,30 @@
+-- Возвраты книг: покупатель выбирает позиции заказа, админ одобряет или отклоняет
+CREATE TABLE IF NOT EXISTS returns (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    status TEXT NOT NULL DEFAULT 'requested',
+    reason TEXT NOT NULL,
+    refund_amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+    -- решение админа: кто и с каким комментарием
+    resolved_by TEXT NOT NULL DEFAULT '',
+    resolution TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_returns_order ON returns (order_id, id);
+CREATE INDEX IF NOT EXISTS idx_returns_status ON returns (status, id);
+
+CREATE TABLE IF NOT EXISTS return_items (
+    id SERIAL PRIMARY KEY,
+    return_id INT NOT NULL REFERENCES returns(id) ON DELETE CASCADE,
+    order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    UNIQUE (return_id, order_item_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_return_items_order_item ON return_items (order_item_id);
+
+-- Частичные возвраты денег по платежу
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded NUMERIC(12,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,39 @@
+-- Промокоды: процент или фиксированная сумма, область действия, окно и лимиты
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id SERIAL PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    percent INT NOT NULL DEFAULT 0,
+    amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+    min_subtotal NUMERIC(12,2) NOT NULL DEFAULT 0,
+    -- область действия: категория или книга; обе пустые — все книги
+    category_id INT REFERENCES categories(id),
+    book_id INT REFERENCES books(id),
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    -- 0 — без ограничений
+    per_user_limit INT NOT NULL DEFAULT 0,
+    usage_limit INT NOT NULL DEFAULT 0,
+    used_count INT NOT NULL DEFAULT 0 CHECK (used_count >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Погашения: один заказ — не больше одного промокода
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id SERIAL PRIMARY KEY,
+    promo_id INT NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    order_id INT NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_user ON promo_redemptions (promo_id, user_id);
+
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS promo_code TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS promo_code TEXT NOT NULL DEFAULT '';
+-- Скидка на всю позицию: по ней считается доля скидки при возврате
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS discount NUMERIC(12,2) NOT NULL DEFAULT 0;
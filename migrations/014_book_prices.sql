@@ -0,0 +1,24 @@
+-- История и расписание цен: books.price — цена, действующая сейчас.
+-- Интервал [effective_from, effective_to), без effective_to — до следующей
+-- цены; из пересекающихся действует начавшаяся позже. Время в UTC.
+CREATE TABLE IF NOT EXISTS book_prices (
+    id SERIAL PRIMARY KEY,
+    book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    price NUMERIC(10,2) NOT NULL CHECK (price >= 0),
+    effective_from TIMESTAMP NOT NULL,
+    effective_to TIMESTAMP CHECK (effective_to > effective_from),
+    reason TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_prices_book ON book_prices (book_id, effective_from, id);
+-- Для поиска ближайшей смены цены планировщиком
+CREATE INDEX IF NOT EXISTS idx_book_prices_from ON book_prices (effective_from);
+CREATE INDEX IF NOT EXISTS idx_book_prices_to ON book_prices (effective_to) WHERE effective_to IS NOT NULL;
+
+-- Цены, появившиеся до истории, записываем одной строкой от создания книги
+INSERT INTO book_prices (book_id, price, effective_from, reason)
+SELECT b.id, b.price, b.created_at, 'opening price'
+FROM books b
+WHERE NOT EXISTS (SELECT 1 FROM book_prices p WHERE p.book_id = b.id);
@@ -0,0 +1,25 @@
+-- Ставки НДС по стране и региону доставки и категории книг. Строки не
+-- меняются: новая ставка — новая строка с более поздним effective_from.
+-- Пустой region — вся страна, category_id NULL — все категории.
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id SERIAL PRIMARY KEY,
+    country TEXT NOT NULL,
+    region TEXT NOT NULL DEFAULT '',
+    category_id INT REFERENCES categories(id) ON DELETE CASCADE,
+    rate NUMERIC(5,2) NOT NULL CHECK (rate >= 0 AND rate <= 100),
+    effective_from TIMESTAMP NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_rates_country ON tax_rates (country, effective_from);
+
+-- Печатные книги: пониженная ставка в РФ и РБ, в Казахстане и Армении — общая
+INSERT INTO tax_rates (country, rate, effective_from)
+SELECT v.country, v.rate, TIMESTAMP '2019-01-01'
+FROM (VALUES ('RU', 10), ('BY', 10), ('KZ', 12), ('AM', 20)) AS v (country, rate)
+WHERE NOT EXISTS (SELECT 1 FROM tax_rates);
+
+-- НДС, включённый в сумму позиции, и ставка, по которой он посчитан
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax NUMERIC(10,2) NOT NULL DEFAULT 0;